@@ -6,7 +6,6 @@ from datetime import datetime
 from typing import Optional, Dict, Any
 
 from sqlalchemy import create_engine, text
-from sqlalchemy.ext.declarative import declarative_base
 from sqlalchemy.orm import sessionmaker
 
 # Add parent to path for imports
@@ -24,8 +23,6 @@ from models.ai_analysis import AIAnalysis
 logging.basicConfig(level=logging.INFO)
 logger = logging.getLogger(__name__)
 
-Base = declarative_base()
-
 
 class AIAnalysisService:
     def __init__(self, db_url: str = None, openai_api_key: str = None):
@@ -36,7 +33,7 @@ class AIAnalysisService:
             )
         
         self.engine = create_engine(db_url)
-        Base.metadata.create_all(self.engine)
+        # Tables are created by the shared migrations (migrations/sql)
         self.Session = sessionmaker(bind=self.engine)
         
         # Initialize OpenAI client
@@ -8,7 +8,6 @@ from datetime import datetime
 from typing import Optional, List, Dict, Any
 
 from sqlalchemy import create_engine, Column, Integer, String, Float, DateTime, Text, text
-from sqlalchemy.ext.declarative import declarative_base
 from sqlalchemy.orm import sessionmaker, Session
 
 # Add parent to path for imports
@@ -18,8 +17,6 @@ from models.detected_change import DetectedChange
 logging.basicConfig(level=logging.INFO)
 logger = logging.getLogger(__name__)
 
-Base = declarative_base()
-
 
 class Snapshot:
     """Represents a snapshot from the scraped data"""
@@ -55,7 +52,7 @@ class IntelligentChangeDetectionService:
             )
         
         self.engine = create_engine(db_url)
-        Base.metadata.create_all(self.engine)
+        # Tables are created by the shared migrations (migrations/sql)
         self.Session = sessionmaker(bind=self.engine)
     
     def parse_price(self, price_str: str) -> Optional[float]:
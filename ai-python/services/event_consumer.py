@@ -0,0 +1,118 @@
+import os
+import sys
+import socket
+import logging
+import argparse
+from datetime import datetime, timezone
+
+import redis
+
+sys.path.insert(0, os.path.dirname(os.path.dirname(os.path.abspath(__file__))))
+from services.change_detection_service import IntelligentChangeDetectionService
+
+logging.basicConfig(level=logging.INFO)
+logger = logging.getLogger(__name__)
+
+# Streams shared with scraper-go and api-go (contracts in domain/queue)
+SCHEMA_VERSION = 1
+SNAPSHOT_EVENTS_STREAM = "snapshot_events"
+CHANGE_EVENTS_STREAM = "change_events"
+EVENT_SNAPSHOT_CREATED = "snapshot.created"
+EVENT_CHANGE_DETECTED = "change.detected"
+
+CONSUMER_GROUP = "detector"
+STREAM_MAX_LEN = 100000
+
+
+class SnapshotEventConsumer:
+    """Runs change detection when the scraper announces a new snapshot,
+    and announces detected changes to the alerting side."""
+
+    def __init__(self, redis_url: str = None, detector: IntelligentChangeDetectionService = None):
+        self.redis = redis.Redis.from_url(
+            redis_url or os.getenv("REDIS_URL", "redis://localhost:6379"),
+            decode_responses=True,
+        )
+        self.detector = detector or IntelligentChangeDetectionService()
+        self.consumer = socket.gethostname()
+        self._ensure_group()
+
+    def _ensure_group(self):
+        try:
+            self.redis.xgroup_create(SNAPSHOT_EVENTS_STREAM, CONSUMER_GROUP, id="0", mkstream=True)
+        except redis.exceptions.ResponseError as e:
+            if "BUSYGROUP" not in str(e):
+                raise
+
+    def handle(self, fields: dict):
+        """Process one snapshot event, returns True when it can be acknowledged"""
+        if fields.get("type") != EVENT_SNAPSHOT_CREATED:
+            return True
+        if int(fields.get("schema_version") or 1) > SCHEMA_VERSION:
+            logger.warning(f"Skipping event with unsupported schema version {fields.get('schema_version')}")
+            return True
+
+        page_id = int(fields.get("page_id", 0))
+        if not page_id:
+            return True
+
+        change = self.detector.detect_changes(page_id)
+        if change:
+            self.redis.xadd(
+                CHANGE_EVENTS_STREAM,
+                {
+                    "schema_version": SCHEMA_VERSION,
+                    "type": EVENT_CHANGE_DETECTED,
+                    "occurred_at": datetime.now(timezone.utc).isoformat().replace("+00:00", "Z"),
+                    "change_id": change.id,
+                    "page_id": page_id,
+                },
+                maxlen=STREAM_MAX_LEN,
+                approximate=True,
+            )
+        return True
+
+    def consume(self, start: str = ">", block_ms: int = 5000) -> int:
+        """Read one batch. start=">" for new events, "0" for our pending ones."""
+        response = self.redis.xreadgroup(
+            CONSUMER_GROUP,
+            self.consumer,
+            {SNAPSHOT_EVENTS_STREAM: start},
+            count=50,
+            block=block_ms if start == ">" else None,
+        )
+        handled = 0
+        for _stream, messages in response or []:
+            for message_id, fields in messages:
+                try:
+                    if self.handle(fields):
+                        self.redis.xack(SNAPSHOT_EVENTS_STREAM, CONSUMER_GROUP, message_id)
+                        handled += 1
+                except Exception as e:
+                    # Left pending, retried on the next catch-up
+                    logger.error(f"Error handling snapshot event {message_id}: {e}")
+        return handled
+
+    def catch_up(self, full_scan: bool = False):
+        """Reprocess events delivered before a crash. Events published while we
+        were down are still in the stream and come with the next read.
+        full_scan also re-runs detection on every page (e.g. after Redis data loss)."""
+        self.consume(start="0")
+        if full_scan:
+            self.detector.run_detection_for_all_pages()
+
+    def run(self):
+        logger.info(f"📡 Consuming {SNAPSHOT_EVENTS_STREAM} as {self.consumer}")
+        while True:
+            self.consume()
+
+
+if __name__ == "__main__":
+    parser = argparse.ArgumentParser(description="Event-driven change detection")
+    parser.add_argument("--catch-up", action="store_true",
+                        help="re-run detection on every page before consuming events")
+    args = parser.parse_args()
+
+    consumer = SnapshotEventConsumer()
+    consumer.catch_up(full_scan=args.catch_up)
+    consumer.run()
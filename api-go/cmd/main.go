@@ -4,29 +4,34 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"net/http"
-	"strconv"
+	"os"
+	_ "time/tzdata" // user timezones, the runtime image has no zoneinfo
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	"github.com/rivalprice/migrations"
+
 	"github.com/rivalprice/api-go/config"
 	"github.com/rivalprice/api-go/middleware"
-	"github.com/rivalprice/api-go/models"
 	"github.com/rivalprice/api-go/routes"
 	"github.com/rivalprice/api-go/services"
 	"github.com/rivalprice/api-go/workers"
 )
 
 var (
-	db            *gorm.DB
-	redisClient   *redis.Client
-	scrapingSvc   *services.ScrapingService
-	schedulerSvc  *services.SchedulerService
-	alertWorker   *workers.AlertWorker
-	appConfig     *config.Config
+	db             *gorm.DB
+	redisClient    *redis.Client
+	schedulerSvc   *services.SchedulerService
+	alertWorker    *workers.AlertWorker
+	digestWorker   *workers.DigestWorker
+	deliveryWorker *workers.DeliveryWorker
+	keyWorker      *workers.KeyRotationWorker
+	auditWorker    *workers.AuditRetentionWorker
+	signingKeySvc  *services.SigningKeyService
+	appConfig      *config.Config
 )
 
 func initDB(cfg *config.Config) {
@@ -37,20 +42,18 @@ func initDB(cfg *config.Config) {
 	}
 	log.Println("✅ PostgreSQL connected")
 
-	// AutoMigrate - create tables automatically
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Project{},
-		&models.Competitor{},
-		&models.MonitoredPage{},
-		&models.Snapshot{},
-		&models.AlertLog{},
-		&models.UserNotificationSettings{},
-	)
-	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+	// The schema is managed by versioned migrations (`api-go migrate up`),
+	// never by the services themselves
+	if err := checkSchema(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	// Projects created before organizations existed go to their creator's
+	// personal organization
+	if err := services.NewOrganizationService(db).EnsurePersonalOrganizations(); err != nil {
+		log.Fatalf("Failed to assign projects to organizations: %v", err)
 	}
-	log.Println("✅ Database migrated successfully")
+	log.Println("✅ Database schema up to date")
 }
 
 func initRedis(cfg *config.Config) {
@@ -59,28 +62,34 @@ func initRedis(cfg *config.Config) {
 		Password: cfg.RedisPass,
 		DB:       cfg.RedisDB,
 	})
-	
+
 	// Test connection
 	_, err := redisClient.Ping(context.Background()).Result()
 	if err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
-	
+
 	log.Println("✅ Redis connected")
 }
 
 func initServices() {
-	scrapingSvc = services.NewScrapingService(db, redisClient)
 	schedulerSvc = services.NewSchedulerService(db, redisClient)
+	signingKeySvc = services.NewSigningKeyService(db, appConfig.JWTSecret)
 }
 
 func main() {
 	// Load configuration
 	appConfig = config.Load()
+
+	// `api-go migrate <command>` manages the database schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(appConfig, os.Args[2:])
+		return
+	}
 	if err := appConfig.Validate(); err != nil {
 		log.Fatalf("Configuration error: %v", err)
 	}
-	
+
 	log.Printf("🚀 Starting RivalPrice API in %s mode", appConfig.Environment)
 
 	// Initialize connections
@@ -88,6 +97,11 @@ func main() {
 	initRedis(appConfig)
 	initServices()
 
+	// Access token signing keys (created on first start)
+	if err := signingKeySvc.Init(); err != nil {
+		log.Fatalf("Failed to initialize signing keys: %v", err)
+	}
+
 	// Initialize scheduler for existing pages
 	if err := schedulerSvc.InitializeScheduledPages(); err != nil {
 		log.Printf("⚠️  Scheduler: failed to initialize pages: %v", err)
@@ -96,110 +110,85 @@ func main() {
 	// Start scheduler in background
 	go schedulerSvc.Start()
 
-	// Start alert worker in background (polls every 30s)
-	alertWorker = workers.NewAlertWorker(db)
+	// Start alert worker in background (consumes change and snapshot events)
+	alertWorker = workers.NewAlertWorker(db, redisClient)
 	go alertWorker.Start()
 
+	// Start digest worker in background (daily / weekly alert summaries)
+	digestWorker = workers.NewDigestWorker(db)
+	go digestWorker.Start()
+
+	// Start delivery worker in background (notifications held by quiet hours)
+	deliveryWorker = workers.NewDeliveryWorker(db)
+	go deliveryWorker.Start()
+
+	// Start key rotation worker in background (signing key rotation schedule)
+	keyWorker = workers.NewKeyRotationWorker(signingKeySvc)
+	go keyWorker.Start()
+
+	// Start audit retention worker in background (AUDIT_RETENTION_DAYS)
+	auditWorker = workers.NewAuditRetentionWorker(services.NewAuditService(db))
+	go auditWorker.Start()
+
 	// Setup Gin
 	if appConfig.IsProduction() {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	
-	r := gin.Default()
 
-	// Apply global middleware
-	r.Use(middleware.StandardRateLimit())       // Rate limiting: 100 req/min
-	r.Use(middleware.JWTAuthMiddleware(appConfig.JWTSecret)) // JWT authentication
+	r := gin.Default()
 
-	// Health check endpoint (public)
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "ok",
-			"env":    appConfig.Environment,
-		})
-	})
+	// Every route declares its access (public, authenticated, scoped or
+	// session): JWT or API key authentication runs first, then rate limiting
+	// (100 req/min per API key, user or IP, shared through Redis)
+	sessionSvc := services.NewSessionService(db, redisClient, middleware.NewAccessTokenSigner(signingKeySvc))
+	auth := middleware.NewAuthenticator(signingKeySvc, services.NewAPIKeyService(db), sessionSvc)
+	limiter := middleware.NewRateLimiter(redisClient)
+	api := routes.NewRouter(r, auth, limiter.Limit(middleware.PolicyStandard))
 
-	// Database status endpoint (public)
-	r.GET("/db/status", func(c *gin.Context) {
-		sqlDB, err := db.DB()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if err := sqlDB.Ping(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"status": "disconnected", "database": "error"})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{"status": "connected", "database": "postgres"})
-	})
-
-	// Redis status endpoint (public)
-	r.GET("/redis/status", func(c *gin.Context) {
-		_, err := redisClient.Ping(context.Background()).Result()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"status": "disconnected", "redis": "error"})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{"status": "connected", "redis": "ok"})
-	})
+	// Health, database, Redis and migration status (public)
+	routes.SetupSystemRoutes(api, db, redisClient, appConfig.Environment)
 
-	// Migration status endpoint (public)
-	r.GET("/migrate", func(c *gin.Context) {
-		tables := []string{"users", "projects", "competitors", "monitored_pages", "snapshots", "alert_logs", "user_notification_settings"}
-		var existingTables []string
-		
-		for _, table := range tables {
-			if db.Migrator().HasTable(table) {
-				existingTables = append(existingTables, table)
-			}
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "migrated",
-			"tables":  existingTables,
-			"count":   len(existingTables),
-		})
-	})
+	// Setup API routes
+	routes.SetupRoutes(api, db, redisClient, appConfig.JWTSecret, signingKeySvc)
 
-	// Scrape endpoints with stricter rate limiting
-	scrapeGroup := r.Group("/scrape")
-	scrapeGroup.Use(middleware.StrictRateLimit()) // 10 req/min for scraping
-	{
-		scrapeGroup.POST("/page/:id", func(c *gin.Context) {
-			id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page ID"})
-				return
-			}
-
-			if err := scrapingSvc.QueueScrapeJob(uint(id)); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{"message": "Scrape job queued", "page_id": id})
-		})
-
-		scrapeGroup.POST("/project/:id", func(c *gin.Context) {
-			id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
-				return
-			}
-
-			if err := scrapingSvc.QueueScrapeJobForProject(uint(id)); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{"message": "Scrape jobs queued for project", "project_id": id})
-		})
+	// Refuse to start with a route that bypassed its access declaration
+	if err := api.Verify(r); err != nil {
+		log.Fatalf("❌ %v", err)
 	}
 
-	// Setup API routes
-	routes.SetupRoutes(r, db, appConfig.JWTSecret)
-
 	fmt.Printf("🚀 Server starting on port %s\n", appConfig.Port)
 	if err := r.Run(":" + appConfig.Port); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// checkSchema refuses to start on a database behind the migrations this
+// binary was built with
+func checkSchema() error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	migrator, err := migrations.New(sqlDB)
+	if err != nil {
+		return err
+	}
+	return migrator.Check(context.Background())
+}
+
+// runMigrate runs the migrate subcommand
+func runMigrate(cfg *config.Config, args []string) {
+	gormDB, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer sqlDB.Close()
+
+	if err := migrations.Run(context.Background(), sqlDB, args, os.Stdout); err != nil {
+		log.Fatalf("❌ migrate: %v", err)
+	}
+}
@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/services"
+	"github.com/rivalprice/api-go/utils"
+)
+
+type AlertController struct {
+	alertLogService *services.AlertLogService
+}
+
+func NewAlertController(alertLogService *services.AlertLogService) *AlertController {
+	return &AlertController{alertLogService: alertLogService}
+}
+
+type TransitionAlertRequest struct {
+	State        string     `json:"state" binding:"required"` // new, acknowledged, snoozed, resolved, dismissed
+	SnoozedUntil *time.Time `json:"snoozed_until"`            // required when state is snoozed
+}
+
+type AssignAlertRequest struct {
+	AssigneeID *uint `json:"assignee_id"` // null to unassign
+}
+
+type AddCommentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// ListAlerts - GET /alerts
+func (c *AlertController) ListAlerts(ctx *gin.Context) {
+	viewer, exists := requestViewer(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	pagination := utils.GetPaginationParams(ctx)
+
+	filter := services.AlertFilter{
+		State:     models.AlertState(ctx.Query("state")),
+		Severity:  models.AlertSeverity(ctx.Query("severity")),
+		VisibleTo: viewer,
+	}
+	if filter.State != "" && !services.IsValidAlertState(filter.State) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state"})
+		return
+	}
+	if raw := ctx.Query("page_id"); raw != "" {
+		pid, err := strconv.Atoi(raw)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page_id"})
+			return
+		}
+		filter.PageID = pid
+	}
+	if raw := ctx.Query("assignee_id"); raw != "" {
+		aid, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignee_id"})
+			return
+		}
+		filter.AssigneeID = uint(aid)
+	}
+
+	alerts, total, err := c.alertLogService.ListAlertsPaginated(filter, pagination.Offset, pagination.PageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pagination.PageSize)))
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"alerts": alerts,
+		"pagination": gin.H{
+			"current_page": pagination.Page,
+			"page_size":    pagination.PageSize,
+			"total_pages":  totalPages,
+			"total_count":  total,
+			"has_next":     pagination.Page < totalPages,
+			"has_previous": pagination.Page > 1,
+		},
+	})
+}
+
+// GetAlert - GET /alerts/:id
+func (c *AlertController) GetAlert(ctx *gin.Context) {
+	id, ok := parseAlertID(ctx)
+	if !ok {
+		return
+	}
+
+	alert, err := c.alertLogService.GetAlertByID(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	folded, err := c.alertLogService.GetFoldedChanges(id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch folded changes"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"alert":          alert,
+		"folded_changes": folded,
+	})
+}
+
+// TransitionAlert - POST /alerts/:id/state
+func (c *AlertController) TransitionAlert(ctx *gin.Context) {
+	id, ok := parseAlertID(ctx)
+	if !ok {
+		return
+	}
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req TransitionAlertRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	alert, err := c.alertLogService.TransitionAlert(auditActor(ctx), id, models.AlertState(req.State), req.SnoozedUntil)
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Alert state updated",
+		"alert":   alert,
+	})
+}
+
+// AssignAlert - PUT /alerts/:id/assignee
+func (c *AlertController) AssignAlert(ctx *gin.Context) {
+	id, ok := parseAlertID(ctx)
+	if !ok {
+		return
+	}
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req AssignAlertRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	alert, err := c.alertLogService.AssignAlert(auditActor(ctx), id, req.AssigneeID)
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Alert assignee updated",
+		"alert":   alert,
+	})
+}
+
+// ListComments - GET /alerts/:id/comments
+func (c *AlertController) ListComments(ctx *gin.Context) {
+	id, ok := parseAlertID(ctx)
+	if !ok {
+		return
+	}
+
+	comments, err := c.alertLogService.GetComments(id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"comments": comments})
+}
+
+// AddComment - POST /alerts/:id/comments
+func (c *AlertController) AddComment(ctx *gin.Context) {
+	id, ok := parseAlertID(ctx)
+	if !ok {
+		return
+	}
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req AddCommentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := c.alertLogService.AddComment(id, userID, req.Body)
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message": "Comment added",
+		"comment": comment,
+	})
+}
+
+// ListActivity - GET /alerts/:id/activity
+func (c *AlertController) ListActivity(ctx *gin.Context) {
+	id, ok := parseAlertID(ctx)
+	if !ok {
+		return
+	}
+
+	activity, err := c.alertLogService.GetActivity(id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"activity": activity})
+}
+
+func parseAlertID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// alertErrorStatus maps service errors to 404 for missing alerts, 400 otherwise
+func alertErrorStatus(err error) int {
+	if errors.Is(err, services.ErrNotOrgMember) {
+		return http.StatusUnprocessableEntity
+	}
+	if strings.HasSuffix(err.Error(), "not found") {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
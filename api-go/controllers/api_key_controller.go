@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/services"
+)
+
+type APIKeyController struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyController(apiKeyService *services.APIKeyService) *APIKeyController {
+	return &APIKeyController{apiKeyService: apiKeyService}
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Kind      string     `json:"kind"`       // personal (default), service
+	Scopes    []string   `json:"scopes"`     // every scope when empty (personal keys only)
+	ExpiresAt *time.Time `json:"expires_at"` // required for service tokens
+}
+
+// CreateAPIKey - POST /api_keys (the key is only returned once)
+func (c *APIKeyController) CreateAPIKey(ctx *gin.Context) {
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	apiKey, plaintext, err := c.apiKeyService.CreateKey(auditActor(ctx), req.Name, req.Kind, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message": "API key created, store it now: it will not be shown again",
+		"api_key": apiKey,
+		"key":     plaintext,
+	})
+}
+
+// ListAPIKeys - GET /api_keys
+func (c *APIKeyController) ListAPIKeys(ctx *gin.Context) {
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	keys, err := c.apiKeyService.ListKeys(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// RevokeAPIKey - DELETE /api_keys/:id
+func (c *APIKeyController) RevokeAPIKey(ctx *gin.Context) {
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	apiKey, err := c.apiKeyService.RevokeKey(auditActor(ctx), uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked",
+		"api_key": apiKey,
+	})
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/services"
+	"github.com/rivalprice/api-go/utils"
+)
+
+type AuditController struct {
+	auditService *services.AuditService
+}
+
+func NewAuditController(auditService *services.AuditService) *AuditController {
+	return &AuditController{auditService: auditService}
+}
+
+// ListEvents - GET /audit?organization_id= (filters: actor_user_id, action, target_type, target_id, from, to)
+func (c *AuditController) ListEvents(ctx *gin.Context) {
+	filter, ok := parseAuditFilter(ctx)
+	if !ok {
+		return
+	}
+	c.list(ctx, filter)
+}
+
+// ListAccountEvents - GET /audit/account (the current user's account actions: API keys, 2FA, settings)
+func (c *AuditController) ListAccountEvents(ctx *gin.Context) {
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	filter, ok := parseAuditFilter(ctx)
+	if !ok {
+		return
+	}
+	filter.OrganizationID = 0
+	filter.ActorUserID = userID
+	c.list(ctx, filter)
+}
+
+// ExportEvents - GET /audit/export?organization_id= (same filters, CSV)
+func (c *AuditController) ExportEvents(ctx *gin.Context) {
+	filter, ok := parseAuditFilter(ctx)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%d-%s.csv", filter.OrganizationID, time.Now().UTC().Format("20060102"))
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	ctx.Status(http.StatusOK)
+	if err := c.auditService.ExportCSV(filter, ctx.Writer); err != nil {
+		// Headers are gone already: cut the download short
+		ctx.Error(err)
+		ctx.Abort()
+	}
+}
+
+func (c *AuditController) list(ctx *gin.Context, filter services.AuditFilter) {
+	pagination := utils.GetPaginationParams(ctx)
+	events, total, err := c.auditService.ListEventsPaginated(filter, pagination.Offset, pagination.PageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit events"})
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pagination.PageSize)))
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"pagination": gin.H{
+			"current_page": pagination.Page,
+			"page_size":    pagination.PageSize,
+			"total_pages":  totalPages,
+			"total_count":  total,
+			"has_next":     pagination.Page < totalPages,
+			"has_previous": pagination.Page > 1,
+		},
+	})
+}
+
+// parseAuditFilter reads the audit filters of the query string; the
+// organization comes from the role middleware
+func parseAuditFilter(ctx *gin.Context) (services.AuditFilter, bool) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	filter := services.AuditFilter{
+		OrganizationID: orgID,
+		Action:         ctx.Query("action"),
+		TargetType:     ctx.Query("target_type"),
+	}
+
+	for param, dest := range map[string]*uint{"actor_user_id": &filter.ActorUserID, "target_id": &filter.TargetID} {
+		if raw := ctx.Query(param); raw != "" {
+			id, err := strconv.ParseUint(raw, 10, 32)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return filter, false
+			}
+			*dest = uint(id)
+		}
+	}
+	for param, dest := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if raw := ctx.Query(param); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", expected RFC 3339"})
+				return filter, false
+			}
+			*dest = &t
+		}
+	}
+	return filter, true
+}
+
+// auditActor is the caller of a request, as recorded in the audit log
+func auditActor(ctx *gin.Context) services.Actor {
+	actor := services.Actor{IP: ctx.ClientIP(), UserAgent: ctx.Request.UserAgent()}
+	actor.UserID, _ = middleware.GetUserID(ctx)
+	actor.MFA = middleware.MFAVerified(ctx)
+	if key, ok := middleware.GetAPIKey(ctx); ok {
+		actor.APIKeyID = key.ID
+	}
+	return actor
+}
+
+// requestViewer is who the lists of a request are filtered for, false when
+// the request is not authenticated
+func requestViewer(ctx *gin.Context) (services.Viewer, bool) {
+	userID, ok := middleware.GetUserID(ctx)
+	return services.Viewer{UserID: userID, MFA: middleware.MFAVerified(ctx)}, ok
+}
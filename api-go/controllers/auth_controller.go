@@ -1,22 +1,31 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/models"
 	"github.com/rivalprice/api-go/services"
 )
 
 type AuthController struct {
-	userService *services.UserService
-	jwtSecret   string
+	userService    *services.UserService
+	sessionService *services.SessionService
+	ssoService     *services.SSOService
+	accountService *services.AccountService
+	mfaService     *services.MFAService
 }
 
-func NewAuthController(userService *services.UserService, jwtSecret string) *AuthController {
+func NewAuthController(userService *services.UserService, sessionService *services.SessionService, ssoService *services.SSOService, accountService *services.AccountService, mfaService *services.MFAService) *AuthController {
 	return &AuthController{
-		userService: userService,
-		jwtSecret:   jwtSecret,
+		userService:    userService,
+		sessionService: sessionService,
+		ssoService:     ssoService,
+		accountService: accountService,
+		mfaService:     mfaService,
 	}
 }
 
@@ -31,6 +40,24 @@ type RegisterRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
 // Login - POST /auth/login
 func (c *AuthController) Login(ctx *gin.Context) {
 	var req LoginRequest
@@ -39,15 +66,70 @@ func (c *AuthController) Login(ctx *gin.Context) {
 		return
 	}
 
-	// TODO: Implement password verification (currently not stored)
 	user, err := c.userService.GetUserByEmail(req.Email)
+	if err != nil || !c.userService.ValidatePassword(user, req.Password) {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	c.completeLogin(ctx, user)
+}
+
+// VerifyMFA - POST /auth/mfa/verify (second step of a login with 2FA)
+func (c *AuthController) VerifyMFA(ctx *gin.Context) {
+	var req VerifyMFARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := c.mfaService.CompleteChallenge(req.MFAToken, req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := c.userService.GetUserByID(userID)
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate JWT token (valid for 24 hours)
-	token, err := middleware.GenerateToken(user.ID, user.Email, c.jwtSecret, 24)
+	c.startSession(ctx, user, true)
+}
+
+// completeLogin answers a successful first factor (password or SSO): an MFA
+// challenge when the user enabled 2FA, a new session otherwise
+func (c *AuthController) completeLogin(ctx *gin.Context, user *models.User) {
+	enabled, err := c.mfaService.IsEnabled(user.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor authentication"})
+		return
+	}
+	if !enabled {
+		c.startSession(ctx, user, false)
+		return
+	}
+
+	mfaToken, err := c.mfaService.StartChallenge(user.ID)
+	if err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Two-factor login unavailable"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":      "Two-factor code required",
+		"mfa_required": true,
+		"mfa_token":    mfaToken,
+		"expires_in":   int(services.MFAChallengeTTL.Seconds()),
+	})
+}
+
+// startSession opens a session (short-lived access token + rotating refresh
+// token) and returns it. mfa marks sessions opened with a second factor
+// (amr claim), which organizations requiring 2FA check.
+func (c *AuthController) startSession(ctx *gin.Context, user *models.User, mfa bool) {
+	session, err := c.sessionService.StartSession(user, mfa, ctx.Request.UserAgent(), ctx.ClientIP())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -55,7 +137,8 @@ func (c *AuthController) Login(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "Login successful",
-		"token":   token,
+		"token":   session.AccessToken,
+		"session": session,
 		"user": gin.H{
 			"id":    user.ID,
 			"email": user.Email,
@@ -79,25 +162,31 @@ func (c *AuthController) Register(ctx *gin.Context) {
 	}
 
 	// Create user
-	user, err := c.userService.CreateUser(req.Name, req.Email)
+	user, err := c.userService.CreateUser(req.Email, req.Password)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateToken(user.ID, user.Email, c.jwtSecret, 24)
+	// The account works right away, but monitored pages need a verified email
+	verificationSent := c.accountService.SendVerification(user) == nil
+
+	// Open a session
+	session, err := c.sessionService.StartSession(user, false, ctx.Request.UserAgent(), ctx.ClientIP())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	ctx.JSON(http.StatusCreated, gin.H{
-		"message": "User registered successfully",
-		"token":   token,
+		"message":           "User registered successfully",
+		"token":             session.AccessToken,
+		"session":           session,
+		"verification_sent": verificationSent,
 		"user": gin.H{
-			"id":    user.ID,
-			"email": user.Email,
+			"id":             user.ID,
+			"email":          user.Email,
+			"email_verified": false,
 		},
 	})
 }
@@ -118,8 +207,208 @@ func (c *AuthController) Me(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"user": gin.H{
-			"id":    user.ID,
-			"email": user.Email,
+			"id":             user.ID,
+			"email":          user.Email,
+			"email_verified": user.EmailVerifiedAt != nil,
 		},
 	})
 }
+
+// Refresh - POST /auth/refresh (public, the refresh token is single-use)
+func (c *AuthController) Refresh(ctx *gin.Context) {
+	var req RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := c.sessionService.Refresh(req.RefreshToken, ctx.Request.UserAgent(), ctx.ClientIP())
+	if err != nil {
+		// Includes reuse of a consumed token, which revokes the whole session
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"token":   session.AccessToken,
+		"session": session,
+	})
+}
+
+// Logout - POST /auth/logout (revokes the current session)
+func (c *AuthController) Logout(ctx *gin.Context) {
+	claims, exists := middleware.GetClaims(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := c.sessionService.Logout(claims.UserID, claims.SessionID, claims.ID, claims.ExpiresAt.Time); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll - POST /auth/logout_all (revokes every session of the current user)
+func (c *AuthController) LogoutAll(ctx *gin.Context) {
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := c.sessionService.LogoutAll(userID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "All sessions logged out"})
+}
+
+// OIDCLogin - GET /auth/oidc/:slug/login (redirects to the identity provider)
+func (c *AuthController) OIDCLogin(ctx *gin.Context) {
+	authURL, err := c.ssoService.BeginLogin(ctx.Request.Context(), ctx.Param("slug"))
+	if err != nil {
+		if err.Error() == "SSO provider not found" {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable: " + err.Error()})
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback - GET /auth/oidc/:slug/callback (redirect target of the identity provider)
+func (c *AuthController) OIDCCallback(ctx *gin.Context) {
+	if idpErr := ctx.Query("error"); idpErr != "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"error":       "Login refused by identity provider",
+			"idp_error":   idpErr,
+			"description": ctx.Query("error_description"),
+		})
+		return
+	}
+
+	user, err := c.ssoService.CompleteLogin(ctx.Request.Context(), ctx.Param("slug"), ctx.Query("code"), ctx.Query("state"))
+	var linkErr *services.LinkRequiredError
+	if errors.As(err, &linkErr) {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":      err.Error(),
+			"link_token": linkErr.Token,
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.completeLogin(ctx, user)
+}
+
+// ConfirmOIDCLink - POST /auth/oidc/links/:token/confirm
+// The owner of an existing account accepts an SSO login with its email
+func (c *AuthController) ConfirmOIDCLink(ctx *gin.Context) {
+	identity, err := c.ssoService.ConfirmLink(ctx.Request.Context(), auditActor(ctx), ctx.Param("token"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.HasSuffix(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "SSO identity linked to your account",
+		"identity": identity,
+	})
+}
+
+// VerifyEmail - GET /auth/verify_email/:token (link sent by email)
+func (c *AuthController) VerifyEmail(ctx *gin.Context) {
+	user, err := c.accountService.VerifyEmail(ctx.Param("token"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Email verified",
+		"email":   user.Email,
+	})
+}
+
+// ResendVerification - POST /auth/resend_verification
+func (c *AuthController) ResendVerification(ctx *gin.Context) {
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := c.userService.GetUserByID(userID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := c.accountService.SendVerification(user); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrAccountRateLimited) {
+			status = http.StatusTooManyRequests
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// ForgotPassword - POST /auth/forgot_password (same answer whether the account exists or not)
+func (c *AuthController) ForgotPassword(ctx *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.accountService.RequestPasswordReset(req.Email); err != nil {
+		if errors.Is(err, services.ErrAccountRateLimited) {
+			ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message": "If an account exists for this email, a password reset link has been sent",
+	})
+}
+
+// ResetPassword - POST /auth/reset_password (logs out every session of the user)
+func (c *AuthController) ResetPassword(ctx *gin.Context) {
+	var req ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := c.accountService.ResetPassword(auditActor(ctx), req.Token, req.Password)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.sessionService.LogoutAll(userID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Password changed, but existing sessions could not be logged out"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Password changed, please log in again"})
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/services"
+)
+
+type BillingController struct {
+	billingService *services.BillingService
+	userService    *services.UserService
+}
+
+func NewBillingController(billingService *services.BillingService, userService *services.UserService) *BillingController {
+	return &BillingController{billingService: billingService, userService: userService}
+}
+
+type BillingCheckoutRequest struct {
+	Plan string `json:"plan" binding:"required"` // pro or business
+}
+
+// Checkout - POST /organizations/:id/billing/checkout (returns the provider's checkout URL)
+func (c *BillingController) Checkout(ctx *gin.Context) {
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	orgID, _ := middleware.GetOrgID(ctx)
+
+	var req BillingCheckoutRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := c.userService.GetUserByID(userID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	url, err := c.billingService.Checkout(ctx.Request.Context(), orgID, req.Plan, user.Email)
+	if err != nil {
+		ctx.JSON(billingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+// Portal - POST /organizations/:id/billing/portal (returns the provider's customer portal URL)
+func (c *BillingController) Portal(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	url, err := c.billingService.Portal(ctx.Request.Context(), orgID)
+	if err != nil {
+		ctx.JSON(billingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+// Webhook - POST /billing/webhook (subscription events from the payment provider)
+func (c *BillingController) Webhook(ctx *gin.Context) {
+	payload, err := ctx.GetRawData()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+
+	if err := c.billingService.HandleWebhook(payload, ctx.Request.Header); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidBillingSignature):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrBillingDisabled):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			// The provider retries failed deliveries
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process event"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+// billingErrorStatus is 503 when billing is disabled, else 400
+func billingErrorStatus(err error) int {
+	if errors.Is(err, services.ErrBillingDisabled) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
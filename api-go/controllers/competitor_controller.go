@@ -33,9 +33,9 @@ func (c *CompetitorController) CreateCompetitor(ctx *gin.Context) {
 		return
 	}
 
-	competitor, err := c.competitorService.CreateCompetitor(req.ProjectID, req.Name, req.URL)
+	competitor, err := c.competitorService.CreateCompetitor(auditActor(ctx), req.ProjectID, req.Name, req.URL)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.JSON(planErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -47,27 +47,33 @@ func (c *CompetitorController) CreateCompetitor(ctx *gin.Context) {
 
 // ListCompetitors - GET /competitors
 func (c *CompetitorController) ListCompetitors(ctx *gin.Context) {
+	viewer, exists := requestViewer(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
 	// Get pagination params
 	pagination := utils.GetPaginationParams(ctx)
-	
+
 	var competitors []models.Competitor
 	var total int64
 	var err error
-	
+
 	// Optional: filter by project_id
 	projectID := ctx.Query("project_id")
 	if projectID != "" {
 		pid, parseErr := strconv.ParseUint(projectID, 10, 32)
 		if parseErr == nil {
-			competitors, total, err = c.competitorService.GetCompetitorsByProjectIDPaginated(uint(pid), pagination.Offset, pagination.PageSize)
+			competitors, total, err = c.competitorService.GetCompetitorsByProjectIDPaginated(viewer, uint(pid), pagination.Offset, pagination.PageSize)
 		} else {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project_id"})
 			return
 		}
 	} else {
-		competitors, total, err = c.competitorService.GetAllCompetitorsPaginated(pagination.Offset, pagination.PageSize)
+		competitors, total, err = c.competitorService.GetAllCompetitorsPaginated(viewer, pagination.Offset, pagination.PageSize)
 	}
-	
+
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch competitors"})
 		return
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/services"
+)
+
+type JWKSController struct {
+	signingKeys *services.SigningKeyService
+}
+
+func NewJWKSController(signingKeys *services.SigningKeyService) *JWKSController {
+	return &JWKSController{signingKeys: signingKeys}
+}
+
+// JWKS - GET /.well-known/jwks.json (public keys verifying access tokens)
+func (c *JWKSController) JWKS(ctx *gin.Context) {
+	// Short enough for verifiers to see a new key before it signs
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, gin.H{"keys": c.signingKeys.JWKS()})
+}
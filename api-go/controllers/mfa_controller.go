@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/services"
+)
+
+type MFAController struct {
+	mfaService  *services.MFAService
+	userService *services.UserService
+}
+
+func NewMFAController(mfaService *services.MFAService, userService *services.UserService) *MFAController {
+	return &MFAController{mfaService: mfaService, userService: userService}
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"` // TOTP code (or recovery code, except for activate)
+}
+
+// GetStatus - GET /auth/mfa
+func (c *MFAController) GetStatus(ctx *gin.Context) {
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	status, err := c.mfaService.Status(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor status"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"mfa": status})
+}
+
+// Enroll - POST /auth/mfa/enroll (returns the secret and its otpauth:// URI for a QR code)
+func (c *MFAController) Enroll(ctx *gin.Context) {
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := c.userService.GetUserByID(userID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	secret, uri, err := c.mfaService.Enroll(user)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":          "Scan the QR code, then confirm a code with POST /auth/mfa/activate",
+		"secret":           secret,
+		"provisioning_uri": uri,
+	})
+}
+
+// Activate - POST /auth/mfa/activate (recovery codes are only returned once)
+func (c *MFAController) Activate(ctx *gin.Context) {
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := c.mfaService.Activate(auditActor(ctx), req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled, store the recovery codes now",
+		"recovery_codes": codes,
+	})
+}
+
+// Disable - POST /auth/mfa/disable
+func (c *MFAController) Disable(ctx *gin.Context) {
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.mfaService.Disable(auditActor(ctx), req.Code); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes - POST /auth/mfa/recovery_codes (invalidates the previous codes)
+func (c *MFAController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := c.mfaService.RegenerateRecoveryCodes(auditActor(ctx), req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":        "New recovery codes generated, the previous ones no longer work",
+		"recovery_codes": codes,
+	})
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/services"
+	"github.com/rivalprice/api-go/utils"
+)
+
+type MonitorAlertController struct {
+	alertService *services.AlertService
+}
+
+func NewMonitorAlertController(alertService *services.AlertService) *MonitorAlertController {
+	return &MonitorAlertController{alertService: alertService}
+}
+
+// ListMonitorAlerts - GET /monitor_alerts
+func (c *MonitorAlertController) ListMonitorAlerts(ctx *gin.Context) {
+	viewer, exists := requestViewer(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	pagination := utils.GetPaginationParams(ctx)
+
+	// Optional: filter by page_id
+	var pageID uint
+	if raw := ctx.Query("page_id"); raw != "" {
+		pid, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page_id"})
+			return
+		}
+		pageID = uint(pid)
+	}
+
+	// Optional: filter by acknowledged=true|false
+	var acknowledged *bool
+	if raw := ctx.Query("acknowledged"); raw != "" {
+		ack, err := strconv.ParseBool(raw)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid acknowledged"})
+			return
+		}
+		acknowledged = &ack
+	}
+
+	alerts, total, err := c.alertService.ListMonitorAlerts(viewer, pageID, acknowledged, pagination.Offset, pagination.PageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch monitor alerts"})
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pagination.PageSize)))
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"monitor_alerts": alerts,
+		"pagination": gin.H{
+			"current_page": pagination.Page,
+			"page_size":    pagination.PageSize,
+			"total_pages":  totalPages,
+			"total_count":  total,
+			"has_next":     pagination.Page < totalPages,
+			"has_previous": pagination.Page > 1,
+		},
+	})
+}
+
+// AcknowledgeMonitorAlert - POST /monitor_alerts/:id/acknowledge
+func (c *MonitorAlertController) AcknowledgeMonitorAlert(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid monitor alert ID"})
+		return
+	}
+
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	alert, err := c.alertService.AcknowledgeMonitorAlert(uint(id), userID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":       "Monitor alert acknowledged",
+		"monitor_alert": alert,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/models"
 	"github.com/rivalprice/api-go/services"
 )
 
@@ -17,10 +18,22 @@ func NewMonitoredPageController(monitoredPageService *services.MonitoredPageServ
 }
 
 type CreateMonitoredPageRequest struct {
-	CompetitorID uint   `json:"competitor_id" binding:"required"`
-	PageType     string `json:"page_type" binding:"required"` // pricing or features
-	URL          string `json:"url" binding:"required"`
-	CSSSelector  string `json:"css_selector"`
+	CompetitorID      uint   `json:"competitor_id" binding:"required"`
+	PageType          string `json:"page_type" binding:"required"` // pricing or features
+	URL               string `json:"url" binding:"required"`
+	CSSSelector       string `json:"css_selector"`
+	CaptureScreenshot bool   `json:"capture_screenshot"` // opt-in visual diff
+	Frequency         string `json:"frequency"`          // daily, weekly or monthly; defaults to the plan's most frequent
+}
+
+type UpdateIgnoreRulesRequest struct {
+	Selectors []string `json:"selectors"` // e.g. ".testimonials", "#promo-banner"
+	Patterns  []string `json:"patterns"`  // regexes, e.g. "Offer ends .*"
+}
+
+type UpdateAlertWindowsRequest struct {
+	CooldownMinutes    *int `json:"cooldown_minutes"`     // null for the global default
+	MergeWindowMinutes *int `json:"merge_window_minutes"` // null for the global default
 }
 
 // CreateMonitoredPage - POST /monitored_pages
@@ -31,26 +44,32 @@ func (c *MonitoredPageController) CreateMonitoredPage(ctx *gin.Context) {
 		return
 	}
 
-	monitoredPage, err := c.monitoredPageService.CreateMonitoredPage(req.CompetitorID, req.PageType, req.URL, req.CSSSelector)
+	monitoredPage, err := c.monitoredPageService.CreateMonitoredPage(auditActor(ctx), req.CompetitorID, req.PageType, req.URL, req.CSSSelector, req.CaptureScreenshot, models.Frequency(req.Frequency))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.JSON(planErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
 	ctx.JSON(http.StatusCreated, gin.H{
-		"message":       "Monitored page created successfully",
+		"message":        "Monitored page created successfully",
 		"monitored_page": monitoredPage,
 	})
 }
 
 // ListMonitoredPages - GET /monitored_pages
 func (c *MonitoredPageController) ListMonitoredPages(ctx *gin.Context) {
+	viewer, exists := requestViewer(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
 	// Optional: filter by competitor_id
 	competitorID := ctx.Query("competitor_id")
 	if competitorID != "" {
 		cid, err := strconv.ParseUint(competitorID, 10, 32)
 		if err == nil {
-			monitoredPages, err := c.monitoredPageService.GetMonitoredPagesByCompetitorID(uint(cid))
+			monitoredPages, err := c.monitoredPageService.GetMonitoredPagesByCompetitorID(viewer, uint(cid))
 			if err != nil {
 				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch monitored pages"})
 				return
@@ -60,7 +79,7 @@ func (c *MonitoredPageController) ListMonitoredPages(ctx *gin.Context) {
 		}
 	}
 
-	monitoredPages, err := c.monitoredPageService.GetAllMonitoredPages()
+	monitoredPages, err := c.monitoredPageService.GetAllMonitoredPages(viewer)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch monitored pages"})
 		return
@@ -87,3 +106,55 @@ func (c *MonitoredPageController) GetMonitoredPage(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, gin.H{"monitored_page": monitoredPage})
 }
+
+// UpdateIgnoreRules - PUT /monitored_pages/:id/ignore_rules
+func (c *MonitoredPageController) UpdateIgnoreRules(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid monitored page ID"})
+		return
+	}
+
+	var req UpdateIgnoreRulesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	monitoredPage, err := c.monitoredPageService.UpdateIgnoreRules(auditActor(ctx), uint(id), req.Selectors, req.Patterns)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":        "Ignore rules updated",
+		"monitored_page": monitoredPage,
+	})
+}
+
+// UpdateAlertWindows - PUT /monitored_pages/:id/alert_windows
+func (c *MonitoredPageController) UpdateAlertWindows(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid monitored page ID"})
+		return
+	}
+
+	var req UpdateAlertWindowsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	monitoredPage, err := c.monitoredPageService.UpdateAlertWindows(auditActor(ctx), uint(id), req.CooldownMinutes, req.MergeWindowMinutes)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":        "Alert windows updated",
+		"monitored_page": monitoredPage,
+	})
+}
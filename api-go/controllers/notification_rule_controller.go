@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/services"
+)
+
+type NotificationRuleController struct {
+	ruleService *services.NotificationRuleService
+}
+
+func NewNotificationRuleController(ruleService *services.NotificationRuleService) *NotificationRuleController {
+	return &NotificationRuleController{ruleService: ruleService}
+}
+
+type NotificationRuleRequest struct {
+	Name        string   `json:"name"`
+	ScopeType   string   `json:"scope_type" binding:"required"` // project, competitor, page
+	ScopeID     uint     `json:"scope_id" binding:"required"`
+	Priority    *int     `json:"priority"` // defaults to 100
+	Enabled     *bool    `json:"enabled"`  // defaults to true
+	ChangeTypes string   `json:"change_types"`
+	MinSeverity string   `json:"min_severity"`
+	MinPercent  *float64 `json:"min_percent"`
+	MaxPercent  *float64 `json:"max_percent"`
+	Direction   string   `json:"direction"`
+	PlanName    string   `json:"plan_name"`
+	Keyword     string   `json:"keyword"`
+	Action      string   `json:"action"` // notify (default), suppress
+	Channels    string   `json:"channels"`
+	Recipients  string   `json:"recipients"`
+	WebhookURL  string   `json:"webhook_url"`
+}
+
+func (r *NotificationRuleRequest) toModel() *models.NotificationRule {
+	rule := &models.NotificationRule{
+		Name:        r.Name,
+		ScopeType:   models.RuleScope(r.ScopeType),
+		ScopeID:     r.ScopeID,
+		Priority:    100,
+		Enabled:     true,
+		ChangeTypes: r.ChangeTypes,
+		MinSeverity: models.AlertSeverity(r.MinSeverity),
+		MinPercent:  r.MinPercent,
+		MaxPercent:  r.MaxPercent,
+		Direction:   r.Direction,
+		PlanName:    r.PlanName,
+		Keyword:     r.Keyword,
+		Action:      models.RuleAction(r.Action),
+		Channels:    r.Channels,
+		Recipients:  r.Recipients,
+		WebhookURL:  r.WebhookURL,
+	}
+	if r.Priority != nil {
+		rule.Priority = *r.Priority
+	}
+	if r.Enabled != nil {
+		rule.Enabled = *r.Enabled
+	}
+	return rule
+}
+
+// CreateRule - POST /notification_rules
+func (c *NotificationRuleController) CreateRule(ctx *gin.Context) {
+	var req NotificationRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := req.toModel()
+	if err := c.ruleService.CreateRule(auditActor(ctx), rule); err != nil {
+		ctx.JSON(planErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message": "Notification rule created successfully",
+		"rule":    rule,
+	})
+}
+
+// ListRules - GET /notification_rules
+func (c *NotificationRuleController) ListRules(ctx *gin.Context) {
+	viewer, exists := requestViewer(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var scopeID uint
+	if raw := ctx.Query("scope_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope_id"})
+			return
+		}
+		scopeID = uint(id)
+	}
+
+	rules, err := c.ruleService.ListRules(viewer, models.RuleScope(ctx.Query("scope_type")), scopeID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification rules"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// GetRule - GET /notification_rules/:id
+func (c *NotificationRuleController) GetRule(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	rule, err := c.ruleService.GetRuleByID(uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"rule": rule})
+}
+
+// UpdateRule - PUT /notification_rules/:id
+func (c *NotificationRuleController) UpdateRule(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var req NotificationRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := c.ruleService.UpdateRule(auditActor(ctx), uint(id), req.toModel())
+	if err != nil {
+		if err.Error() == "notification rule not found" {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(planErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Notification rule updated successfully",
+		"rule":    rule,
+	})
+}
+
+// DeleteRule - DELETE /notification_rules/:id
+func (c *NotificationRuleController) DeleteRule(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	if err := c.ruleService.DeleteRule(auditActor(ctx), uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Notification rule deleted successfully"})
+}
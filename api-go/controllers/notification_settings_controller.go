@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/services"
+)
+
+type NotificationSettingsController struct {
+	prefService *services.PreferenceService
+}
+
+func NewNotificationSettingsController(prefService *services.PreferenceService) *NotificationSettingsController {
+	return &NotificationSettingsController{prefService: prefService}
+}
+
+// UpdateNotificationSettingsRequest is a partial update: omitted fields are kept
+type UpdateNotificationSettingsRequest struct {
+	NotifyEmail              *bool    `json:"notify_email"`
+	NotifyWebhook            *bool    `json:"notify_webhook"`
+	WebhookURL               *string  `json:"webhook_url"`
+	MinimumChangePercent     *float64 `json:"minimum_change_percent"`
+	AlertOnPriceChange       *bool    `json:"alert_on_price_change"`
+	AlertOnFeatureChange     *bool    `json:"alert_on_feature_change"`
+	AlertOnMessaging         *bool    `json:"alert_on_messaging"`
+	AlertOnVisualChange      *bool    `json:"alert_on_visual_change"`
+	DigestFrequency          *string  `json:"digest_frequency"` // immediate, daily, weekly
+	DigestHour               *int     `json:"digest_hour"`
+	DigestWeekday            *int     `json:"digest_weekday"`
+	Timezone                 *string  `json:"timezone"`
+	QuietHoursEnabled        *bool    `json:"quiet_hours_enabled"`
+	QuietHoursStart          *string  `json:"quiet_hours_start"` // HH:MM, local time
+	QuietHoursEnd            *string  `json:"quiet_hours_end"`
+	QuietHoursBypassCritical *bool    `json:"quiet_hours_bypass_critical"`
+}
+
+func (r *UpdateNotificationSettingsRequest) updates() map[string]interface{} {
+	updates := map[string]interface{}{}
+	if r.NotifyEmail != nil {
+		updates["notify_email"] = *r.NotifyEmail
+	}
+	if r.NotifyWebhook != nil {
+		updates["notify_webhook"] = *r.NotifyWebhook
+	}
+	if r.WebhookURL != nil {
+		updates["webhook_url"] = *r.WebhookURL
+	}
+	if r.MinimumChangePercent != nil {
+		updates["minimum_change_percent"] = *r.MinimumChangePercent
+	}
+	if r.AlertOnPriceChange != nil {
+		updates["alert_on_price_change"] = *r.AlertOnPriceChange
+	}
+	if r.AlertOnFeatureChange != nil {
+		updates["alert_on_feature_change"] = *r.AlertOnFeatureChange
+	}
+	if r.AlertOnMessaging != nil {
+		updates["alert_on_messaging"] = *r.AlertOnMessaging
+	}
+	if r.AlertOnVisualChange != nil {
+		updates["alert_on_visual_change"] = *r.AlertOnVisualChange
+	}
+	if r.DigestFrequency != nil {
+		updates["digest_frequency"] = *r.DigestFrequency
+	}
+	if r.DigestHour != nil {
+		updates["digest_hour"] = *r.DigestHour
+	}
+	if r.DigestWeekday != nil {
+		updates["digest_weekday"] = *r.DigestWeekday
+	}
+	if r.Timezone != nil {
+		updates["timezone"] = *r.Timezone
+	}
+	if r.QuietHoursEnabled != nil {
+		updates["quiet_hours_enabled"] = *r.QuietHoursEnabled
+	}
+	if r.QuietHoursStart != nil {
+		updates["quiet_hours_start"] = *r.QuietHoursStart
+	}
+	if r.QuietHoursEnd != nil {
+		updates["quiet_hours_end"] = *r.QuietHoursEnd
+	}
+	if r.QuietHoursBypassCritical != nil {
+		updates["quiet_hours_bypass_critical"] = *r.QuietHoursBypassCritical
+	}
+	return updates
+}
+
+// GetSettings - GET /notification_settings
+func (c *NotificationSettingsController) GetSettings(ctx *gin.Context) {
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	settings, err := c.prefService.GetSettingsForUser(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification settings"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"settings": settings})
+}
+
+// UpdateSettings - PUT /notification_settings
+func (c *NotificationSettingsController) UpdateSettings(ctx *gin.Context) {
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req UpdateNotificationSettingsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := c.prefService.UpdateSettings(auditActor(ctx), req.updates())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "Notification settings updated successfully",
+		"settings": settings,
+	})
+}
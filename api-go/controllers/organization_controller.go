@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/services"
+)
+
+type OrganizationController struct {
+	orgService     *services.OrganizationService
+	projectService *services.ProjectService
+}
+
+func NewOrganizationController(orgService *services.OrganizationService, projectService *services.ProjectService) *OrganizationController {
+	return &OrganizationController{orgService: orgService, projectService: projectService}
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name       *string `json:"name"`
+	RequireMFA *bool   `json:"require_mfa"` // members must enable 2FA
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"` // owner, admin, editor, viewer
+}
+
+type InviteRequest struct {
+	Email string `json:"email" binding:"required"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type CreateOrganizationProjectRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// CreateOrganization - POST /organizations (the creator becomes owner)
+func (c *OrganizationController) CreateOrganization(ctx *gin.Context) {
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateOrganizationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := c.orgService.CreateOrganization(auditActor(ctx), req.Name)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message":      "Organization created successfully",
+		"organization": org,
+	})
+}
+
+// ListOrganizations - GET /organizations (organizations of the current user)
+func (c *OrganizationController) ListOrganizations(ctx *gin.Context) {
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	orgs, err := c.orgService.ListForUser(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// GetOrganization - GET /organizations/:id
+func (c *OrganizationController) GetOrganization(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	org, err := c.orgService.GetOrganizationByID(orgID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, _ := middleware.GetOrgRole(ctx)
+	ctx.JSON(http.StatusOK, gin.H{
+		"organization": org,
+		"role":         role,
+	})
+}
+
+// UpdateOrganization - PUT /organizations/:id
+func (c *OrganizationController) UpdateOrganization(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req UpdateOrganizationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := c.orgService.UpdateOrganization(auditActor(ctx), orgID, req.Name, req.RequireMFA)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":      "Organization updated successfully",
+		"organization": org,
+	})
+}
+
+// ListMembers - GET /organizations/:id/members
+func (c *OrganizationController) ListMembers(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	members, err := c.orgService.ListMembers(orgID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// UpdateMember - PUT /organizations/:id/members/:user_id
+func (c *OrganizationController) UpdateMember(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	memberID, err := strconv.ParseUint(ctx.Param("user_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorRole, _ := middleware.GetOrgRole(ctx)
+	membership, err := c.orgService.UpdateMemberRole(auditActor(ctx), orgID, uint(memberID), models.OrgRole(req.Role), actorRole)
+	if err != nil {
+		ctx.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":    "Member updated successfully",
+		"membership": membership,
+	})
+}
+
+// RemoveMember - DELETE /organizations/:id/members/:user_id
+func (c *OrganizationController) RemoveMember(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	memberID, err := strconv.ParseUint(ctx.Param("user_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	actorRole, _ := middleware.GetOrgRole(ctx)
+	if err := c.orgService.RemoveMember(auditActor(ctx), orgID, uint(memberID), actorRole); err != nil {
+		ctx.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// Invite - POST /organizations/:id/invitations
+func (c *OrganizationController) Invite(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req InviteRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorRole, _ := middleware.GetOrgRole(ctx)
+	invitation, err := c.orgService.Invite(auditActor(ctx), orgID, req.Email, models.OrgRole(req.Role), actorRole)
+	if err != nil {
+		ctx.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message":    "Invitation sent successfully",
+		"invitation": invitation,
+	})
+}
+
+// ListInvitations - GET /organizations/:id/invitations (pending only)
+func (c *OrganizationController) ListInvitations(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	invitations, err := c.orgService.ListInvitations(orgID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// AcceptInvitation - POST /invitations/:token/accept
+func (c *OrganizationController) AcceptInvitation(ctx *gin.Context) {
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	membership, err := c.orgService.AcceptInvitation(auditActor(ctx), ctx.Param("token"))
+	if err != nil {
+		ctx.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":    "Invitation accepted",
+		"membership": membership,
+	})
+}
+
+// CreateProject - POST /organizations/:id/projects
+func (c *OrganizationController) CreateProject(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	if _, exists := middleware.GetUserID(ctx); !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateOrganizationProjectRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, err := c.projectService.CreateProject(auditActor(ctx), orgID, req.Name)
+	if err != nil {
+		ctx.JSON(planErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message": "Project created successfully",
+		"project": project,
+	})
+}
+
+// memberErrorStatus maps membership errors to HTTP statuses
+func memberErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"):
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "only owners"), strings.HasPrefix(msg, "invitation was sent"):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
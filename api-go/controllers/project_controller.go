@@ -5,33 +5,51 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
 	"github.com/rivalprice/api-go/services"
 )
 
 type ProjectController struct {
 	projectService *services.ProjectService
+	orgService     *services.OrganizationService
 }
 
-func NewProjectController(projectService *services.ProjectService) *ProjectController {
-	return &ProjectController{projectService: projectService}
+func NewProjectController(projectService *services.ProjectService, orgService *services.OrganizationService) *ProjectController {
+	return &ProjectController{projectService: projectService, orgService: orgService}
 }
 
 type CreateProjectRequest struct {
-	UserID uint   `json:"user_id" binding:"required"`
+	UserID uint   `json:"user_id"` // optional, must be the current user
 	Name   string `json:"name" binding:"required"`
 }
 
-// CreateProject - POST /projects
+// CreateProject - POST /projects (in the personal organization of the current user)
 func (c *ProjectController) CreateProject(ctx *gin.Context) {
+	userID, exists := middleware.GetUserID(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
 	var req CreateProjectRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.UserID != 0 && req.UserID != userID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Projects can only be created for yourself"})
+		return
+	}
 
-	project, err := c.projectService.CreateProject(req.UserID, req.Name)
+	org, err := c.orgService.PersonalOrganization(userID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve personal organization"})
+		return
+	}
+
+	project, err := c.projectService.CreateProject(auditActor(ctx), org.ID, req.Name)
+	if err != nil {
+		ctx.JSON(planErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -41,24 +59,26 @@ func (c *ProjectController) CreateProject(ctx *gin.Context) {
 	})
 }
 
-// ListProjects - GET /projects
+// ListProjects - GET /projects (projects of the current user's organizations)
 func (c *ProjectController) ListProjects(ctx *gin.Context) {
-	// Optional: filter by user_id
-	userID := ctx.Query("user_id")
-	if userID != "" {
-		uid, err := strconv.ParseUint(userID, 10, 32)
-		if err == nil {
-			projects, err := c.projectService.GetProjectsByUserID(uint(uid))
-			if err != nil {
-				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
-				return
-			}
-			ctx.JSON(http.StatusOK, gin.H{"projects": projects})
+	viewer, exists := requestViewer(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Optional: filter by organization_id
+	var orgID uint
+	if raw := ctx.Query("organization_id"); raw != "" {
+		oid, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization_id"})
 			return
 		}
+		orgID = uint(oid)
 	}
 
-	projects, err := c.projectService.GetAllProjects()
+	projects, err := c.projectService.GetProjectsVisibleTo(viewer, orgID)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
 		return
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/services"
+)
+
+type ProjectRecipientController struct {
+	recipientService *services.ProjectRecipientService
+}
+
+func NewProjectRecipientController(recipientService *services.ProjectRecipientService) *ProjectRecipientController {
+	return &ProjectRecipientController{recipientService: recipientService}
+}
+
+type AddRecipientRequest struct {
+	UserID      *uint  `json:"user_id"` // collaborator, alerts follow their own settings
+	Email       string `json:"email"`   // external address or team alias
+	Label       string `json:"label"`
+	NotifyEmail *bool  `json:"notify_email"` // defaults to true
+	WebhookURL  string `json:"webhook_url"`
+	MinSeverity string `json:"min_severity"` // external recipients only
+}
+
+// ListRecipients - GET /projects/:id/recipients
+func (c *ProjectRecipientController) ListRecipients(ctx *gin.Context) {
+	projectID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	recipients, err := c.recipientService.ListRecipients(uint(projectID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recipients"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"recipients": recipients})
+}
+
+// AddRecipient - POST /projects/:id/recipients
+func (c *ProjectRecipientController) AddRecipient(ctx *gin.Context) {
+	projectID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var req AddRecipientRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recipient := &models.ProjectRecipient{
+		ProjectID:   uint(projectID),
+		UserID:      req.UserID,
+		Email:       req.Email,
+		Label:       req.Label,
+		NotifyEmail: req.NotifyEmail == nil || *req.NotifyEmail,
+		WebhookURL:  req.WebhookURL,
+		MinSeverity: models.AlertSeverity(req.MinSeverity),
+	}
+	if err := c.recipientService.AddRecipient(auditActor(ctx), recipient); err != nil {
+		status := planErrorStatus(err, http.StatusBadRequest)
+		if errors.Is(err, services.ErrNotOrgMember) {
+			status = http.StatusUnprocessableEntity
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message":   "Recipient added successfully",
+		"recipient": recipient,
+	})
+}
+
+// RemoveRecipient - DELETE /projects/:id/recipients/:recipient_id
+func (c *ProjectRecipientController) RemoveRecipient(ctx *gin.Context) {
+	projectID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	recipientID, err := strconv.ParseUint(ctx.Param("recipient_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipient ID"})
+		return
+	}
+
+	if err := c.recipientService.RemoveRecipient(auditActor(ctx), uint(projectID), uint(recipientID)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Recipient removed successfully"})
+}
+
+// unsubscribePage asks to confirm an unsubscribe link, then says it is done
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>RivalPrice — Unsubscribe</title></head>
+<body>
+{{if .Done}}<p>You will no longer receive alerts for {{.Project}}.</p>
+{{else}}<p>Stop receiving RivalPrice alerts for {{.Project}}?</p>
+<form method="post"><button type="submit">Unsubscribe</button></form>
+{{end}}</body>
+</html>
+`))
+
+func renderUnsubscribePage(ctx *gin.Context, project string, done bool) {
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Status(http.StatusOK)
+	unsubscribePage.Execute(ctx.Writer, gin.H{"Project": project, "Done": done})
+}
+
+// UnsubscribePage - GET /unsubscribe/:token (public, linked from alert emails)
+// Only asks for confirmation: link scanners open every link of an email.
+func (c *ProjectRecipientController) UnsubscribePage(ctx *gin.Context) {
+	recipient, project, err := c.recipientService.RecipientByToken(ctx.Param("token"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	renderUnsubscribePage(ctx, project, recipient.UnsubscribedAt != nil)
+}
+
+// Unsubscribe - POST /unsubscribe/:token (public, confirmation form and
+// RFC 8058 one-click unsubscribe from mail clients)
+func (c *ProjectRecipientController) Unsubscribe(ctx *gin.Context) {
+	token := ctx.Param("token")
+	_, project, err := c.recipientService.RecipientByToken(token)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := c.recipientService.Unsubscribe(auditActor(ctx), token); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe"})
+		return
+	}
+	renderUnsubscribePage(ctx, project, true)
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/services"
+	"gorm.io/gorm"
+)
+
+type ScrapeController struct {
+	scrapingService *services.ScrapingService
+}
+
+func NewScrapeController(scrapingService *services.ScrapingService) *ScrapeController {
+	return &ScrapeController{scrapingService: scrapingService}
+}
+
+// ScrapePage - POST /scrape/page/:id (scrape on demand)
+func (c *ScrapeController) ScrapePage(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page ID"})
+		return
+	}
+
+	if err := c.scrapingService.QueueScrapeJob(auditActor(ctx), uint(id)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(planErrorStatus(err, status), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Scrape job queued", "page_id": id})
+}
+
+// ScrapeProject - POST /scrape/project/:id (every page of the project)
+func (c *ScrapeController) ScrapeProject(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	if err := c.scrapingService.QueueScrapeJobForProject(auditActor(ctx), uint(id)); err != nil {
+		ctx.JSON(planErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Scrape jobs queued for project", "project_id": id})
+}
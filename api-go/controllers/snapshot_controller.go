@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/services"
+)
+
+type SnapshotController struct {
+	snapshotService *services.SnapshotService
+}
+
+func NewSnapshotController(snapshotService *services.SnapshotService) *SnapshotController {
+	return &SnapshotController{snapshotService: snapshotService}
+}
+
+// DiffSnapshots - GET /snapshots/:id/diff/:other
+// Returns field, plan and normalized text diffs from snapshot :id to :other.
+// Either snapshot outside the caller's pages is a 404.
+func (c *SnapshotController) DiffSnapshots(ctx *gin.Context) {
+	oldID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid snapshot ID"})
+		return
+	}
+	newID, err := strconv.ParseUint(ctx.Param("other"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid snapshot ID"})
+		return
+	}
+
+	viewer, exists := requestViewer(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	diff, err := c.snapshotService.DiffSnapshots(uint(oldID), uint(newID), viewer)
+	if err != nil {
+		if errors.Is(err, services.ErrSnapshotPageMismatch) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrSnapshotDiffTooLarge) {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"diff": diff})
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/services"
+)
+
+type SSOProviderController struct {
+	ssoService *services.SSOService
+}
+
+func NewSSOProviderController(ssoService *services.SSOService) *SSOProviderController {
+	return &SSOProviderController{ssoService: ssoService}
+}
+
+type SSOProviderRequest struct {
+	Slug           string `json:"slug"` // create only, used in login URLs
+	Name           string `json:"name" binding:"required"`
+	Issuer         string `json:"issuer" binding:"required"`
+	ClientID       string `json:"client_id" binding:"required"`
+	ClientSecret   string `json:"client_secret"`   // kept when empty on update
+	Scopes         string `json:"scopes"`          // extra scopes, space-separated
+	AllowedDomains string `json:"allowed_domains"` // comma-separated, empty = any (no linking of existing accounts by email)
+	DefaultRole    string `json:"default_role"`    // viewer (default), editor, admin
+	Enabled        *bool  `json:"enabled"`         // default true
+}
+
+func (r *SSOProviderRequest) toModel(orgID uint) *models.OIDCProvider {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+	return &models.OIDCProvider{
+		OrganizationID: orgID,
+		Slug:           r.Slug,
+		Name:           r.Name,
+		Issuer:         r.Issuer,
+		ClientID:       r.ClientID,
+		ClientSecret:   r.ClientSecret,
+		Scopes:         r.Scopes,
+		AllowedDomains: r.AllowedDomains,
+		DefaultRole:    models.OrgRole(r.DefaultRole),
+		Enabled:        enabled,
+	}
+}
+
+// CreateProvider - POST /organizations/:id/sso_providers
+func (c *SSOProviderController) CreateProvider(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+
+	var req SSOProviderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider := req.toModel(orgID)
+	if err := c.ssoService.CreateProvider(auditActor(ctx), provider); err != nil {
+		status := http.StatusBadRequest
+		if strings.Contains(err.Error(), "already taken") {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message":      "SSO provider created successfully",
+		"provider":     provider,
+		"redirect_uri": c.ssoService.RedirectURI(provider.Slug),
+	})
+}
+
+// ListProviders - GET /organizations/:id/sso_providers
+func (c *SSOProviderController) ListProviders(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	providers, err := c.ssoService.ListProviders(orgID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch SSO providers"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"providers": providers})
+}
+
+// UpdateProvider - PUT /organizations/:id/sso_providers/:provider_id
+func (c *SSOProviderController) UpdateProvider(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	id, err := strconv.ParseUint(ctx.Param("provider_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider ID"})
+		return
+	}
+
+	var req SSOProviderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider, err := c.ssoService.UpdateProvider(auditActor(ctx), orgID, uint(id), req.toModel(orgID))
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.HasSuffix(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":      "SSO provider updated successfully",
+		"provider":     provider,
+		"redirect_uri": c.ssoService.RedirectURI(provider.Slug),
+	})
+}
+
+// DeleteProvider - DELETE /organizations/:id/sso_providers/:provider_id
+func (c *SSOProviderController) DeleteProvider(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	id, err := strconv.ParseUint(ctx.Param("provider_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider ID"})
+		return
+	}
+
+	if err := c.ssoService.DeleteProvider(auditActor(ctx), orgID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "SSO provider deleted successfully"})
+}
+
+// ListDomains - GET /organizations/:id/domains
+func (c *SSOProviderController) ListDomains(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	domains, err := c.ssoService.ListDomains(orgID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch domains"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"domains": domains})
+}
+
+// AddDomain - POST /organizations/:id/domains
+func (c *SSOProviderController) AddDomain(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+
+	var req struct {
+		Domain string `json:"domain" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	domain, err := c.ssoService.AddDomain(auditActor(ctx), orgID, req.Domain)
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.Contains(err.Error(), "already added") {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	name, value := services.DomainVerificationRecord(domain)
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message":    "Domain added, publish the TXT record then verify it",
+		"domain":     domain,
+		"txt_record": gin.H{"name": name, "value": value},
+	})
+}
+
+// VerifyDomain - POST /organizations/:id/domains/:domain_id/verify
+func (c *SSOProviderController) VerifyDomain(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	id, err := strconv.ParseUint(ctx.Param("domain_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+
+	domain, err := c.ssoService.VerifyDomain(ctx.Request.Context(), auditActor(ctx), orgID, uint(id))
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		switch {
+		case err.Error() == "domain not found":
+			status = http.StatusNotFound
+		case strings.Contains(err.Error(), "another organization"):
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Domain verified",
+		"domain":  domain,
+	})
+}
+
+// DeleteDomain - DELETE /organizations/:id/domains/:domain_id
+func (c *SSOProviderController) DeleteDomain(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	id, err := strconv.ParseUint(ctx.Param("domain_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+
+	if err := c.ssoService.DeleteDomain(auditActor(ctx), orgID, uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Domain deleted successfully"})
+}
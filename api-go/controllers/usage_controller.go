@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/services"
+)
+
+type UsageController struct {
+	quotaService *services.QuotaService
+}
+
+func NewUsageController(quotaService *services.QuotaService) *UsageController {
+	return &UsageController{quotaService: quotaService}
+}
+
+// GetUsage - GET /organizations/:id/usage (consumption against the plan)
+func (c *UsageController) GetUsage(ctx *gin.Context) {
+	orgID, _ := middleware.GetOrgID(ctx)
+	usage, err := c.quotaService.Usage(orgID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch usage"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"usage": usage})
+}
+
+// planErrorStatus is 402 when err is a plan limit, else status
+func planErrorStatus(err error, status int) int {
+	if errors.Is(err, services.ErrQuotaExceeded) {
+		return http.StatusPaymentRequired
+	}
+	return status
+}
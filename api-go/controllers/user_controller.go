@@ -1,72 +0,0 @@
-package controllers
-
-import (
-	"net/http"
-	"strconv"
-
-	"github.com/gin-gonic/gin"
-	"github.com/rivalprice/api-go/services"
-)
-
-type UserController struct {
-	userService *services.UserService
-}
-
-func NewUserController(userService *services.UserService) *UserController {
-	return &UserController{userService: userService}
-}
-
-type CreateUserRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
-}
-
-// CreateUser - POST /users
-func (c *UserController) CreateUser(ctx *gin.Context) {
-	var req CreateUserRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	user, err := c.userService.CreateUser(req.Email, req.Password)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusCreated, gin.H{
-		"message": "User created successfully",
-		"user":    user,
-	})
-}
-
-// ListUsers - GET /users
-func (c *UserController) ListUsers(ctx *gin.Context) {
-	users, err := c.userService.GetAllUsers()
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, gin.H{
-		"users": users,
-	})
-}
-
-// GetUser - GET /users/:id
-func (c *UserController) GetUser(ctx *gin.Context) {
-	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
-	}
-
-	user, err := c.userService.GetUserByID(uint(id))
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, gin.H{"user": user})
-}
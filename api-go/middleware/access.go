@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLevel is how a route authenticates its callers
+type AccessLevel string
+
+const (
+	AccessPublic        AccessLevel = "public"        // anyone
+	AccessAuthenticated AccessLevel = "authenticated" // JWT or API key with any scope
+	AccessScoped        AccessLevel = "scoped"        // JWT, or API key with the route's scope
+	AccessSession       AccessLevel = "session"       // JWT only, API keys are refused
+)
+
+// Access is the authentication requirement of a route, declared when the
+// route is registered (see routes.Router)
+type Access struct {
+	Level      AccessLevel
+	ReadScope  string // API key scope for GET / HEAD (AccessScoped)
+	WriteScope string // API key scope for other methods (AccessScoped)
+}
+
+// Public routes need no credentials (login, health checks, email links)
+func Public() Access {
+	return Access{Level: AccessPublic}
+}
+
+// Authenticated routes accept any logged-in user or API key
+func Authenticated() Access {
+	return Access{Level: AccessAuthenticated}
+}
+
+// Scoped routes accept users, and API keys holding scope
+func Scoped(scope string) Access {
+	return Access{Level: AccessScoped, ReadScope: scope, WriteScope: scope}
+}
+
+// ScopedByMethod routes require read for GET / HEAD requests and write for
+// every other method from API keys
+func ScopedByMethod(read, write string) Access {
+	return Access{Level: AccessScoped, ReadScope: read, WriteScope: write}
+}
+
+// Session routes are only for a logged-in user (e.g. managing API keys)
+func Session() Access {
+	return Access{Level: AccessSession}
+}
+
+// String describes the requirement, e.g. "scoped(read:projects/write:projects)"
+func (a Access) String() string {
+	if a.Level != AccessScoped {
+		return string(a.Level)
+	}
+	if a.ReadScope == a.WriteScope {
+		return fmt.Sprintf("scoped(%s)", a.ReadScope)
+	}
+	return fmt.Sprintf("scoped(%s/%s)", a.ReadScope, a.WriteScope)
+}
+
+// Require returns the middleware enforcing access. It runs first in the
+// route's chain so everything after it sees the authenticated caller.
+func (a *Authenticator) Require(access Access) gin.HandlerFunc {
+	var check gin.HandlerFunc
+	switch access.Level {
+	case AccessPublic:
+		return func(c *gin.Context) { c.Next() }
+	case AccessAuthenticated:
+	case AccessScoped:
+		check = RequireScopeByMethod(access.ReadScope, access.WriteScope)
+	case AccessSession:
+		check = RequireSession()
+	default:
+		panic(fmt.Sprintf("unknown access level %q", access.Level))
+	}
+
+	return func(c *gin.Context) {
+		if !a.authenticate(c) {
+			return
+		}
+		if check != nil {
+			check(c)
+			return
+		}
+		c.Next()
+	}
+}
@@ -3,70 +3,163 @@ package middleware
 import (
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
 )
 
-// Claims represents JWT claims
+// Claims represents JWT claims. The jti (RegisteredClaims.ID) identifies the
+// token for revocation, SessionID the login session it was issued for, AMR
+// how that session was authenticated.
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    uint     `json:"user_id"`
+	Email     string   `json:"email"`
+	SessionID string   `json:"sid,omitempty"`
+	AMR       []string `json:"amr,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// JWTAuthMiddleware creates a middleware that validates JWT tokens
-func JWTAuthMiddleware(jwtSecret string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Skip auth for public endpoints
-		if isPublicPath(c.Request.URL.Path) {
-			c.Next()
-			return
-		}
+// AMRMFA is the amr claim of sessions opened with a second factor
+const AMRMFA = "mfa"
 
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Authorization header required",
-			})
-			return
+// HasMFA reports whether the session passed two-factor authentication
+func (c *Claims) HasMFA() bool {
+	for _, method := range c.AMR {
+		if method == AMRMFA {
+			return true
 		}
+	}
+	return false
+}
 
-		// Extract Bearer token
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid authorization header format. Expected: Bearer <token>",
-			})
-			return
-		}
+// APIKeyAuthenticator validates API keys (see services.APIKeyService)
+type APIKeyAuthenticator interface {
+	Authenticate(key, ip string) (*models.APIKey, error)
+}
 
-		tokenString := parts[1]
-		claims, err := ValidateToken(tokenString, jwtSecret)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid or expired token",
-			})
-			return
-		}
+// TokenKeys signs access tokens and returns the key verifying a token (see
+// services.SigningKeyService)
+type TokenKeys interface {
+	Sign(claims jwt.Claims) (string, error)
+	Keyfunc(token *jwt.Token) (interface{}, error)
+}
+
+// TokenDenylist tells whether an access token was revoked (see
+// services.SessionService)
+type TokenDenylist interface {
+	IsRevoked(jti string, userID uint, issuedAt time.Time) (bool, error)
+}
+
+// Authenticator authenticates requests with a JWT access token, or an API
+// key sent as a Bearer token or in the X-API-Key header. Tokens are checked
+// against the revocation denylist. Routes declare what they require with an
+// Access (see Require).
+type Authenticator struct {
+	keys     TokenKeys
+	apiKeys  APIKeyAuthenticator
+	denylist TokenDenylist
+}
+
+func NewAuthenticator(keys TokenKeys, apiKeys APIKeyAuthenticator, denylist TokenDenylist) *Authenticator {
+	return &Authenticator{keys: keys, apiKeys: apiKeys, denylist: denylist}
+}
+
+// authenticate stores the caller in the context, or aborts the request and
+// returns false
+func (a *Authenticator) authenticate(c *gin.Context) bool {
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+		return a.authenticateAPIKey(c, apiKey)
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Authorization header required",
+		})
+		return false
+	}
+
+	// Extract Bearer token
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid authorization header format. Expected: Bearer <token>",
+		})
+		return false
+	}
 
-		// Store user info in context
-		c.Set("userID", claims.UserID)
-		c.Set("userEmail", claims.Email)
-		c.Next()
+	tokenString := parts[1]
+	if strings.HasPrefix(tokenString, models.APIKeyPrefix) {
+		return a.authenticateAPIKey(c, tokenString)
 	}
+
+	claims, err := ValidateToken(tokenString, a.keys)
+	if err != nil || claims.ID == "" || claims.IssuedAt == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid or expired token",
+		})
+		return false
+	}
+
+	revoked, err := a.denylist.IsRevoked(claims.ID, claims.UserID, claims.IssuedAt.Time)
+	if err != nil {
+		// Fail closed: a logged out token must never get through
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Token revocation check unavailable",
+		})
+		return false
+	}
+	if revoked {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Token revoked",
+		})
+		return false
+	}
+
+	// Store user info in context
+	c.Set("userID", claims.UserID)
+	c.Set("userEmail", claims.Email)
+	c.Set("claims", claims)
+	c.Set("mfa", claims.HasMFA())
+	return true
 }
 
-// ValidateToken validates a JWT token and returns the claims
-func ValidateToken(tokenString string, jwtSecret string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(jwtSecret), nil
-	})
+// authenticateAPIKey authenticates the request as the owner of an API key.
+// The key is kept in the context so scopes and rate limits apply to it.
+func (a *Authenticator) authenticateAPIKey(c *gin.Context, plaintext string) bool {
+	if a.apiKeys == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API keys are not accepted here"})
+		return false
+	}
+
+	key, err := a.apiKeys.Authenticate(plaintext, c.ClientIP())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return false
+	}
+
+	c.Set("userID", key.UserID)
+	c.Set("userEmail", key.User.Email)
+	c.Set("apiKey", key)
+	c.Set("mfa", key.MFA)
+	return true
+}
+
+// TokenIssuer is the iss claim of access tokens
+const TokenIssuer = "rivalprice-api"
+
+// ValidateToken validates a JWT token against the published signing keys and
+// returns the claims
+func ValidateToken(tokenString string, keys TokenKeys) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.Keyfunc,
+		jwt.WithValidMethods([]string{"RS256", "EdDSA", "HS256"}),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
@@ -79,38 +172,73 @@ func ValidateToken(tokenString string, jwtSecret string) (*Claims, error) {
 	return nil, errors.New("invalid token claims")
 }
 
-// GenerateToken generates a new JWT token for a user
-func GenerateToken(userID uint, email string, jwtSecret string, expiryHours int) (string, error) {
+// GenerateToken generates a new access token for a user session and returns
+// it with its jti. mfa records that the session passed two-factor
+// authentication (amr claim).
+func GenerateToken(keys TokenKeys, userID uint, email, sessionID string, mfa bool, ttl time.Duration) (string, string, error) {
+	jti, err := utils.RandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expiryHours) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        jti,
+			Issuer:    TokenIssuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtSecret))
+	if mfa {
+		claims.AMR = []string{AMRMFA}
+	}
+
+	signed, err := keys.Sign(claims)
+	if err != nil {
+		return "", "", err
+	}
+	return signed, jti, nil
 }
 
-// isPublicPath returns true if the path doesn't require authentication
-func isPublicPath(path string) bool {
-	publicPaths := []string{
-		"/health",
-		"/db/status",
-		"/redis/status",
-		"/migrate",
-		"/api/v1/users",      // Allow user creation (register)
-		"/api/v1/auth/login", // Allow login
+// NewAccessTokenSigner returns a signer issuing access tokens with keys
+// (matches services.AccessTokenSigner)
+func NewAccessTokenSigner(keys TokenKeys) func(userID uint, email, sessionID string, mfa bool, ttl time.Duration) (string, string, error) {
+	return func(userID uint, email, sessionID string, mfa bool, ttl time.Duration) (string, string, error) {
+		return GenerateToken(keys, userID, email, sessionID, mfa, ttl)
 	}
+}
 
-	for _, publicPath := range publicPaths {
-		if path == publicPath {
-			return true
-		}
+// GetClaims retrieves the access token claims of a JWT-authenticated request
+func GetClaims(c *gin.Context) (*Claims, bool) {
+	claims, exists := c.Get("claims")
+	if !exists {
+		return nil, false
 	}
-	return false
+	cl, ok := claims.(*Claims)
+	return cl, ok
+}
+
+// GetAPIKey retrieves the API key the request was authenticated with, if any
+func GetAPIKey(c *gin.Context) (*models.APIKey, bool) {
+	key, exists := c.Get("apiKey")
+	if !exists {
+		return nil, false
+	}
+	k, ok := key.(*models.APIKey)
+	return k, ok
+}
+
+// MFAVerified reports whether the request's credential passed two-factor
+// authentication: a session opened with a second factor, or an API key
+// created from one
+func MFAVerified(c *gin.Context) bool {
+	return c.GetBool("mfa")
 }
 
 // GetUserID retrieves the user ID from the context
@@ -1,97 +1,185 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
-// RateLimiter implements a token bucket rate limiter per IP
-type RateLimiter struct {
-	requests map[string]*clientLimit
-	mu       sync.RWMutex
-	rate     int           // requests per window
-	window   time.Duration // time window
+// RateLimitPolicy is the request budget of a class of routes. Each client
+// (API key, user or IP) gets its own Limit requests per Period, with bursts
+// of up to Limit. With LocalFallback the budget is kept in memory, per
+// replica, while Redis is unavailable instead of not being enforced at all.
+type RateLimitPolicy struct {
+	Name          string
+	Limit         int
+	Period        time.Duration
+	LocalFallback bool
 }
 
-type clientLimit struct {
-	count     int
-	resetTime time.Time
-}
+var (
+	// PolicyStandard applies to every API route
+	PolicyStandard = RateLimitPolicy{Name: "standard", Limit: 100, Period: time.Minute}
+	// PolicyStrict applies to expensive endpoints (scrapes on demand)
+	PolicyStrict = RateLimitPolicy{Name: "strict", Limit: 10, Period: time.Minute}
+	// PolicyCredentials applies to endpoints checking credentials or sending
+	// emails (login, register, password reset), against guessing
+	PolicyCredentials = RateLimitPolicy{Name: "credentials", Limit: 10, Period: time.Minute, LocalFallback: true}
+)
 
-// NewRateLimiter creates a new rate limiter
-func NewRateLimiter(requestsPerMinute int) *RateLimiter {
-	rl := &RateLimiter{
-		requests: make(map[string]*clientLimit),
-		rate:     requestsPerMinute,
-		window:   time.Minute,
-	}
-	// Cleanup old entries periodically
-	go rl.cleanup()
-	return rl
-}
+const rateLimitKeyPrefix = "ratelimit:" // + policy:client
 
-// Limit returns a gin middleware that rate limits requests
-func (rl *RateLimiter) Limit() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
+// gcraScript implements GCRA (a smooth sliding window) atomically in Redis.
+// The key holds the client's theoretical arrival time (TAT) in milliseconds,
+// on the Redis clock so replicas agree. Returns allowed (0/1), remaining
+// requests, milliseconds until a retry is allowed and until the budget is
+// full again.
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
 
-		rl.mu.Lock()
-		defer rl.mu.Unlock()
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+local allow_at = new_tat - tolerance
+if allow_at > now then
+	return {0, 0, allow_at - now, tat - now}
+end
 
-		now := time.Now()
-		limit, exists := rl.requests[clientIP]
+redis.call('SET', KEYS[1], new_tat, 'PX', new_tat - now)
+return {1, math.floor((now - allow_at) / interval), 0, new_tat - now}
+`)
 
-		if !exists || now.After(limit.resetTime) {
-			// First request or window expired
-			rl.requests[clientIP] = &clientLimit{
-				count:     1,
-				resetTime: now.Add(rl.window),
-			}
+// RateLimiter limits requests per API key, per user for JWT sessions, or per
+// IP for everything else, with budgets kept in Redis so they survive restarts
+// and are shared by every replica. It must run after authentication to see
+// the caller.
+type RateLimiter struct {
+	redis *redis.Client
+	local *localGCRA // policies with LocalFallback while Redis is unavailable
+}
+
+func NewRateLimiter(redisClient *redis.Client) *RateLimiter {
+	return &RateLimiter{redis: redisClient, local: newLocalGCRA()}
+}
+
+// Limit returns a gin middleware enforcing policy. It sets the RateLimit-*
+// headers, and Retry-After on 429. If Redis is unavailable, policies with
+// LocalFallback are enforced per replica and the others let requests
+// through: failing closed would take the whole API down.
+func (rl *RateLimiter) Limit(policy RateLimitPolicy) gin.HandlerFunc {
+	interval := policy.Period.Milliseconds() / int64(policy.Limit)
+	tolerance := policy.Period.Milliseconds()
+	policyHeader := fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Period.Seconds()))
+
+	return func(c *gin.Context) {
+		if rl.redis == nil && !policy.LocalFallback {
 			c.Next()
 			return
 		}
 
-		if limit.count >= rl.rate {
+		key := rateLimitKeyPrefix + policy.Name + ":" + rateLimitKey(c)
+		var res []int64
+		err := errors.New("no Redis client")
+		if rl.redis != nil {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), time.Second)
+			res, err = gcraScript.Run(ctx, rl.redis, []string{key}, interval, tolerance).Int64Slice()
+			cancel()
+		}
+		if err != nil || len(res) != 4 {
+			if !policy.LocalFallback {
+				log.Printf("⚠️  Rate limit unavailable (%s): %v", policy.Name, err)
+				c.Next()
+				return
+			}
+			log.Printf("⚠️  Rate limit unavailable (%s), limiting in memory: %v", policy.Name, err)
+			res = rl.local.take(key, interval, tolerance, time.Now())
+		}
+		allowed, remaining := res[0] == 1, res[1]
+		retryAfter, reset := millisToSeconds(res[2]), millisToSeconds(res[3])
+
+		c.Header("RateLimit-Policy", policyHeader)
+		c.Header("RateLimit-Limit", strconv.Itoa(policy.Limit))
+		c.Header("RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("RateLimit-Reset", strconv.FormatInt(reset, 10))
+
+		if !allowed {
+			c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
 				"error":       "Rate limit exceeded",
-				"retry_after": limit.resetTime.Format(time.RFC3339),
+				"retry_after": time.Now().Add(time.Duration(retryAfter) * time.Second).Format(time.RFC3339),
 			})
 			return
 		}
-
-		limit.count++
 		c.Next()
 	}
 }
 
-// cleanup removes expired entries periodically
-func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		rl.mu.Lock()
-		now := time.Now()
-		for ip, limit := range rl.requests {
-			if now.After(limit.resetTime) {
-				delete(rl.requests, ip)
+// localGCRA is gcraScript in memory, for a single replica
+type localGCRA struct {
+	mu    sync.Mutex
+	tats  map[string]int64 // key -> theoretical arrival time (ms)
+	swept int64
+}
+
+func newLocalGCRA() *localGCRA {
+	return &localGCRA{tats: map[string]int64{}}
+}
+
+// take returns the same values as gcraScript
+func (l *localGCRA) take(key string, interval, tolerance int64, at time.Time) []int64 {
+	now := at.UnixMilli()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop budgets that are full again, at most once a minute
+	if now-l.swept > time.Minute.Milliseconds() {
+		for k, tat := range l.tats {
+			if tat <= now {
+				delete(l.tats, k)
 			}
 		}
-		rl.mu.Unlock()
+		l.swept = now
+	}
+
+	tat, ok := l.tats[key]
+	if !ok || tat < now {
+		tat = now
 	}
+	newTAT := tat + interval
+	allowAt := newTAT - tolerance
+	if allowAt > now {
+		return []int64{0, 0, allowAt - now, tat - now}
+	}
+	l.tats[key] = newTAT
+	return []int64{1, (now - allowAt) / interval, 0, newTAT - now}
 }
 
-// StrictRateLimit is a stricter rate limiter for sensitive endpoints
-func StrictRateLimit() gin.HandlerFunc {
-	limiter := NewRateLimiter(10) // 10 requests per minute
-	return limiter.Limit()
+// rateLimitKey identifies the client a request counts against: its API key,
+// so CI jobs behind one NAT don't share a budget, else its user, else its IP
+func rateLimitKey(c *gin.Context) string {
+	if key, ok := GetAPIKey(c); ok {
+		return fmt.Sprintf("key:%d", key.ID)
+	}
+	if userID, ok := GetUserID(c); ok {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	return "ip:" + c.ClientIP()
 }
 
-// StandardRateLimit is a standard rate limiter for API endpoints
-func StandardRateLimit() gin.HandlerFunc {
-	limiter := NewRateLimiter(100) // 100 requests per minute
-	return limiter.Limit()
+// millisToSeconds rounds up, so clients never retry too early
+func millisToSeconds(ms int64) int64 {
+	return (ms + 999) / 1000
 }
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestLimitWithoutRedis(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	redisClient := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { redisClient.Close() })
+	limiter := NewRateLimiter(redisClient)
+
+	engine := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	engine.POST("/login", limiter.Limit(PolicyCredentials), ok)
+	engine.GET("/projects", limiter.Limit(PolicyStandard), ok)
+
+	serve := func(method, path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// Credentials are still limited, in memory
+	for i := 0; i < PolicyCredentials.Limit; i++ {
+		if w := serve(http.MethodPost, "/login", "192.0.2.1"); w.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status %d, want 204", i+1, w.Code)
+		}
+	}
+	w := serve(http.MethodPost, "/login", "192.0.2.1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("request over the limit: status %d, Retry-After %q, want 429", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve(http.MethodPost, "/login", "192.0.2.2"); w.Code != http.StatusNoContent {
+		t.Errorf("another client: status %d, want 204", w.Code)
+	}
+
+	// Other policies let requests through
+	for i := 0; i <= PolicyStandard.Limit; i++ {
+		if w := serve(http.MethodGet, "/projects", "192.0.2.1"); w.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status %d, want 204", i+1, w.Code)
+		}
+	}
+}
+
+func TestLocalGCRA(t *testing.T) {
+	l := newLocalGCRA()
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	interval, tolerance := int64(6000), int64(60000) // 10 per minute
+
+	for i := 0; i < 10; i++ {
+		if res := l.take("k", interval, tolerance, now); res[0] != 1 || res[1] != int64(9-i) {
+			t.Fatalf("request %d = %v, want allowed with %d remaining", i+1, res, 9-i)
+		}
+	}
+	if res := l.take("k", interval, tolerance, now); res[0] != 0 || res[2] != interval {
+		t.Fatalf("request 11 = %v, want refused, retry in %dms", res, interval)
+	}
+	// One request is allowed again after each interval
+	if res := l.take("k", interval, tolerance, now.Add(6*time.Second)); res[0] != 1 || res[1] != 0 {
+		t.Fatalf("request after an interval = %v, want allowed with 0 remaining", res)
+	}
+
+	// Full budgets are dropped
+	l.take("k", interval, tolerance, now.Add(2*time.Hour))
+	if len(l.tats) != 1 {
+		t.Errorf("%d budgets kept, want 1", len(l.tats))
+	}
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/models"
+)
+
+// OrgAuthorizer returns the role of a user in an organization, and whether
+// the user meets its two-factor authentication policy with a credential
+// that passed 2FA (verified) or not
+type OrgAuthorizer interface {
+	MemberRole(orgID, userID uint) (models.OrgRole, error)
+	MFASatisfied(orgID, userID uint, verified bool) (bool, error)
+}
+
+// OrgLookup maps a resource ID to the ID of the organization owning it
+type OrgLookup func(id uint) (uint, error)
+
+// OrgResolver finds the organization a request acts on
+type OrgResolver func(c *gin.Context) (uint, error)
+
+// ErrBadRequest marks resolver errors caused by the request itself (400
+// instead of 404)
+var ErrBadRequest = errors.New("bad request")
+
+// RequireOrgRole rejects the request unless the authenticated user has at
+// least role min in the organization returned by resolve. Unknown resources
+// and organizations the user doesn't belong to both answer 404, so IDs of
+// other organizations are not disclosed.
+func RequireOrgRole(authz OrgAuthorizer, min models.OrgRole, resolve OrgResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		orgID, err := resolve(c)
+		if err != nil {
+			status := http.StatusNotFound
+			if errors.Is(err, ErrBadRequest) {
+				status = http.StatusBadRequest
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		role, err := authz.MemberRole(orgID, userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+			return
+		}
+		if !role.AtLeast(min) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": fmt.Sprintf("%s role required in this organization", min),
+			})
+			return
+		}
+		if ok, err := authz.MFASatisfied(orgID, userID, MFAVerified(c)); err != nil || !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":        "This organization requires two-factor authentication, enable it on your account and sign in with it",
+				"mfa_required": true,
+			})
+			return
+		}
+
+		c.Set("orgID", orgID)
+		c.Set("orgRole", role)
+		c.Next()
+	}
+}
+
+// FromParam resolves the organization from a URL parameter holding the ID of
+// a resource
+func FromParam(param string, lookup OrgLookup) OrgResolver {
+	return func(c *gin.Context) (uint, error) {
+		id, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("%w: invalid %s", ErrBadRequest, param)
+		}
+		return lookup(uint(id))
+	}
+}
+
+// FromQuery resolves the organization from a query parameter holding the ID
+// of a resource
+func FromQuery(param string, lookup OrgLookup) OrgResolver {
+	return func(c *gin.Context) (uint, error) {
+		raw := c.Query(param)
+		if raw == "" {
+			return 0, fmt.Errorf("%w: %s is required", ErrBadRequest, param)
+		}
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("%w: invalid %s", ErrBadRequest, param)
+		}
+		return lookup(uint(id))
+	}
+}
+
+// FromBodyField resolves the organization from a numeric field of the JSON
+// body, e.g. project_id when creating a competitor
+func FromBodyField(field string, lookup OrgLookup) OrgResolver {
+	return FromBody(func(body map[string]interface{}) (uint, error) {
+		value, ok := body[field].(float64)
+		if !ok || value <= 0 {
+			return 0, fmt.Errorf("%w: %s is required", ErrBadRequest, field)
+		}
+		return lookup(uint(value))
+	})
+}
+
+// FromBody resolves the organization from the decoded JSON body. The body is
+// restored afterwards so the handler can still bind it.
+func FromBody(resolve func(body map[string]interface{}) (uint, error)) OrgResolver {
+	return func(c *gin.Context) (uint, error) {
+		raw, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return 0, fmt.Errorf("%w: unreadable body", ErrBadRequest)
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			return 0, fmt.Errorf("%w: invalid JSON body", ErrBadRequest)
+		}
+		return resolve(body)
+	}
+}
+
+// GetOrgID retrieves the organization ID set by RequireOrgRole
+func GetOrgID(c *gin.Context) (uint, bool) {
+	orgID, exists := c.Get("orgID")
+	if !exists {
+		return 0, false
+	}
+	id, ok := orgID.(uint)
+	return id, ok
+}
+
+// GetOrgRole retrieves the role set by RequireOrgRole
+func GetOrgRole(c *gin.Context) (models.OrgRole, bool) {
+	role, exists := c.Get("orgRole")
+	if !exists {
+		return "", false
+	}
+	r, ok := role.(models.OrgRole)
+	return r, ok
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope rejects requests authenticated with an API key lacking scope.
+// Requests authenticated with a JWT (interactive sessions) have every scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key, ok := GetAPIKey(c); ok && !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": fmt.Sprintf("API key lacks the %s scope", scope),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScopeByMethod requires read for GET / HEAD requests and write for
+// every other method, so a whole route group can be scoped at once
+func RequireScopeByMethod(read, write string) gin.HandlerFunc {
+	readCheck, writeCheck := RequireScope(read), RequireScope(write)
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			readCheck(c)
+			return
+		}
+		writeCheck(c)
+	}
+}
+
+// RequireSession rejects requests authenticated with an API key, for
+// endpoints only a logged-in user may call (e.g. managing API keys)
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := GetAPIKey(c); ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This endpoint requires a user session, not an API key"})
+			return
+		}
+		c.Next()
+	}
+}
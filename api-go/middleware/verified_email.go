@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmailVerificationChecker tells whether a user confirmed their email
+// address (see services.AccountService)
+type EmailVerificationChecker interface {
+	IsEmailVerified(userID uint) (bool, error)
+}
+
+// RequireVerifiedEmail rejects users (or API keys of users) whose email
+// address is not verified yet
+func RequireVerifiedEmail(checker EmailVerificationChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := GetUserID(c)
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		verified, err := checker.IsEmailVerified(userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email verification"})
+			return
+		}
+		if !verified {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Email address not verified, check your inbox or request a new link",
+			})
+			return
+		}
+		c.Next()
+	}
+}
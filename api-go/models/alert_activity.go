@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Alert activity actions
+const (
+	AlertActionStateChanged = "state_changed"
+	AlertActionAssigned     = "assigned"
+	AlertActionCommented    = "commented"
+	AlertActionFolded       = "change_folded"
+)
+
+// AlertActivity is the audit trail of an alert: who changed what and when.
+// ActorID is nil for changes made by the system (e.g. a snooze expiring).
+type AlertActivity struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	AlertID   uint      `gorm:"column:alert_id;not null;index" json:"alert_id"`
+	ActorID   *uint     `gorm:"column:actor_id" json:"actor_id"`
+	Action    string    `gorm:"column:action;type:varchar(50);not null" json:"action"`
+	FromValue string    `gorm:"column:from_value;type:varchar(255)" json:"from_value"`
+	ToValue   string    `gorm:"column:to_value;type:varchar(255)" json:"to_value"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (AlertActivity) TableName() string {
+	return "alert_activities"
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// AlertComment is one message in the discussion thread of an alert
+type AlertComment struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	AlertID   uint      `gorm:"column:alert_id;not null;index" json:"alert_id"`
+	UserID    uint      `gorm:"column:user_id;not null" json:"user_id"`
+	Body      string    `gorm:"column:body;type:text;not null" json:"body"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	User      User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (AlertComment) TableName() string {
+	return "alert_comments"
+}
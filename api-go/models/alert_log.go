@@ -12,28 +12,52 @@ const (
 	SeverityCritical AlertSeverity = "critical"
 )
 
+// AlertState is the triage state of an alert
+type AlertState string
+
+const (
+	AlertStateNew          AlertState = "new"
+	AlertStateAcknowledged AlertState = "acknowledged"
+	AlertStateSnoozed      AlertState = "snoozed"
+	AlertStateResolved     AlertState = "resolved"
+	AlertStateDismissed    AlertState = "dismissed"
+)
+
 // AlertLog stores every alert generated by the Alert Engine
 type AlertLog struct {
-	ID             uint          `gorm:"primaryKey;autoIncrement" json:"id"`
-	ChangeID       uint          `gorm:"column:change_id;not null;index" json:"change_id"`
-	PageID         int           `gorm:"column:page_id;not null;index" json:"page_id"`
-	AlertType      string        `gorm:"column:alert_type;type:varchar(50);not null" json:"alert_type"` // price_increase, price_decrease, feature_added, etc.
-	Severity       AlertSeverity `gorm:"column:severity;type:varchar(20);not null" json:"severity"`
+	ID        uint          `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChangeID  uint          `gorm:"column:change_id;not null;uniqueIndex:uq_alert_logs_change_id" json:"change_id"` // one alert per change
+	PageID    int           `gorm:"column:page_id;not null;index" json:"page_id"`
+	AlertType string        `gorm:"column:alert_type;type:varchar(50);not null" json:"alert_type"` // price_increase, price_decrease, feature_added, etc.
+	Severity  AlertSeverity `gorm:"column:severity;type:varchar(20);not null" json:"severity"`
 	// Factual data (always present, never AI-generated)
-	OldPrice       string        `gorm:"column:old_price;type:varchar(50)" json:"old_price"`
-	NewPrice       string        `gorm:"column:new_price;type:varchar(50)" json:"new_price"`
-	ChangePercent  float64       `gorm:"column:change_percent" json:"change_percent"`
+	OldPrice      string  `gorm:"column:old_price;type:varchar(50)" json:"old_price"`
+	NewPrice      string  `gorm:"column:new_price;type:varchar(50)" json:"new_price"`
+	ChangePercent float64 `gorm:"column:change_percent" json:"change_percent"`
 	// AI-generated enrichment
-	AISummary      string        `gorm:"column:ai_summary;type:text" json:"ai_summary"`
-	AIRecommendation string      `gorm:"column:ai_recommendation;type:varchar(500)" json:"ai_recommendation"`
-	ImpactLevel    int           `gorm:"column:impact_level;default:0" json:"impact_level"` // 1-10 score from AI
-	AIModel        string        `gorm:"column:ai_model;type:varchar(50)" json:"ai_model"`
+	AISummary        string `gorm:"column:ai_summary;type:text" json:"ai_summary"`
+	AIRecommendation string `gorm:"column:ai_recommendation;type:varchar(500)" json:"ai_recommendation"`
+	ImpactLevel      int    `gorm:"column:impact_level;default:0" json:"impact_level"` // 1-10 score from AI
+	AIModel          string `gorm:"column:ai_model;type:varchar(50)" json:"ai_model"`
+	// Link to the snapshot diff behind this change, when snapshots are known
+	DiffURL string `gorm:"column:diff_url;type:varchar(255)" json:"diff_url,omitempty"`
 	// Full assembled message (factual + AI)
-	Message        string        `gorm:"column:message;type:text" json:"message"`
-	Notified       bool          `gorm:"column:notified;default:false" json:"notified"`
-	NotifiedAt     *time.Time    `gorm:"column:notified_at" json:"notified_at"`
-	NotifyChannel  string        `gorm:"column:notify_channel;type:varchar(50)" json:"notify_channel"` // email, webhook, log
-	CreatedAt      time.Time     `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	Message       string     `gorm:"column:message;type:text" json:"message"`
+	Notified      bool       `gorm:"column:notified;default:false" json:"notified"`
+	NotifiedAt    *time.Time `gorm:"column:notified_at" json:"notified_at"`
+	NotifyChannel string     `gorm:"column:notify_channel;type:varchar(50)" json:"notify_channel"` // email, webhook, log
+	DigestedAt    *time.Time `gorm:"column:digested_at" json:"digested_at"`                        // sent in a daily/weekly digest
+	RuleID        *uint      `gorm:"column:rule_id" json:"rule_id"`                                // notification rule that routed this alert
+	// Changes folded into this alert (repeats, reverts, cool-down)
+	Occurrences      int        `gorm:"column:occurrences;not null;default:1" json:"occurrences"`
+	LastOccurrenceAt *time.Time `gorm:"column:last_occurrence_at" json:"last_occurrence_at"`
+	Flapping         bool       `gorm:"column:flapping;default:false" json:"flapping"` // price oscillates (A/B test, revert)
+	// Triage lifecycle
+	State          AlertState `gorm:"column:state;type:varchar(20);not null;default:new;index" json:"state"`
+	SnoozedUntil   *time.Time `gorm:"column:snoozed_until" json:"snoozed_until"`
+	AssigneeID     *uint      `gorm:"column:assignee_id;index" json:"assignee_id"`
+	StateChangedAt *time.Time `gorm:"column:state_changed_at" json:"state_changed_at"`
+	CreatedAt      time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
 }
 
 func (AlertLog) TableName() string {
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actor types
+const (
+	AuditActorUser      = "user"      // a user's session
+	AuditActorAPIKey    = "api_key"   // a user's API key
+	AuditActorSystem    = "system"    // workers and provider webhooks
+	AuditActorAnonymous = "anonymous" // unauthenticated requests (unsubscribe links...)
+)
+
+// Audited actions, "<target type>.<verb>"
+const (
+	AuditProjectCreated           = "project.created"
+	AuditProjectUpdated           = "project.updated"
+	AuditProjectDeleted           = "project.deleted"
+	AuditCompetitorCreated        = "competitor.created"
+	AuditCompetitorUpdated        = "competitor.updated"
+	AuditCompetitorDeleted        = "competitor.deleted"
+	AuditPageCreated              = "monitored_page.created"
+	AuditPageUpdated              = "monitored_page.updated"
+	AuditPageDeleted              = "monitored_page.deleted"
+	AuditScrapeTriggered          = "monitored_page.scrape_triggered"
+	AuditProjectScrapeTriggered   = "project.scrape_triggered"
+	AuditRuleCreated              = "notification_rule.created"
+	AuditRuleUpdated              = "notification_rule.updated"
+	AuditRuleDeleted              = "notification_rule.deleted"
+	AuditSettingsUpdated          = "notification_settings.updated"
+	AuditRecipientAdded           = "project_recipient.added"
+	AuditRecipientRemoved         = "project_recipient.removed"
+	AuditRecipientUnsubscribed    = "project_recipient.unsubscribed"
+	AuditAlertTransitioned        = "alert.transitioned"
+	AuditAlertAssigned            = "alert.assigned"
+	AuditOrganizationCreated      = "organization.created"
+	AuditOrganizationUpdated      = "organization.updated"
+	AuditMemberRoleChanged        = "membership.role_changed"
+	AuditMemberRemoved            = "membership.removed"
+	AuditMemberInvited            = "invitation.created"
+	AuditInvitationAccepted       = "invitation.accepted"
+	AuditSSOProviderCreated       = "oidc_provider.created"
+	AuditSSOProviderUpdated       = "oidc_provider.updated"
+	AuditSSOProviderDeleted       = "oidc_provider.deleted"
+	AuditDomainAdded              = "organization_domain.added"
+	AuditDomainVerified           = "organization_domain.verified"
+	AuditDomainDeleted            = "organization_domain.deleted"
+	AuditSSOIdentityLinked        = "user_identity.linked"
+	AuditSubscriptionChanged      = "organization.subscription_changed"
+	AuditAPIKeyCreated            = "api_key.created"
+	AuditAPIKeyRevoked            = "api_key.revoked"
+	AuditMFAEnabled               = "user.mfa_enabled"
+	AuditMFADisabled              = "user.mfa_disabled"
+	AuditRecoveryCodesRegenerated = "user.recovery_codes_regenerated"
+	AuditPasswordReset            = "user.password_reset"
+)
+
+// AuditEvent records who did what, append-only: rows are never updated and
+// are only deleted by the retention policy. Changes maps each changed field
+// to {"before": ..., "after": ...} (before is null on creation, after on
+// deletion).
+type AuditEvent struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	OrganizationID *uint           `gorm:"index:idx_audit_events_org_created,priority:1" json:"organization_id"` // nil for account actions (API keys, 2FA)
+	ActorType      string          `gorm:"type:varchar(20);not null" json:"actor_type"`
+	ActorUserID    *uint           `gorm:"index" json:"actor_user_id"`
+	ActorAPIKeyID  *uint           `json:"actor_api_key_id"`
+	Action         string          `gorm:"type:varchar(100);not null;index" json:"action"`
+	TargetType     string          `gorm:"type:varchar(50);not null;index:idx_audit_events_target,priority:1" json:"target_type"`
+	TargetID       *uint           `gorm:"index:idx_audit_events_target,priority:2" json:"target_id"`
+	Changes        json.RawMessage `gorm:"type:jsonb" json:"changes"`
+	IP             string          `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent      string          `gorm:"type:varchar(512)" json:"user_agent"`
+	CreatedAt      time.Time       `gorm:"not null;index;index:idx_audit_events_org_created,priority:2" json:"created_at"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
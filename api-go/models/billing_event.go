@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// BillingEvent records a payment provider webhook event once processed, so
+// redelivered events are ignored
+type BillingEvent struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Provider       string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_billing_event_provider_id" json:"provider"`
+	EventID        string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_billing_event_provider_id" json:"event_id"`
+	Type           string    `gorm:"type:varchar(100);not null" json:"type"`
+	OrganizationID *uint     `gorm:"index" json:"organization_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (BillingEvent) TableName() string {
+	return "billing_events"
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// ProcessingState tracks where a detected change is in the alert pipeline
+type ProcessingState string
+
+const (
+	ProcessingPending    ProcessingState = "pending"
+	ProcessingInProgress ProcessingState = "processing"
+	ProcessingAlerted    ProcessingState = "alerted"
+	ProcessingSuppressed ProcessingState = "suppressed"
+	ProcessingFolded     ProcessingState = "folded" // merged into an existing alert
+	ProcessingFailed     ProcessingState = "failed"
+)
+
+// ChangeProcessing records the alerting outcome of a detected change. It is
+// kept apart from detected_changes, which belongs to the Python detector.
+// Workers claim rows with SELECT ... FOR UPDATE SKIP LOCKED.
+type ChangeProcessing struct {
+	ChangeID          uint            `gorm:"column:change_id;primaryKey;autoIncrement:false" json:"change_id"`
+	State             ProcessingState `gorm:"column:state;type:varchar(20);not null;default:pending;index" json:"state"`
+	Reason            string          `gorm:"column:reason;type:text" json:"reason"` // why suppressed / folded / failed
+	FoldedIntoAlertID *uint           `gorm:"column:folded_into_alert_id;index" json:"folded_into_alert_id"`
+	Attempts          int             `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	ClaimedBy         string          `gorm:"column:claimed_by;type:varchar(100)" json:"claimed_by"`
+	ClaimedAt         *time.Time      `gorm:"column:claimed_at" json:"claimed_at"`
+	NextAttemptAt     *time.Time      `gorm:"column:next_attempt_at" json:"next_attempt_at"` // failed changes wait until then
+	CreatedAt         time.Time       `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time       `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (ChangeProcessing) TableName() string {
+	return "change_processing"
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// DigestedAlert records that an alert went out in a user's digest. Each
+// user receiving an alert by digest gets it in exactly one digest.
+type DigestedAlert struct {
+	UserID     uint      `gorm:"column:user_id;primaryKey;autoIncrement:false" json:"user_id"`
+	AlertID    uint      `gorm:"column:alert_id;primaryKey;autoIncrement:false;index" json:"alert_id"`
+	DigestedAt time.Time `gorm:"column:digested_at;not null" json:"digested_at"`
+}
+
+func (DigestedAlert) TableName() string {
+	return "digested_alerts"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Invitation lets someone join an organization by accepting an emailed token
+type Invitation struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	OrganizationID uint       `gorm:"not null;index" json:"organization_id"`
+	Email          string     `gorm:"type:varchar(255);not null" json:"email"`
+	Role           OrgRole    `gorm:"type:varchar(20);not null" json:"role"`
+	Token          string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	InvitedByID    uint       `gorm:"not null" json:"invited_by_id"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (Invitation) TableName() string {
+	return "invitations"
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// OrgRole is the role of a member inside an organization
+type OrgRole string
+
+const (
+	RoleViewer OrgRole = "viewer" // read everything
+	RoleEditor OrgRole = "editor" // manage competitors, pages, rules and triage alerts
+	RoleAdmin  OrgRole = "admin"  // manage members, invitations and recipients
+	RoleOwner  OrgRole = "owner"  // everything, including owners
+)
+
+var orgRoleRank = map[OrgRole]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// Valid reports whether r is a known role
+func (r OrgRole) Valid() bool {
+	return orgRoleRank[r] > 0
+}
+
+// AtLeast reports whether r grants everything min grants
+func (r OrgRole) AtLeast(min OrgRole) bool {
+	return r.Valid() && orgRoleRank[r] >= orgRoleRank[min]
+}
+
+// Membership gives a user a role in an organization
+type Membership struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:uq_memberships_org_user" json:"organization_id"`
+	UserID         uint      `gorm:"not null;uniqueIndex:uq_memberships_org_user;index" json:"user_id"`
+	Role           OrgRole   `gorm:"type:varchar(20);not null" json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	User           User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (Membership) TableName() string {
+	return "memberships"
+}
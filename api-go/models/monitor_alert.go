@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// AlertTypeMonitorBroken is the alert type raised when a monitored page can
+// no longer be scraped reliably (as opposed to a competitor change).
+const AlertTypeMonitorBroken = "monitor_broken"
+
+// MonitorBrokenReason explains why a page is considered broken
+type MonitorBrokenReason string
+
+const (
+	ReasonConsecutiveFailures MonitorBrokenReason = "consecutive_failures"
+	ReasonHTTPError           MonitorBrokenReason = "http_error"
+	ReasonPriceMissing        MonitorBrokenReason = "price_missing"
+	ReasonSelectorNotMatched  MonitorBrokenReason = "selector_not_matched"
+)
+
+// MonitorAlert is a page-health alert. It lives apart from AlertLog so that
+// users can acknowledge broken monitors without touching competitor alerts.
+type MonitorAlert struct {
+	ID                  uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	PageID              uint                `gorm:"column:page_id;not null;index" json:"page_id"`
+	AlertType           string              `gorm:"column:alert_type;type:varchar(50);not null;default:monitor_broken" json:"alert_type"`
+	Reason              MonitorBrokenReason `gorm:"column:reason;type:varchar(50);not null" json:"reason"`
+	Severity            AlertSeverity       `gorm:"column:severity;type:varchar(20);not null" json:"severity"`
+	Detail              string              `gorm:"column:detail;type:text" json:"detail"`
+	StatusCode          int                 `gorm:"column:status_code" json:"status_code"`
+	ConsecutiveFailures int                 `gorm:"column:consecutive_failures" json:"consecutive_failures"`
+	Notified            bool                `gorm:"column:notified;default:false" json:"notified"`
+	Acknowledged        bool                `gorm:"column:acknowledged;default:false;index" json:"acknowledged"`
+	AcknowledgedAt      *time.Time          `gorm:"column:acknowledged_at" json:"acknowledged_at"`
+	AcknowledgedBy      *uint               `gorm:"column:acknowledged_by" json:"acknowledged_by"`
+	ResolvedAt          *time.Time          `gorm:"column:resolved_at" json:"resolved_at"`
+	CreatedAt           time.Time           `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (MonitorAlert) TableName() string {
+	return "monitor_alerts"
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// RuleScope is the level a notification rule is attached to
+type RuleScope string
+
+const (
+	RuleScopeProject    RuleScope = "project"
+	RuleScopeCompetitor RuleScope = "competitor"
+	RuleScopePage       RuleScope = "page"
+)
+
+// RuleAction is what happens when a notification rule matches
+type RuleAction string
+
+const (
+	RuleActionNotify   RuleAction = "notify"
+	RuleActionSuppress RuleAction = "suppress"
+)
+
+// Price direction conditions
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// NotificationRule overrides user_notification_settings for a project,
+// competitor or page. Empty conditions match anything.
+type NotificationRule struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string    `gorm:"column:name;type:varchar(255)" json:"name"`
+	ScopeType RuleScope `gorm:"column:scope_type;type:varchar(20);not null;index:idx_notification_rules_scope" json:"scope_type"`
+	ScopeID   uint      `gorm:"column:scope_id;not null;index:idx_notification_rules_scope" json:"scope_id"`
+	Priority  int       `gorm:"column:priority;not null" json:"priority"` // lower runs first within a scope
+	Enabled   bool      `gorm:"column:enabled;not null" json:"enabled"`
+	// Conditions
+	ChangeTypes string        `gorm:"column:change_types;type:varchar(255)" json:"change_types"` // comma-separated, e.g. price_increase,feature_removed
+	MinSeverity AlertSeverity `gorm:"column:min_severity;type:varchar(20)" json:"min_severity"`
+	MinPercent  *float64      `gorm:"column:min_percent" json:"min_percent"` // on |change_percent|
+	MaxPercent  *float64      `gorm:"column:max_percent" json:"max_percent"`
+	Direction   string        `gorm:"column:direction;type:varchar(10)" json:"direction"` // up, down
+	PlanName    string        `gorm:"column:plan_name;type:varchar(255)" json:"plan_name"`
+	Keyword     string        `gorm:"column:keyword;type:varchar(255)" json:"keyword"`
+	// Actions
+	Action     RuleAction `gorm:"column:action;type:varchar(20);not null;default:notify" json:"action"`
+	Channels   string     `gorm:"column:channels;type:varchar(100)" json:"channels"`       // comma-separated: email, webhook. Empty keeps the user settings
+	Recipients string     `gorm:"column:recipients;type:text" json:"recipients"`           // comma-separated emails. Empty notifies the page owner
+	WebhookURL string     `gorm:"column:webhook_url;type:varchar(512)" json:"webhook_url"` // overrides the user webhook
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (NotificationRule) TableName() string {
+	return "notification_rules"
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// OIDCProvider is an organization's identity provider (Google Workspace,
+// Microsoft Entra, Okta or any OpenID Connect issuer). Users log in through
+// /auth/oidc/:slug/login and join the organization with DefaultRole.
+type OIDCProvider struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;index" json:"organization_id"`
+	Slug           string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"slug"` // used in login URLs
+	Name           string    `gorm:"type:varchar(255);not null" json:"name"`
+	Issuer         string    `gorm:"type:text;not null" json:"issuer"`
+	ClientID       string    `gorm:"type:varchar(255);not null" json:"client_id"`
+	ClientSecret   string    `gorm:"type:text" json:"-"`
+	Scopes         string    `gorm:"type:text" json:"scopes"`          // space-separated, openid is always requested
+	AllowedDomains string    `gorm:"type:text" json:"allowed_domains"` // comma-separated email domains, empty = any
+	DefaultRole    OrgRole   `gorm:"type:varchar(20);not null" json:"default_role"`
+	Enabled        bool      `json:"enabled"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (OIDCProvider) TableName() string {
+	return "oidc_providers"
+}
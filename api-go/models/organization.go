@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Organization owns projects and groups the users working on them. Every
+// user gets a personal organization for the projects they create alone.
+// Its plan and subscription are kept in sync by the payment provider's
+// webhooks.
+type Organization struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	Name               string     `gorm:"type:varchar(255);not null" json:"name"`
+	Personal           bool       `gorm:"default:false" json:"personal"`
+	OwnerUserID        *uint      `gorm:"index" json:"owner_user_id"`                // set for personal organizations
+	RequireMFA         bool       `gorm:"not null;default:false" json:"require_mfa"` // members need 2FA enabled
+	Plan               string     `gorm:"type:varchar(20);not null;default:free" json:"plan"`
+	BillingCustomerID  *string    `gorm:"type:varchar(255);uniqueIndex" json:"-"`
+	SubscriptionID     string     `gorm:"type:varchar(255)" json:"-"`
+	SubscriptionStatus string     `gorm:"type:varchar(30)" json:"subscription_status"` // active, trialing, past_due, canceled...
+	BillingEventAt     *time.Time `json:"-"`                                           // newest webhook event applied
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+func (Organization) TableName() string {
+	return "organizations"
+}
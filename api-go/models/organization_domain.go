@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// OrganizationDomain is an email domain an organization proves it owns by
+// publishing VerificationToken in a DNS TXT record. SSO logins link existing
+// accounts by email only on a verified domain.
+type OrganizationDomain struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	OrganizationID    uint       `gorm:"not null;uniqueIndex:uq_organization_domains_org_domain" json:"organization_id"`
+	Domain            string     `gorm:"type:varchar(253);not null;uniqueIndex:uq_organization_domains_org_domain" json:"domain"`
+	VerificationToken string     `gorm:"type:varchar(64);not null" json:"verification_token"`
+	VerifiedAt        *time.Time `json:"verified_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+func (OrganizationDomain) TableName() string {
+	return "organization_domains"
+}
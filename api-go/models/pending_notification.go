@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Kinds of alert a queued notification belongs to
+const (
+	PendingKindAlert   = "alert"   // alert_logs
+	PendingKindMonitor = "monitor" // monitor_alerts
+)
+
+// PendingNotification is a notification held back by quiet hours. The
+// DeliveryWorker sends it once DeliverAfter has passed.
+type PendingNotification struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	AlertID          uint       `gorm:"column:alert_id;not null;index" json:"alert_id"`
+	AlertKind        string     `gorm:"column:alert_kind;type:varchar(20);not null;default:alert" json:"alert_kind"`
+	Channel          string     `gorm:"column:channel;type:varchar(20);not null" json:"channel"`      // email, webhook
+	Recipient        string     `gorm:"column:recipient;type:varchar(512);not null" json:"recipient"` // email address or webhook URL
+	UnsubscribeToken string     `gorm:"column:unsubscribe_token;type:varchar(64)" json:"-"`           // project recipients only
+	DeliverAfter     time.Time  `gorm:"column:deliver_after;not null;index" json:"deliver_after"`
+	DeliveredAt      *time.Time `gorm:"column:delivered_at" json:"delivered_at"`
+	ClaimedAt        *time.Time `gorm:"column:claimed_at" json:"-"` // set while a worker is sending it
+	Attempts         int        `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	LastError        string     `gorm:"column:last_error;type:text" json:"last_error"`
+	CreatedAt        time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (PendingNotification) TableName() string {
+	return "pending_notifications"
+}
@@ -0,0 +1,99 @@
+package models
+
+// Plan names
+const (
+	PlanFree     = "free"
+	PlanPro      = "pro"
+	PlanBusiness = "business"
+)
+
+// Unlimited is the value of a plan limit that does not apply
+const Unlimited = -1
+
+// Plan is a subscription tier and the limits it puts on an organization.
+// Counts (projects, competitors, pages) are checked against what exists;
+// monthly allowances are metered in usage_counters.
+type Plan struct {
+	Name                  string    `json:"name"`
+	MaxProjects           int       `json:"max_projects"`
+	MaxCompetitors        int       `json:"max_competitors"`
+	MaxPages              int       `json:"max_pages"`
+	MinFrequency          Frequency `json:"min_frequency"` // most frequent scrape schedule allowed
+	Channels              []string  `json:"channels"`      // notification channels allowed
+	AIAnalysesPerMonth    int       `json:"ai_analyses_per_month"`
+	ManualScrapesPerMonth int       `json:"manual_scrapes_per_month"` // /scrape runs
+}
+
+// Plans are the available plans by name
+var Plans = map[string]Plan{
+	PlanFree: {
+		Name:                  PlanFree,
+		MaxProjects:           1,
+		MaxCompetitors:        3,
+		MaxPages:              10,
+		MinFrequency:          FrequencyWeekly,
+		Channels:              []string{"email"},
+		AIAnalysesPerMonth:    20,
+		ManualScrapesPerMonth: 10,
+	},
+	PlanPro: {
+		Name:                  PlanPro,
+		MaxProjects:           5,
+		MaxCompetitors:        25,
+		MaxPages:              100,
+		MinFrequency:          FrequencyDaily,
+		Channels:              []string{"email", "webhook"},
+		AIAnalysesPerMonth:    500,
+		ManualScrapesPerMonth: 300,
+	},
+	PlanBusiness: {
+		Name:                  PlanBusiness,
+		MaxProjects:           Unlimited,
+		MaxCompetitors:        200,
+		MaxPages:              1000,
+		MinFrequency:          FrequencyDaily,
+		Channels:              []string{"email", "webhook"},
+		AIAnalysesPerMonth:    5000,
+		ManualScrapesPerMonth: 3000,
+	},
+}
+
+// PlanByName returns plan name, or the free plan for unknown names
+func PlanByName(name string) Plan {
+	if plan, ok := Plans[name]; ok {
+		return plan
+	}
+	return Plans[PlanFree]
+}
+
+// frequencyDays orders scrape frequencies, most frequent first
+var frequencyDays = map[Frequency]int{
+	FrequencyDaily:   1,
+	FrequencyWeekly:  7,
+	FrequencyMonthly: 30,
+}
+
+// AllowsFrequency reports whether pages may be scraped as often as f
+func (p Plan) AllowsFrequency(f Frequency) bool {
+	days, ok := frequencyDays[f]
+	return ok && days >= frequencyDays[p.MinFrequency]
+}
+
+// AllowsChannel reports whether alerts may be sent through channel
+func (p Plan) AllowsChannel(channel string) bool {
+	for _, c := range p.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// ClampFrequency returns f, or the plan's most frequent schedule if f is
+// more frequent than the plan allows
+func (p Plan) ClampFrequency(f Frequency) Frequency {
+	if p.AllowsFrequency(f) {
+		return f
+	}
+	return p.MinFrequency
+}
@@ -3,11 +3,12 @@ package models
 import "time"
 
 type Project struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null;index" json:"user_id"`
-	Name      string    `gorm:"not null" json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	User      User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	UserID         uint      `gorm:"not null;index" json:"user_id"` // creator
+	OrganizationID *uint     `gorm:"index" json:"organization_id"`
+	Name           string    `gorm:"not null" json:"name"`
+	CreatedAt      time.Time `json:"created_at"`
+	User           User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
 
 func (Project) TableName() string {
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ProjectRecipient is an extra audience for a project's alerts: a collaborator
+// (UserID set, who receives alerts according to their own notification
+// settings) or an external address such as a team alias.
+type ProjectRecipient struct {
+	ID               uint          `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProjectID        uint          `gorm:"column:project_id;not null;index" json:"project_id"`
+	UserID           *uint         `gorm:"column:user_id;index" json:"user_id"`
+	Email            string        `gorm:"column:email;type:varchar(255)" json:"email"` // external address or alias, unused for collaborators
+	Label            string        `gorm:"column:label;type:varchar(255)" json:"label"` // e.g. "Pricing team"
+	NotifyEmail      bool          `gorm:"column:notify_email;not null" json:"notify_email"`
+	WebhookURL       string        `gorm:"column:webhook_url;type:varchar(512)" json:"webhook_url"`
+	MinSeverity      AlertSeverity `gorm:"column:min_severity;type:varchar(20)" json:"min_severity"` // external recipients only
+	UnsubscribeToken string        `gorm:"column:unsubscribe_token;type:varchar(64);not null;uniqueIndex" json:"-"`
+	UnsubscribedAt   *time.Time    `gorm:"column:unsubscribed_at" json:"unsubscribed_at"`
+	CreatedAt        time.Time     `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	User             *User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (ProjectRecipient) TableName() string {
+	return "project_recipients"
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// RefreshToken is one link of a login session's rotation chain. Every
+// refresh consumes the token and issues the next one in the same family;
+// presenting a consumed token again revokes the whole family.
+type RefreshToken struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID        string     `gorm:"type:varchar(64);not null;index" json:"family_id"` // the session, also the "sid" claim
+	TokenHash       string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	AccessJTI       string     `gorm:"column:access_jti;type:varchar(64)" json:"-"` // access token issued with it
+	AccessExpiresAt time.Time  `json:"-"`
+	ExpiresAt       time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt          *time.Time `json:"used_at"`
+	RevokedAt       *time.Time `json:"revoked_at"`
+	UserAgent       string     `gorm:"type:varchar(255)" json:"user_agent"`
+	IP              string     `gorm:"type:varchar(64)" json:"ip"`
+	MFA             bool       `gorm:"column:mfa;not null;default:false" json:"mfa"` // the session passed 2FA
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
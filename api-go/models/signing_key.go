@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SigningKey is a key pair signing access tokens (RS256 or EdDSA). A new key
+// is published before it starts signing (SignFrom), and a replaced key stays
+// published until the tokens it signed have expired (ExpiresAt).
+type SigningKey struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	KID           string     `gorm:"column:kid;type:varchar(64);not null;uniqueIndex" json:"kid"`
+	Algorithm     string     `gorm:"type:varchar(10);not null" json:"algorithm"`
+	PrivateKeyEnc string     `gorm:"type:text;not null" json:"-"` // PKCS#8, encrypted
+	SignFrom      time.Time  `gorm:"not null" json:"sign_from"`
+	ExpiresAt     *time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
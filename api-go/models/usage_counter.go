@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Metered usage, counted per organization and calendar month
+const (
+	UsageAIAnalyses    = "ai_analyses"
+	UsageManualScrapes = "manual_scrapes"
+)
+
+// UsageCounter is how much of a monthly allowance an organization used.
+// Period is the month, as YYYY-MM (UTC).
+type UsageCounter struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_usage_org_metric_period" json:"organization_id"`
+	Metric         string    `gorm:"type:varchar(30);not null;uniqueIndex:idx_usage_org_metric_period" json:"metric"`
+	Period         string    `gorm:"type:varchar(7);not null;uniqueIndex:idx_usage_org_metric_period" json:"period"`
+	Count          int       `gorm:"not null;default:0" json:"count"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (UsageCounter) TableName() string {
+	return "usage_counters"
+}
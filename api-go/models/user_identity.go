@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to their account at an OIDC provider (the "sub"
+// claim), so later logins find the user even if their email changes
+type UserIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	ProviderID  uint       `gorm:"not null;uniqueIndex:uq_user_identities_provider_subject" json:"provider_id"`
+	Subject     string     `gorm:"type:varchar(255);not null;uniqueIndex:uq_user_identities_provider_subject" json:"subject"`
+	Email       string     `gorm:"type:varchar(255)" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// UserMFA is the TOTP second factor of a user. The secret is encrypted at
+// rest; EnabledAt stays nil until the user confirms a first code.
+type UserMFA struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	SecretEnc    string     `gorm:"type:text;not null" json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at"`
+	LastUsedStep int64      `json:"-"` // codes of this time step or older are rejected (replay)
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
@@ -2,19 +2,38 @@ package models
 
 import "time"
 
+// Digest frequencies
+const (
+	DigestImmediate = "immediate"
+	DigestDaily     = "daily"
+	DigestWeekly    = "weekly"
+)
+
 // UserNotificationSettings stores per-user alert preferences
 type UserNotificationSettings struct {
-	ID                   uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID               uint      `gorm:"column:user_id;not null;uniqueIndex" json:"user_id"`
-	NotifyEmail          bool      `gorm:"column:notify_email;default:true" json:"notify_email"`
-	NotifyWebhook        bool      `gorm:"column:notify_webhook;default:false" json:"notify_webhook"`
-	WebhookURL           string    `gorm:"column:webhook_url;type:varchar(512)" json:"webhook_url"`
-	MinimumChangePercent float64   `gorm:"column:minimum_change_percent;default:5" json:"minimum_change_percent"` // alert if |change_percent| >= this
-	AlertOnPriceChange   bool      `gorm:"column:alert_on_price_change;default:true" json:"alert_on_price_change"`
-	AlertOnFeatureChange bool      `gorm:"column:alert_on_feature_change;default:true" json:"alert_on_feature_change"`
-	AlertOnMessaging     bool      `gorm:"column:alert_on_messaging;default:false" json:"alert_on_messaging"`
-	CreatedAt            time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	UpdatedAt            time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	ID                   uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID               uint    `gorm:"column:user_id;not null;uniqueIndex" json:"user_id"`
+	NotifyEmail          bool    `gorm:"column:notify_email;default:true" json:"notify_email"`
+	NotifyWebhook        bool    `gorm:"column:notify_webhook;default:false" json:"notify_webhook"`
+	WebhookURL           string  `gorm:"column:webhook_url;type:varchar(512)" json:"webhook_url"`
+	MinimumChangePercent float64 `gorm:"column:minimum_change_percent;default:5" json:"minimum_change_percent"` // alert if |change_percent| >= this
+	AlertOnPriceChange   bool    `gorm:"column:alert_on_price_change;default:true" json:"alert_on_price_change"`
+	AlertOnFeatureChange bool    `gorm:"column:alert_on_feature_change;default:true" json:"alert_on_feature_change"`
+	AlertOnMessaging     bool    `gorm:"column:alert_on_messaging;default:false" json:"alert_on_messaging"`
+	AlertOnVisualChange  bool    `gorm:"column:alert_on_visual_change;default:true" json:"alert_on_visual_change"`
+	// Digest: non-critical alerts are batched into one email per period
+	DigestFrequency string     `gorm:"column:digest_frequency;type:varchar(20);default:immediate" json:"digest_frequency"` // immediate, daily, weekly
+	DigestHour      int        `gorm:"column:digest_hour;default:8" json:"digest_hour"`                                    // local hour, 0-23
+	DigestWeekday   int        `gorm:"column:digest_weekday;default:1" json:"digest_weekday"`                              // weekly only, 0 = Sunday
+	Timezone        string     `gorm:"column:timezone;type:varchar(64);default:UTC" json:"timezone"`                       // IANA name, e.g. Europe/Paris
+	LastDigestAt    *time.Time `gorm:"column:last_digest_at" json:"last_digest_at"`
+	// Quiet hours (local time): non-critical notifications wait until they end
+	QuietHoursEnabled        bool      `gorm:"column:quiet_hours_enabled;default:false" json:"quiet_hours_enabled"`
+	QuietHoursStart          string    `gorm:"column:quiet_hours_start;type:varchar(5);default:22:00" json:"quiet_hours_start"` // HH:MM
+	QuietHoursEnd            string    `gorm:"column:quiet_hours_end;type:varchar(5);default:07:00" json:"quiet_hours_end"`     // HH:MM
+	QuietHoursBypassCritical bool      `gorm:"column:quiet_hours_bypass_critical;default:true" json:"quiet_hours_bypass_critical"`
+	CreatedAt                time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt                time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
 
 	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rivalprice/api-go/middleware"
+)
+
+// Router registers routes with their authentication requirement. Every
+// route declares an Access, which is enforced first in its chain and
+// recorded in the access table so undeclared routes are caught at startup.
+type Router struct {
+	group  *gin.RouterGroup
+	auth   *middleware.Authenticator
+	limits []gin.HandlerFunc // after authentication, so API keys get their own budget
+	table  map[string]middleware.Access
+}
+
+func NewRouter(r *gin.Engine, auth *middleware.Authenticator, limits ...gin.HandlerFunc) *Router {
+	return &Router{
+		group:  &r.RouterGroup,
+		auth:   auth,
+		limits: limits,
+		table:  map[string]middleware.Access{},
+	}
+}
+
+// Group returns a router for the routes under relativePath. Group-wide
+// middleware is not accepted: it would run before authentication.
+func (rt *Router) Group(relativePath string) *Router {
+	return &Router{
+		group:  rt.group.Group(relativePath),
+		auth:   rt.auth,
+		limits: rt.limits,
+		table:  rt.table,
+	}
+}
+
+// WithLimit returns a router applying limit too (e.g. a stricter rate limit)
+func (rt *Router) WithLimit(limit gin.HandlerFunc) *Router {
+	sub := *rt
+	sub.limits = append(append([]gin.HandlerFunc{}, rt.limits...), limit)
+	return &sub
+}
+
+func (rt *Router) GET(relativePath string, access middleware.Access, handlers ...gin.HandlerFunc) {
+	rt.handle(http.MethodGet, relativePath, access, handlers)
+}
+
+func (rt *Router) POST(relativePath string, access middleware.Access, handlers ...gin.HandlerFunc) {
+	rt.handle(http.MethodPost, relativePath, access, handlers)
+}
+
+func (rt *Router) PUT(relativePath string, access middleware.Access, handlers ...gin.HandlerFunc) {
+	rt.handle(http.MethodPut, relativePath, access, handlers)
+}
+
+func (rt *Router) DELETE(relativePath string, access middleware.Access, handlers ...gin.HandlerFunc) {
+	rt.handle(http.MethodDelete, relativePath, access, handlers)
+}
+
+func (rt *Router) handle(method, relativePath string, access middleware.Access, handlers []gin.HandlerFunc) {
+	chain := make([]gin.HandlerFunc, 0, 1+len(rt.limits)+len(handlers))
+	chain = append(chain, rt.auth.Require(access))
+	chain = append(chain, rt.limits...)
+	chain = append(chain, handlers...)
+	rt.group.Handle(method, relativePath, chain...)
+
+	rt.table[routeKey(method, joinPath(rt.group.BasePath(), relativePath))] = access
+}
+
+// RouteAccess is one line of the access table
+type RouteAccess struct {
+	Method string
+	Path   string
+	Access middleware.Access
+}
+
+// AccessTable lists every route registered through the router with its
+// requirement, sorted by path then method
+func (rt *Router) AccessTable() []RouteAccess {
+	table := make([]RouteAccess, 0, len(rt.table))
+	for key, access := range rt.table {
+		method, routePath, _ := strings.Cut(key, " ")
+		table = append(table, RouteAccess{Method: method, Path: routePath, Access: access})
+	}
+	sort.Slice(table, func(i, j int) bool {
+		if table[i].Path != table[j].Path {
+			return table[i].Path < table[j].Path
+		}
+		return table[i].Method < table[j].Method
+	})
+	return table
+}
+
+// Verify checks that every route of r declared its access, i.e. none was
+// registered on the engine directly, bypassing authentication
+func (rt *Router) Verify(r *gin.Engine) error {
+	var undeclared []string
+	for _, route := range r.Routes() {
+		if _, ok := rt.table[routeKey(route.Method, route.Path)]; !ok {
+			undeclared = append(undeclared, route.Method+" "+route.Path)
+		}
+	}
+	if len(undeclared) > 0 {
+		sort.Strings(undeclared)
+		return fmt.Errorf("routes without an access declaration: %s", strings.Join(undeclared, ", "))
+	}
+	return nil
+}
+
+func routeKey(method, fullPath string) string {
+	return method + " " + fullPath
+}
+
+// joinPath joins paths the way gin does, keeping a trailing slash
+func joinPath(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	joined := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(joined, "/") {
+		return joined + "/"
+	}
+	return joined
+}
@@ -1,75 +1,285 @@
 package routes
 
 import (
+	"fmt"
+
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"github.com/rivalprice/api-go/controllers"
 	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/models"
 	"github.com/rivalprice/api-go/services"
 	"gorm.io/gorm"
 )
 
-func SetupRoutes(r *gin.Engine, db *gorm.DB, jwtSecret string) {
+// orgDirectory finds the organization owning the resources in requests, and
+// the caller's role in it (see services.OrganizationService)
+type orgDirectory interface {
+	middleware.OrgAuthorizer
+	OrgExists(id uint) (uint, error)
+	OrgOfProject(id uint) (uint, error)
+	OrgOfCompetitor(id uint) (uint, error)
+	OrgOfPage(id uint) (uint, error)
+	OrgOfSnapshot(id uint) (uint, error)
+	OrgOfAlert(id uint) (uint, error)
+	OrgOfMonitorAlert(id uint) (uint, error)
+	OrgOfRule(id uint) (uint, error)
+	OrgOfScope(scopeType models.RuleScope, scopeID uint) (uint, error)
+}
+
+// SetupRoutes registers the API routes on api, each with its access
+// requirement
+func SetupRoutes(api *Router, db *gorm.DB, redisClient *redis.Client, jwtSecret string, signingKeys *services.SigningKeyService) {
+	setupRoutes(api, db, redisClient, jwtSecret, signingKeys, services.NewOrganizationService(db))
+}
+
+// setupRoutes registers the API routes with role checks answered by org
+func setupRoutes(api *Router, db *gorm.DB, redisClient *redis.Client, jwtSecret string, signingKeys *services.SigningKeyService, org orgDirectory) {
 	// Initialize services
 	userService := services.NewUserService(db)
 	projectService := services.NewProjectService(db)
 	competitorService := services.NewCompetitorService(db)
 	monitoredPageService := services.NewMonitoredPageService(db)
+	alertService := services.NewAlertService(db)
+	snapshotService := services.NewSnapshotService(db)
+	alertLogService := services.NewAlertLogService(db)
+	notificationRuleService := services.NewNotificationRuleService(db)
+	preferenceService := services.NewPreferenceService(db)
+	projectRecipientService := services.NewProjectRecipientService(db)
+	organizationService := services.NewOrganizationService(db)
+	apiKeyService := services.NewAPIKeyService(db)
+	sessionService := services.NewSessionService(db, redisClient, middleware.NewAccessTokenSigner(signingKeys))
+	ssoService := services.NewSSOService(db, redisClient)
+	accountService := services.NewAccountService(db, redisClient, jwtSecret)
+	mfaService := services.NewMFAService(db, redisClient, jwtSecret)
+	quotaService := services.NewQuotaService(db)
+	billingService := services.NewBillingService(db, services.NewBillingProvider())
+	auditService := services.NewAuditService(db)
+	scrapingService := services.NewScrapingService(db, redisClient)
 
 	// Initialize controllers
-	userController := controllers.NewUserController(userService)
-	projectController := controllers.NewProjectController(projectService)
+	projectController := controllers.NewProjectController(projectService, organizationService)
 	competitorController := controllers.NewCompetitorController(competitorService)
 	monitoredPageController := controllers.NewMonitoredPageController(monitoredPageService)
-	authController := controllers.NewAuthController(userService, jwtSecret)
+	authController := controllers.NewAuthController(userService, sessionService, ssoService, accountService, mfaService)
+	monitorAlertController := controllers.NewMonitorAlertController(alertService)
+	snapshotController := controllers.NewSnapshotController(snapshotService)
+	alertController := controllers.NewAlertController(alertLogService)
+	notificationRuleController := controllers.NewNotificationRuleController(notificationRuleService)
+	notificationSettingsController := controllers.NewNotificationSettingsController(preferenceService)
+	projectRecipientController := controllers.NewProjectRecipientController(projectRecipientService)
+	organizationController := controllers.NewOrganizationController(organizationService, projectService)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	ssoProviderController := controllers.NewSSOProviderController(ssoService)
+	mfaController := controllers.NewMFAController(mfaService, userService)
+	jwksController := controllers.NewJWKSController(signingKeys)
+	usageController := controllers.NewUsageController(quotaService)
+	billingController := controllers.NewBillingController(billingService, userService)
+	auditController := controllers.NewAuditController(auditService)
+	scrapeController := controllers.NewScrapeController(scrapingService)
 
-	// Public routes (no auth required)
-	public := r.Group("/api/v1")
-	{
-		// Auth
-		auth := public.Group("/auth")
-		{
-			auth.POST("/login", authController.Login)
-			auth.POST("/register", authController.Register)
+	// Role checks: the organization is resolved from the resource in the URL
+	// or the body, then the caller's membership role is compared to the minimum
+	role := func(min models.OrgRole, resolve middleware.OrgResolver) gin.HandlerFunc {
+		return middleware.RequireOrgRole(org, min, resolve)
+	}
+	ruleScope := middleware.FromBody(func(body map[string]interface{}) (uint, error) {
+		scopeType, _ := body["scope_type"].(string)
+		scopeID, _ := body["scope_id"].(float64)
+		switch models.RuleScope(scopeType) {
+		case models.RuleScopeProject, models.RuleScopeCompetitor, models.RuleScopePage:
+		default:
+			return 0, fmt.Errorf("%w: invalid scope_type %q", middleware.ErrBadRequest, scopeType)
+		}
+		if scopeID <= 0 {
+			return 0, fmt.Errorf("%w: scope_id is required", middleware.ErrBadRequest)
 		}
+		return org.OrgOfScope(models.RuleScope(scopeType), uint(scopeID))
+	})
+
+	limiter := middleware.NewRateLimiter(redisClient)
+
+	// Access requirements. API key scopes: GET needs read:*, other methods
+	// write:* (JWT sessions have all scopes)
+	public := middleware.Public()
+	authenticated := middleware.Authenticated()
+	session := middleware.Session()
+	projectScopes := middleware.ScopedByMethod(models.ScopeReadProjects, models.ScopeWriteProjects)
+	pageScopes := middleware.ScopedByMethod(models.ScopeReadPages, models.ScopeWritePages)
+	alertScopes := middleware.ScopedByMethod(models.ScopeReadAlerts, models.ScopeWriteAlerts)
+	auditAccess := middleware.Scoped(models.ScopeReadAudit)
+	scrapeAccess := middleware.Scoped(models.ScopeScrapeTrigger)
+
+	// Public keys verifying access tokens (for other services)
+	api.GET("/.well-known/jwks.json", public, jwksController.JWKS)
+
+	// Scrapes on demand count against the organization's manual scrapes, with
+	// a stricter rate limit (10 req/min)
+	scrape := api.Group("/scrape").WithLimit(limiter.Limit(middleware.PolicyStrict))
+	{
+		scrape.POST("/page/:id", scrapeAccess, role(models.RoleEditor, middleware.FromParam("id", org.OrgOfPage)), scrapeController.ScrapePage)
+		scrape.POST("/project/:id", scrapeAccess, role(models.RoleEditor, middleware.FromParam("id", org.OrgOfProject)), scrapeController.ScrapeProject)
 	}
 
-	// Protected routes (auth required)
-	v1 := r.Group("/api/v1")
-	v1.Use(middleware.JWTAuthMiddleware(jwtSecret))
+	v1 := api.Group("/api/v1")
 	{
-		// Auth (protected)
-		v1.GET("/auth/me", authController.Me)
+		// Auth. Credential checks and emails get a tighter budget per client.
+		auth := v1.Group("/auth")
+		credentials := auth.WithLimit(limiter.Limit(middleware.PolicyCredentials))
+		{
+			credentials.POST("/login", public, authController.Login)
+			credentials.POST("/register", public, authController.Register)
+			auth.POST("/refresh", public, authController.Refresh)
+			credentials.POST("/mfa/verify", public, authController.VerifyMFA) // authenticated by its mfa_token
+			auth.GET("/me", authenticated, authController.Me)
+			auth.POST("/logout", session, authController.Logout)
+			auth.POST("/logout_all", session, authController.LogoutAll)
+
+			// Email verification and password reset
+			auth.GET("/verify_email/:token", public, authController.VerifyEmail)
+			credentials.POST("/resend_verification", session, authController.ResendVerification)
+			credentials.POST("/forgot_password", public, authController.ForgotPassword)
+			credentials.POST("/reset_password", public, authController.ResetPassword)
+
+			// Single sign-on through an organization's OIDC provider
+			auth.GET("/oidc/:slug/login", public, authController.OIDCLogin)
+			auth.GET("/oidc/:slug/callback", public, authController.OIDCCallback)
+			auth.POST("/oidc/links/:token/confirm", session, authController.ConfirmOIDCLink)
+		}
+
+		// Two-factor authentication (TOTP) of the current user
+		mfa := v1.Group("/auth/mfa")
+		{
+			mfa.GET("", session, mfaController.GetStatus)
+			mfa.POST("/enroll", session, mfaController.Enroll)
+			mfa.POST("/activate", session, mfaController.Activate)
+			mfa.POST("/disable", session, mfaController.Disable)
+			mfa.POST("/recovery_codes", session, mfaController.RegenerateRecoveryCodes)
+		}
+
+		// Subscription events from the payment provider, authenticated by their
+		// signature
+		v1.POST("/billing/webhook", public, billingController.Webhook)
 
-		// Users
-		users := v1.Group("/users")
+		// Unsubscribe links in alert emails
+		v1.GET("/unsubscribe/:token", public, projectRecipientController.UnsubscribePage)
+		v1.POST("/unsubscribe/:token", public, projectRecipientController.Unsubscribe)
+
+		// Organizations, members and invitations
+		organizations := v1.Group("/organizations")
+		{
+			organizations.POST("", projectScopes, organizationController.CreateOrganization)
+			organizations.GET("", projectScopes, organizationController.ListOrganizations)
+			organizations.GET("/:id", projectScopes, role(models.RoleViewer, middleware.FromParam("id", org.OrgExists)), organizationController.GetOrganization)
+			organizations.PUT("/:id", session, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), organizationController.UpdateOrganization)
+			organizations.GET("/:id/members", projectScopes, role(models.RoleViewer, middleware.FromParam("id", org.OrgExists)), organizationController.ListMembers)
+			organizations.PUT("/:id/members/:user_id", projectScopes, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), organizationController.UpdateMember)
+			organizations.DELETE("/:id/members/:user_id", projectScopes, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), organizationController.RemoveMember)
+			organizations.GET("/:id/invitations", projectScopes, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), organizationController.ListInvitations)
+			organizations.POST("/:id/invitations", projectScopes, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), organizationController.Invite)
+			organizations.POST("/:id/projects", projectScopes, role(models.RoleEditor, middleware.FromParam("id", org.OrgExists)), organizationController.CreateProject)
+			organizations.GET("/:id/usage", projectScopes, role(models.RoleViewer, middleware.FromParam("id", org.OrgExists)), usageController.GetUsage)
+			organizations.POST("/:id/billing/checkout", session, role(models.RoleOwner, middleware.FromParam("id", org.OrgExists)), billingController.Checkout)
+			organizations.POST("/:id/billing/portal", session, role(models.RoleOwner, middleware.FromParam("id", org.OrgExists)), billingController.Portal)
+
+			// SSO providers hold client secrets: admins, from a user session only
+			organizations.GET("/:id/sso_providers", session, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), ssoProviderController.ListProviders)
+			organizations.POST("/:id/sso_providers", session, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), ssoProviderController.CreateProvider)
+			organizations.PUT("/:id/sso_providers/:provider_id", session, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), ssoProviderController.UpdateProvider)
+			organizations.DELETE("/:id/sso_providers/:provider_id", session, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), ssoProviderController.DeleteProvider)
+
+			// Email domains the organization owns, verified by DNS: SSO links
+			// existing accounts by email only on these
+			organizations.GET("/:id/domains", session, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), ssoProviderController.ListDomains)
+			organizations.POST("/:id/domains", session, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), ssoProviderController.AddDomain)
+			organizations.POST("/:id/domains/:domain_id/verify", session, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), ssoProviderController.VerifyDomain)
+			organizations.DELETE("/:id/domains/:domain_id", session, role(models.RoleAdmin, middleware.FromParam("id", org.OrgExists)), ssoProviderController.DeleteDomain)
+		}
+		v1.POST("/invitations/:token/accept", session, organizationController.AcceptInvitation)
+
+		// API keys (managed from a user session only)
+		apiKeys := v1.Group("/api_keys")
 		{
-			users.POST("", userController.CreateUser)
-			users.GET("", userController.ListUsers)
-			users.GET("/:id", userController.GetUser)
+			apiKeys.POST("", session, apiKeyController.CreateAPIKey)
+			apiKeys.GET("", session, apiKeyController.ListAPIKeys)
+			apiKeys.DELETE("/:id", session, apiKeyController.RevokeAPIKey)
 		}
 
 		// Projects
 		projects := v1.Group("/projects")
 		{
-			projects.POST("", projectController.CreateProject)
-			projects.GET("", projectController.ListProjects)
-			projects.GET("/:id", projectController.GetProject)
+			projects.POST("", projectScopes, projectController.CreateProject)
+			projects.GET("", projectScopes, projectController.ListProjects)
+			projects.GET("/:id", projectScopes, role(models.RoleViewer, middleware.FromParam("id", org.OrgOfProject)), projectController.GetProject)
+			projects.GET("/:id/recipients", projectScopes, role(models.RoleAdmin, middleware.FromParam("id", org.OrgOfProject)), projectRecipientController.ListRecipients)
+			projects.POST("/:id/recipients", projectScopes, role(models.RoleAdmin, middleware.FromParam("id", org.OrgOfProject)), projectRecipientController.AddRecipient)
+			projects.DELETE("/:id/recipients/:recipient_id", projectScopes, role(models.RoleAdmin, middleware.FromParam("id", org.OrgOfProject)), projectRecipientController.RemoveRecipient)
 		}
 
 		// Competitors
 		competitors := v1.Group("/competitors")
 		{
-			competitors.POST("", competitorController.CreateCompetitor)
-			competitors.GET("", competitorController.ListCompetitors)
-			competitors.GET("/:id", competitorController.GetCompetitor)
+			competitors.POST("", projectScopes, role(models.RoleEditor, middleware.FromBodyField("project_id", org.OrgOfProject)), competitorController.CreateCompetitor)
+			competitors.GET("", projectScopes, competitorController.ListCompetitors)
+			competitors.GET("/:id", projectScopes, role(models.RoleViewer, middleware.FromParam("id", org.OrgOfCompetitor)), competitorController.GetCompetitor)
 		}
 
 		// Monitored Pages
 		monitoredPages := v1.Group("/monitored_pages")
 		{
-			monitoredPages.POST("", monitoredPageController.CreateMonitoredPage)
-			monitoredPages.GET("", monitoredPageController.ListMonitoredPages)
-			monitoredPages.GET("/:id", monitoredPageController.GetMonitoredPage)
+			monitoredPages.POST("", pageScopes, middleware.RequireVerifiedEmail(accountService), role(models.RoleEditor, middleware.FromBodyField("competitor_id", org.OrgOfCompetitor)), monitoredPageController.CreateMonitoredPage)
+			monitoredPages.GET("", pageScopes, monitoredPageController.ListMonitoredPages)
+			monitoredPages.GET("/:id", pageScopes, role(models.RoleViewer, middleware.FromParam("id", org.OrgOfPage)), monitoredPageController.GetMonitoredPage)
+			monitoredPages.PUT("/:id/ignore_rules", pageScopes, role(models.RoleEditor, middleware.FromParam("id", org.OrgOfPage)), monitoredPageController.UpdateIgnoreRules)
+			monitoredPages.PUT("/:id/alert_windows", pageScopes, role(models.RoleEditor, middleware.FromParam("id", org.OrgOfPage)), monitoredPageController.UpdateAlertWindows)
+		}
+
+		// Snapshots (both snapshots must belong to the same page, checked by the service)
+		snapshots := v1.Group("/snapshots")
+		{
+			snapshots.GET("/:id/diff/:other", pageScopes, role(models.RoleViewer, middleware.FromParam("id", org.OrgOfSnapshot)), snapshotController.DiffSnapshots)
+		}
+
+		// Alerts (triage lifecycle)
+		alerts := v1.Group("/alerts")
+		{
+			alerts.GET("", alertScopes, alertController.ListAlerts)
+			alerts.GET("/:id", alertScopes, role(models.RoleViewer, middleware.FromParam("id", org.OrgOfAlert)), alertController.GetAlert)
+			alerts.POST("/:id/state", alertScopes, role(models.RoleEditor, middleware.FromParam("id", org.OrgOfAlert)), alertController.TransitionAlert)
+			alerts.PUT("/:id/assignee", alertScopes, role(models.RoleEditor, middleware.FromParam("id", org.OrgOfAlert)), alertController.AssignAlert)
+			alerts.GET("/:id/comments", alertScopes, role(models.RoleViewer, middleware.FromParam("id", org.OrgOfAlert)), alertController.ListComments)
+			alerts.POST("/:id/comments", alertScopes, role(models.RoleEditor, middleware.FromParam("id", org.OrgOfAlert)), alertController.AddComment)
+			alerts.GET("/:id/activity", alertScopes, role(models.RoleViewer, middleware.FromParam("id", org.OrgOfAlert)), alertController.ListActivity)
+		}
+
+		// Notification settings of the current user (channels, digest, timezone)
+		v1.GET("/notification_settings", alertScopes, notificationSettingsController.GetSettings)
+		v1.PUT("/notification_settings", alertScopes, notificationSettingsController.UpdateSettings)
+
+		// Notification rules (per project / competitor / page)
+		notificationRules := v1.Group("/notification_rules")
+		{
+			notificationRules.POST("", alertScopes, role(models.RoleEditor, ruleScope), notificationRuleController.CreateRule)
+			notificationRules.GET("", alertScopes, notificationRuleController.ListRules)
+			notificationRules.GET("/:id", alertScopes, role(models.RoleViewer, middleware.FromParam("id", org.OrgOfRule)), notificationRuleController.GetRule)
+			// The new scope may belong to another organization: check both
+			notificationRules.PUT("/:id", alertScopes, role(models.RoleEditor, middleware.FromParam("id", org.OrgOfRule)), role(models.RoleEditor, ruleScope), notificationRuleController.UpdateRule)
+			notificationRules.DELETE("/:id", alertScopes, role(models.RoleEditor, middleware.FromParam("id", org.OrgOfRule)), notificationRuleController.DeleteRule)
+		}
+
+		// Monitor health alerts (broken pages / extractors)
+		monitorAlerts := v1.Group("/monitor_alerts")
+		{
+			monitorAlerts.GET("", alertScopes, monitorAlertController.ListMonitorAlerts)
+			monitorAlerts.POST("/:id/acknowledge", alertScopes, role(models.RoleEditor, middleware.FromParam("id", org.OrgOfMonitorAlert)), monitorAlertController.AcknowledgeMonitorAlert)
+		}
+
+		// Audit log: an organization's (admins), or the caller's account actions
+		audit := v1.Group("/audit")
+		{
+			audit.GET("", auditAccess, role(models.RoleAdmin, middleware.FromQuery("organization_id", org.OrgExists)), auditController.ListEvents)
+			audit.GET("/export", auditAccess, role(models.RoleAdmin, middleware.FromQuery("organization_id", org.OrgExists)), auditController.ExportEvents)
+			audit.GET("/account", auditAccess, auditController.ListAccountEvents)
 		}
 	}
 }
@@ -0,0 +1,379 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/services"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testKeys signs access tokens with a shared secret
+type testKeys struct{}
+
+var testSecret = []byte("routes-test-secret")
+
+func (testKeys) Sign(claims jwt.Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testSecret)
+}
+func (testKeys) Keyfunc(*jwt.Token) (interface{}, error) { return testSecret, nil }
+
+type testDenylist struct{}
+
+func (testDenylist) IsRevoked(string, uint, time.Time) (bool, error) { return false, nil }
+
+// testAPIKeys accepts any key and grants it the scopes set by the test, on
+// behalf of user (1 by default)
+type testAPIKeys struct {
+	scopes []string
+	user   uint
+	mfa    bool // created from a session that passed 2FA
+}
+
+func (k *testAPIKeys) Authenticate(key, ip string) (*models.APIKey, error) {
+	userID := k.user
+	if userID == 0 {
+		userID = 1
+	}
+	return &models.APIKey{ID: 1, UserID: userID, Scopes: strings.Join(k.scopes, ","), MFA: k.mfa}, nil
+}
+
+// testOrgs puts every resource in organization 1, where users have the
+// roles of members
+type testOrgs struct {
+	members    map[uint]models.OrgRole
+	requireMFA bool
+	resolved   int // organization lookups since the last reset
+}
+
+func (o *testOrgs) MemberRole(orgID, userID uint) (models.OrgRole, error) {
+	role, ok := o.members[userID]
+	if orgID != 1 || !ok {
+		return "", errors.New("membership not found")
+	}
+	return role, nil
+}
+
+func (o *testOrgs) MFASatisfied(orgID, userID uint, verified bool) (bool, error) {
+	return !o.requireMFA || verified, nil
+}
+
+func (o *testOrgs) lookup(id uint) (uint, error) {
+	o.resolved++
+	return 1, nil
+}
+
+func (o *testOrgs) OrgExists(id uint) (uint, error)         { return o.lookup(id) }
+func (o *testOrgs) OrgOfProject(id uint) (uint, error)      { return o.lookup(id) }
+func (o *testOrgs) OrgOfCompetitor(id uint) (uint, error)   { return o.lookup(id) }
+func (o *testOrgs) OrgOfPage(id uint) (uint, error)         { return o.lookup(id) }
+func (o *testOrgs) OrgOfSnapshot(id uint) (uint, error)     { return o.lookup(id) }
+func (o *testOrgs) OrgOfAlert(id uint) (uint, error)        { return o.lookup(id) }
+func (o *testOrgs) OrgOfMonitorAlert(id uint) (uint, error) { return o.lookup(id) }
+func (o *testOrgs) OrgOfRule(id uint) (uint, error)         { return o.lookup(id) }
+func (o *testOrgs) OrgOfScope(scopeType models.RuleScope, scopeID uint) (uint, error) {
+	return o.lookup(scopeID)
+}
+
+// Users of the tests: a member of organization 1 and someone else's member
+const (
+	viewerID   uint = 2
+	outsiderID uint = 3
+)
+
+type testServer struct {
+	engine  *gin.Engine
+	api     *Router
+	apiKeys *testAPIKeys
+	orgs    *testOrgs
+}
+
+// newTestServer registers every route on a fresh engine, as cmd/main.go
+// does. Nothing connects to Postgres or Redis until a handler runs a query,
+// and then fails at once.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("BILLING_PROVIDER", "")
+
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 dbname=test sslmode=disable connect_timeout=1"), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redisClient := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { redisClient.Close() })
+
+	srv := &testServer{
+		engine:  gin.New(),
+		apiKeys: &testAPIKeys{},
+		orgs:    &testOrgs{members: map[uint]models.OrgRole{viewerID: models.RoleViewer}},
+	}
+	srv.engine.Use(gin.Recovery())
+	srv.api = NewRouter(srv.engine, middleware.NewAuthenticator(testKeys{}, srv.apiKeys, testDenylist{}))
+	SetupSystemRoutes(srv.api, db, redisClient, "test")
+	setupRoutes(srv.api, db, redisClient, "test-secret", services.NewSigningKeyService(db, "test-secret"), srv.orgs)
+	return srv
+}
+
+var routeParam = regexp.MustCompile(`:[a-z_]+`)
+
+// serve sends a request on routePath with every parameter set to 1, and a
+// body and query naming resource 1 for routes resolving them
+func (srv *testServer) serve(method, routePath string, header http.Header) *httptest.ResponseRecorder {
+	target := routeParam.ReplaceAllString(routePath, "1") + "?organization_id=1"
+	body := `{"project_id": 1, "competitor_id": 1, "scope_type": "project", "scope_id": 1}`
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	srv.engine.ServeHTTP(w, req)
+	return w
+}
+
+func apiKeyHeader() http.Header {
+	return http.Header{"X-Api-Key": {models.APIKeyPrefix + "test"}}
+}
+
+func sessionHeader(t *testing.T, userID uint) http.Header {
+	t.Helper()
+	return sessionHeaderMFA(t, userID, false)
+}
+
+// sessionHeaderMFA authenticates as a session that passed 2FA, or not
+func sessionHeaderMFA(t *testing.T, userID uint, mfa bool) http.Header {
+	t.Helper()
+	token, _, err := middleware.GenerateToken(testKeys{}, userID, "user@example.com", "session-1", mfa, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// scopesExcept returns every API key scope but scope
+func scopesExcept(scope string) []string {
+	var scopes []string
+	for _, s := range models.AllScopes {
+		if s != scope {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+func TestEveryRouteDeclaresItsAccess(t *testing.T) {
+	srv := newTestServer(t)
+	if err := srv.api.Verify(srv.engine); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(srv.api.AccessTable()), len(srv.engine.Routes()); got != want {
+		t.Errorf("AccessTable() has %d routes, the engine %d", got, want)
+	}
+}
+
+// Only these routes may be called without credentials
+func TestPublicRoutes(t *testing.T) {
+	want := map[string]bool{
+		"GET /health":                          true,
+		"GET /db/status":                       true,
+		"GET /redis/status":                    true,
+		"GET /migrate":                         true,
+		"GET /.well-known/jwks.json":           true,
+		"POST /api/v1/auth/login":              true,
+		"POST /api/v1/auth/register":           true,
+		"POST /api/v1/auth/refresh":            true,
+		"POST /api/v1/auth/mfa/verify":         true,
+		"GET /api/v1/auth/verify_email/:token": true,
+		"POST /api/v1/auth/forgot_password":    true,
+		"POST /api/v1/auth/reset_password":     true,
+		"GET /api/v1/auth/oidc/:slug/login":    true,
+		"GET /api/v1/auth/oidc/:slug/callback": true,
+		"POST /api/v1/billing/webhook":         true,
+		"GET /api/v1/unsubscribe/:token":       true,
+		"POST /api/v1/unsubscribe/:token":      true,
+	}
+
+	srv := newTestServer(t)
+	for _, route := range srv.api.AccessTable() {
+		key := route.Method + " " + route.Path
+		if route.Access.Level == middleware.AccessPublic && !want[key] {
+			t.Errorf("%s is public", key)
+		}
+		delete(want, key)
+	}
+	for key := range want {
+		t.Errorf("%s is not registered", key)
+	}
+}
+
+func TestRouteAccess(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, route := range srv.api.AccessTable() {
+		route := route
+		if route.Access.Level == middleware.AccessPublic {
+			continue
+		}
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			if w := srv.serve(route.Method, route.Path, nil); w.Code != http.StatusUnauthorized {
+				t.Errorf("without credentials: status %d, want 401", w.Code)
+			}
+
+			switch route.Access.Level {
+			case middleware.AccessSession:
+				srv.apiKeys.scopes = models.AllScopes
+				if w := srv.serve(route.Method, route.Path, apiKeyHeader()); w.Code != http.StatusForbidden {
+					t.Errorf("API key on a session route: status %d, want 403", w.Code)
+				}
+			case middleware.AccessScoped:
+				scope := route.Access.WriteScope
+				if route.Method == http.MethodGet {
+					scope = route.Access.ReadScope
+				}
+				srv.apiKeys.scopes = scopesExcept(scope)
+				if w := srv.serve(route.Method, route.Path, apiKeyHeader()); w.Code != http.StatusForbidden {
+					t.Errorf("API key without %s: status %d, want 403", scope, w.Code)
+				}
+			}
+		})
+	}
+}
+
+// Routes on resources owned by users rather than organizations
+var userOwnedRoutes = map[string]bool{
+	"DELETE /api/v1/api_keys/:id": true,
+}
+
+// Every route acting on an organization's resource checks the caller's role
+// in it. Members of other organizations get 404, so they can't tell whether
+// the resource exists.
+func TestOrgScopedRoutesRejectOtherOrganizations(t *testing.T) {
+	srv := newTestServer(t)
+	outsider := sessionHeader(t, outsiderID)
+
+	for _, route := range srv.api.AccessTable() {
+		route := route
+		if route.Access.Level == middleware.AccessPublic {
+			continue
+		}
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			srv.orgs.resolved = 0
+			w := srv.serve(route.Method, route.Path, outsider)
+			if srv.orgs.resolved == 0 {
+				if strings.Contains(route.Path, ":id") && !userOwnedRoutes[route.Method+" "+route.Path] {
+					t.Errorf("does not check the role of the caller in the organization of :id")
+				}
+				return
+			}
+			if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "organization not found") {
+				t.Errorf("member of another organization: status %d %s, want 404", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// Viewers can read but not change anything nor spend the organization's
+// quotas
+func TestViewerCannotWrite(t *testing.T) {
+	srv := newTestServer(t)
+	viewer := sessionHeader(t, viewerID)
+
+	for _, route := range []string{
+		"POST /scrape/page/:id",
+		"POST /scrape/project/:id",
+		"PUT /api/v1/organizations/:id",
+		"POST /api/v1/organizations/:id/invitations",
+		"PUT /api/v1/monitored_pages/:id/ignore_rules",
+		"POST /api/v1/alerts/:id/state",
+		"POST /api/v1/competitors",
+		"POST /api/v1/notification_rules",
+		"POST /api/v1/organizations/:id/billing/checkout",
+		"GET /api/v1/audit",
+	} {
+		method, routePath, _ := strings.Cut(route, " ")
+		if w := srv.serve(method, routePath, viewer); w.Code != http.StatusForbidden {
+			t.Errorf("%s as a viewer: status %d %s, want 403", route, w.Code, w.Body.String())
+		}
+	}
+}
+
+// Organizations requiring 2FA admit sessions opened with a second factor
+// and API keys created from one, not members who merely enrolled
+func TestOrgRequiringMFA(t *testing.T) {
+	srv := newTestServer(t)
+	srv.orgs.requireMFA = true
+	srv.apiKeys.scopes = models.AllScopes
+	srv.apiKeys.user = viewerID
+	const route = "/api/v1/organizations/:id/usage"
+
+	tests := []struct {
+		name   string
+		header http.Header
+		admit  bool
+	}{
+		{name: "session without 2FA", header: sessionHeaderMFA(t, viewerID, false)},
+		{name: "session with 2FA", header: sessionHeaderMFA(t, viewerID, true), admit: true},
+		{name: "API key created without 2FA", header: apiKeyHeader()},
+	}
+	for _, tt := range tests {
+		w := srv.serve(http.MethodGet, route, tt.header)
+		rejected := w.Code == http.StatusForbidden && strings.Contains(w.Body.String(), `"mfa_required":true`)
+		if rejected == tt.admit {
+			t.Errorf("%s: status %d %s, admitted %v", tt.name, w.Code, w.Body.String(), tt.admit)
+		}
+	}
+
+	srv.apiKeys.mfa = true
+	if w := srv.serve(http.MethodGet, route, apiKeyHeader()); w.Code == http.StatusForbidden {
+		t.Errorf("API key created with 2FA: status %d %s, want admitted", w.Code, w.Body.String())
+	}
+}
+
+// Routes that hand out credentials or account control must not be reachable
+// with an API key
+func TestSensitiveRoutesRequireASession(t *testing.T) {
+	srv := newTestServer(t)
+	access := map[string]middleware.Access{}
+	for _, route := range srv.api.AccessTable() {
+		access[route.Method+" "+route.Path] = route.Access
+	}
+
+	for _, route := range []string{
+		"POST /api/v1/api_keys",
+		"GET /api/v1/api_keys",
+		"DELETE /api/v1/api_keys/:id",
+		"POST /api/v1/auth/mfa/disable",
+		"PUT /api/v1/organizations/:id",
+		"POST /api/v1/organizations/:id/sso_providers",
+		"POST /api/v1/organizations/:id/domains/:domain_id/verify",
+		"POST /api/v1/organizations/:id/billing/checkout",
+		"POST /api/v1/invitations/:token/accept",
+	} {
+		a, ok := access[route]
+		if !ok {
+			t.Errorf("%s is not registered", route)
+			continue
+		}
+		if a.Level != middleware.AccessSession {
+			t.Errorf("%s is %s, want session", route, a)
+		}
+	}
+
+	for key := range access {
+		if strings.HasPrefix(strings.SplitN(key, " ", 2)[1], "/api/v1/users") {
+			t.Errorf("%s is registered: user accounts are not managed through the API", key)
+		}
+	}
+}
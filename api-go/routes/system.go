@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/rivalprice/api-go/middleware"
+	"github.com/rivalprice/migrations"
+	"gorm.io/gorm"
+)
+
+// SetupSystemRoutes registers the public health and status endpoints, outside
+// /api/v1
+func SetupSystemRoutes(api *Router, db *gorm.DB, redisClient *redis.Client, environment string) {
+	public := middleware.Public()
+
+	// Health check endpoint (public)
+	api.GET("/health", public, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "ok",
+			"env":    environment,
+		})
+	})
+
+	// Database status endpoint (public)
+	api.GET("/db/status", public, func(c *gin.Context) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := sqlDB.Ping(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "disconnected", "database": "error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "connected", "database": "postgres"})
+	})
+
+	// Redis status endpoint (public)
+	api.GET("/redis/status", public, func(c *gin.Context) {
+		_, err := redisClient.Ping(context.Background()).Result()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "disconnected", "redis": "error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "connected", "redis": "ok"})
+	})
+
+	// Migration status endpoint (public)
+	api.GET("/migrate", public, func(c *gin.Context) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		migrator, err := migrations.New(sqlDB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		statuses, err := migrator.Status(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		version, err := migrator.Version(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		status := "migrated"
+		list := make([]gin.H, 0, len(statuses))
+		for _, s := range statuses {
+			if s.Pending() {
+				status = "pending"
+			}
+			list = append(list, gin.H{
+				"version":    s.Version,
+				"name":       s.Name,
+				"applied_at": s.AppliedAt,
+				"modified":   s.Modified,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status":     status,
+			"version":    version,
+			"latest":     migrator.Latest(),
+			"migrations": list,
+		})
+	})
+}
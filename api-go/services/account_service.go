@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	passwordResetTTL     = time.Hour
+
+	// At most accountEmailLimit emails per address and purpose per window
+	accountEmailLimit    = 3
+	accountEmailWindow   = time.Hour
+	accountRateKeyPrefix = "account:ratelimit:" // + purpose:sha256(email)
+)
+
+// ErrAccountRateLimited is returned when too many verification or reset
+// emails were requested for one address. It is returned whether or not an
+// account exists, so it reveals nothing.
+var ErrAccountRateLimited = errors.New("too many requests for this email, try again later")
+
+var errInvalidAccountToken = errors.New("invalid or expired token")
+
+// AccountService handles email verification and password reset. Tokens are
+// random, single-use and expiring; only their HMAC (keyed with the server
+// secret and bound to their purpose) is stored.
+type AccountService struct {
+	db       *gorm.DB
+	redis    *redis.Client
+	emailSvc *EmailService
+	secret   []byte
+}
+
+func NewAccountService(db *gorm.DB, redisClient *redis.Client, secret string) *AccountService {
+	return &AccountService{
+		db:       db,
+		redis:    redisClient,
+		emailSvc: NewEmailService(),
+		secret:   []byte(secret),
+	}
+}
+
+// EmailVerificationPath is the API path that verifies an email address
+func EmailVerificationPath(token string) string {
+	return "/api/v1/auth/verify_email/" + token
+}
+
+// PasswordResetPath is the API path a new password is posted to
+const PasswordResetPath = "/api/v1/auth/reset_password"
+
+// IsEmailVerified reports whether userID confirmed their email address
+func (s *AccountService) IsEmailVerified(userID uint) (bool, error) {
+	var user models.User
+	if err := s.db.Select("id", "email_verified_at").First(&user, userID).Error; err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt != nil, nil
+}
+
+// SendVerification emails user a link confirming their address
+func (s *AccountService) SendVerification(user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return errors.New("email already verified")
+	}
+	if err := s.allow(models.TokenVerifyEmail, user.Email); err != nil {
+		return err
+	}
+
+	token, err := s.issue(user.ID, models.TokenVerifyEmail, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	return s.emailSvc.SendEmailVerification(user.Email, EmailVerificationPath(token))
+}
+
+// VerifyEmail consumes a verification token and marks the address verified
+func (s *AccountService) VerifyEmail(token string) (*models.User, error) {
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		row, err := s.consume(tx, models.TokenVerifyEmail, token)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, row.UserID).Error; err != nil {
+			return errInvalidAccountToken
+		}
+		if user.EmailVerifiedAt == nil {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+			return tx.Model(&user).Update("email_verified_at", now).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RequestPasswordReset emails a reset token if an account uses email.
+// Unknown addresses are silently ignored so callers cannot probe accounts:
+// the token is issued and sent in the background, so the call takes the same
+// time either way.
+func (s *AccountService) RequestPasswordReset(email string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if err := s.allow(models.TokenResetPassword, email); err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := s.db.Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	go func() {
+		token, err := s.issue(user.ID, models.TokenResetPassword, passwordResetTTL)
+		if err != nil {
+			log.Printf("❌ AccountService: failed to issue a password reset token for user %d: %v", user.ID, err)
+			return
+		}
+		if err := s.emailSvc.SendPasswordReset(user.Email, token, PasswordResetPath); err != nil {
+			log.Printf("⚠️  AccountService: password reset email to user %d failed: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// ResetPassword consumes a reset token and sets the new password. Receiving
+// the email proves the address, so it is marked verified too. Returns the
+// user ID so the caller can end the user's sessions.
+func (s *AccountService) ResetPassword(actor Actor, token, password string) (uint, error) {
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	var userID uint
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		row, err := s.consume(tx, models.TokenResetPassword, token)
+		if err != nil {
+			return err
+		}
+		userID = row.UserID
+
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"hashed_password":   hashed,
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+		}).Error; err != nil {
+			return err
+		}
+		// Other reset links sent meanwhile die with this one
+		if err := tx.Model(&models.AccountToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, models.TokenResetPassword).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		// The reset link proves who the anonymous caller is
+		actor.UserID = userID
+		return recordAudit(tx, actor, auditRecord{Action: models.AuditPasswordReset, TargetID: userID})
+	})
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("🔑 Password reset for user %d", userID)
+	return userID, nil
+}
+
+// issue creates a token for userID, replacing the unused ones of the same
+// purpose, and returns its plaintext
+func (s *AccountService) issue(userID uint, purpose string, ttl time.Duration) (string, error) {
+	plaintext, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AccountToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AccountToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: s.hashToken(purpose, plaintext),
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", errors.New("failed to create token")
+	}
+	return plaintext, nil
+}
+
+// consume marks a valid token as used and returns it
+func (s *AccountService) consume(tx *gorm.DB, purpose, plaintext string) (*models.AccountToken, error) {
+	if plaintext == "" {
+		return nil, errInvalidAccountToken
+	}
+
+	var row models.AccountToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", s.hashToken(purpose, plaintext), purpose).
+		First(&row).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidAccountToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if row.UsedAt != nil || now.After(row.ExpiresAt) {
+		return nil, errInvalidAccountToken
+	}
+	if err := tx.Model(&row).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+func (s *AccountService) hashToken(purpose, plaintext string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(purpose + ":" + plaintext))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// allow counts an email sent to address for purpose in Redis. If Redis is
+// down the email is allowed: failing closed would lock users out.
+func (s *AccountService) allow(purpose, address string) error {
+	if s.redis == nil {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(address)))
+	key := accountRateKeyPrefix + purpose + ":" + hex.EncodeToString(sum[:])
+
+	ctx := context.Background()
+	n, err := s.redis.Incr(ctx, key).Result()
+	if err != nil {
+		log.Printf("⚠️  AccountService: rate limit unavailable: %v", err)
+		return nil
+	}
+	if n == 1 {
+		s.redis.Expire(ctx, key, accountEmailWindow)
+	}
+	if n > accountEmailLimit {
+		return ErrAccountRateLimited
+	}
+	return nil
+}
@@ -44,7 +44,7 @@ type openAIResponse struct {
 type AIInsight struct {
 	Summary        string
 	Recommendation string
-	ImpactLevel    int    // 1-10 score
+	ImpactLevel    int // 1-10 score
 	Model          string
 }
 
@@ -191,6 +191,10 @@ func (c *AIClient) ruleBasedAnalysis(changeType, oldPrice, newPrice string, chan
 		summary = "Competitor updated their messaging and positioning"
 		recommendation = "Review your own messaging for competitive differentiation"
 		impactLevel = 5
+	case strings.Contains(changeType, "visual_change"):
+		summary = fmt.Sprintf("Competitor page layout changed (%.1f%% of the page differs)", changePercent)
+		recommendation = "Open the diff image to spot new badges, plans or hidden offers"
+		impactLevel = 4
 	default:
 		summary = fmt.Sprintf("Competitor made changes: %s", changeType)
 		recommendation = "Monitor closely for further changes"
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAlertCooldown    = 60 * time.Minute
+	defaultAlertMergeWindow = 24 * time.Hour
+)
+
+// foldWindows are the noise-control windows of a page
+type foldWindows struct {
+	cooldown    time.Duration // no new alert on the page this long after the last one
+	mergeWindow time.Duration // repeats and reverts this close to an alert are folded into it
+}
+
+// foldDecision says which open alert a change is folded into, and why
+type foldDecision struct {
+	alert    *models.AlertLog
+	reason   string
+	flapping bool
+	extends  bool // the change carries the alert's price move further
+}
+
+// pageFoldWindows returns the page's windows, falling back to
+// ALERT_COOLDOWN_MINUTES and ALERT_MERGE_WINDOW_MINUTES
+func (s *AlertService) pageFoldWindows(pageID int) foldWindows {
+	windows := foldWindows{
+		cooldown:    envMinutes("ALERT_COOLDOWN_MINUTES", defaultAlertCooldown),
+		mergeWindow: envMinutes("ALERT_MERGE_WINDOW_MINUTES", defaultAlertMergeWindow),
+	}
+
+	var page models.MonitoredPage
+	if err := s.db.Select("id", "alert_cooldown_minutes", "alert_merge_window_minutes").First(&page, pageID).Error; err != nil {
+		return windows
+	}
+	if page.AlertCooldownMinutes != nil {
+		windows.cooldown = time.Duration(*page.AlertCooldownMinutes) * time.Minute
+	}
+	if page.AlertMergeWindowMinutes != nil {
+		windows.mergeWindow = time.Duration(*page.AlertMergeWindowMinutes) * time.Minute
+	}
+	return windows
+}
+
+// recentOpenAlerts returns the page's alerts still open within the longest
+// window, most recent first
+func (s *AlertService) recentOpenAlerts(pageID int, windows foldWindows, now time.Time) ([]models.AlertLog, error) {
+	horizon := windows.mergeWindow
+	if windows.cooldown > horizon {
+		horizon = windows.cooldown
+	}
+
+	var alerts []models.AlertLog
+	err := s.db.Where("page_id = ? AND state NOT IN ?", pageID, []models.AlertState{models.AlertStateResolved, models.AlertStateDismissed}).
+		Where("COALESCE(last_occurrence_at, created_at) >= ?", now.Add(-horizon)).
+		Order("created_at DESC").
+		Limit(50).
+		Find(&alerts).Error
+	return alerts, err
+}
+
+// decideFold checks, in order:
+//  1. flapping: the change reverts or repeats a price move of an open alert
+//  2. merge: the same change type fired on the page within the merge window
+//  3. cool-down: the same change type fired on the page within the cool-down
+//
+// Windows 1 and 2 slide from the alert's last occurrence, so a price that keeps
+// oscillating stays folded. Critical changes bypass the cool-down only. A price
+// change is merged or cooled down only when it starts at the alert's new price:
+// the alert then shows the whole move, any other move gets an alert of its own.
+func decideFold(change *models.DetectedChange, severity models.AlertSeverity, recent []models.AlertLog, windows foldWindows, now time.Time) *foldDecision {
+	for i := range recent {
+		a := &recent[i]
+		if now.Sub(lastOccurrence(a)) > windows.mergeWindow || change.NewPrice == "" || a.OldPrice == "" {
+			continue
+		}
+		if change.NewPrice == a.OldPrice {
+			return &foldDecision{alert: a, reason: fmt.Sprintf("price reverted to %s", a.OldPrice), flapping: true}
+		}
+		if change.OldPrice == a.OldPrice && change.NewPrice == a.NewPrice {
+			return &foldDecision{alert: a, reason: fmt.Sprintf("price moved again %s → %s", a.OldPrice, a.NewPrice), flapping: true}
+		}
+	}
+
+	for i := range recent {
+		a := &recent[i]
+		if a.AlertType == change.ChangeType && now.Sub(lastOccurrence(a)) <= windows.mergeWindow && continuesMove(change, a) {
+			return &foldDecision{alert: a, reason: fmt.Sprintf("repeated %s within merge window", change.ChangeType), extends: true}
+		}
+	}
+
+	if severity == models.SeverityCritical {
+		return nil
+	}
+	for i := range recent {
+		a := &recent[i]
+		if a.AlertType == change.ChangeType && now.Sub(a.CreatedAt) <= windows.cooldown && continuesMove(change, a) {
+			return &foldDecision{alert: a, reason: fmt.Sprintf("%s in alert cool-down", change.ChangeType), extends: true}
+		}
+	}
+	return nil
+}
+
+// continuesMove reports whether a change picks up where the alert's price
+// move ended. Changes without a price always do.
+func continuesMove(change *models.DetectedChange, a *models.AlertLog) bool {
+	return change.NewPrice == "" || change.OldPrice == a.NewPrice
+}
+
+// extendedMove returns the alert's price move once the change is added to it,
+// with the severity of the whole move (never lowered)
+func extendedMove(a *models.AlertLog, change *models.DetectedChange) (newPrice string, percent float64, severity models.AlertSeverity) {
+	percent = ((1+a.ChangePercent/100)*(1+change.ChangePercent/100) - 1) * 100
+	percent = math.Round(percent*100) / 100
+
+	severity = a.Severity
+	if s := severityFromChange(a.AlertType, percent); severityRank[s] > severityRank[severity] {
+		severity = s
+	}
+	return change.NewPrice, percent, severity
+}
+
+func lastOccurrence(a *models.AlertLog) time.Time {
+	if a.LastOccurrenceAt != nil {
+		return *a.LastOccurrenceAt
+	}
+	return a.CreatedAt
+}
+
+// foldChange records a change as an extra occurrence of an existing alert.
+// The change is kept in change_processing, linked to the alert.
+func (s *AlertService) foldChange(change *models.DetectedChange, fold *foldDecision, now time.Time) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"occurrences":        gorm.Expr("occurrences + 1"),
+			"last_occurrence_at": now,
+		}
+		if fold.flapping {
+			updates["flapping"] = true
+		}
+		if fold.extends && change.NewPrice != "" {
+			// Keep the alert's price move current instead of the first step
+			newPrice, percent, severity := extendedMove(fold.alert, change)
+			updates["new_price"] = newPrice
+			updates["change_percent"] = percent
+			updates["severity"] = severity
+		}
+		if err := tx.Model(fold.alert).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.ChangeProcessing{}).
+			Where("change_id = ?", change.ID).
+			Updates(map[string]interface{}{
+				"state":                models.ProcessingFolded,
+				"reason":               fold.reason,
+				"folded_into_alert_id": fold.alert.ID,
+			}).Error; err != nil {
+			return err
+		}
+
+		return recordAlertActivity(tx, fold.alert.ID, nil, models.AlertActionFolded, "", strconv.Itoa(int(change.ID)))
+	})
+	if err != nil {
+		log.Printf("❌ AlertService: failed to fold change %d into alert %d: %v", change.ID, fold.alert.ID, err)
+		s.finishProcessing(change.ID, models.ProcessingFailed, err.Error())
+		return err
+	}
+
+	log.Printf("ℹ️  AlertService: change %d folded into alert %d — %s", change.ID, fold.alert.ID, fold.reason)
+	return nil
+}
+
+// envMinutes reads a duration in minutes from the environment
+func envMinutes(key string, fallback time.Duration) time.Duration {
+	if raw := os.Getenv(key); raw != "" {
+		if minutes, err := strconv.Atoi(raw); err == nil && minutes >= 0 {
+			return time.Duration(minutes) * time.Minute
+		}
+	}
+	return fallback
+}
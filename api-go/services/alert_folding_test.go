@@ -0,0 +1,120 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+)
+
+func TestDecideFold(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	windows := foldWindows{cooldown: time.Hour, mergeWindow: 24 * time.Hour}
+
+	priceAlert := models.AlertLog{ID: 1, AlertType: "price_increase", OldPrice: "$10", NewPrice: "$12", ChangePercent: 20, CreatedAt: now.Add(-2 * time.Hour)}
+	featureAlert := models.AlertLog{ID: 2, AlertType: "feature_added", CreatedAt: now.Add(-10 * time.Minute)}
+
+	tests := []struct {
+		name     string
+		change   models.DetectedChange
+		severity models.AlertSeverity
+		recent   []models.AlertLog
+		want     uint // 0 when the change opens a new alert
+		flapping bool
+		extends  bool
+	}{
+		{
+			name:     "price reverted",
+			change:   models.DetectedChange{ChangeType: "price_decrease", OldPrice: "$12", NewPrice: "$10"},
+			recent:   []models.AlertLog{priceAlert},
+			want:     1,
+			flapping: true,
+		},
+		{
+			name:     "same move again",
+			change:   models.DetectedChange{ChangeType: "price_increase", OldPrice: "$10", NewPrice: "$12"},
+			recent:   []models.AlertLog{priceAlert},
+			want:     1,
+			flapping: true,
+		},
+		{
+			name:    "price move continued",
+			change:  models.DetectedChange{ChangeType: "price_increase", OldPrice: "$12", NewPrice: "$15"},
+			recent:  []models.AlertLog{priceAlert},
+			want:    1,
+			extends: true,
+		},
+		{
+			name:   "unrelated price move of the same type",
+			change: models.DetectedChange{ChangeType: "price_increase", OldPrice: "$20", NewPrice: "$25"},
+			recent: []models.AlertLog{priceAlert},
+		},
+		{
+			name:    "repeated change without a price",
+			change:  models.DetectedChange{ChangeType: "feature_added"},
+			recent:  []models.AlertLog{featureAlert},
+			want:    2,
+			extends: true,
+		},
+		{
+			name:   "cool-down ignores other change types",
+			change: models.DetectedChange{ChangeType: "messaging_change"},
+			recent: []models.AlertLog{featureAlert},
+		},
+		{
+			name:    "cool-down on the same change type",
+			change:  models.DetectedChange{ChangeType: "messaging_change"},
+			recent:  []models.AlertLog{featureAlert, {ID: 3, AlertType: "messaging_change", CreatedAt: now.Add(-30 * time.Minute), LastOccurrenceAt: timePtr(now.Add(-25 * time.Hour))}},
+			want:    3,
+			extends: true,
+		},
+		{
+			name:     "critical changes bypass the cool-down",
+			change:   models.DetectedChange{ChangeType: "messaging_change"},
+			severity: models.SeverityCritical,
+			recent:   []models.AlertLog{{ID: 3, AlertType: "messaging_change", CreatedAt: now.Add(-30 * time.Minute), LastOccurrenceAt: timePtr(now.Add(-25 * time.Hour))}},
+		},
+		{
+			name:   "merge window elapsed",
+			change: models.DetectedChange{ChangeType: "feature_added"},
+			recent: []models.AlertLog{{ID: 4, AlertType: "feature_added", CreatedAt: now.Add(-48 * time.Hour)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			severity := tt.severity
+			if severity == "" {
+				severity = models.SeverityMedium
+			}
+			got := decideFold(&tt.change, severity, tt.recent, windows, now)
+			switch {
+			case tt.want == 0 && got != nil:
+				t.Fatalf("decideFold() folds into alert %d (%s), want a new alert", got.alert.ID, got.reason)
+			case tt.want == 0:
+				return
+			case got == nil:
+				t.Fatalf("decideFold() = new alert, want alert %d", tt.want)
+			case got.alert.ID != tt.want || got.flapping != tt.flapping || got.extends != tt.extends:
+				t.Fatalf("decideFold() = alert %d, flapping %v, extends %v, want alert %d, %v, %v",
+					got.alert.ID, got.flapping, got.extends, tt.want, tt.flapping, tt.extends)
+			}
+		})
+	}
+}
+
+func TestExtendedMove(t *testing.T) {
+	alert := &models.AlertLog{AlertType: "price_increase", Severity: models.SeverityHigh, NewPrice: "$12", ChangePercent: 20}
+
+	newPrice, percent, severity := extendedMove(alert, &models.DetectedChange{NewPrice: "$15", ChangePercent: 25})
+	if newPrice != "$15" || percent != 50 || severity != models.SeverityCritical {
+		t.Errorf("extendedMove() = %s, %v, %s, want $15, 50, critical", newPrice, percent, severity)
+	}
+
+	// A move back down keeps the severity the alert already had
+	_, percent, severity = extendedMove(alert, &models.DetectedChange{NewPrice: "$11", ChangePercent: -8.33})
+	if percent != 10 || severity != models.SeverityHigh {
+		t.Errorf("extendedMove() = %v, %s, want 10, high", percent, severity)
+	}
+}
+
+func timePtr(t time.Time) *time.Time { return &t }
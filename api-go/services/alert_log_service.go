@@ -0,0 +1,297 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"gorm.io/gorm"
+)
+
+// alertTransitions lists the states an alert may move to from each state
+var alertTransitions = map[models.AlertState][]models.AlertState{
+	models.AlertStateNew:          {models.AlertStateAcknowledged, models.AlertStateSnoozed, models.AlertStateResolved, models.AlertStateDismissed},
+	models.AlertStateAcknowledged: {models.AlertStateSnoozed, models.AlertStateResolved, models.AlertStateDismissed},
+	models.AlertStateSnoozed:      {models.AlertStateNew, models.AlertStateAcknowledged, models.AlertStateResolved, models.AlertStateDismissed},
+	models.AlertStateResolved:     {models.AlertStateNew},
+	models.AlertStateDismissed:    {models.AlertStateNew},
+}
+
+// AlertFilter narrows the alerts list; zero values mean "any"
+type AlertFilter struct {
+	State      models.AlertState
+	Severity   models.AlertSeverity
+	PageID     int
+	AssigneeID uint
+	VisibleTo  Viewer // only alerts of the organizations this user can see
+}
+
+// AlertLogService handles alert triage: states, assignment and comments.
+// Every change is recorded in alert_activities.
+type AlertLogService struct {
+	db *gorm.DB
+}
+
+func NewAlertLogService(db *gorm.DB) *AlertLogService {
+	return &AlertLogService{db: db}
+}
+
+func (s *AlertLogService) GetAlertByID(id uint) (*models.AlertLog, error) {
+	var alert models.AlertLog
+	if err := s.db.First(&alert, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("alert not found")
+		}
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func (s *AlertLogService) ListAlertsPaginated(filter AlertFilter, offset, limit int) ([]models.AlertLog, int64, error) {
+	query := s.db.Model(&models.AlertLog{})
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+	if filter.Severity != "" {
+		query = query.Where("severity = ?", filter.Severity)
+	}
+	if filter.PageID != 0 {
+		query = query.Where("page_id = ?", filter.PageID)
+	}
+	if filter.AssigneeID != 0 {
+		query = query.Where("assignee_id = ?", filter.AssigneeID)
+	}
+	if filter.VisibleTo.UserID != 0 {
+		query = query.Where("page_id IN (?)", visiblePageIDs(s.db, filter.VisibleTo))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var alerts []models.AlertLog
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&alerts).Error; err != nil {
+		return nil, 0, err
+	}
+	return alerts, total, nil
+}
+
+// IsValidAlertState reports whether state is a known alert state
+func IsValidAlertState(state models.AlertState) bool {
+	_, ok := alertTransitions[state]
+	return ok
+}
+
+// TransitionAlert moves an alert to a new state. snoozedUntil is required for
+// the snoozed state and ignored otherwise.
+func (s *AlertLogService) TransitionAlert(actor Actor, id uint, to models.AlertState, snoozedUntil *time.Time) (*models.AlertLog, error) {
+	if !IsValidAlertState(to) {
+		return nil, fmt.Errorf("unknown alert state %q", to)
+	}
+	if to == models.AlertStateSnoozed {
+		if snoozedUntil == nil || !snoozedUntil.After(time.Now()) {
+			return nil, errors.New("snoozed_until must be in the future")
+		}
+	} else {
+		snoozedUntil = nil
+	}
+
+	var alert models.AlertLog
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&alert, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("alert not found")
+			}
+			return err
+		}
+
+		from := alert.State
+		if !canTransition(from, to) {
+			return fmt.Errorf("cannot move alert from %s to %s", from, to)
+		}
+		before := alert
+
+		now := time.Now()
+		if err := tx.Model(&alert).Updates(map[string]interface{}{
+			"state":            to,
+			"snoozed_until":    snoozedUntil,
+			"state_changed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		toValue := string(to)
+		if snoozedUntil != nil {
+			toValue += " until " + snoozedUntil.UTC().Format(time.RFC3339)
+		}
+		if err := recordAlertActivity(tx, alert.ID, &actor.UserID, models.AlertActionStateChanged, string(from), toValue); err != nil {
+			return err
+		}
+		return s.audit(tx, actor, models.AuditAlertTransitioned, &alert, before)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// AssignAlert sets (or clears, with a nil assignee) the analyst in charge,
+// who must be a member of the project's organization
+func (s *AlertLogService) AssignAlert(actor Actor, id uint, assigneeID *uint) (*models.AlertLog, error) {
+	if assigneeID != nil {
+		var user models.User
+		if err := s.db.First(&user, *assigneeID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("assignee not found")
+			}
+			return nil, err
+		}
+	}
+
+	var alert models.AlertLog
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&alert, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("alert not found")
+			}
+			return err
+		}
+		if assigneeID != nil {
+			orgs := NewOrganizationService(tx)
+			orgID, err := orgs.OrgOfPage(uint(alert.PageID))
+			if err != nil {
+				return err
+			}
+			if err := orgs.RequireMember(orgID, *assigneeID); err != nil {
+				return err
+			}
+		}
+
+		before := alert
+		from := formatUserRef(alert.AssigneeID)
+		if err := tx.Model(&alert).Update("assignee_id", assigneeID).Error; err != nil {
+			return err
+		}
+		if err := recordAlertActivity(tx, alert.ID, &actor.UserID, models.AlertActionAssigned, from, formatUserRef(assigneeID)); err != nil {
+			return err
+		}
+		return s.audit(tx, actor, models.AuditAlertAssigned, &alert, before)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// AddComment appends a comment to the alert thread
+func (s *AlertLogService) AddComment(alertID, userID uint, body string) (*models.AlertComment, error) {
+	if _, err := s.GetAlertByID(alertID); err != nil {
+		return nil, err
+	}
+
+	comment := models.AlertComment{AlertID: alertID, UserID: userID, Body: body}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return errors.New("failed to add comment")
+		}
+		return recordAlertActivity(tx, alertID, &userID, models.AlertActionCommented, "", strconv.Itoa(int(comment.ID)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (s *AlertLogService) GetComments(alertID uint) ([]models.AlertComment, error) {
+	var comments []models.AlertComment
+	if err := s.db.Preload("User").Where("alert_id = ?", alertID).Order("created_at ASC").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (s *AlertLogService) GetActivity(alertID uint) ([]models.AlertActivity, error) {
+	var activity []models.AlertActivity
+	if err := s.db.Where("alert_id = ?", alertID).Order("created_at ASC").Find(&activity).Error; err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
+
+// GetFoldedChanges returns the detected changes folded into an alert
+func (s *AlertLogService) GetFoldedChanges(alertID uint) ([]models.ChangeProcessing, error) {
+	var folded []models.ChangeProcessing
+	if err := s.db.Where("folded_into_alert_id = ?", alertID).Order("change_id ASC").Find(&folded).Error; err != nil {
+		return nil, err
+	}
+	return folded, nil
+}
+
+// WakeSnoozedAlerts puts alerts whose snooze expired back in the new state
+func (s *AlertLogService) WakeSnoozedAlerts() (int, error) {
+	var alerts []models.AlertLog
+	now := time.Now()
+	if err := s.db.Where("state = ? AND snoozed_until <= ?", models.AlertStateSnoozed, now).Find(&alerts).Error; err != nil {
+		return 0, err
+	}
+
+	for _, alert := range alerts {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&alert).Updates(map[string]interface{}{
+				"state":            models.AlertStateNew,
+				"snoozed_until":    nil,
+				"state_changed_at": now,
+			}).Error; err != nil {
+				return err
+			}
+			return recordAlertActivity(tx, alert.ID, nil, models.AlertActionStateChanged, string(models.AlertStateSnoozed), string(models.AlertStateNew))
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(alerts), nil
+}
+
+func canTransition(from, to models.AlertState) bool {
+	if from == "" {
+		from = models.AlertStateNew
+	}
+	for _, allowed := range alertTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// audit records a triage action on alert, in its page's organization
+func (s *AlertLogService) audit(tx *gorm.DB, actor Actor, action string, alert *models.AlertLog, before models.AlertLog) error {
+	return recordAudit(tx, actor, auditRecord{
+		OrganizationID: auditOrg(NewOrganizationService(tx).OrgOfAlert, alert.ID),
+		Action:         action,
+		TargetID:       alert.ID,
+		Before:         before,
+		After:          alert,
+	})
+}
+
+func recordAlertActivity(tx *gorm.DB, alertID uint, actorID *uint, action, from, to string) error {
+	return tx.Create(&models.AlertActivity{
+		AlertID:   alertID,
+		ActorID:   actorID,
+		Action:    action,
+		FromValue: from,
+		ToValue:   to,
+	}).Error
+}
+
+func formatUserRef(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return "user:" + strconv.Itoa(int(*id))
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rivalprice/api-go/models"
+)
+
+func TestCanTransition(t *testing.T) {
+	states := []models.AlertState{
+		models.AlertStateNew, models.AlertStateAcknowledged, models.AlertStateSnoozed,
+		models.AlertStateResolved, models.AlertStateDismissed,
+	}
+	// Spelled out rather than read from alertTransitions, so a change to the
+	// lifecycle has to change this table too
+	allowed := map[models.AlertState]string{
+		models.AlertStateNew:          "acknowledged snoozed resolved dismissed",
+		models.AlertStateAcknowledged: "snoozed resolved dismissed",
+		models.AlertStateSnoozed:      "new acknowledged resolved dismissed",
+		models.AlertStateResolved:     "new",
+		models.AlertStateDismissed:    "new",
+		"":                            "acknowledged snoozed resolved dismissed", // rows from before triage
+	}
+	for from, targets := range allowed {
+		for _, to := range states {
+			want := strings.Contains(" "+targets+" ", " "+string(to)+" ")
+			if got := canTransition(from, to); got != want {
+				t.Errorf("canTransition(%q, %q) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+	if canTransition(models.AlertStateNew, "archived") {
+		t.Error("canTransition() to an unknown state")
+	}
+}
+
+var alertColumns = []string{"id", "page_id", "state", "snoozed_until"}
+
+func TestTransitionAlert(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name         string
+		from         models.AlertState // state of the stored alert, if loaded
+		loaded       bool
+		to           models.AlertState
+		snoozedUntil *time.Time
+		activityTo   string // to_value of the activity, when the move is allowed
+		wantErr      string
+	}{
+		{name: "unknown state", to: "archived", wantErr: `unknown alert state "archived"`},
+		{name: "snooze without a date", to: models.AlertStateSnoozed, wantErr: "snoozed_until must be in the future"},
+		{name: "snooze into the past", to: models.AlertStateSnoozed, snoozedUntil: &past, wantErr: "snoozed_until must be in the future"},
+		{name: "missing alert", loaded: true, to: models.AlertStateAcknowledged, wantErr: "alert not found"},
+		{name: "reopening needs new first", from: models.AlertStateResolved, loaded: true, to: models.AlertStateAcknowledged, wantErr: "cannot move alert from resolved to acknowledged"},
+		{name: "acknowledged cannot go back to new", from: models.AlertStateAcknowledged, loaded: true, to: models.AlertStateNew, wantErr: "cannot move alert from acknowledged to new"},
+		{name: "acknowledge", from: models.AlertStateNew, loaded: true, to: models.AlertStateAcknowledged, activityTo: "acknowledged"},
+		{name: "reopen a dismissed alert", from: models.AlertStateDismissed, loaded: true, to: models.AlertStateNew, activityTo: "new"},
+		{
+			name: "snooze", from: models.AlertStateAcknowledged, loaded: true, to: models.AlertStateSnoozed, snoozedUntil: &future,
+			activityTo: "snoozed until " + future.UTC().Format(time.RFC3339),
+		},
+		{
+			// The date only matters when snoozing
+			name: "resolve clears the snooze", from: models.AlertStateSnoozed, loaded: true, to: models.AlertStateResolved, snoozedUntil: &future,
+			activityTo: "resolved",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			if tt.loaded {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows(alertColumns)
+				if tt.from != "" {
+					rows.AddRow(4, 9, tt.from, nil)
+				}
+				mock.ExpectQuery(`SELECT \* FROM "alert_logs" WHERE "alert_logs"."id" = \$1`).WithArgs(4, 1).WillReturnRows(rows)
+			}
+			if tt.activityTo != "" {
+				var snoozedUntil interface{}
+				if tt.to == models.AlertStateSnoozed {
+					snoozedUntil = *tt.snoozedUntil
+				}
+				mock.ExpectExec(`UPDATE "alert_logs" SET "snoozed_until"=\$1,"state"=\$2,"state_changed_at"=\$3 WHERE "id" = \$4`).
+					WithArgs(snoozedUntil, tt.to, sqlmock.AnyArg(), 4).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`INSERT INTO "alert_activities"`).
+					WithArgs(4, 7, models.AlertActionStateChanged, string(tt.from), tt.activityTo, sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectQuery(`SELECT p.organization_id FROM alert_logs al`).WithArgs(4).
+					WillReturnRows(sqlmock.NewRows([]string{"organization_id"}).AddRow(2))
+				mock.ExpectQuery(`INSERT INTO "audit_events"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectCommit()
+			} else if tt.loaded {
+				mock.ExpectRollback()
+			}
+
+			alert, err := NewAlertLogService(db).TransitionAlert(Actor{UserID: 7}, 4, tt.to, tt.snoozedUntil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("TransitionAlert() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if alert.State != tt.to || alert.StateChangedAt == nil {
+				t.Errorf("alert = %+v, want state %s with its change time", alert, tt.to)
+			}
+			if tt.to != models.AlertStateSnoozed && alert.SnoozedUntil != nil {
+				t.Errorf("SnoozedUntil = %v, want it cleared", alert.SnoozedUntil)
+			}
+		})
+	}
+}
+
+func TestWakeSnoozedAlerts(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(`SELECT \* FROM "alert_logs" WHERE state = \$1 AND snoozed_until <= \$2`).
+		WithArgs(models.AlertStateSnoozed, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(alertColumns).AddRow(4, 9, models.AlertStateSnoozed, time.Now().Add(-time.Minute)))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "alert_logs" SET "snoozed_until"=\$1,"state"=\$2,"state_changed_at"=\$3 WHERE "id" = \$4`).
+		WithArgs(nil, models.AlertStateNew, sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Woken by the system, not by a user
+	mock.ExpectQuery(`INSERT INTO "alert_activities"`).
+		WithArgs(4, nil, models.AlertActionStateChanged, "snoozed", "new", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	if n, err := NewAlertLogService(db).WakeSnoozedAlerts(); err != nil || n != 1 {
+		t.Errorf("WakeSnoozedAlerts() = %d, %v, want 1 alert woken", n, err)
+	}
+}
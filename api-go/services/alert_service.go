@@ -1,6 +1,8 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -12,20 +14,27 @@ import (
 )
 
 // AlertService decides whether to create an alert and persists it.
-// Decision is 100% deterministic based on user_notification_settings.
+// Decision is 100% deterministic based on notification_rules, then
+// user_notification_settings.
 type AlertService struct {
-	db       *gorm.DB
-	aiClient *AIClient
-	emailSvc *EmailService
-	prefSvc  *PreferenceService
+	db           *gorm.DB
+	aiClient     *AIClient
+	prefSvc      *PreferenceService
+	ruleSvc      *NotificationRuleService
+	deliverySvc  *DeliveryService
+	recipientSvc *ProjectRecipientService
+	quotaSvc     *QuotaService
 }
 
 func NewAlertService(db *gorm.DB) *AlertService {
 	return &AlertService{
-		db:       db,
-		aiClient: NewAIClient(),
-		emailSvc: NewEmailService(),
-		prefSvc:  NewPreferenceService(db),
+		db:           db,
+		aiClient:     NewAIClient(),
+		prefSvc:      NewPreferenceService(db),
+		ruleSvc:      NewNotificationRuleService(db),
+		deliverySvc:  NewDeliveryService(db),
+		recipientSvc: NewProjectRecipientService(db),
+		quotaSvc:     NewQuotaService(db),
 	}
 }
 
@@ -49,13 +58,41 @@ func severityFromChange(changeType string, changePercent float64) models.AlertSe
 		return models.SeverityHigh
 	case strings.Contains(changeType, "messaging_change"):
 		return models.SeverityMedium
+	case strings.Contains(changeType, models.ChangeTypeVisual):
+		// changePercent is the share of pixels that changed
+		if abs >= 25 {
+			return models.SeverityHigh
+		}
+		return models.SeverityMedium
 	default:
 		return models.SeverityLow
 	}
 }
 
-// shouldAlert applies deterministic rules from user_notification_settings
-func shouldAlert(change *models.DetectedChange, settings *models.UserNotificationSettings) (bool, string) {
+// alertDecision is the outcome of shouldAlert. rule is the notification rule
+// that decided, nil when the user settings did.
+type alertDecision struct {
+	alert  bool
+	reason string
+	rule   *models.NotificationRule
+}
+
+// shouldAlert applies the first matching notification rule, falling back to
+// user_notification_settings when no rule matches
+func shouldAlert(change *models.DetectedChange, severity models.AlertSeverity, settings *models.UserNotificationSettings, rules []models.NotificationRule) alertDecision {
+	if rule := matchRule(rules, change, severity); rule != nil {
+		if rule.Action == models.RuleActionSuppress {
+			return alertDecision{reason: fmt.Sprintf("suppressed by notification rule %d", rule.ID), rule: rule}
+		}
+		return alertDecision{alert: true, rule: rule}
+	}
+
+	ok, reason := settingsAllow(change, settings)
+	return alertDecision{alert: ok, reason: reason}
+}
+
+// settingsAllow applies deterministic rules from user_notification_settings
+func settingsAllow(change *models.DetectedChange, settings *models.UserNotificationSettings) (bool, string) {
 	changeType := change.ChangeType
 	abs := math.Abs(change.ChangePercent)
 
@@ -86,6 +123,14 @@ func shouldAlert(change *models.DetectedChange, settings *models.UserNotificatio
 		return true, ""
 	}
 
+	// Visual (screenshot) changes
+	if strings.Contains(changeType, models.ChangeTypeVisual) {
+		if !settings.AlertOnVisualChange {
+			return false, "visual alerts disabled by user"
+		}
+		return true, ""
+	}
+
 	return false, "unknown change type"
 }
 
@@ -98,6 +143,9 @@ func buildAlertMessage(change *models.DetectedChange, insight *AIInsight) string
 	if change.OldPrice != "" && change.NewPrice != "" {
 		msg.WriteString(fmt.Sprintf("Prix: %s → %s (%.1f%%)\n", change.OldPrice, change.NewPrice, change.ChangePercent))
 	}
+	if change.ChangeType == models.ChangeTypeVisual {
+		msg.WriteString(fmt.Sprintf("Pixels modifiés: %.1f%%\n", change.ChangePercent))
+	}
 	msg.WriteString(fmt.Sprintf("Type de changement: %s\n", change.ChangeType))
 	msg.WriteString(fmt.Sprintf("Page ID: %d\n", change.PageID))
 	msg.WriteString("\n")
@@ -118,7 +166,25 @@ func buildAlertMessage(change *models.DetectedChange, insight *AIInsight) string
 	return msg.String()
 }
 
-// ProcessChange evaluates a detected change using deterministic rules, then notifies
+// changeDiffPath links a change to the diff of the two snapshots it was
+// detected from. Both the Python detector and the scraper (visual changes)
+// record the snapshot IDs in raw_data.
+func changeDiffPath(change *models.DetectedChange) string {
+	if change.RawData == "" {
+		return ""
+	}
+	var ids struct {
+		Previous uint `json:"previous_snapshot_id"`
+		Latest   uint `json:"latest_snapshot_id"`
+	}
+	if err := json.Unmarshal([]byte(change.RawData), &ids); err != nil || ids.Previous == 0 || ids.Latest == 0 {
+		return ""
+	}
+	return DiffPath(ids.Previous, ids.Latest)
+}
+
+// ProcessChange evaluates a claimed detected change using deterministic rules,
+// then notifies. The outcome is recorded in change_processing.
 func (s *AlertService) ProcessChange(change *models.DetectedChange) error {
 	// 1. Resolve page → user → notification settings
 	settings, userEmail, err := s.prefSvc.GetSettingsForPage(change.PageID)
@@ -127,115 +193,286 @@ func (s *AlertService) ProcessChange(change *models.DetectedChange) error {
 		settings = s.prefSvc.defaultSettings()
 	}
 
-	// 2. Deterministic decision
-	ok, reason := shouldAlert(change, settings)
-	if !ok {
-		log.Printf("ℹ️  AlertService: change %d skipped — %s", change.ID, reason)
-		return nil
+	rules, err := s.ruleSvc.RulesForPage(change.PageID)
+	if err != nil {
+		log.Printf("⚠️  AlertService: failed to load notification rules for page %d: %v", change.PageID, err)
 	}
 
-	// 3. Compute severity
+	// 2. Compute severity
 	severity := severityFromChange(change.ChangeType, change.ChangePercent)
 
-	// 4. Enrich with AI (summary + recommendation + impact_level)
-	insight, err := s.aiClient.Analyze(
-		change.ChangeType,
-		change.PageType,
-		change.OldPrice,
-		change.NewPrice,
-		change.ChangePercent,
-		change.FeaturesAdded,
-		change.FeaturesRemoved,
-		change.OldText,
-		change.NewText,
-	)
-	if err != nil || insight == nil {
+	// 3. Deterministic decision
+	decision := shouldAlert(change, severity, settings, rules)
+	if !decision.alert {
+		log.Printf("ℹ️  AlertService: change %d skipped — %s", change.ID, decision.reason)
+		s.finishProcessing(change.ID, models.ProcessingSuppressed, decision.reason)
+		return nil
+	}
+	// Fold repeats, reverts and cool-down hits into the alert they belong to
+	now := time.Now()
+	windows := s.pageFoldWindows(change.PageID)
+	recent, err := s.recentOpenAlerts(change.PageID, windows, now)
+	if err != nil {
+		log.Printf("⚠️  AlertService: failed to load recent alerts for page %d: %v", change.PageID, err)
+	}
+	if fold := decideFold(change, severity, recent, windows, now); fold != nil {
+		return s.foldChange(change, fold, now)
+	}
+
+	route := routeNotification(decision.rule, settings, userEmail)
+	if usesDigest(settings, severity) {
+		// The owner gets it in their next digest; explicit rule recipients still get it now
+		route.recipients = removeRecipient(route.recipients, userEmail)
+	}
+
+	// A redelivered change is not analysed (nor charged) twice
+	if exists, _ := s.hasAlertForChange(change.ID); exists {
+		log.Printf("ℹ️  AlertService: change %d already alerted", change.ID)
+		s.finishProcessing(change.ID, models.ProcessingAlerted, "")
+		return nil
+	}
+
+	// 4. Enrich with AI (summary + recommendation + impact_level), within the
+	// monthly AI analyses of the plan
+	var insight *AIInsight
+	aiConsumed := false
+	if err := s.quotaSvc.ConsumeForPage(uint(change.PageID), models.UsageAIAnalyses, 1); err != nil {
+		log.Printf("ℹ️  AlertService: no AI analysis for change %d — %v", change.ID, err)
+	} else {
+		aiConsumed = true
+		insight, err = s.aiClient.Analyze(
+			change.ChangeType,
+			change.PageType,
+			change.OldPrice,
+			change.NewPrice,
+			change.ChangePercent,
+			change.FeaturesAdded,
+			change.FeaturesRemoved,
+			change.OldText,
+			change.NewText,
+		)
+		if err != nil {
+			insight = nil
+		}
+	}
+	if insight == nil {
 		insight = &AIInsight{
 			Summary:        "Competitor change detected: " + change.ChangeType,
 			Recommendation: "Review competitor activity",
-			Model:         "rule-based",
-			ImpactLevel:   5,
+			Model:          "rule-based",
+			ImpactLevel:    5,
 		}
 	}
 
 	// 5. Build full alert message (factual + AI)
 	message := buildAlertMessage(change, insight)
+	diffPath := changeDiffPath(change)
 
 	// 6. Persist alert log
-	now := time.Now()
 	alert := &models.AlertLog{
-		ChangeID:        change.ID,
-		PageID:          change.PageID,
-		AlertType:       change.ChangeType,
-		Severity:        severity,
-		OldPrice:        change.OldPrice,
-		NewPrice:        change.NewPrice,
-		ChangePercent:   change.ChangePercent,
-		AISummary:       insight.Summary,
+		ChangeID:         change.ID,
+		PageID:           change.PageID,
+		AlertType:        change.ChangeType,
+		Severity:         severity,
+		OldPrice:         change.OldPrice,
+		NewPrice:         change.NewPrice,
+		ChangePercent:    change.ChangePercent,
+		AISummary:        insight.Summary,
 		AIRecommendation: insight.Recommendation,
-		ImpactLevel:     insight.ImpactLevel,
-		AIModel:         insight.Model,
-		Message:         message,
-		Notified:        false,
-		NotifyChannel:   "log",
+		ImpactLevel:      insight.ImpactLevel,
+		AIModel:          insight.Model,
+		DiffURL:          diffPath,
+		RuleID:           ruleID(decision.rule),
+		Message:          message,
+		Notified:         false,
+		NotifyChannel:    "log",
 	}
 
 	if err := s.db.Create(alert).Error; err != nil {
+		// The unique index on change_id rejects a second alert for the same change
+		// (created concurrently since the check above): the analysis
+		// counted for this one is given back
+		if exists, _ := s.hasAlertForChange(change.ID); exists {
+			log.Printf("ℹ️  AlertService: change %d already alerted", change.ID)
+			if aiConsumed {
+				if err := s.quotaSvc.ReleaseForPage(uint(change.PageID), models.UsageAIAnalyses, 1); err != nil {
+					log.Printf("⚠️  AlertService: failed to release the AI analysis of change %d: %v", change.ID, err)
+				}
+			}
+			s.finishProcessing(change.ID, models.ProcessingAlerted, "")
+			return nil
+		}
 		log.Printf("❌ AlertService: failed to save alert for change %d: %v", change.ID, err)
+		s.finishProcessing(change.ID, models.ProcessingFailed, err.Error())
 		return err
 	}
+	s.finishProcessing(change.ID, models.ProcessingAlerted, "")
 
 	log.Printf("🚨 Alert created [%s] change=%d page=%d severity=%s impact=%d | %s",
 		change.ChangeType, change.ID, change.PageID, severity, insight.ImpactLevel, insight.Summary)
 
-	// 7. Send notifications
-	notified := false
-	channel := "log"
+	// 7. Fan out to the owner (or the rule's route) and the project
+	// recipients, each subject to their own quiet hours
+	deliveries := routeDeliveries(route, quietUntil(settings, severity, now))
+	deliveries = append(deliveries, s.recipientDeliveries(change.PageID, change, severity, now)...)
+
+	// 8. Send now, or queue until quiet hours end
+	s.deliverySvc.Dispatch(alert, dedupeDeliveries(deliveries), now)
+
+	return nil
+}
+
+// notificationRoute is where an alert is delivered
+type notificationRoute struct {
+	email      bool
+	recipients []string
+	webhook    bool
+	webhookURL string
+}
 
-	if settings.NotifyEmail && userEmail != "" {
-		if err := s.emailSvc.SendAlert(userEmail, change.ChangeType, string(severity), insight.Summary, insight.Recommendation, change.PageID); err != nil {
-			log.Printf("⚠️  Email notification failed: %v", err)
-		} else {
-			notified = true
-			channel = "email"
+// routeNotification picks channels and recipients: the matched rule's when it
+// sets them, the page owner's settings otherwise
+func routeNotification(rule *models.NotificationRule, settings *models.UserNotificationSettings, ownerEmail string) notificationRoute {
+	route := notificationRoute{
+		email:      settings.NotifyEmail,
+		webhook:    settings.NotifyWebhook,
+		webhookURL: settings.WebhookURL,
+	}
+	if ownerEmail != "" {
+		route.recipients = []string{ownerEmail}
+	}
+	if rule == nil {
+		return route
+	}
+
+	if channels := splitList(rule.Channels); len(channels) > 0 {
+		route.email, route.webhook = false, false
+		for _, c := range channels {
+			switch c {
+			case "email":
+				route.email = true
+			case "webhook":
+				route.webhook = true
+			}
 		}
 	}
+	if recipients := splitList(rule.Recipients); len(recipients) > 0 {
+		route.recipients = recipients
+	}
+	if rule.WebhookURL != "" {
+		route.webhookURL = rule.WebhookURL
+	}
+	return route
+}
 
-	if settings.NotifyWebhook && settings.WebhookURL != "" {
-		if err := s.emailSvc.SendWebhook(settings.WebhookURL, change.ChangeType, string(severity), message, insight.Recommendation, change.PageID, alert.ID); err != nil {
-			log.Printf("⚠️  Webhook notification failed: %v", err)
-		} else {
-			notified = true
-			if channel == "email" {
-				channel = "email,webhook"
-			} else {
-				channel = "webhook"
+// routeDeliveries turns the owner / rule route into deliveries
+func routeDeliveries(route notificationRoute, deliverAfter time.Time) []delivery {
+	var deliveries []delivery
+	if route.email {
+		for _, to := range route.recipients {
+			deliveries = append(deliveries, delivery{channel: "email", recipient: to, deliverAfter: deliverAfter})
+		}
+	}
+	if route.webhook && route.webhookURL != "" {
+		deliveries = append(deliveries, delivery{channel: "webhook", recipient: route.webhookURL, deliverAfter: deliverAfter})
+	}
+	return deliveries
+}
+
+// recipientDeliveries returns the deliveries to the project's extra recipients.
+// Collaborators follow their own notification settings; external addresses
+// follow the channels and minimum severity set on the recipient. change is nil
+// for a monitor alert, which skips the change filters and the digest.
+func (s *AlertService) recipientDeliveries(pageID int, change *models.DetectedChange, severity models.AlertSeverity, now time.Time) []delivery {
+	recipients, err := s.recipientSvc.RecipientsForPage(pageID)
+	if err != nil {
+		log.Printf("⚠️  AlertService: failed to load recipients for page %d: %v", pageID, err)
+		return nil
+	}
+
+	var deliveries []delivery
+	for _, r := range recipients {
+		if r.UserID != nil {
+			if r.User == nil || r.User.Email == "" {
+				continue
+			}
+			settings, err := s.prefSvc.GetSettingsForUser(*r.UserID)
+			if err != nil {
+				continue
 			}
+			if change != nil {
+				if ok, _ := settingsAllow(change, settings); !ok {
+					continue
+				}
+			}
+			after := quietUntil(settings, severity, now)
+			if settings.NotifyEmail && (change == nil || !usesDigest(settings, severity)) {
+				deliveries = append(deliveries, delivery{channel: "email", recipient: r.User.Email, unsubscribeToken: r.UnsubscribeToken, deliverAfter: after})
+			}
+			if settings.NotifyWebhook && settings.WebhookURL != "" {
+				deliveries = append(deliveries, delivery{channel: "webhook", recipient: settings.WebhookURL, unsubscribeToken: r.UnsubscribeToken, deliverAfter: after})
+			}
+			continue
+		}
+
+		if r.MinSeverity != "" && severityRank[severity] < severityRank[r.MinSeverity] {
+			continue
+		}
+		if r.NotifyEmail && r.Email != "" {
+			deliveries = append(deliveries, delivery{channel: "email", recipient: r.Email, unsubscribeToken: r.UnsubscribeToken})
+		}
+		if r.WebhookURL != "" {
+			deliveries = append(deliveries, delivery{channel: "webhook", recipient: r.WebhookURL, unsubscribeToken: r.UnsubscribeToken})
 		}
 	}
+	return deliveries
+}
 
-	// 8. Mark as notified
-	if notified {
-		s.db.Model(alert).Updates(map[string]interface{}{
-			"notified":        true,
-			"notified_at":     now,
-			"notify_channel":   channel,
-		})
+// quietUntil returns when a notification to this user may go out: the end of
+// their quiet hours, or the zero time to send now
+func quietUntil(settings *models.UserNotificationSettings, severity models.AlertSeverity, now time.Time) time.Time {
+	if severity == models.SeverityCritical && settings.QuietHoursBypassCritical {
+		return time.Time{}
+	}
+	until, quiet := quietHoursEnd(settings, now)
+	if !quiet {
+		return time.Time{}
 	}
+	return until
+}
 
-	return nil
+func removeRecipient(recipients []string, email string) []string {
+	kept := recipients[:0:0]
+	for _, r := range recipients {
+		if !strings.EqualFold(r, email) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+func ruleID(rule *models.NotificationRule) *uint {
+	if rule == nil {
+		return nil
+	}
+	return &rule.ID
+}
+
+// GetChangeByID loads a detected change announced by a change.detected event
+func (s *AlertService) GetChangeByID(id uint) (*models.DetectedChange, error) {
+	var change models.DetectedChange
+	if err := s.db.First(&change, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("detected change not found")
+		}
+		return nil, err
+	}
+	return &change, nil
 }
 
-// GetUnprocessedChanges returns detected_changes that have no alert_log yet
-func (s *AlertService) GetUnprocessedChanges() ([]models.DetectedChange, error) {
-	var changes []models.DetectedChange
-	err := s.db.Raw(`
-		SELECT dc.*
-		FROM detected_changes dc
-		LEFT JOIN alert_logs al ON dc.id = al.change_id
-		WHERE al.id IS NULL
-		ORDER BY dc.detected_at ASC
-		LIMIT 50
-	`).Scan(&changes).Error
-	return changes, err
+// hasAlertForChange reports whether an alert was already created for a change
+func (s *AlertService) hasAlertForChange(changeID uint) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.AlertLog{}).Where("change_id = ?", changeID).Count(&count).Error
+	return count > 0, err
 }
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxServiceTokenTTL caps the lifetime of service tokens
+	maxServiceTokenTTL = 365 * 24 * time.Hour
+
+	// lastUsedResolution limits last_used_at writes to one per key per minute
+	lastUsedResolution = time.Minute
+)
+
+// APIKeyService issues, authenticates and revokes API keys.
+// Keys look like rp_<prefix>_<secret>: the prefix is stored in clear to find
+// the key, the whole key only as a SHA-256 hash.
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+// CreateKey issues a key for the actor and returns it with its plaintext
+// value, which is never stored and cannot be shown again
+func (s *APIKeyService) CreateKey(actor Actor, name, kind string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("name is required")
+	}
+	if kind == "" {
+		kind = models.APIKeyPersonal
+	}
+	if kind != models.APIKeyPersonal && kind != models.APIKeyService {
+		return nil, "", fmt.Errorf("invalid kind %q", kind)
+	}
+
+	if len(scopes) == 0 {
+		if kind == models.APIKeyService {
+			return nil, "", errors.New("service tokens need explicit scopes")
+		}
+		scopes = models.AllScopes
+	}
+	for _, scope := range scopes {
+		if !isValidScope(scope) {
+			return nil, "", fmt.Errorf("invalid scope %q", scope)
+		}
+	}
+
+	now := time.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, "", errors.New("expires_at must be in the future")
+	}
+	if kind == models.APIKeyService {
+		if expiresAt == nil {
+			return nil, "", errors.New("service tokens need an expiry")
+		}
+		if expiresAt.Sub(now) > maxServiceTokenTTL {
+			return nil, "", errors.New("service tokens cannot live longer than one year")
+		}
+	}
+
+	prefix, err := utils.RandomToken(6)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext := models.APIKeyPrefix + prefix + "_" + secret
+
+	key := models.APIKey{
+		UserID:    actor.UserID,
+		Name:      name,
+		Kind:      kind,
+		Prefix:    prefix,
+		KeyHash:   hashAPIKey(plaintext),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expiresAt,
+		MFA:       actor.MFA,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&key).Error; err != nil {
+			return errors.New("failed to create API key")
+		}
+		return recordAudit(tx, actor, auditRecord{Action: models.AuditAPIKeyCreated, TargetID: key.ID, After: key})
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &key, plaintext, nil
+}
+
+// ListKeys returns the keys of userID, revoked ones included
+func (s *APIKeyService) ListKeys(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeKey disables one of the actor's keys immediately
+func (s *APIKeyService) RevokeKey(actor Actor, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.Where("id = ? AND user_id = ?", id, actor.UserID).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("API key not found")
+		}
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return &key, nil
+	}
+
+	before := key
+	now := time.Now()
+	key.RevokedAt = &now
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&key).Update("revoked_at", now).Error; err != nil {
+			return errors.New("failed to revoke API key")
+		}
+		return recordAudit(tx, actor, auditRecord{Action: models.AuditAPIKeyRevoked, TargetID: key.ID, Before: before, After: key})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Authenticate returns the active key matching plaintext and records its use
+func (s *APIKeyService) Authenticate(plaintext, ip string) (*models.APIKey, error) {
+	invalid := errors.New("invalid API key")
+
+	rest := strings.TrimPrefix(plaintext, models.APIKeyPrefix)
+	prefix, _, ok := strings.Cut(rest, "_")
+	if rest == plaintext || !ok || prefix == "" {
+		return nil, invalid
+	}
+
+	var key models.APIKey
+	if err := s.db.Preload("User").Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, invalid
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashAPIKey(plaintext))) != 1 {
+		return nil, invalid
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		return nil, errors.New("API key revoked")
+	}
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, errors.New("API key expired")
+	}
+
+	s.db.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.ID, now.Add(-lastUsedResolution)).
+		Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	return &key, nil
+}
+
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func isValidScope(scope string) bool {
+	for _, s := range models.AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rivalprice/api-go/models"
+)
+
+func TestCreateKeyValidation(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	nextMonth := time.Now().Add(30 * 24 * time.Hour)
+	twoYears := time.Now().Add(2 * 365 * 24 * time.Hour)
+
+	tests := []struct {
+		name      string
+		keyName   string
+		kind      string
+		scopes    []string
+		expiresAt *time.Time
+		wantErr   string
+	}{
+		{name: "blank name", keyName: "  ", wantErr: "name is required"},
+		{name: "unknown kind", keyName: "ci", kind: "robot", wantErr: `invalid kind "robot"`},
+		{name: "unknown scope", keyName: "ci", scopes: []string{models.ScopeReadPages, "admin:all"}, wantErr: `invalid scope "admin:all"`},
+		{name: "scope with spaces", keyName: "ci", scopes: []string{" read:pages"}, wantErr: `invalid scope " read:pages"`},
+		{name: "already expired", keyName: "ci", expiresAt: &past, wantErr: "expires_at must be in the future"},
+		{name: "service token without scopes", keyName: "ci", kind: models.APIKeyService, expiresAt: &nextMonth, wantErr: "explicit scopes"},
+		{name: "service token without expiry", keyName: "ci", kind: models.APIKeyService, scopes: []string{models.ScopeReadPages}, wantErr: "need an expiry"},
+		{name: "service token for two years", keyName: "ci", kind: models.APIKeyService, scopes: []string{models.ScopeReadPages}, expiresAt: &twoYears, wantErr: "longer than one year"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newMockDB(t) // nothing is written
+			_, _, err := NewAPIKeyService(db).CreateKey(Actor{UserID: 7}, tt.keyName, tt.kind, tt.scopes, tt.expiresAt)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CreateKey() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		kind       string
+		scopes     []string
+		wantScopes string
+	}{
+		{name: "personal key gets every scope", wantScopes: strings.Join(models.AllScopes, ",")},
+		{name: "service token keeps its scopes", kind: models.APIKeyService, scopes: []string{models.ScopeReadPages, models.ScopeScrapeTrigger}, wantScopes: "read:pages,scrape:trigger"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO "api_keys"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+			mock.ExpectQuery(`INSERT INTO "audit_events"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectCommit()
+
+			expiresAt := time.Now().Add(24 * time.Hour)
+			key, plaintext, err := NewAPIKeyService(db).CreateKey(Actor{UserID: 7, MFA: true}, " deploy ", tt.kind, tt.scopes, &expiresAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// rp_<12 hex prefix>_<64 hex secret>, only the prefix and a hash stored
+			if !regexp.MustCompile(`^rp_[0-9a-f]{12}_[0-9a-f]{64}$`).MatchString(plaintext) {
+				t.Errorf("plaintext = %q, want rp_<prefix>_<secret>", plaintext)
+			}
+			if !strings.HasPrefix(plaintext, models.APIKeyPrefix+key.Prefix+"_") {
+				t.Errorf("Prefix = %q, not the prefix of %q", key.Prefix, plaintext)
+			}
+			if key.KeyHash != hashAPIKey(plaintext) {
+				t.Errorf("KeyHash = %q, want the SHA-256 of the key only", key.KeyHash)
+			}
+			if key.ID != 5 || key.UserID != 7 || key.Name != "deploy" || !key.MFA {
+				t.Errorf("key = %+v, want key 5 of user 7 named deploy, created with 2FA", key)
+			}
+			if key.Scopes != tt.wantScopes {
+				t.Errorf("Scopes = %q, want %q", key.Scopes, tt.wantScopes)
+			}
+		})
+	}
+
+	t.Run("keys are unique", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		seen := map[string]bool{}
+		for i := 0; i < 3; i++ {
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO "api_keys"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(i + 1))
+			mock.ExpectQuery(`INSERT INTO "audit_events"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(i + 1))
+			mock.ExpectCommit()
+			key, plaintext, err := NewAPIKeyService(db).CreateKey(Actor{UserID: 7}, "deploy", "", nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seen[key.Prefix] || seen[plaintext] {
+				t.Fatalf("key %q issued twice", plaintext)
+			}
+			seen[key.Prefix], seen[plaintext] = true, true
+		}
+	})
+}
+
+func TestAPIKeyHasScope(t *testing.T) {
+	key := models.APIKey{Scopes: "read:pages, scrape:trigger"}
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{models.ScopeReadPages, true},
+		{models.ScopeScrapeTrigger, true}, // spaces around commas are ignored
+		{models.ScopeWritePages, false},
+		{"read", false}, // no prefix matching
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := key.HasScope(tt.scope); got != tt.want {
+			t.Errorf("HasScope(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	const plaintext = "rp_0a1b2c3d4e5f_" + "secret"
+	hour := time.Hour
+	past := -time.Hour
+
+	tests := []struct {
+		name      string
+		plaintext string
+		lookup    bool // the prefix is looked up
+		found     bool
+		hash      string
+		revoked   bool
+		expiresIn *time.Duration
+		wantErr   string
+	}{
+		{name: "valid key", plaintext: plaintext, lookup: true, found: true, expiresIn: &hour},
+		{name: "valid key without expiry", plaintext: plaintext, lookup: true, found: true},
+		{name: "JWT instead of a key", plaintext: "eyJhbGciOiJIUzI1NiJ9.e30.x", wantErr: "invalid API key"},
+		{name: "no secret", plaintext: "rp_0a1b2c3d4e5f", wantErr: "invalid API key"},
+		{name: "empty prefix", plaintext: "rp__secret", wantErr: "invalid API key"},
+		{name: "unknown prefix", plaintext: plaintext, lookup: true, wantErr: "invalid API key"},
+		{name: "wrong secret", plaintext: "rp_0a1b2c3d4e5f_guess", lookup: true, found: true, wantErr: "invalid API key"},
+		{name: "hash of another key", plaintext: plaintext, lookup: true, found: true, hash: hashAPIKey("rp_0a1b2c3d4e5f_other"), wantErr: "invalid API key"},
+		{name: "revoked", plaintext: plaintext, lookup: true, found: true, revoked: true, wantErr: "API key revoked"},
+		{name: "expired", plaintext: plaintext, lookup: true, found: true, expiresIn: &past, wantErr: "API key expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			if tt.lookup {
+				rows := sqlmock.NewRows([]string{"id", "user_id", "prefix", "key_hash", "scopes", "expires_at", "revoked_at"})
+				if tt.found {
+					hash := tt.hash
+					if hash == "" {
+						hash = hashAPIKey(plaintext)
+					}
+					var expiresAt, revokedAt *time.Time
+					if tt.expiresIn != nil {
+						at := time.Now().Add(*tt.expiresIn)
+						expiresAt = &at
+					}
+					if tt.revoked {
+						at := time.Now().Add(-time.Minute)
+						revokedAt = &at
+					}
+					rows.AddRow(5, 7, "0a1b2c3d4e5f", hash, "read:pages", expiresAt, revokedAt)
+				}
+				mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE prefix = \$1`).WithArgs("0a1b2c3d4e5f", 1).WillReturnRows(rows)
+				if tt.found {
+					mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).WithArgs(7).
+						WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				}
+			}
+			if tt.wantErr == "" {
+				// Last use recorded at most once a minute
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "api_keys" SET "last_used_at"=\$1,"last_used_ip"=\$2 WHERE id = \$3 AND \(last_used_at IS NULL OR last_used_at < \$4\)`).
+					WithArgs(sqlmock.AnyArg(), "203.0.113.9", 5, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			key, err := NewAPIKeyService(db).Authenticate(tt.plaintext, "203.0.113.9")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Authenticate() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || key.ID != 5 || key.UserID != 7 {
+				t.Errorf("Authenticate() = %+v, %v, want key 5 of user 7", key, err)
+			}
+		})
+	}
+}
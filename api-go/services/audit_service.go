@@ -0,0 +1,314 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"gorm.io/gorm"
+)
+
+// Actor is who performs an audited action: a user (through a session or one
+// of their API keys), an anonymous request, or the system when UserID is 0
+// and IP is empty. MFA tells whether the credential passed 2FA.
+type Actor struct {
+	UserID    uint
+	APIKeyID  uint
+	MFA       bool
+	IP        string
+	UserAgent string
+}
+
+// SystemActor performs the actions of workers and provider webhooks
+var SystemActor = Actor{}
+
+func (a Actor) actorType() string {
+	switch {
+	case a.APIKeyID != 0:
+		return models.AuditActorAPIKey
+	case a.UserID != 0:
+		return models.AuditActorUser
+	case a.IP != "":
+		return models.AuditActorAnonymous
+	default:
+		return models.AuditActorSystem
+	}
+}
+
+// auditRecord describes an audited action. Before and After are the target
+// before and after it (nil on creation and deletion respectively); the
+// audit event keeps the fields that differ.
+type auditRecord struct {
+	OrganizationID uint // 0 for account actions
+	Action         string
+	TargetType     string
+	TargetID       uint
+	Before         interface{}
+	After          interface{}
+}
+
+// auditIgnoredFields change on every write or are not settings
+var auditIgnoredFields = map[string]bool{"created_at": true, "updated_at": true}
+
+// recordAudit appends an action to the audit log. Pass the transaction of
+// the action, so one is never stored without the other.
+func recordAudit(db *gorm.DB, actor Actor, rec auditRecord) error {
+	changes, err := auditChanges(rec.Before, rec.After)
+	if err != nil {
+		return err
+	}
+	event := models.AuditEvent{
+		ActorType:  actor.actorType(),
+		Action:     rec.Action,
+		TargetType: rec.TargetType,
+		Changes:    changes,
+		IP:         actor.IP,
+		UserAgent:  truncate(actor.UserAgent, 512),
+	}
+	if rec.OrganizationID != 0 {
+		event.OrganizationID = &rec.OrganizationID
+	}
+	if actor.UserID != 0 {
+		event.ActorUserID = &actor.UserID
+	}
+	if actor.APIKeyID != 0 {
+		event.ActorAPIKeyID = &actor.APIKeyID
+	}
+	if event.TargetType == "" {
+		event.TargetType = strings.SplitN(rec.Action, ".", 2)[0]
+	}
+	if rec.TargetID != 0 {
+		event.TargetID = &rec.TargetID
+	}
+	if err := db.Create(&event).Error; err != nil {
+		return fmt.Errorf("failed to write audit event: %w", err)
+	}
+	return nil
+}
+
+// auditOrg resolves the organization of an audited target with lookup, or
+// returns 0 (no organization) when it has none
+func auditOrg(lookup func(id uint) (uint, error), id uint) uint {
+	orgID, _ := lookup(id)
+	return orgID
+}
+
+// auditChange is the value of a field before and after an action
+type auditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// auditChanges diffs the JSON representations of before and after, so
+// fields hidden from the API (secrets, hashes) are never logged. Nested
+// objects (preloaded associations) are skipped.
+func auditChanges(before, after interface{}) (json.RawMessage, error) {
+	if before == nil && after == nil {
+		return nil, nil
+	}
+	b, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]auditChange{}
+	for field, value := range b {
+		if other, ok := a[field]; !ok || !reflect.DeepEqual(value, other) {
+			changes[field] = auditChange{Before: value, After: a[field]}
+		}
+	}
+	for field, value := range a {
+		if _, ok := b[field]; !ok {
+			changes[field] = auditChange{After: value}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(changes)
+}
+
+func auditFields(v interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v == nil {
+		return fields, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for field, value := range fields {
+		if _, nested := value.(map[string]interface{}); nested || auditIgnoredFields[field] {
+			delete(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+// AuditFilter narrows the audit log of an organization, or the account
+// actions (no organization) when OrganizationID is 0; other zero values
+// match everything
+type AuditFilter struct {
+	OrganizationID uint
+	ActorUserID    uint
+	Action         string // exact action, or a target type prefix such as "competitor."
+	TargetType     string
+	TargetID       uint
+	From           *time.Time
+	To             *time.Time
+}
+
+// defaultAuditRetention is how long audit events are kept
+const defaultAuditRetention = 365 * 24 * time.Hour
+
+// AuditService reads the audit log and enforces its retention. Events are
+// written by the services performing the actions, with recordAudit.
+type AuditService struct {
+	db        *gorm.DB
+	retention time.Duration // 0 keeps events forever
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	retention := defaultAuditRetention
+	if raw := os.Getenv("AUDIT_RETENTION_DAYS"); raw != "" {
+		if days, err := strconv.Atoi(raw); err == nil && days >= 0 {
+			retention = time.Duration(days) * 24 * time.Hour
+		}
+	}
+	return &AuditService{db: db, retention: retention}
+}
+
+// ListEventsPaginated returns the events matching filter, newest first
+func (s *AuditService) ListEventsPaginated(filter AuditFilter, offset, limit int) ([]models.AuditEvent, int64, error) {
+	var total int64
+	if err := s.query(filter).Model(&models.AuditEvent{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []models.AuditEvent
+	if err := s.query(filter).Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+// auditExportBatch is the number of events read at once by ExportCSV
+const auditExportBatch = 1000
+
+// ExportCSV writes the events matching filter to w as CSV, newest first,
+// reading them in batches so large exports don't load the whole log
+func (s *AuditService) ExportCSV(filter AuditFilter, w io.Writer) error {
+	out := csv.NewWriter(w)
+	header := []string{"id", "created_at", "organization_id", "actor_type", "actor_user_id", "actor_api_key_id", "action", "target_type", "target_id", "changes", "ip", "user_agent"}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	// Keyset pagination on id, stable while new events are appended
+	var lastID uint
+	for {
+		query := s.query(filter).Order("id DESC").Limit(auditExportBatch)
+		if lastID != 0 {
+			query = query.Where("id < ?", lastID)
+		}
+		var events []models.AuditEvent
+		if err := query.Find(&events).Error; err != nil {
+			return err
+		}
+		for _, e := range events {
+			row := []string{
+				strconv.FormatUint(uint64(e.ID), 10),
+				e.CreatedAt.UTC().Format(time.RFC3339),
+				formatOptionalID(e.OrganizationID),
+				e.ActorType,
+				formatOptionalID(e.ActorUserID),
+				formatOptionalID(e.ActorAPIKeyID),
+				e.Action,
+				e.TargetType,
+				formatOptionalID(e.TargetID),
+				string(e.Changes),
+				e.IP,
+				csvSafe(e.UserAgent),
+			}
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+		out.Flush()
+		if err := out.Error(); err != nil {
+			return err
+		}
+		if len(events) < auditExportBatch {
+			return nil
+		}
+		lastID = events[len(events)-1].ID
+	}
+}
+
+// PurgeExpired deletes the events older than the retention period (from
+// AUDIT_RETENTION_DAYS) and returns how many were deleted
+func (s *AuditService) PurgeExpired(now time.Time) (int64, error) {
+	if s.retention == 0 {
+		return 0, nil
+	}
+	result := s.db.Where("created_at < ?", now.Add(-s.retention)).Delete(&models.AuditEvent{})
+	return result.RowsAffected, result.Error
+}
+
+func (s *AuditService) query(filter AuditFilter) *gorm.DB {
+	query := s.db.Where("organization_id IS NULL")
+	if filter.OrganizationID != 0 {
+		query = s.db.Where("organization_id = ?", filter.OrganizationID)
+	}
+	if filter.ActorUserID != 0 {
+		query = query.Where("actor_user_id = ?", filter.ActorUserID)
+	}
+	if strings.HasSuffix(filter.Action, ".") {
+		query = query.Where("action LIKE ?", strings.ReplaceAll(filter.Action, "_", `\_`)+"%")
+	} else if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	return query
+}
+
+func formatOptionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+// csvSafe keeps spreadsheets from evaluating a client-controlled cell as a
+// formula
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
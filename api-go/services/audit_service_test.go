@@ -0,0 +1,164 @@
+package services
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rivalprice/api-go/models"
+)
+
+func TestAuditChanges(t *testing.T) {
+	created := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	revoked := created.Add(time.Hour)
+	key := models.APIKey{ID: 5, UserID: 7, Name: "deploy", Prefix: "0a1b2c3d4e5f", KeyHash: "secret-hash", Scopes: "read:pages", CreatedAt: created}
+	revokedKey := key
+	revokedKey.RevokedAt = &revoked
+
+	page := &models.MonitoredPage{}
+	page.ID = 9
+	page.URL = "https://example.com/pricing"
+	page.Competitor = models.Competitor{Name: "Acme"}
+	movedPage := *page
+	movedPage.URL = "https://example.com/plans"
+	movedPage.UpdatedAt = created
+
+	tests := []struct {
+		name          string
+		before, after interface{}
+		want          map[string]auditChange // nil when nothing is logged
+	}{
+		{name: "nothing", want: nil},
+		{name: "unchanged", before: key, after: key, want: nil},
+		{
+			name: "one field changed", before: key, after: revokedKey,
+			want: map[string]auditChange{"revoked_at": {Before: nil, After: revoked.Format(time.RFC3339)}},
+		},
+		{
+			// Hidden fields (the key hash) and timestamps are never logged
+			name: "creation", after: key,
+			want: map[string]auditChange{
+				"id": {After: 5.0}, "user_id": {After: 7.0}, "name": {After: "deploy"}, "kind": {After: ""},
+				"prefix": {After: "0a1b2c3d4e5f"}, "scopes": {After: "read:pages"}, "expires_at": {},
+				"last_used_at": {}, "last_used_ip": {After: ""}, "revoked_at": {}, "mfa": {After: false},
+			},
+		},
+		{
+			name: "deletion", before: map[string]interface{}{"name": "deploy", "scopes": "read:pages"},
+			want: map[string]auditChange{"name": {Before: "deploy"}, "scopes": {Before: "read:pages"}},
+		},
+		{
+			// The preloaded competitor and updated_at are left out
+			name: "nested objects skipped", before: page, after: &movedPage,
+			want: map[string]auditChange{"url": {Before: "https://example.com/pricing", After: "https://example.com/plans"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := auditChanges(tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if raw != nil {
+					t.Errorf("auditChanges() = %s, want nothing", raw)
+				}
+				return
+			}
+			want, _ := json.Marshal(tt.want)
+			if string(raw) != string(want) {
+				t.Errorf("auditChanges() = %s, want %s", raw, want)
+			}
+			if strings.Contains(string(raw), "secret-hash") {
+				t.Error("hidden field logged")
+			}
+		})
+	}
+}
+
+func TestRecordAudit(t *testing.T) {
+	longUA := strings.Repeat("a", 600)
+
+	tests := []struct {
+		name       string
+		actor      Actor
+		rec        auditRecord
+		orgID      interface{}
+		actorType  string
+		userID     interface{}
+		apiKeyID   interface{}
+		targetType string
+		targetID   interface{}
+		userAgent  string
+		changes    string // JSON, empty when the event has none
+	}{
+		{
+			name:  "user session in an organization",
+			actor: Actor{UserID: 7, MFA: true, IP: "203.0.113.9", UserAgent: "curl/8"},
+			rec: auditRecord{
+				OrganizationID: 2, Action: models.AuditPageUpdated, TargetID: 9,
+				Before: map[string]interface{}{"url": "https://example.com/pricing"},
+				After:  map[string]interface{}{"url": "https://example.com/plans"},
+			},
+			orgID:      2,
+			actorType:  models.AuditActorUser,
+			userID:     7,
+			targetType: "monitored_page",
+			targetID:   9,
+			userAgent:  "curl/8",
+			changes:    `{"url":{"before":"https://example.com/pricing","after":"https://example.com/plans"}}`,
+		},
+		{
+			name:       "API key of a user, account action",
+			actor:      Actor{UserID: 7, APIKeyID: 5, IP: "203.0.113.9", UserAgent: longUA},
+			rec:        auditRecord{Action: models.AuditAPIKeyRevoked, TargetID: 5},
+			actorType:  models.AuditActorAPIKey,
+			userID:     7,
+			apiKeyID:   5,
+			targetType: "api_key",
+			targetID:   5,
+			userAgent:  longUA[:512],
+		},
+		{
+			name:       "unsubscribe link",
+			actor:      Actor{IP: "198.51.100.4"},
+			rec:        auditRecord{OrganizationID: 2, Action: models.AuditRecipientUnsubscribed, TargetType: "project", TargetID: 3},
+			orgID:      2,
+			actorType:  models.AuditActorAnonymous,
+			targetType: "project",
+			targetID:   3,
+		},
+		{
+			name:       "worker",
+			actor:      SystemActor,
+			rec:        auditRecord{OrganizationID: 2, Action: models.AuditSubscriptionChanged},
+			orgID:      2,
+			actorType:  models.AuditActorSystem,
+			targetType: "organization",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			// Events without changes store NULL
+			changes, args := `\(NULL\)`, []driver.Value{tt.orgID, tt.actorType, tt.userID, tt.apiKeyID, tt.rec.Action, tt.targetType, tt.targetID}
+			if tt.changes != "" {
+				changes, args = `\$8`, append(args, json.RawMessage(tt.changes))
+			}
+			args = append(args, tt.actor.IP, tt.userAgent, sqlmock.AnyArg())
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO "audit_events" \("organization_id","actor_type","actor_user_id","actor_api_key_id","action","target_type","target_id","changes","ip","user_agent","created_at"\) ` +
+				`VALUES \(\$1,\$2,\$3,\$4,\$5,\$6,\$7,` + changes).
+				WithArgs(args...).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectCommit()
+
+			if err := recordAudit(db, tt.actor, tt.rec); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
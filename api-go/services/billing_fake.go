@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// FakeBillingProvider stands in for a payment provider in development and
+// tests: checkout and portal URLs point nowhere, and webhooks are
+// BillingWebhookEvent JSON signed with the hex HMAC-SHA256 of the body in
+// X-Billing-Signature (FakeBillingSignature).
+type FakeBillingProvider struct {
+	webhookSecret string
+}
+
+func NewFakeBillingProvider(webhookSecret string) *FakeBillingProvider {
+	return &FakeBillingProvider{webhookSecret: webhookSecret}
+}
+
+func (p *FakeBillingProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeBillingProvider) CreateCheckoutSession(ctx context.Context, req CheckoutRequest) (string, error) {
+	query := url.Values{
+		"organization_id": {fmt.Sprint(req.OrganizationID)},
+		"price":           {req.PriceID},
+		"success_url":     {req.SuccessURL},
+	}
+	return "http://billing.invalid/checkout?" + query.Encode(), nil
+}
+
+func (p *FakeBillingProvider) CreatePortalSession(ctx context.Context, customerID, returnURL string) (string, error) {
+	query := url.Values{"customer": {customerID}, "return_url": {returnURL}}
+	return "http://billing.invalid/portal?" + query.Encode(), nil
+}
+
+func (p *FakeBillingProvider) ParseWebhook(payload []byte, header http.Header) (*BillingWebhookEvent, error) {
+	got, err := hex.DecodeString(header.Get("X-Billing-Signature"))
+	if err != nil {
+		return nil, ErrInvalidBillingSignature
+	}
+	expected, _ := hex.DecodeString(FakeBillingSignature(p.webhookSecret, payload))
+	if !hmac.Equal(got, expected) {
+		return nil, ErrInvalidBillingSignature
+	}
+
+	var event BillingWebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid event payload: %w", err)
+	}
+	return &event, nil
+}
+
+// FakeBillingSignature signs a webhook payload for FakeBillingProvider
+func FakeBillingSignature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/rivalprice/api-go/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrBillingDisabled is returned when no payment provider is configured
+var ErrBillingDisabled = errors.New("billing is not enabled")
+
+// BillingService sells plans through a payment provider and applies the
+// provider's subscription webhooks to organizations. Quotas read the plan
+// from the organization row, so a change applies to the next request.
+type BillingService struct {
+	db       *gorm.DB
+	provider BillingProvider
+	prices   map[string]string // plan name -> provider price ID
+	appURL   string            // public frontend URL, where checkout returns
+}
+
+func NewBillingService(db *gorm.DB, provider BillingProvider) *BillingService {
+	appURL := strings.TrimRight(os.Getenv("PUBLIC_APP_URL"), "/")
+	if appURL == "" {
+		appURL = "http://localhost:3000"
+	}
+	return &BillingService{
+		db:       db,
+		provider: provider,
+		prices: map[string]string{
+			models.PlanPro:      os.Getenv("BILLING_PRICE_PRO"),
+			models.PlanBusiness: os.Getenv("BILLING_PRICE_BUSINESS"),
+		},
+		appURL: appURL,
+	}
+}
+
+// Checkout starts the subscription of orgID to plan and returns the URL of
+// the provider's checkout page. Organizations already subscribed change
+// plans in the portal.
+func (s *BillingService) Checkout(ctx context.Context, orgID uint, plan, email string) (string, error) {
+	if s.provider == nil {
+		return "", ErrBillingDisabled
+	}
+	price := s.prices[plan]
+	if price == "" {
+		return "", fmt.Errorf("plan %q cannot be purchased", plan)
+	}
+
+	var org models.Organization
+	if err := s.db.First(&org, orgID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errors.New("organization not found")
+		}
+		return "", err
+	}
+	if subscriptionLive(org.SubscriptionStatus) || org.SubscriptionStatus == "past_due" {
+		return "", errors.New("organization already has a subscription, change it in the billing portal")
+	}
+
+	req := CheckoutRequest{
+		OrganizationID: orgID,
+		PriceID:        price,
+		CustomerEmail:  email,
+		SuccessURL:     fmt.Sprintf("%s/organizations/%d/billing?checkout=success", s.appURL, orgID),
+		CancelURL:      fmt.Sprintf("%s/organizations/%d/billing?checkout=cancelled", s.appURL, orgID),
+	}
+	if org.BillingCustomerID != nil {
+		req.CustomerID = *org.BillingCustomerID
+	}
+	return s.provider.CreateCheckoutSession(ctx, req)
+}
+
+// Portal returns the URL of the provider's portal, where the customer of
+// orgID manages their subscription and payment methods
+func (s *BillingService) Portal(ctx context.Context, orgID uint) (string, error) {
+	if s.provider == nil {
+		return "", ErrBillingDisabled
+	}
+	var org models.Organization
+	if err := s.db.First(&org, orgID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errors.New("organization not found")
+		}
+		return "", err
+	}
+	if org.BillingCustomerID == nil {
+		return "", errors.New("organization has no billing account, subscribe to a plan first")
+	}
+	return s.provider.CreatePortalSession(ctx, *org.BillingCustomerID, fmt.Sprintf("%s/organizations/%d/billing", s.appURL, orgID))
+}
+
+// HandleWebhook verifies and applies a provider webhook. Each event is
+// applied once: redeliveries are recorded in billing_events and ignored,
+// and events older than the last one applied to the organization don't
+// overwrite it. A returned error other than a bad signature asks the
+// provider to retry.
+func (s *BillingService) HandleWebhook(payload []byte, header http.Header) error {
+	if s.provider == nil {
+		return ErrBillingDisabled
+	}
+	event, err := s.provider.ParseWebhook(payload, header)
+	if err != nil {
+		return err
+	}
+	if event.ID == "" {
+		return errors.New("event has no ID")
+	}
+	if event.Type == "" {
+		return nil // not an event we handle
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		record := models.BillingEvent{Provider: s.provider.Name(), EventID: event.ID, Type: event.Type}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil // already processed
+		}
+
+		org, err := s.findOrganization(tx, event)
+		if err != nil {
+			return err
+		}
+		if org == nil {
+			log.Printf("⚠️  Billing event %s (%s) matches no organization", event.ID, event.Type)
+			return nil
+		}
+		if err := tx.Model(&record).Update("organization_id", org.ID).Error; err != nil {
+			return err
+		}
+		if org.BillingEventAt != nil && event.Created.Before(*org.BillingEventAt) {
+			log.Printf("ℹ️  Billing event %s is older than the last applied to organization %d, skipped", event.ID, org.ID)
+			return nil
+		}
+
+		before := *org
+		updates := s.applyEvent(org, event)
+		if len(updates) == 0 {
+			return nil
+		}
+		updates["billing_event_at"] = event.Created
+		if err := tx.Model(org).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, SystemActor, auditRecord{
+			OrganizationID: org.ID,
+			Action:         models.AuditSubscriptionChanged,
+			TargetID:       org.ID,
+			Before:         before,
+			After:          org,
+		}); err != nil {
+			return err
+		}
+		log.Printf("💳 Organization %d: %s (plan %s, status %s)", org.ID, event.Type, org.Plan, org.SubscriptionStatus)
+		return nil
+	})
+}
+
+// findOrganization locks the organization an event is about, found by the
+// ID in the subscription metadata or else by customer. Nil if none.
+func (s *BillingService) findOrganization(tx *gorm.DB, event *BillingWebhookEvent) (*models.Organization, error) {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	switch {
+	case event.OrganizationID != 0:
+		query = query.Where("id = ?", event.OrganizationID)
+	case event.CustomerID != "":
+		query = query.Where("billing_customer_id = ?", event.CustomerID)
+	default:
+		return nil, nil
+	}
+
+	var org models.Organization
+	if err := query.First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+// applyEvent returns the organization columns event changes, and updates
+// org to match
+func (s *BillingService) applyEvent(org *models.Organization, event *BillingWebhookEvent) map[string]interface{} {
+	updates := map[string]interface{}{}
+	if event.CustomerID != "" && (org.BillingCustomerID == nil || *org.BillingCustomerID != event.CustomerID) {
+		customerID := event.CustomerID
+		org.BillingCustomerID = &customerID
+		updates["billing_customer_id"] = customerID
+	}
+
+	switch event.Type {
+	case BillingPaymentFailed:
+		// The plan is kept while the provider retries the payment; it is
+		// downgraded by the cancellation if all retries fail
+		if event.SubscriptionID != "" && event.SubscriptionID != org.SubscriptionID {
+			return updates
+		}
+		org.SubscriptionStatus = "past_due"
+
+	case BillingSubscriptionCancelled:
+		if org.SubscriptionID != "" && event.SubscriptionID != org.SubscriptionID {
+			return updates // an old subscription, replaced since
+		}
+		org.Plan = models.PlanFree
+		org.SubscriptionStatus = "canceled"
+
+	default: // created, updated
+		org.SubscriptionID = event.SubscriptionID
+		org.SubscriptionStatus = event.Status
+		switch {
+		case subscriptionLive(event.Status):
+			if plan := s.planOfPrice(event.PriceID); plan != "" {
+				org.Plan = plan
+			} else {
+				log.Printf("⚠️  Billing event %s: unknown price %q, plan unchanged", event.ID, event.PriceID)
+			}
+		case event.Status == "past_due":
+			// keep the plan while the payment is retried
+		default: // canceled, unpaid, incomplete, incomplete_expired
+			org.Plan = models.PlanFree
+		}
+	}
+
+	updates["plan"] = org.Plan
+	updates["subscription_id"] = org.SubscriptionID
+	updates["subscription_status"] = org.SubscriptionStatus
+	return updates
+}
+
+// planOfPrice is the plan sold at a provider price, or "" if none
+func (s *BillingService) planOfPrice(priceID string) string {
+	for plan, price := range s.prices {
+		if price != "" && price == priceID {
+			return plan
+		}
+	}
+	return ""
+}
+
+// subscriptionLive tells whether a subscription status grants its plan
+func subscriptionLive(status string) bool {
+	return status == "active" || status == "trialing"
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rivalprice/api-go/models"
+	"gorm.io/gorm"
+)
+
+const testWebhookSecret = "whsec_test"
+
+func newTestBillingService(db *gorm.DB) *BillingService {
+	s := NewBillingService(db, NewFakeBillingProvider(testWebhookSecret))
+	s.prices = map[string]string{models.PlanPro: "price_pro", models.PlanBusiness: "price_business"}
+	return s
+}
+
+// signedWebhook encodes event as FakeBillingProvider delivers it
+func signedWebhook(t *testing.T, secret string, event BillingWebhookEvent) ([]byte, http.Header) {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set("X-Billing-Signature", FakeBillingSignature(secret, payload))
+	return payload, header
+}
+
+var organizationColumns = []string{"id", "name", "plan", "subscription_id", "subscription_status", "billing_customer_id", "billing_event_at"}
+
+func TestBillingWebhookRejectsBadSignatures(t *testing.T) {
+	db, _ := newMockDB(t) // no query expected
+	s := newTestBillingService(db)
+	event := BillingWebhookEvent{ID: "evt_1", Type: BillingSubscriptionCancelled, OrganizationID: 3}
+
+	payload, header := signedWebhook(t, "another-secret", event)
+	if err := s.HandleWebhook(payload, header); !errors.Is(err, ErrInvalidBillingSignature) {
+		t.Errorf("HandleWebhook() with another secret = %v, want ErrInvalidBillingSignature", err)
+	}
+
+	if err := s.HandleWebhook(payload, http.Header{}); !errors.Is(err, ErrInvalidBillingSignature) {
+		t.Errorf("HandleWebhook() unsigned = %v, want ErrInvalidBillingSignature", err)
+	}
+
+	// The signature covers the whole body
+	payload, header = signedWebhook(t, testWebhookSecret, event)
+	tampered := []byte(string(payload[:len(payload)-1]) + ` `)
+	if err := s.HandleWebhook(tampered, header); !errors.Is(err, ErrInvalidBillingSignature) {
+		t.Errorf("HandleWebhook() with a modified body = %v, want ErrInvalidBillingSignature", err)
+	}
+}
+
+func TestBillingWebhookIgnoresRedeliveries(t *testing.T) {
+	db, mock := newMockDB(t)
+	s := newTestBillingService(db)
+
+	// billing_events already holds the event: the insert conflicts and the
+	// organization is left alone
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "billing_events" .* ON CONFLICT DO NOTHING`).
+		WithArgs("fake", "evt_1", BillingSubscriptionCancelled, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	payload, header := signedWebhook(t, testWebhookSecret, BillingWebhookEvent{ID: "evt_1", Type: BillingSubscriptionCancelled, OrganizationID: 3})
+	if err := s.HandleWebhook(payload, header); err != nil {
+		t.Fatalf("HandleWebhook() = %v", err)
+	}
+}
+
+func TestBillingWebhookSkipsOutOfOrderEvents(t *testing.T) {
+	db, mock := newMockDB(t)
+	s := newTestBillingService(db)
+	lastApplied := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "billing_events"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(`SELECT \* FROM "organizations" WHERE id = \$1 .*FOR UPDATE`).WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows(organizationColumns).AddRow(3, "Acme", models.PlanPro, "sub_1", "active", "cus_1", lastApplied))
+	mock.ExpectExec(`UPDATE "billing_events" SET "organization_id"=\$1 WHERE "id" = \$2`).WithArgs(3, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Created before the event already applied: no UPDATE of the organization
+	payload, header := signedWebhook(t, testWebhookSecret, BillingWebhookEvent{
+		ID: "evt_old", Type: BillingSubscriptionUpdated, Created: lastApplied.Add(-time.Minute),
+		OrganizationID: 3, SubscriptionID: "sub_1", Status: "canceled",
+	})
+	if err := s.HandleWebhook(payload, header); err != nil {
+		t.Fatalf("HandleWebhook() = %v", err)
+	}
+}
+
+func TestBillingWebhookCancellationDowngradesToFree(t *testing.T) {
+	db, mock := newMockDB(t)
+	s := newTestBillingService(db)
+	created := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "billing_events"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectQuery(`SELECT \* FROM "organizations" WHERE billing_customer_id = \$1 .*FOR UPDATE`).WithArgs("cus_1", 1).
+		WillReturnRows(sqlmock.NewRows(organizationColumns).AddRow(3, "Acme", models.PlanBusiness, "sub_1", "active", "cus_1", created.Add(-time.Hour)))
+	mock.ExpectExec(`UPDATE "billing_events" SET "organization_id"`).WithArgs(3, 8).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "organizations" SET "billing_event_at"=\$1,"plan"=\$2,"subscription_id"=\$3,"subscription_status"=\$4,"updated_at"=\$5 WHERE "id" = \$6`).
+		WithArgs(created, models.PlanFree, "sub_1", "canceled", sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_events"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	payload, header := signedWebhook(t, testWebhookSecret, BillingWebhookEvent{
+		ID: "evt_2", Type: BillingSubscriptionCancelled, Created: created, CustomerID: "cus_1", SubscriptionID: "sub_1",
+	})
+	if err := s.HandleWebhook(payload, header); err != nil {
+		t.Fatalf("HandleWebhook() = %v", err)
+	}
+}
+
+func TestApplyBillingEvent(t *testing.T) {
+	s := newTestBillingService(nil)
+	subscribed := models.Organization{Plan: models.PlanBusiness, SubscriptionID: "sub_1", SubscriptionStatus: "active"}
+
+	tests := []struct {
+		name       string
+		org        models.Organization
+		event      BillingWebhookEvent
+		wantPlan   string
+		wantStatus string
+	}{
+		{
+			name:       "subscription created",
+			org:        models.Organization{Plan: models.PlanFree},
+			event:      BillingWebhookEvent{Type: BillingSubscriptionCreated, SubscriptionID: "sub_1", Status: "active", PriceID: "price_pro"},
+			wantPlan:   models.PlanPro,
+			wantStatus: "active",
+		},
+		{
+			name:       "downgrade to a cheaper plan",
+			org:        subscribed,
+			event:      BillingWebhookEvent{Type: BillingSubscriptionUpdated, SubscriptionID: "sub_1", Status: "active", PriceID: "price_pro"},
+			wantPlan:   models.PlanPro,
+			wantStatus: "active",
+		},
+		{
+			name:       "unknown price keeps the plan",
+			org:        subscribed,
+			event:      BillingWebhookEvent{Type: BillingSubscriptionUpdated, SubscriptionID: "sub_1", Status: "active", PriceID: "price_legacy"},
+			wantPlan:   models.PlanBusiness,
+			wantStatus: "active",
+		},
+		{
+			name:       "past due keeps the plan",
+			org:        subscribed,
+			event:      BillingWebhookEvent{Type: BillingSubscriptionUpdated, SubscriptionID: "sub_1", Status: "past_due", PriceID: "price_business"},
+			wantPlan:   models.PlanBusiness,
+			wantStatus: "past_due",
+		},
+		{
+			name:       "unpaid downgrades to free",
+			org:        subscribed,
+			event:      BillingWebhookEvent{Type: BillingSubscriptionUpdated, SubscriptionID: "sub_1", Status: "unpaid", PriceID: "price_business"},
+			wantPlan:   models.PlanFree,
+			wantStatus: "unpaid",
+		},
+		{
+			name:       "cancellation downgrades to free",
+			org:        subscribed,
+			event:      BillingWebhookEvent{Type: BillingSubscriptionCancelled, SubscriptionID: "sub_1"},
+			wantPlan:   models.PlanFree,
+			wantStatus: "canceled",
+		},
+		{
+			name:       "cancellation of a replaced subscription",
+			org:        subscribed,
+			event:      BillingWebhookEvent{Type: BillingSubscriptionCancelled, SubscriptionID: "sub_0"},
+			wantPlan:   models.PlanBusiness,
+			wantStatus: "active",
+		},
+		{
+			name:       "payment failed keeps the plan while retried",
+			org:        subscribed,
+			event:      BillingWebhookEvent{Type: BillingPaymentFailed, SubscriptionID: "sub_1"},
+			wantPlan:   models.PlanBusiness,
+			wantStatus: "past_due",
+		},
+		{
+			name:       "payment failed for another subscription",
+			org:        subscribed,
+			event:      BillingWebhookEvent{Type: BillingPaymentFailed, SubscriptionID: "sub_0"},
+			wantPlan:   models.PlanBusiness,
+			wantStatus: "active",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org := tt.org
+			updates := s.applyEvent(&org, &tt.event)
+			if org.Plan != tt.wantPlan || org.SubscriptionStatus != tt.wantStatus {
+				t.Errorf("applyEvent() = plan %s, status %s, want %s, %s", org.Plan, org.SubscriptionStatus, tt.wantPlan, tt.wantStatus)
+			}
+			if plan, ok := updates["plan"]; ok && plan != org.Plan {
+				t.Errorf("applyEvent() updates plan to %v, organization has %s", plan, org.Plan)
+			}
+		})
+	}
+}
+
+func TestStripeWebhooks(t *testing.T) {
+	p := NewStripeProvider("sk_test", testWebhookSecret)
+	sign := func(payload []byte, secret string, at time.Time) http.Header {
+		timestamp := fmt.Sprint(at.Unix())
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(payload)
+		header := http.Header{}
+		header.Set("Stripe-Signature", "t="+timestamp+",v1="+hex.EncodeToString(mac.Sum(nil)))
+		return header
+	}
+
+	payload := []byte(`{"id": "evt_3", "type": "invoice.payment_failed", "created": 1772452800,
+		"data": {"object": {"id": "in_1", "customer": "cus_1", "subscription": "sub_1"}}}`)
+	event, err := p.ParseWebhook(payload, sign(payload, testWebhookSecret, time.Now()))
+	if err != nil {
+		t.Fatalf("ParseWebhook() = %v", err)
+	}
+	if event.ID != "evt_3" || event.Type != BillingPaymentFailed || event.CustomerID != "cus_1" || event.SubscriptionID != "sub_1" {
+		t.Errorf("ParseWebhook() = %+v, want payment.failed of sub_1 for cus_1", event)
+	}
+
+	for name, header := range map[string]http.Header{
+		"another secret":    sign(payload, "another-secret", time.Now()),
+		"replayed too late": sign(payload, testWebhookSecret, time.Now().Add(-time.Hour)),
+		"unsigned":          {},
+	} {
+		if _, err := p.ParseWebhook(payload, header); !errors.Is(err, ErrInvalidBillingSignature) {
+			t.Errorf("ParseWebhook() with %s = %v, want ErrInvalidBillingSignature", name, err)
+		}
+	}
+}
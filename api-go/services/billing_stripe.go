@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	stripeAPIURL = "https://api.stripe.com/v1"
+
+	// stripeSignatureTolerance rejects replays of old signed payloads
+	stripeSignatureTolerance = 5 * time.Minute
+)
+
+// StripeProvider bills through Stripe Checkout, the Stripe customer portal
+// and Stripe webhooks
+type StripeProvider struct {
+	http          *http.Client
+	secretKey     string
+	webhookSecret string
+}
+
+func NewStripeProvider(secretKey, webhookSecret string) *StripeProvider {
+	return &StripeProvider{
+		http:          &http.Client{Timeout: 15 * time.Second},
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+	}
+}
+
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+func (p *StripeProvider) CreateCheckoutSession(ctx context.Context, req CheckoutRequest) (string, error) {
+	orgID := strconv.FormatUint(uint64(req.OrganizationID), 10)
+	form := url.Values{
+		"mode":                    {"subscription"},
+		"line_items[0][price]":    {req.PriceID},
+		"line_items[0][quantity]": {"1"},
+		"success_url":             {req.SuccessURL},
+		"cancel_url":              {req.CancelURL},
+		"client_reference_id":     {orgID},
+		"subscription_data[metadata][organization_id]": {orgID},
+	}
+	if req.CustomerID != "" {
+		form.Set("customer", req.CustomerID)
+	} else if req.CustomerEmail != "" {
+		form.Set("customer_email", req.CustomerEmail)
+	}
+
+	var session struct {
+		URL string `json:"url"`
+	}
+	if err := p.post(ctx, "/checkout/sessions", form, &session); err != nil {
+		return "", err
+	}
+	return session.URL, nil
+}
+
+func (p *StripeProvider) CreatePortalSession(ctx context.Context, customerID, returnURL string) (string, error) {
+	form := url.Values{"customer": {customerID}, "return_url": {returnURL}}
+	var session struct {
+		URL string `json:"url"`
+	}
+	if err := p.post(ctx, "/billing_portal/sessions", form, &session); err != nil {
+		return "", err
+	}
+	return session.URL, nil
+}
+
+// stripeEvent is the part of a Stripe event we read. data.object is a
+// subscription or an invoice depending on the type.
+type stripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object struct {
+			ID           string            `json:"id"`
+			Customer     string            `json:"customer"`
+			Subscription string            `json:"subscription"` // invoices
+			Status       string            `json:"status"`
+			Metadata     map[string]string `json:"metadata"`
+			Items        struct {
+				Data []struct {
+					Price struct {
+						ID string `json:"id"`
+					} `json:"price"`
+				} `json:"data"`
+			} `json:"items"`
+		} `json:"object"`
+	} `json:"data"`
+}
+
+func (p *StripeProvider) ParseWebhook(payload []byte, header http.Header) (*BillingWebhookEvent, error) {
+	if err := p.verifySignature(payload, header.Get("Stripe-Signature"), time.Now()); err != nil {
+		return nil, err
+	}
+
+	var raw stripeEvent
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("invalid event payload: %w", err)
+	}
+	obj := raw.Data.Object
+	event := &BillingWebhookEvent{
+		ID:         raw.ID,
+		Created:    time.Unix(raw.Created, 0),
+		CustomerID: obj.Customer,
+	}
+
+	switch raw.Type {
+	case "customer.subscription.created":
+		event.Type = BillingSubscriptionCreated
+	case "customer.subscription.updated":
+		event.Type = BillingSubscriptionUpdated
+	case "customer.subscription.deleted":
+		event.Type = BillingSubscriptionCancelled
+	case "invoice.payment_failed":
+		event.Type = BillingPaymentFailed
+		event.SubscriptionID = obj.Subscription
+		return event, nil
+	default:
+		return event, nil // acknowledged, not handled
+	}
+
+	event.SubscriptionID = obj.ID
+	event.Status = obj.Status
+	if len(obj.Items.Data) > 0 {
+		event.PriceID = obj.Items.Data[0].Price.ID
+	}
+	if id, err := strconv.ParseUint(obj.Metadata["organization_id"], 10, 32); err == nil {
+		event.OrganizationID = uint(id)
+	}
+	return event, nil
+}
+
+// verifySignature checks a Stripe-Signature header: t=<unix>,v1=<hex>,...
+// where v1 is the HMAC-SHA256 of "<t>.<payload>" with the webhook secret
+func (p *StripeProvider) verifySignature(payload []byte, header string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidBillingSignature
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > stripeSignatureTolerance || age < -stripeSignatureTolerance {
+		return ErrInvalidBillingSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.webhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+	for _, signature := range signatures {
+		if got, err := hex.DecodeString(signature); err == nil && hmac.Equal(got, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidBillingSignature
+}
+
+func (p *StripeProvider) post(ctx context.Context, path string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, stripeAPIURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.secretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("payment provider unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(body, &apiErr)
+		if apiErr.Error.Message != "" {
+			return errors.New("payment provider: " + apiErr.Error.Message)
+		}
+		return fmt.Errorf("payment provider returned %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
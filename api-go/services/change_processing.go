@@ -0,0 +1,148 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxProcessingAttempts is the number of tries before a failed change is
+	// left alone (still visible with state=failed and its reason)
+	maxProcessingAttempts = 5
+
+	// processingLease is how long a claim stays valid. A worker that dies
+	// mid-change releases it implicitly once the lease expires.
+	processingLease = 10 * time.Minute
+
+	// retryBackoffMinutes is the wait after the first failure, doubled on
+	// each further attempt and capped by maxRetryBackoffMinutes
+	retryBackoffMinutes    = 1
+	maxRetryBackoffMinutes = 60
+)
+
+// claimableCondition selects processing rows a worker may take over
+const claimableCondition = `(state = ? OR (state = ? AND attempts < ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)) OR (state = ? AND claimed_at < ?))`
+
+func claimableArgs(now time.Time) []interface{} {
+	return []interface{}{
+		models.ProcessingPending,
+		models.ProcessingFailed, maxProcessingAttempts, now,
+		models.ProcessingInProgress, now.Add(-processingLease),
+	}
+}
+
+// nextAttemptExpr schedules the retry of a failed change with exponential
+// backoff on the attempts already made
+var nextAttemptExpr = gorm.Expr(
+	"NOW() + LEAST(? * power(2, GREATEST(attempts - 1, 0)), ?) * interval '1 minute'",
+	retryBackoffMinutes, maxRetryBackoffMinutes)
+
+// registerNewChanges creates a pending processing row for every detected
+// change that does not have one yet
+func (s *AlertService) registerNewChanges() error {
+	return s.db.Exec(`
+		INSERT INTO change_processing (change_id, state, attempts, created_at, updated_at)
+		SELECT dc.id, ?, 0, NOW(), NOW()
+		FROM detected_changes dc
+		LEFT JOIN change_processing cp ON cp.change_id = dc.id
+		WHERE cp.change_id IS NULL
+		ON CONFLICT (change_id) DO NOTHING
+	`, models.ProcessingPending).Error
+}
+
+// ClaimChanges locks up to limit claimable changes for this worker and marks
+// them as processing. Rows locked by another worker are skipped, so several
+// API instances can sweep concurrently without picking the same change.
+func (s *AlertService) ClaimChanges(worker string, limit int) ([]models.DetectedChange, error) {
+	if err := s.registerNewChanges(); err != nil {
+		return nil, err
+	}
+
+	var ids []uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var rows []models.ChangeProcessing
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where(claimableCondition, claimableArgs(now)...).
+			Order("change_id").Limit(limit).
+			Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		for _, row := range rows {
+			ids = append(ids, row.ChangeID)
+		}
+		return tx.Model(&models.ChangeProcessing{}).
+			Where("change_id IN ?", ids).
+			Updates(map[string]interface{}{
+				"state":      models.ProcessingInProgress,
+				"claimed_by": worker,
+				"claimed_at": now,
+				"attempts":   gorm.Expr("attempts + 1"),
+			}).Error
+	})
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var changes []models.DetectedChange
+	if err := s.db.Where("id IN ?", ids).Order("detected_at ASC").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ClaimChange claims a single change announced by an event. It returns false
+// when the change is already handled or being handled by another worker.
+func (s *AlertService) ClaimChange(changeID uint, worker string) (bool, error) {
+	claimed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		row := models.ChangeProcessing{ChangeID: changeID, State: models.ProcessingPending}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var locked []models.ChangeProcessing
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("change_id = ?", changeID).
+			Where(claimableCondition, claimableArgs(now)...).
+			Find(&locked).Error
+		if err != nil || len(locked) == 0 {
+			return err
+		}
+
+		claimed = true
+		return tx.Model(&locked[0]).Updates(map[string]interface{}{
+			"state":      models.ProcessingInProgress,
+			"claimed_by": worker,
+			"claimed_at": now,
+			"attempts":   gorm.Expr("attempts + 1"),
+		}).Error
+	})
+	return claimed, err
+}
+
+// finishProcessing records the outcome of a claimed change. A failed change
+// is retried by a later sweep once its backoff has elapsed.
+func (s *AlertService) finishProcessing(changeID uint, state models.ProcessingState, reason string) {
+	updates := map[string]interface{}{
+		"state":           state,
+		"reason":          reason,
+		"next_attempt_at": nil,
+	}
+	if state == models.ProcessingFailed {
+		updates["next_attempt_at"] = nextAttemptExpr
+	}
+	err := s.db.Model(&models.ChangeProcessing{}).
+		Where("change_id = ?", changeID).
+		Updates(updates).Error
+	if err != nil {
+		log.Printf("⚠️  AlertService: failed to record state %s for change %d: %v", state, changeID, err)
+	}
+}
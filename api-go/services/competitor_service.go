@@ -15,7 +15,7 @@ func NewCompetitorService(db *gorm.DB) *CompetitorService {
 	return &CompetitorService{db: db}
 }
 
-func (s *CompetitorService) CreateCompetitor(projectID uint, name, url string) (*models.Competitor, error) {
+func (s *CompetitorService) CreateCompetitor(actor Actor, projectID uint, name, url string) (*models.Competitor, error) {
 	// Verify project exists
 	var project models.Project
 	if err := s.db.First(&project, projectID).Error; err != nil {
@@ -31,8 +31,24 @@ func (s *CompetitorService) CreateCompetitor(projectID uint, name, url string) (
 		URL:       url,
 	}
 
-	if err := s.db.Create(&competitor).Error; err != nil {
-		return nil, errors.New("failed to create competitor")
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if project.OrganizationID != nil {
+			if err := checkPlanCount(tx, *project.OrganizationID, resourceCompetitors); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&competitor).Error; err != nil {
+			return errors.New("failed to create competitor")
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: projectOrgID(&project),
+			Action:         models.AuditCompetitorCreated,
+			TargetID:       competitor.ID,
+			After:          competitor,
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &competitor, nil
@@ -49,29 +65,32 @@ func (s *CompetitorService) GetCompetitorByID(id uint) (*models.Competitor, erro
 	return &competitor, nil
 }
 
-func (s *CompetitorService) GetAllCompetitorsPaginated(offset, limit int) ([]models.Competitor, int64, error) {
+// GetAllCompetitorsPaginated returns the competitors of every project viewer can see
+func (s *CompetitorService) GetAllCompetitorsPaginated(viewer Viewer, offset, limit int) ([]models.Competitor, int64, error) {
 	var competitors []models.Competitor
 	var total int64
-	
-	if err := s.db.Model(&models.Competitor{}).Count(&total).Error; err != nil {
+	visible := visibleProjectIDs(s.db, viewer)
+
+	if err := s.db.Model(&models.Competitor{}).Where("project_id IN (?)", visible).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
-	
-	if err := s.db.Preload("Project").Offset(offset).Limit(limit).Find(&competitors).Error; err != nil {
+
+	if err := s.db.Preload("Project").Where("project_id IN (?)", visible).Offset(offset).Limit(limit).Find(&competitors).Error; err != nil {
 		return nil, 0, err
 	}
 	return competitors, total, nil
 }
 
-func (s *CompetitorService) GetCompetitorsByProjectIDPaginated(projectID uint, offset, limit int) ([]models.Competitor, int64, error) {
+func (s *CompetitorService) GetCompetitorsByProjectIDPaginated(viewer Viewer, projectID uint, offset, limit int) ([]models.Competitor, int64, error) {
 	var competitors []models.Competitor
 	var total int64
-	
-	if err := s.db.Model(&models.Competitor{}).Where("project_id = ?", projectID).Count(&total).Error; err != nil {
+	visible := visibleProjectIDs(s.db, viewer)
+
+	if err := s.db.Model(&models.Competitor{}).Where("project_id = ? AND project_id IN (?)", projectID, visible).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
-	
-	if err := s.db.Where("project_id = ?", projectID).Offset(offset).Limit(limit).Find(&competitors).Error; err != nil {
+
+	if err := s.db.Where("project_id = ? AND project_id IN (?)", projectID, visible).Offset(offset).Limit(limit).Find(&competitors).Error; err != nil {
 		return nil, 0, err
 	}
 	return competitors, total, nil
@@ -95,7 +114,7 @@ func (s *CompetitorService) GetCompetitorsByProjectID(projectID uint) ([]models.
 	return competitors, nil
 }
 
-func (s *CompetitorService) UpdateCompetitor(id uint, name, url string) (*models.Competitor, error) {
+func (s *CompetitorService) UpdateCompetitor(actor Actor, id uint, name, url string) (*models.Competitor, error) {
 	var competitor models.Competitor
 	if err := s.db.First(&competitor, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -104,16 +123,23 @@ func (s *CompetitorService) UpdateCompetitor(id uint, name, url string) (*models
 		return nil, err
 	}
 
+	before := competitor
 	competitor.Name = name
 	competitor.URL = url
-	if err := s.db.Save(&competitor).Error; err != nil {
-		return nil, errors.New("failed to update competitor")
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&competitor).Error; err != nil {
+			return errors.New("failed to update competitor")
+		}
+		return s.audit(tx, actor, models.AuditCompetitorUpdated, &competitor, before, competitor)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &competitor, nil
 }
 
-func (s *CompetitorService) DeleteCompetitor(id uint) error {
+func (s *CompetitorService) DeleteCompetitor(actor Actor, id uint) error {
 	var competitor models.Competitor
 	if err := s.db.First(&competitor, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -122,12 +148,24 @@ func (s *CompetitorService) DeleteCompetitor(id uint) error {
 		return err
 	}
 
-	// Delete related monitored pages first
-	s.db.Where("competitor_id = ?", id).Delete(&models.MonitoredPage{})
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Delete related monitored pages first
+		tx.Where("competitor_id = ?", id).Delete(&models.MonitoredPage{})
 
-	if err := s.db.Delete(&competitor).Error; err != nil {
-		return errors.New("failed to delete competitor")
-	}
+		if err := tx.Delete(&competitor).Error; err != nil {
+			return errors.New("failed to delete competitor")
+		}
+		return s.audit(tx, actor, models.AuditCompetitorDeleted, &competitor, competitor, nil)
+	})
+}
 
-	return nil
+// audit records an action on competitor, in its project's organization
+func (s *CompetitorService) audit(tx *gorm.DB, actor Actor, action string, competitor *models.Competitor, before, after interface{}) error {
+	return recordAudit(tx, actor, auditRecord{
+		OrganizationID: auditOrg(NewOrganizationService(tx).OrgOfProject, competitor.ProjectID),
+		Action:         action,
+		TargetID:       competitor.ID,
+		Before:         before,
+		After:          after,
+	})
 }
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/domain/queue"
+	"github.com/rivalprice/domain/queue/contracttest"
+)
+
+// The API produces scrape jobs
+func TestScrapeJobContract(t *testing.T) {
+	var page models.MonitoredPage
+	page.ID = 42
+	page.URL = "https://example.com/pricing"
+	page.PageType = string(models.PageTypePricing)
+
+	payload, err := scrapeJobPayload(&page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contracttest.ScrapeJobProducer(t, payload, queue.ScrapeJob{PageID: 42, URL: page.URL, Type: page.PageType})
+}
+
+// The API consumes snapshot and change events
+func TestStreamEventContract(t *testing.T) {
+	contracttest.EventConsumer(t, func(values map[string]interface{}) (queue.Event, error) {
+		event, err := ParseStreamEvent("1-0", values)
+		return event.Event, err
+	})
+}
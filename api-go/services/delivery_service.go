@@ -0,0 +1,298 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxDeliveryAttempts is the number of tries before a queued notification
+	// is given up (it stays in pending_notifications with its last error)
+	maxDeliveryAttempts = 5
+
+	// deliveryLease is how long a claimed notification stays with the worker
+	// sending it. A worker that dies mid-send releases it once it expires.
+	deliveryLease = 10 * time.Minute
+)
+
+// DeliveryService sends alert notifications, holding the ones deferred by
+// quiet hours until the window ends
+type DeliveryService struct {
+	db       *gorm.DB
+	emailSvc *EmailService
+}
+
+func NewDeliveryService(db *gorm.DB) *DeliveryService {
+	return &DeliveryService{
+		db:       db,
+		emailSvc: NewEmailService(),
+	}
+}
+
+// delivery is one notification to one recipient
+type delivery struct {
+	channel          string    // email, webhook
+	recipient        string    // email address or webhook URL
+	unsubscribeToken string    // project recipients only, cancels the queued ones on unsubscribe
+	deliverAfter     time.Time // zero to send now
+}
+
+// dedupeDeliveries keeps the first delivery per channel and recipient
+func dedupeDeliveries(deliveries []delivery) []delivery {
+	seen := map[string]bool{}
+	var out []delivery
+	for _, d := range deliveries {
+		key := d.channel + "|" + strings.ToLower(d.recipient)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, d)
+	}
+	return out
+}
+
+// notice is what a notification says, taken from an alert or a monitor alert
+type notice struct {
+	kind           string // models.PendingKindAlert or models.PendingKindMonitor
+	alertID        uint
+	alertType      string
+	severity       models.AlertSeverity
+	summary        string
+	recommendation string
+	message        string // webhook payload
+	pageID         int
+	diffPath       string
+	notifyChannel  string // channels the alert went out on so far
+}
+
+func alertNotice(alert *models.AlertLog) notice {
+	return notice{
+		kind:           models.PendingKindAlert,
+		alertID:        alert.ID,
+		alertType:      alert.AlertType,
+		severity:       alert.Severity,
+		summary:        alert.AISummary,
+		recommendation: alert.AIRecommendation,
+		message:        alert.Message,
+		pageID:         alert.PageID,
+		diffPath:       alert.DiffURL,
+		notifyChannel:  alert.NotifyChannel,
+	}
+}
+
+func monitorNotice(alert *models.MonitorAlert, page *models.MonitoredPage) notice {
+	summary := fmt.Sprintf("Monitoring of %s is broken: %s", page.URL, alert.Detail)
+	return notice{
+		kind:           models.PendingKindMonitor,
+		alertID:        alert.ID,
+		alertType:      alert.AlertType,
+		severity:       alert.Severity,
+		summary:        summary,
+		recommendation: "Check the page and update its URL or CSS selector",
+		message:        summary,
+		pageID:         int(alert.PageID),
+	}
+}
+
+// Dispatch sends the deliveries due now and queues the ones held by quiet
+// hours, then records the outcome on the alert
+func (s *DeliveryService) Dispatch(alert *models.AlertLog, deliveries []delivery, now time.Time) {
+	sent, queued := s.deliver(alertNotice(alert), deliveries, now)
+
+	channel := alert.NotifyChannel
+	for _, c := range sent {
+		channel = mergeChannel(channel, c)
+	}
+	if len(sent) == 0 && queued {
+		channel = "queued"
+	}
+
+	updates := map[string]interface{}{"notify_channel": channel}
+	if len(sent) > 0 {
+		updates["notified"] = true
+		updates["notified_at"] = now
+	}
+	if channel != alert.NotifyChannel || len(sent) > 0 {
+		s.db.Model(alert).Updates(updates)
+	}
+}
+
+// DispatchMonitor sends a monitor_broken alert the same way as Dispatch
+func (s *DeliveryService) DispatchMonitor(alert *models.MonitorAlert, page *models.MonitoredPage, deliveries []delivery, now time.Time) {
+	if sent, _ := s.deliver(monitorNotice(alert, page), deliveries, now); len(sent) > 0 {
+		s.db.Model(alert).Update("notified", true)
+	}
+}
+
+// deliver sends the deliveries due now and queues the others. It returns the
+// channel of each delivery sent, and whether any was queued.
+func (s *DeliveryService) deliver(n notice, deliveries []delivery, now time.Time) ([]string, bool) {
+	var sent []string
+	var pending []models.PendingNotification
+
+	for _, d := range deliveries {
+		if d.deliverAfter.After(now) {
+			pending = append(pending, models.PendingNotification{
+				AlertID:          n.alertID,
+				AlertKind:        n.kind,
+				Channel:          d.channel,
+				Recipient:        d.recipient,
+				UnsubscribeToken: d.unsubscribeToken,
+				DeliverAfter:     d.deliverAfter,
+			})
+			continue
+		}
+
+		if err := s.send(d.channel, d.recipient, d.unsubscribeToken, n); err != nil {
+			log.Printf("⚠️  %s notification to %s failed: %v", d.channel, d.recipient, err)
+			continue
+		}
+		sent = append(sent, d.channel)
+	}
+
+	if len(pending) == 0 {
+		return sent, false
+	}
+	if err := s.db.Create(&pending).Error; err != nil {
+		log.Printf("❌ DeliveryService: failed to queue %d notification(s) for %s %d: %v", len(pending), n.kind, n.alertID, err)
+		return sent, false
+	}
+	log.Printf("🌙 DeliveryService: %d notification(s) for %s %d queued (quiet hours)", len(pending), n.kind, n.alertID)
+	return sent, true
+}
+
+// FlushDue sends every queued notification whose quiet hours are over. The
+// due rows are claimed in a short transaction (SKIP LOCKED, so several API
+// instances can flush at once), then sent with no lock or transaction held,
+// and each outcome is recorded once its send returns.
+func (s *DeliveryService) FlushDue(now time.Time) (int, error) {
+	due, err := s.claimDue(now)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range due {
+		if s.deliverQueued(&due[i], now) {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// claimDue claims up to 100 due notifications. A claim counts as an attempt,
+// so a notification whose worker keeps dying is eventually given up.
+func (s *DeliveryService) claimDue(now time.Time) ([]models.PendingNotification, error) {
+	var due []models.PendingNotification
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("delivered_at IS NULL AND deliver_after <= ? AND attempts < ?", now, maxDeliveryAttempts).
+			Where("claimed_at IS NULL OR claimed_at < ?", now.Add(-deliveryLease)).
+			Order("deliver_after, id").
+			Limit(100).
+			Find(&due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(due))
+		for i, p := range due {
+			ids[i] = p.ID
+		}
+		return tx.Model(&models.PendingNotification{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"claimed_at": now,
+				"attempts":   gorm.Expr("attempts + 1"),
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// deliverQueued sends a claimed notification, then releases the claim with
+// the outcome
+func (s *DeliveryService) deliverQueued(p *models.PendingNotification, now time.Time) bool {
+	n, err := s.queuedNotice(p)
+	if err != nil {
+		s.db.Model(p).Updates(map[string]interface{}{"attempts": maxDeliveryAttempts, "last_error": "alert not found", "claimed_at": nil})
+		return false
+	}
+
+	if err := s.send(p.Channel, p.Recipient, p.UnsubscribeToken, n); err != nil {
+		log.Printf("⚠️  DeliveryService: %s to %s for %s %d failed: %v", p.Channel, p.Recipient, n.kind, n.alertID, err)
+		s.db.Model(p).Updates(map[string]interface{}{"last_error": err.Error(), "claimed_at": nil})
+		return false
+	}
+
+	s.db.Model(p).Updates(map[string]interface{}{"delivered_at": now, "claimed_at": nil})
+	if n.kind == models.PendingKindMonitor {
+		s.db.Model(&models.MonitorAlert{}).Where("id = ?", n.alertID).Update("notified", true)
+	} else {
+		s.db.Model(&models.AlertLog{}).Where("id = ?", n.alertID).Updates(map[string]interface{}{
+			"notified":       true,
+			"notified_at":    now,
+			"notify_channel": mergeChannel(n.notifyChannel, p.Channel),
+		})
+	}
+	return true
+}
+
+// queuedNotice loads the alert a queued notification belongs to
+func (s *DeliveryService) queuedNotice(p *models.PendingNotification) (notice, error) {
+	if p.AlertKind == models.PendingKindMonitor {
+		var alert models.MonitorAlert
+		if err := s.db.First(&alert, p.AlertID).Error; err != nil {
+			return notice{}, err
+		}
+		var page models.MonitoredPage
+		if err := s.db.First(&page, alert.PageID).Error; err != nil {
+			return notice{}, err
+		}
+		return monitorNotice(&alert, &page), nil
+	}
+
+	var alert models.AlertLog
+	if err := s.db.First(&alert, p.AlertID).Error; err != nil {
+		return notice{}, err
+	}
+	return alertNotice(&alert), nil
+}
+
+func (s *DeliveryService) send(channel, recipient, unsubscribeToken string, n notice) error {
+	switch channel {
+	case "email":
+		unsubscribePath := ""
+		if unsubscribeToken != "" {
+			unsubscribePath = UnsubscribePath(unsubscribeToken)
+		}
+		return s.emailSvc.SendAlert(recipient, n.alertType, string(n.severity), n.summary, n.recommendation, n.pageID, n.diffPath, unsubscribePath)
+	case "webhook":
+		return s.emailSvc.SendWebhook(recipient, n.alertType, string(n.severity), n.message, n.recommendation, n.pageID, n.alertID)
+	default:
+		return nil
+	}
+}
+
+// mergeChannel adds channel to an alert's notify_channel list
+func mergeChannel(existing, channel string) string {
+	switch existing {
+	case "", "log", "queued":
+		return channel
+	}
+	for _, c := range strings.Split(existing, ",") {
+		if c == channel {
+			return existing
+		}
+	}
+	return existing + "," + channel
+}
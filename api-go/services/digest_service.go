@@ -0,0 +1,292 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// digestTopRecommendations is the number of AI recommendations listed at
+	// the end of a digest, highest impact first
+	digestTopRecommendations = 5
+
+	// digestLockID is the first key of the per-user Postgres advisory lock
+	// taken while a digest is sent (the second key is the user ID)
+	digestLockID = 7324002
+)
+
+// DigestService batches the non-critical alerts of the projects a user owns
+// or collaborates on into one daily or weekly email, sent at the user's local
+// digest hour (critical alerts are always emailed immediately)
+type DigestService struct {
+	db       *gorm.DB
+	emailSvc *EmailService
+}
+
+func NewDigestService(db *gorm.DB) *DigestService {
+	return &DigestService{
+		db:       db,
+		emailSvc: NewEmailService(),
+	}
+}
+
+// digestAlert is an alert with the names it is grouped under
+type digestAlert struct {
+	models.AlertLog
+	ProjectName    string
+	CompetitorName string
+}
+
+// usesDigest reports whether a non-critical alert should wait for the digest
+// rather than be emailed immediately
+func usesDigest(settings *models.UserNotificationSettings, severity models.AlertSeverity) bool {
+	if severity == models.SeverityCritical {
+		return false
+	}
+	return settings.DigestFrequency == models.DigestDaily || settings.DigestFrequency == models.DigestWeekly
+}
+
+// settingsLocation returns the user's timezone, UTC when unset or unknown
+func settingsLocation(settings *models.UserNotificationSettings) *time.Location {
+	if settings.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// lastDigestSlot returns the most recent scheduled digest time at or before now
+func lastDigestSlot(settings *models.UserNotificationSettings, now time.Time) time.Time {
+	loc := settingsLocation(settings)
+	local := now.In(loc)
+	slot := time.Date(local.Year(), local.Month(), local.Day(), settings.DigestHour, 0, 0, 0, loc)
+
+	period := 1
+	if settings.DigestFrequency == models.DigestWeekly {
+		period = 7
+		back := (int(local.Weekday()) - settings.DigestWeekday + 7) % 7
+		slot = slot.AddDate(0, 0, -back)
+	}
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -period)
+	}
+	return slot
+}
+
+// digestDue reports whether a digest slot has passed since the last digest
+func digestDue(settings *models.UserNotificationSettings, now time.Time) bool {
+	if settings.DigestFrequency != models.DigestDaily && settings.DigestFrequency != models.DigestWeekly {
+		return false
+	}
+	return settings.LastDigestAt == nil || settings.LastDigestAt.Before(lastDigestSlot(settings, now))
+}
+
+// SendDueDigests sends every digest whose slot has passed
+func (s *DigestService) SendDueDigests(now time.Time) error {
+	var all []models.UserNotificationSettings
+	err := s.db.Where("digest_frequency IN ?", []string{models.DigestDaily, models.DigestWeekly}).
+		Find(&all).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range all {
+		if !digestDue(&all[i], now) {
+			continue
+		}
+		if err := s.sendDigestLocked(all[i].UserID, now); err != nil {
+			log.Printf("❌ DigestService: digest for user %d failed: %v", all[i].UserID, err)
+		}
+	}
+	return nil
+}
+
+// sendDigestLocked sends the digest of userID under a per-user advisory
+// lock, so API replicas never send the same digest. The lock is held by the
+// session, outside any transaction: no row stays locked while the email is
+// sent. When another replica holds it, the digest is skipped.
+func (s *DigestService) sendDigestLocked(userID uint, now time.Time) error {
+	return s.db.Connection(func(conn *gorm.DB) error {
+		var locked bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?, ?)", digestLockID, userID).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?, ?)", digestLockID, userID)
+
+		// Reload under the lock: another replica may have sent it meanwhile
+		var settings models.UserNotificationSettings
+		if err := conn.Preload("User").Where("user_id = ?", userID).First(&settings).Error; err != nil {
+			return err
+		}
+		if !digestDue(&settings, now) {
+			return nil
+		}
+		return s.sendDigest(conn, &settings, now)
+	})
+}
+
+// sendDigest emails the alerts the user has not received in a digest yet,
+// then records them. A failed send records nothing, so the next run retries
+// with the same alerts.
+func (s *DigestService) sendDigest(db *gorm.DB, settings *models.UserNotificationSettings, now time.Time) error {
+	// Look one period behind the last digest: alerts committed after it
+	// with an earlier created_at are still picked up
+	period := 7
+	if settings.DigestFrequency == models.DigestDaily {
+		period = 1
+	}
+	since := now.AddDate(0, 0, -period)
+	if settings.LastDigestAt != nil {
+		since = settings.LastDigestAt.AddDate(0, 0, -period)
+	}
+
+	var alerts []digestAlert
+	err := db.Table("alert_logs al").
+		Select("al.*, p.name AS project_name, c.name AS competitor_name").
+		Joins("JOIN monitored_pages mp ON mp.id = al.page_id").
+		Joins("JOIN competitors c ON c.id = mp.competitor_id").
+		Joins("JOIN projects p ON p.id = c.project_id").
+		Where("(p.user_id = ? OR p.id IN (SELECT project_id FROM project_recipients WHERE user_id = ? AND unsubscribed_at IS NULL))",
+			settings.UserID, settings.UserID).
+		Where("al.severity <> ?", models.SeverityCritical).
+		Where("al.state NOT IN ?", []models.AlertState{models.AlertStateResolved, models.AlertStateDismissed}).
+		Where("al.created_at > ? AND al.created_at <= ?", since, now).
+		Where("NOT EXISTS (SELECT 1 FROM digested_alerts da WHERE da.alert_id = al.id AND da.user_id = ?)", settings.UserID).
+		Order("p.name, c.name, al.created_at").
+		Scan(&alerts).Error
+	if err != nil {
+		return err
+	}
+
+	sent := len(alerts) > 0 && settings.NotifyEmail && settings.User.Email != ""
+	if sent {
+		subject, body := buildDigest(settings, alerts, now)
+		if err := s.emailSvc.SendDigest(settings.User.Email, subject, body); err != nil {
+			return err
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if sent {
+			ids := make([]uint, len(alerts))
+			entries := make([]models.DigestedAlert, len(alerts))
+			for i, a := range alerts {
+				ids[i] = a.ID
+				entries[i] = models.DigestedAlert{UserID: settings.UserID, AlertID: a.ID, DigestedAt: now}
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entries).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.AlertLog{}).Where("id IN ? AND digested_at IS NULL", ids).
+				Update("digested_at", now).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.AlertLog{}).Where("id IN ? AND notified = ?", ids, false).
+				Updates(map[string]interface{}{
+					"notified":       true,
+					"notified_at":    now,
+					"notify_channel": "digest",
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(settings).Update("last_digest_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+	if sent {
+		log.Printf("📧 DigestService: %s digest with %d alert(s) sent to user %d", settings.DigestFrequency, len(alerts), settings.UserID)
+	}
+	return nil
+}
+
+// buildDigest renders the digest email: alerts grouped by project and
+// competitor, a price movement table per competitor, then the top AI
+// recommendations
+func buildDigest(settings *models.UserNotificationSettings, alerts []digestAlert, now time.Time) (string, string) {
+	period := "quotidien"
+	if settings.DigestFrequency == models.DigestWeekly {
+		period = "hebdomadaire"
+	}
+	localDate := now.In(settingsLocation(settings)).Format("02/01/2006")
+	subject := fmt.Sprintf("[RivalPrice] Résumé %s du %s — %d alerte(s)", period, localDate, len(alerts))
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("RivalPrice — résumé %s du %s\n", period, localDate))
+	b.WriteString(fmt.Sprintf("%d alerte(s) depuis le dernier résumé\n", len(alerts)))
+
+	project, competitor := "", ""
+	for i := 0; i < len(alerts); {
+		a := alerts[i]
+		if a.ProjectName != project {
+			project, competitor = a.ProjectName, ""
+			b.WriteString(fmt.Sprintf("\n=== Projet: %s ===\n", project))
+		}
+		if a.CompetitorName != competitor {
+			competitor = a.CompetitorName
+			b.WriteString(fmt.Sprintf("\n--- %s ---\n", competitor))
+		}
+
+		// Alerts are ordered by project then competitor: take this group
+		j := i
+		for j < len(alerts) && alerts[j].ProjectName == project && alerts[j].CompetitorName == competitor {
+			j++
+		}
+		writeDigestGroup(&b, alerts[i:j])
+		i = j
+	}
+
+	top := make([]digestAlert, 0, len(alerts))
+	for _, a := range alerts {
+		if a.AIRecommendation != "" {
+			top = append(top, a)
+		}
+	}
+	sort.SliceStable(top, func(i, j int) bool { return top[i].ImpactLevel > top[j].ImpactLevel })
+	if len(top) > digestTopRecommendations {
+		top = top[:digestTopRecommendations]
+	}
+	if len(top) > 0 {
+		b.WriteString("\n=== Recommandations prioritaires ===\n")
+		for i, a := range top {
+			b.WriteString(fmt.Sprintf("%d. [impact %d] %s (%s, page #%d)\n", i+1, a.ImpactLevel, a.AIRecommendation, a.CompetitorName, a.PageID))
+		}
+	}
+
+	return subject, b.String()
+}
+
+func writeDigestGroup(b *strings.Builder, alerts []digestAlert) {
+	var prices, others []digestAlert
+	for _, a := range alerts {
+		if a.OldPrice != "" && a.NewPrice != "" {
+			prices = append(prices, a)
+		} else {
+			others = append(others, a)
+		}
+	}
+
+	if len(prices) > 0 {
+		b.WriteString(fmt.Sprintf("  %-8s %-12s %-12s %s\n", "Page", "Ancien prix", "Nouveau prix", "Variation"))
+		for _, a := range prices {
+			b.WriteString(fmt.Sprintf("  %-8s %-12s %-12s %+.1f%%\n", fmt.Sprintf("#%d", a.PageID), a.OldPrice, a.NewPrice, a.ChangePercent))
+		}
+	}
+	for _, a := range others {
+		b.WriteString(fmt.Sprintf("  [%s] %s — page #%d: %s\n", a.Severity, a.AlertType, a.PageID, a.AISummary))
+	}
+}
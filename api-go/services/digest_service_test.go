@@ -0,0 +1,161 @@
+package services
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // like cmd/main.go, the tests do not depend on the host's zoneinfo
+
+	"github.com/rivalprice/api-go/models"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestLastDigestSlot(t *testing.T) {
+	paris := mustLoadLocation(t, "Europe/Paris")
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	daily := func(tz string, hour int) *models.UserNotificationSettings {
+		return &models.UserNotificationSettings{DigestFrequency: models.DigestDaily, DigestHour: hour, Timezone: tz}
+	}
+	weekly := func(tz string, hour int, weekday time.Weekday) *models.UserNotificationSettings {
+		s := daily(tz, hour)
+		s.DigestFrequency = models.DigestWeekly
+		s.DigestWeekday = int(weekday)
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		settings *models.UserNotificationSettings
+		now      time.Time
+		want     time.Time
+	}{
+		{
+			name:     "daily, after today's slot",
+			settings: daily("Europe/Paris", 8),
+			now:      time.Date(2026, 3, 10, 9, 30, 0, 0, paris),
+			want:     time.Date(2026, 3, 10, 8, 0, 0, 0, paris),
+		},
+		{
+			name:     "daily, before today's slot",
+			settings: daily("Europe/Paris", 8),
+			now:      time.Date(2026, 3, 10, 7, 59, 0, 0, paris),
+			want:     time.Date(2026, 3, 9, 8, 0, 0, 0, paris),
+		},
+		{
+			name:     "daily, exactly at the slot",
+			settings: daily("Europe/Paris", 8),
+			now:      time.Date(2026, 3, 10, 8, 0, 0, 0, paris),
+			want:     time.Date(2026, 3, 10, 8, 0, 0, 0, paris),
+		},
+		{
+			// 03:00 UTC is still the previous evening in New York
+			name:     "local day differs from the UTC day",
+			settings: daily("America/New_York", 20),
+			now:      time.Date(2026, 3, 11, 3, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 3, 10, 20, 0, 0, 0, newYork),
+		},
+		{
+			name:     "unknown time zone falls back to UTC",
+			settings: daily("Mars/Olympus", 8),
+			now:      time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			// Clocks go forward on March 29th: the slot stays at 08:00 local,
+			// 23 hours after the previous one
+			name:     "daily across the spring DST change",
+			settings: daily("Europe/Paris", 8),
+			now:      time.Date(2026, 3, 29, 7, 0, 0, 0, paris),
+			want:     time.Date(2026, 3, 28, 8, 0, 0, 0, paris),
+		},
+		{
+			name:     "daily slot inside the skipped hour",
+			settings: daily("Europe/Paris", 2),
+			now:      time.Date(2026, 3, 29, 12, 0, 0, 0, paris),
+			want:     time.Date(2026, 3, 29, 3, 0, 0, 0, paris), // 02:00 does not exist
+		},
+		{
+			name:     "daily across the autumn DST change",
+			settings: daily("Europe/Paris", 8),
+			now:      time.Date(2026, 10, 25, 7, 30, 0, 0, paris),
+			want:     time.Date(2026, 10, 24, 8, 0, 0, 0, paris),
+		},
+		{
+			name:     "weekly, later in the week",
+			settings: weekly("Europe/Paris", 8, time.Monday),
+			now:      time.Date(2026, 3, 12, 10, 0, 0, 0, paris), // Thursday
+			want:     time.Date(2026, 3, 9, 8, 0, 0, 0, paris),
+		},
+		{
+			name:     "weekly, on the day before the hour",
+			settings: weekly("Europe/Paris", 8, time.Monday),
+			now:      time.Date(2026, 3, 9, 7, 0, 0, 0, paris), // Monday
+			want:     time.Date(2026, 3, 2, 8, 0, 0, 0, paris),
+		},
+		{
+			name:     "weekly on Sunday",
+			settings: weekly("Europe/Paris", 18, time.Sunday),
+			now:      time.Date(2026, 3, 14, 23, 0, 0, 0, paris), // Saturday
+			want:     time.Date(2026, 3, 8, 18, 0, 0, 0, paris),
+		},
+		{
+			name:     "weekly across the spring DST change",
+			settings: weekly("Europe/Paris", 8, time.Monday),
+			now:      time.Date(2026, 3, 30, 7, 0, 0, 0, paris), // Monday after the change
+			want:     time.Date(2026, 3, 23, 8, 0, 0, 0, paris),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastDigestSlot(tt.settings, tt.now); !got.Equal(tt.want) {
+				t.Errorf("lastDigestSlot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	paris := mustLoadLocation(t, "Europe/Paris")
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, paris)
+	at := func(day, hour, min int) *time.Time {
+		t := time.Date(2026, 3, day, hour, min, 0, 0, paris)
+		return &t
+	}
+
+	tests := []struct {
+		name      string
+		frequency string
+		last      *time.Time
+		want      bool
+	}{
+		{name: "never sent", frequency: models.DigestDaily, want: true},
+		{name: "sent before today's slot", frequency: models.DigestDaily, last: at(9, 8, 0), want: true},
+		{name: "sent at today's slot", frequency: models.DigestDaily, last: at(10, 8, 0)},
+		{name: "sent late, after today's slot", frequency: models.DigestDaily, last: at(10, 8, 40)},
+		{name: "weekly sent since Monday's slot", frequency: models.DigestWeekly, last: at(9, 8, 1)},
+		{name: "weekly sent the week before", frequency: models.DigestWeekly, last: at(3, 8, 0), want: true},
+		{name: "immediate delivery has no digest", frequency: models.DigestImmediate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := &models.UserNotificationSettings{
+				DigestFrequency: tt.frequency,
+				DigestHour:      8,
+				DigestWeekday:   int(time.Monday),
+				Timezone:        "Europe/Paris",
+				LastDigestAt:    tt.last,
+			}
+			if got := digestDue(settings, now); got != tt.want {
+				t.Errorf("digestDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 // EmailService handles sending email notifications
@@ -11,6 +12,7 @@ type EmailService struct {
 	fromEmail string
 	smtpHost  string
 	smtpPort  string
+	baseURL   string // public API URL used to build links in emails
 	enabled   bool
 }
 
@@ -20,12 +22,15 @@ func NewEmailService() *EmailService {
 		fromEmail: os.Getenv("SMTP_FROM"),
 		smtpHost:  smtpHost,
 		smtpPort:  os.Getenv("SMTP_PORT"),
+		baseURL:   strings.TrimRight(os.Getenv("PUBLIC_API_URL"), "/"),
 		enabled:   smtpHost != "",
 	}
 }
 
-// SendAlert sends an alert notification by email (or logs if SMTP not configured)
-func (s *EmailService) SendAlert(toEmail, alertType, severity, summary, recommendation string, pageID int) error {
+// SendAlert sends an alert notification by email (or logs if SMTP not configured).
+// diffPath is an optional API path to the snapshot diff behind the alert,
+// unsubscribePath an optional API path that stops these emails.
+func (s *EmailService) SendAlert(toEmail, alertType, severity, summary, recommendation string, pageID int, diffPath, unsubscribePath string) error {
 	subject := fmt.Sprintf("[RivalPrice] %s alert — Page #%d", alertType, pageID)
 	body := fmt.Sprintf(`
 RivalPrice Alert
@@ -40,9 +45,102 @@ Summary:
 Recommendation:
 %s
 `, alertType, severity, pageID, summary, recommendation)
+	if diffPath != "" {
+		body += fmt.Sprintf("\nWhat changed:\n%s%s\n", s.baseURL, diffPath)
+	}
+	headers := ""
+	if unsubscribePath != "" {
+		body += fmt.Sprintf("\nUnsubscribe:\n%s%s\n", s.baseURL, unsubscribePath)
+		// RFC 8058 one-click unsubscribe: the mail client POSTs to the link
+		headers = fmt.Sprintf("List-Unsubscribe: <%s%s>\nList-Unsubscribe-Post: List-Unsubscribe=One-Click\n",
+			s.baseURL, unsubscribePath)
+	}
 
 	if !s.enabled {
 		// Log-only mode when SMTP not configured
+		log.Printf("📧 [EMAIL-LOG] To: %s | Subject: %s\n%s%s", toEmail, subject, headers, body)
+		return nil
+	}
+
+	// TODO: implement real SMTP sending (e.g. net/smtp or SendGrid)
+	log.Printf("📧 Email sent to %s: %s", toEmail, subject)
+	return nil
+}
+
+// SendDigest sends a daily or weekly summary email (or logs if SMTP not configured)
+func (s *EmailService) SendDigest(toEmail, subject, body string) error {
+	if !s.enabled {
+		log.Printf("📧 [EMAIL-LOG] To: %s | Subject: %s\n%s", toEmail, subject, body)
+		return nil
+	}
+
+	// TODO: implement real SMTP sending (e.g. net/smtp or SendGrid)
+	log.Printf("📧 Email sent to %s: %s", toEmail, subject)
+	return nil
+}
+
+// SendInvitation emails an invitation to join an organization (or logs if
+// SMTP not configured). acceptPath is the API path that accepts it.
+func (s *EmailService) SendInvitation(toEmail, orgName, role, acceptPath string) error {
+	subject := fmt.Sprintf("[RivalPrice] Invitation à rejoindre %s", orgName)
+	body := fmt.Sprintf(`
+Vous êtes invité(e) à rejoindre l'organisation %s sur RivalPrice (rôle : %s).
+
+Pour accepter, connectez-vous avec cette adresse email puis envoyez un POST à :
+%s%s
+
+Cette invitation expire dans 7 jours.
+`, orgName, role, s.baseURL, acceptPath)
+
+	if !s.enabled {
+		log.Printf("📧 [EMAIL-LOG] To: %s | Subject: %s\n%s", toEmail, subject, body)
+		return nil
+	}
+
+	// TODO: implement real SMTP sending (e.g. net/smtp or SendGrid)
+	log.Printf("📧 Email sent to %s: %s", toEmail, subject)
+	return nil
+}
+
+// SendEmailVerification emails the link confirming toEmail (or logs if SMTP
+// not configured). verifyPath is the API path that verifies the address.
+func (s *EmailService) SendEmailVerification(toEmail, verifyPath string) error {
+	subject := "[RivalPrice] Confirmez votre adresse email"
+	body := fmt.Sprintf(`
+Bienvenue sur RivalPrice !
+
+Pour confirmer votre adresse email, ouvrez ce lien :
+%s%s
+
+Ce lien expire dans 24 heures. Si vous n'avez pas créé de compte, ignorez cet email.
+`, s.baseURL, verifyPath)
+
+	if !s.enabled {
+		log.Printf("📧 [EMAIL-LOG] To: %s | Subject: %s\n%s", toEmail, subject, body)
+		return nil
+	}
+
+	// TODO: implement real SMTP sending (e.g. net/smtp or SendGrid)
+	log.Printf("📧 Email sent to %s: %s", toEmail, subject)
+	return nil
+}
+
+// SendPasswordReset emails a password reset token (or logs if SMTP not
+// configured). resetPath is the API path the new password is posted to.
+func (s *EmailService) SendPasswordReset(toEmail, token, resetPath string) error {
+	subject := "[RivalPrice] Réinitialisation de votre mot de passe"
+	body := fmt.Sprintf(`
+Une réinitialisation du mot de passe de votre compte RivalPrice a été demandée.
+
+Pour choisir un nouveau mot de passe, envoyez un POST à :
+%s%s
+avec {"token": "%s", "password": "<nouveau mot de passe>"}
+
+Ce lien expire dans 1 heure et ne peut servir qu'une fois. Si vous n'êtes pas à l'origine de cette
+demande, ignorez cet email : votre mot de passe reste inchangé.
+`, s.baseURL, resetPath, token)
+
+	if !s.enabled {
 		log.Printf("📧 [EMAIL-LOG] To: %s | Subject: %s\n%s", toEmail, subject, body)
 		return nil
 	}
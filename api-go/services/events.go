@@ -0,0 +1,24 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/rivalprice/domain/queue"
+)
+
+// StreamEvent is a decoded stream entry. The streams and their events are
+// defined by the shared queue contracts.
+type StreamEvent struct {
+	StreamID string // Redis entry ID, used to acknowledge the event
+	queue.Event
+}
+
+// ParseStreamEvent decodes and validates the flat field map of a stream
+// entry
+func ParseStreamEvent(id string, values map[string]interface{}) (StreamEvent, error) {
+	event, err := queue.ParseEvent(values)
+	if err != nil {
+		return StreamEvent{StreamID: id, Event: event}, fmt.Errorf("event %s: %w", id, err)
+	}
+	return StreamEvent{StreamID: id, Event: event}, nil
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	totpIssuer = "RivalPrice"
+
+	// MFAChallengeTTL is how long a password-checked login waits for its code
+	MFAChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+	mfaChallengeKeyPrefix   = "auth:mfa:" // + sha256(token)
+
+	recoveryCodeCount = 10
+)
+
+var errInvalidMFACode = errors.New("invalid two-factor code")
+
+// MFAStatus describes the second factor of a user
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at"`
+	PendingEnrollment      bool       `json:"pending_enrollment"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+	RequiredByOrganization bool       `json:"required_by_organization"`
+}
+
+// MFAService manages TOTP two-factor authentication: enrollment, recovery
+// codes and the login challenge between password and code.
+type MFAService struct {
+	db    *gorm.DB
+	redis *redis.Client
+	box   *utils.SecretBox
+}
+
+// NewMFAService encrypts TOTP secrets with MFA_ENCRYPTION_KEY, or a key
+// derived from jwtSecret when it is not set
+func NewMFAService(db *gorm.DB, redisClient *redis.Client, jwtSecret string) *MFAService {
+	return &MFAService{
+		db:    db,
+		redis: redisClient,
+		box:   newSecretBox("MFA_ENCRYPTION_KEY", "mfa", jwtSecret),
+	}
+}
+
+// Status returns the 2FA state of userID
+func (s *MFAService) Status(userID uint) (*MFAStatus, error) {
+	status := &MFAStatus{}
+	mfa, err := s.get(s.db, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if mfa != nil {
+		status.Enabled = mfa.EnabledAt != nil
+		status.EnabledAt = mfa.EnabledAt
+		status.PendingEnrollment = mfa.EnabledAt == nil
+	}
+	if err := s.db.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&status.RecoveryCodesRemaining).Error; err != nil {
+		return nil, err
+	}
+	required, err := s.requiredByOrganization(userID)
+	if err != nil {
+		return nil, err
+	}
+	status.RequiredByOrganization = required
+	return status, nil
+}
+
+// IsEnabled reports whether userID must give a code at login
+func (s *MFAService) IsEnabled(userID uint) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.UserMFA{}).
+		Where("user_id = ? AND enabled_at IS NOT NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Enroll generates a new TOTP secret for user and returns it with its
+// provisioning URI (to show as a QR code). 2FA is only enabled once a first
+// code is confirmed with Activate.
+func (s *MFAService) Enroll(user *models.User) (string, string, error) {
+	enabled, err := s.IsEnabled(user.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if enabled {
+		return "", "", errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := s.box.Seal(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	mfa := models.UserMFA{UserID: user.ID, SecretEnc: sealed}
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"secret_enc": sealed, "last_used_step": 0, "updated_at": time.Now()}),
+	}).Create(&mfa).Error
+	if err != nil {
+		return "", "", errors.New("failed to start enrollment")
+	}
+	return secret, utils.TOTPProvisioningURI(totpIssuer, user.Email, secret), nil
+}
+
+// Activate enables 2FA once the user proves their app generates valid codes,
+// and returns the first set of recovery codes
+func (s *MFAService) Activate(actor Actor, code string) ([]string, error) {
+	userID := actor.UserID
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		mfa, err := s.lock(tx, userID)
+		if err != nil {
+			return errors.New("no enrollment in progress")
+		}
+		if mfa.EnabledAt != nil {
+			return errors.New("two-factor authentication is already enabled")
+		}
+		if err := s.checkTOTP(tx, mfa, code); err != nil {
+			return err
+		}
+		if err := tx.Model(mfa).Update("enabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if codes, err = s.replaceRecoveryCodes(tx, userID); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditRecord{Action: models.AuditMFAEnabled, TargetID: userID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("🔐 Two-factor authentication enabled for user %d", userID)
+	return codes, nil
+}
+
+// Disable turns 2FA off after checking a code. Members of an organization
+// requiring 2FA cannot disable it.
+func (s *MFAService) Disable(actor Actor, code string) error {
+	userID := actor.UserID
+	required, err := s.requiredByOrganization(userID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return errors.New("an organization you belong to requires two-factor authentication")
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.verify(tx, userID, code); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserMFA{}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditRecord{Action: models.AuditMFADisabled, TargetID: userID})
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("🔓 Two-factor authentication disabled for user %d", userID)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the actor
+func (s *MFAService) RegenerateRecoveryCodes(actor Actor, code string) ([]string, error) {
+	userID := actor.UserID
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.verify(tx, userID, code); err != nil {
+			return err
+		}
+		var err error
+		if codes, err = s.replaceRecoveryCodes(tx, userID); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditRecord{Action: models.AuditRecoveryCodesRegenerated, TargetID: userID})
+	})
+	return codes, err
+}
+
+// StartChallenge returns a short-lived token standing for a login whose
+// password was checked and which now waits for a second factor
+func (s *MFAService) StartChallenge(userID uint) (string, error) {
+	if s.redis == nil {
+		return "", errors.New("two-factor login unavailable")
+	}
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	ctx := context.Background()
+	key := mfaChallengeKeyPrefix + hashRefreshToken(token)
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, key, "user_id", userID, "attempts", 0)
+	pipe.Expire(ctx, key, MFAChallengeTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CompleteChallenge checks a TOTP or recovery code against a challenge and
+// returns the user to log in. A challenge dies after a success or after
+// mfaChallengeMaxAttempts wrong codes.
+func (s *MFAService) CompleteChallenge(token, code string) (uint, error) {
+	if s.redis == nil {
+		return 0, errors.New("two-factor login unavailable")
+	}
+	ctx := context.Background()
+	key := mfaChallengeKeyPrefix + hashRefreshToken(token)
+
+	attempts, err := s.redis.HIncrBy(ctx, key, "attempts", 1).Result()
+	if err != nil {
+		return 0, err
+	}
+	rawUserID, err := s.redis.HGet(ctx, key, "user_id").Result()
+	if errors.Is(err, redis.Nil) {
+		s.redis.Del(ctx, key) // HIncrBy recreated it without TTL
+		return 0, errors.New("login expired, please log in again")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if attempts > mfaChallengeMaxAttempts {
+		s.redis.Del(ctx, key)
+		return 0, errors.New("too many attempts, please log in again")
+	}
+
+	userID64, err := strconv.ParseUint(rawUserID, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	userID := uint(userID64)
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.verify(tx, userID, code)
+	}); err != nil {
+		return 0, err
+	}
+	s.redis.Del(ctx, key)
+	return userID, nil
+}
+
+// verify accepts a current TOTP code or an unused recovery code of userID
+func (s *MFAService) verify(tx *gorm.DB, userID uint, code string) error {
+	mfa, err := s.lock(tx, userID)
+	if err != nil || mfa.EnabledAt == nil {
+		return errors.New("two-factor authentication is not enabled")
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == 6 && strings.Trim(code, "0123456789") == "" {
+		return s.checkTOTP(tx, mfa, code)
+	}
+
+	result := tx.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidMFACode
+	}
+	log.Printf("ℹ️  Recovery code used by user %d", userID)
+	return nil
+}
+
+// checkTOTP accepts the code of the current time step or its neighbours
+// (clock drift), each step only once
+func (s *MFAService) checkTOTP(tx *gorm.DB, mfa *models.UserMFA, code string) error {
+	secret, err := s.box.Open(mfa.SecretEnc)
+	if err != nil {
+		return err
+	}
+
+	current := utils.TOTPStep(time.Now())
+	for step := current - 1; step <= current+1; step++ {
+		if step <= mfa.LastUsedStep {
+			continue
+		}
+		expected, err := utils.TOTPCode(secret, step)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return tx.Model(mfa).Update("last_used_step", step).Error
+		}
+	}
+	return errInvalidMFACode
+}
+
+func (s *MFAService) replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.MFARecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := utils.RandomToken(8)
+		if err != nil {
+			return nil, err
+		}
+		code := raw[:8] + "-" + raw[8:]
+		codes = append(codes, code)
+		rows = append(rows, models.MFARecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *MFAService) get(db *gorm.DB, userID uint) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	if err := db.Where("user_id = ?", userID).First(&mfa).Error; err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// lock loads the 2FA row of userID for update, serializing code checks
+func (s *MFAService) lock(tx *gorm.DB, userID uint) (*models.UserMFA, error) {
+	return s.get(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID)
+}
+
+func (s *MFAService) requiredByOrganization(userID uint) (bool, error) {
+	var count int64
+	err := s.db.Table("organizations").
+		Joins("JOIN memberships ON memberships.organization_id = organizations.id").
+		Where("memberships.user_id = ? AND organizations.require_mfa = ?", userID, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// hashRecoveryCode ignores case, spaces and dashes so codes can be typed
+// loosely
+func hashRecoveryCode(code string) string {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redis/v8"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
+	"gorm.io/gorm"
+)
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// newTestMFA returns an MFAService and the 2FA row of user 7, enabled with
+// testTOTPSecret
+func newTestMFA(t *testing.T, db *gorm.DB, redisClient *redis.Client) (*MFAService, *models.UserMFA) {
+	t.Helper()
+	t.Setenv("MFA_ENCRYPTION_KEY", strings.Repeat("ab", 32))
+	s := NewMFAService(db, redisClient, "test-secret")
+	sealed, err := s.box.Seal(testTOTPSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabledAt := time.Now().Add(-time.Hour)
+	return s, &models.UserMFA{ID: 3, UserID: 7, SecretEnc: sealed, EnabledAt: &enabledAt}
+}
+
+func totpCode(t *testing.T, step int64) string {
+	t.Helper()
+	code, err := utils.TOTPCode(testTOTPSecret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// expectMFALock expects verify to lock the 2FA row of its user
+func expectMFALock(mock sqlmock.Sqlmock, mfa *models.UserMFA) {
+	mock.ExpectQuery(`SELECT \* FROM "user_mfa" WHERE user_id = \$1 .*FOR UPDATE`).WithArgs(mfa.UserID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "secret_enc", "enabled_at", "last_used_step"}).
+			AddRow(mfa.ID, mfa.UserID, mfa.SecretEnc, mfa.EnabledAt, mfa.LastUsedStep))
+}
+
+func TestCheckTOTP(t *testing.T) {
+	// Steps relative to the test's clock; checkTOTP reads it again, at most
+	// one step later
+	current := utils.TOTPStep(time.Now())
+
+	tests := []struct {
+		name     string
+		step     int64
+		lastUsed int64
+		accepted bool
+	}{
+		{name: "current code", step: current, accepted: true},
+		{name: "next step (clock drift)", step: current + 1, accepted: true},
+		{name: "expired code", step: current - 2},
+		{name: "code from the future", step: current + 3},
+		{name: "replayed code", step: current, lastUsed: current + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			s, mfa := newTestMFA(t, db, nil)
+			mfa.LastUsedStep = tt.lastUsed
+			if tt.accepted {
+				// The step is burnt so the code cannot be replayed
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "user_mfa" SET "last_used_step"=\$1,"updated_at"=\$2 WHERE "id" = \$3`).
+					WithArgs(tt.step, sqlmock.AnyArg(), mfa.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			err := s.checkTOTP(db, mfa, totpCode(t, tt.step))
+			if tt.accepted && err != nil || !tt.accepted && !errors.Is(err, errInvalidMFACode) {
+				t.Errorf("checkTOTP() = %v, want accepted %v", err, tt.accepted)
+			}
+		})
+	}
+}
+
+func TestMFAChallenge(t *testing.T) {
+	challengeKey := func(token string) string { return mfaChallengeKeyPrefix + hashRefreshToken(token) }
+
+	t.Run("completed once", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		redisClient, fake := newFakeRedis(t)
+		s, mfa := newTestMFA(t, db, redisClient)
+
+		token, err := s.StartChallenge(mfa.UserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mock.ExpectBegin()
+		expectMFALock(mock, mfa)
+		mock.ExpectExec(`UPDATE "user_mfa" SET "last_used_step"`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		userID, err := s.CompleteChallenge(token, totpCode(t, utils.TOTPStep(time.Now())))
+		if err != nil || userID != mfa.UserID {
+			t.Fatalf("CompleteChallenge() = %d, %v, want user %d", userID, err, mfa.UserID)
+		}
+		if fake.exists(challengeKey(token)) {
+			t.Error("challenge kept after a successful login")
+		}
+
+		// The token cannot open a second session
+		if _, err := s.CompleteChallenge(token, totpCode(t, utils.TOTPStep(time.Now()))); err == nil || !strings.Contains(err.Error(), "expired") {
+			t.Errorf("CompleteChallenge() reused = %v, want login expired", err)
+		}
+		if fake.exists(challengeKey(token)) {
+			t.Error("reused challenge recreated")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		db, _ := newMockDB(t) // the code is not checked
+		redisClient, fake := newFakeRedis(t)
+		s, mfa := newTestMFA(t, db, redisClient)
+
+		token, err := s.StartChallenge(mfa.UserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fake.advance(MFAChallengeTTL + time.Second)
+
+		if _, err := s.CompleteChallenge(token, totpCode(t, utils.TOTPStep(time.Now()))); err == nil || !strings.Contains(err.Error(), "expired") {
+			t.Errorf("CompleteChallenge() after %s = %v, want login expired", MFAChallengeTTL, err)
+		}
+		// HINCRBY recreated the key without TTL: it must not linger
+		if fake.exists(challengeKey(token)) {
+			t.Error("expired challenge left without TTL")
+		}
+	})
+
+	t.Run("too many attempts", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		redisClient, fake := newFakeRedis(t)
+		s, mfa := newTestMFA(t, db, redisClient)
+
+		token, err := s.StartChallenge(mfa.UserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < mfaChallengeMaxAttempts; i++ {
+			mock.ExpectBegin()
+			expectMFALock(mock, mfa)
+			mock.ExpectRollback()
+			if _, err := s.CompleteChallenge(token, "000000"); !errors.Is(err, errInvalidMFACode) {
+				t.Fatalf("attempt %d: CompleteChallenge() = %v, want an invalid code", i+1, err)
+			}
+		}
+
+		// Even the right code is refused now, without checking it
+		if _, err := s.CompleteChallenge(token, totpCode(t, utils.TOTPStep(time.Now()))); err == nil || !strings.Contains(err.Error(), "too many attempts") {
+			t.Errorf("CompleteChallenge() = %v, want too many attempts", err)
+		}
+		if fake.exists(challengeKey(token)) {
+			t.Error("challenge kept after too many attempts")
+		}
+	})
+}
+
+func TestMFASatisfied(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified bool
+		query    string
+		args     []driver.Value
+	}{
+		{
+			// Enrolling is not enough: the session must have passed 2FA
+			name:  "credential without 2FA",
+			query: `SELECT count\(\*\) FROM "organizations" WHERE id = \$1 AND organizations.require_mfa = FALSE$`,
+			args:  []driver.Value{4},
+		},
+		{
+			name:     "credential with 2FA, still enabled",
+			verified: true,
+			query: `SELECT count\(\*\) FROM "organizations" WHERE id = \$1 AND \(organizations.require_mfa = FALSE OR ` +
+				`EXISTS \(SELECT 1 FROM user_mfa WHERE user_mfa.user_id = \$2 AND user_mfa.enabled_at IS NOT NULL\)\)$`,
+			args: []driver.Value{4, 7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectQuery(tt.query).WithArgs(tt.args...).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+			ok, err := NewOrganizationService(db).MFASatisfied(4, 7, tt.verified)
+			if err != nil || ok {
+				t.Errorf("MFASatisfied() = %v, %v, want false", ok, err)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/rivalprice/api-go/models"
 	"gorm.io/gorm"
@@ -15,25 +18,59 @@ func NewMonitoredPageService(db *gorm.DB) *MonitoredPageService {
 	return &MonitoredPageService{db: db}
 }
 
-func (s *MonitoredPageService) CreateMonitoredPage(competitorID uint, pageType, url, cssSelector string) (*models.MonitoredPage, error) {
+// CreateMonitoredPage adds a page to a competitor. An empty frequency takes
+// the most frequent schedule the organization's plan allows.
+func (s *MonitoredPageService) CreateMonitoredPage(actor Actor, competitorID uint, pageType, url, cssSelector string, captureScreenshot bool, frequency models.Frequency) (*models.MonitoredPage, error) {
 	// Verify competitor exists
 	var competitor models.Competitor
-	if err := s.db.First(&competitor, competitorID).Error; err != nil {
+	if err := s.db.Preload("Project").First(&competitor, competitorID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("competitor not found")
 		}
 		return nil, err
 	}
-
-	monitoredPage := models.MonitoredPage{
-		CompetitorID: competitorID,
-		PageType:     pageType,
-		URL:          url,
-		CSSSelector:  cssSelector,
+	orgID := competitor.Project.OrganizationID
+
+	if frequency == "" {
+		frequency = models.FrequencyDaily
+		if orgID != nil {
+			plan, err := organizationPlan(s.db, *orgID)
+			if err != nil {
+				return nil, err
+			}
+			frequency = plan.MinFrequency
+		}
+	}
+	switch frequency {
+	case models.FrequencyDaily, models.FrequencyWeekly, models.FrequencyMonthly:
+	default:
+		return nil, fmt.Errorf("invalid frequency %q", frequency)
 	}
 
-	if err := s.db.Create(&monitoredPage).Error; err != nil {
-		return nil, errors.New("failed to create monitored page")
+	var monitoredPage models.MonitoredPage
+	monitoredPage.CompetitorID = competitorID
+	monitoredPage.PageType = pageType
+	monitoredPage.URL = url
+	monitoredPage.CSSSelector = cssSelector
+	monitoredPage.CaptureScreenshot = captureScreenshot
+	monitoredPage.Frequency = frequency
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if orgID != nil {
+			if err := checkPlanFrequency(tx, *orgID, frequency); err != nil {
+				return err
+			}
+			if err := checkPlanCount(tx, *orgID, resourcePages); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&monitoredPage).Error; err != nil {
+			return errors.New("failed to create monitored page")
+		}
+		return s.audit(tx, actor, models.AuditPageCreated, &monitoredPage, nil, monitoredPage)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &monitoredPage, nil
@@ -50,23 +87,24 @@ func (s *MonitoredPageService) GetMonitoredPageByID(id uint) (*models.MonitoredP
 	return &monitoredPage, nil
 }
 
-func (s *MonitoredPageService) GetAllMonitoredPages() ([]models.MonitoredPage, error) {
+// GetAllMonitoredPages returns the pages of every project viewer can see
+func (s *MonitoredPageService) GetAllMonitoredPages(viewer Viewer) ([]models.MonitoredPage, error) {
 	var monitoredPages []models.MonitoredPage
-	if err := s.db.Preload("Competitor").Find(&monitoredPages).Error; err != nil {
+	if err := s.db.Preload("Competitor").Where("id IN (?)", visiblePageIDs(s.db, viewer)).Find(&monitoredPages).Error; err != nil {
 		return nil, err
 	}
 	return monitoredPages, nil
 }
 
-func (s *MonitoredPageService) GetMonitoredPagesByCompetitorID(competitorID uint) ([]models.MonitoredPage, error) {
+func (s *MonitoredPageService) GetMonitoredPagesByCompetitorID(viewer Viewer, competitorID uint) ([]models.MonitoredPage, error) {
 	var monitoredPages []models.MonitoredPage
-	if err := s.db.Where("competitor_id = ?", competitorID).Find(&monitoredPages).Error; err != nil {
+	if err := s.db.Where("competitor_id = ? AND competitor_id IN (?)", competitorID, visibleCompetitorIDs(s.db, viewer)).Find(&monitoredPages).Error; err != nil {
 		return nil, err
 	}
 	return monitoredPages, nil
 }
 
-func (s *MonitoredPageService) UpdateMonitoredPage(id uint, pageType, url, cssSelector string) (*models.MonitoredPage, error) {
+func (s *MonitoredPageService) UpdateMonitoredPage(actor Actor, id uint, pageType, url, cssSelector string) (*models.MonitoredPage, error) {
 	var monitoredPage models.MonitoredPage
 	if err := s.db.First(&monitoredPage, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -75,18 +113,76 @@ func (s *MonitoredPageService) UpdateMonitoredPage(id uint, pageType, url, cssSe
 		return nil, err
 	}
 
+	before := monitoredPage
 	monitoredPage.PageType = pageType
 	monitoredPage.URL = url
 	monitoredPage.CSSSelector = cssSelector
 
-	if err := s.db.Save(&monitoredPage).Error; err != nil {
+	if err := s.save(actor, &monitoredPage, before); err != nil {
 		return nil, errors.New("failed to update monitored page")
 	}
 
 	return &monitoredPage, nil
 }
 
-func (s *MonitoredPageService) DeleteMonitoredPage(id uint) error {
+// UpdateIgnoreRules sets the per-page selectors and regexes the scraper strips
+// before computing the canonical text of a page
+func (s *MonitoredPageService) UpdateIgnoreRules(actor Actor, id uint, selectors, patterns []string) (*models.MonitoredPage, error) {
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %v", pattern, err)
+		}
+	}
+	for _, rule := range append(append([]string{}, selectors...), patterns...) {
+		if strings.Contains(rule, "\n") {
+			return nil, errors.New("ignore rules cannot contain line breaks")
+		}
+	}
+
+	var monitoredPage models.MonitoredPage
+	if err := s.db.First(&monitoredPage, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("monitored page not found")
+		}
+		return nil, err
+	}
+
+	before := monitoredPage
+	monitoredPage.IgnoreSelectors = strings.Join(selectors, "\n")
+	monitoredPage.IgnorePatterns = strings.Join(patterns, "\n")
+	if err := s.save(actor, &monitoredPage, before); err != nil {
+		return nil, errors.New("failed to update ignore rules")
+	}
+
+	return &monitoredPage, nil
+}
+
+// UpdateAlertWindows sets the page's alert cool-down and merge window, in
+// minutes. nil restores the global default.
+func (s *MonitoredPageService) UpdateAlertWindows(actor Actor, id uint, cooldownMinutes, mergeWindowMinutes *int) (*models.MonitoredPage, error) {
+	if (cooldownMinutes != nil && *cooldownMinutes < 0) || (mergeWindowMinutes != nil && *mergeWindowMinutes < 0) {
+		return nil, errors.New("alert windows cannot be negative")
+	}
+
+	var monitoredPage models.MonitoredPage
+	if err := s.db.First(&monitoredPage, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("monitored page not found")
+		}
+		return nil, err
+	}
+
+	before := monitoredPage
+	monitoredPage.AlertCooldownMinutes = cooldownMinutes
+	monitoredPage.AlertMergeWindowMinutes = mergeWindowMinutes
+	if err := s.save(actor, &monitoredPage, before); err != nil {
+		return nil, errors.New("failed to update alert windows")
+	}
+
+	return &monitoredPage, nil
+}
+
+func (s *MonitoredPageService) DeleteMonitoredPage(actor Actor, id uint) error {
 	var monitoredPage models.MonitoredPage
 	if err := s.db.First(&monitoredPage, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -95,12 +191,34 @@ func (s *MonitoredPageService) DeleteMonitoredPage(id uint) error {
 		return err
 	}
 
-	// Delete related snapshots first
-	s.db.Where("monitored_page_id = ?", id).Delete(&models.Snapshot{})
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Delete related snapshots first
+		tx.Where("monitored_page_id = ?", id).Delete(&models.Snapshot{})
 
-	if err := s.db.Delete(&monitoredPage).Error; err != nil {
-		return errors.New("failed to delete monitored page")
-	}
+		if err := tx.Delete(&monitoredPage).Error; err != nil {
+			return errors.New("failed to delete monitored page")
+		}
+		return s.audit(tx, actor, models.AuditPageDeleted, &monitoredPage, monitoredPage, nil)
+	})
+}
+
+// save writes the changes made to page since before, with their audit event
+func (s *MonitoredPageService) save(actor Actor, page *models.MonitoredPage, before models.MonitoredPage) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(page).Error; err != nil {
+			return err
+		}
+		return s.audit(tx, actor, models.AuditPageUpdated, page, before, *page)
+	})
+}
 
-	return nil
+// audit records an action on page, in its competitor's organization
+func (s *MonitoredPageService) audit(tx *gorm.DB, actor Actor, action string, page *models.MonitoredPage, before, after interface{}) error {
+	return recordAudit(tx, actor, auditRecord{
+		OrganizationID: auditOrg(NewOrganizationService(tx).OrgOfCompetitor, page.CompetitorID),
+		Action:         action,
+		TargetID:       page.ID,
+		Before:         before,
+		After:          after,
+	})
 }
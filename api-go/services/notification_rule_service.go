@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+
+	"github.com/rivalprice/api-go/models"
+	"gorm.io/gorm"
+)
+
+var validRuleChannels = map[string]bool{"email": true, "webhook": true}
+
+type NotificationRuleService struct {
+	db *gorm.DB
+}
+
+func NewNotificationRuleService(db *gorm.DB) *NotificationRuleService {
+	return &NotificationRuleService{db: db}
+}
+
+func (s *NotificationRuleService) CreateRule(actor Actor, rule *models.NotificationRule) error {
+	if err := s.validateRule(rule); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rule).Error; err != nil {
+			return errors.New("failed to create notification rule")
+		}
+		return s.audit(tx, actor, models.AuditRuleCreated, rule, nil, rule)
+	})
+}
+
+func (s *NotificationRuleService) GetRuleByID(id uint) (*models.NotificationRule, error) {
+	var rule models.NotificationRule
+	if err := s.db.First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("notification rule not found")
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListRules returns the rules viewer can see in precedence order, optionally
+// for a single scope
+func (s *NotificationRuleService) ListRules(viewer Viewer, scopeType models.RuleScope, scopeID uint) ([]models.NotificationRule, error) {
+	query := s.db.Model(&models.NotificationRule{}).
+		Where("((scope_type = ? AND scope_id IN (?)) OR (scope_type = ? AND scope_id IN (?)) OR (scope_type = ? AND scope_id IN (?)))",
+			models.RuleScopeProject, visibleProjectIDs(s.db, viewer),
+			models.RuleScopeCompetitor, visibleCompetitorIDs(s.db, viewer),
+			models.RuleScopePage, visiblePageIDs(s.db, viewer))
+	if scopeType != "" {
+		query = query.Where("scope_type = ?", scopeType)
+	}
+	if scopeID != 0 {
+		query = query.Where("scope_id = ?", scopeID)
+	}
+
+	var rules []models.NotificationRule
+	if err := query.Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	sortRules(rules)
+	return rules, nil
+}
+
+// UpdateRule replaces every field of an existing rule
+func (s *NotificationRuleService) UpdateRule(actor Actor, id uint, input *models.NotificationRule) (*models.NotificationRule, error) {
+	existing, err := s.GetRuleByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateRule(input); err != nil {
+		return nil, err
+	}
+
+	input.ID = existing.ID
+	input.CreatedAt = existing.CreatedAt
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(input).Error; err != nil {
+			return errors.New("failed to update notification rule")
+		}
+		return s.audit(tx, actor, models.AuditRuleUpdated, input, existing, input)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return input, nil
+}
+
+func (s *NotificationRuleService) DeleteRule(actor Actor, id uint) error {
+	existing, err := s.GetRuleByID(id)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.NotificationRule{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("notification rule not found")
+		}
+		return s.audit(tx, actor, models.AuditRuleDeleted, existing, existing, nil)
+	})
+}
+
+// audit records an action on rule, in the organization of its scope
+func (s *NotificationRuleService) audit(tx *gorm.DB, actor Actor, action string, rule *models.NotificationRule, before, after interface{}) error {
+	orgs := NewOrganizationService(tx)
+	return recordAudit(tx, actor, auditRecord{
+		OrganizationID: auditOrg(func(id uint) (uint, error) { return orgs.OrgOfScope(rule.ScopeType, id) }, rule.ScopeID),
+		Action:         action,
+		TargetID:       rule.ID,
+		Before:         before,
+		After:          after,
+	})
+}
+
+// RulesForPage returns the enabled rules attached to a page, its competitor
+// or its project
+func (s *NotificationRuleService) RulesForPage(pageID int) ([]models.NotificationRule, error) {
+	type row struct {
+		CompetitorID uint
+		ProjectID    uint
+	}
+	var r row
+	err := s.db.Raw(`
+		SELECT c.id AS competitor_id, c.project_id
+		FROM monitored_pages mp
+		JOIN competitors c ON mp.competitor_id = c.id
+		WHERE mp.id = ?
+		LIMIT 1
+	`, pageID).Scan(&r).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []models.NotificationRule
+	err = s.db.Where("enabled = ?", true).
+		Where("((scope_type = ? AND scope_id = ?) OR (scope_type = ? AND scope_id = ?) OR (scope_type = ? AND scope_id = ?))",
+			models.RuleScopePage, pageID,
+			models.RuleScopeCompetitor, r.CompetitorID,
+			models.RuleScopeProject, r.ProjectID).
+		Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (s *NotificationRuleService) validateRule(rule *models.NotificationRule) error {
+	var target interface{}
+	switch rule.ScopeType {
+	case models.RuleScopeProject:
+		target = &models.Project{}
+	case models.RuleScopeCompetitor:
+		target = &models.Competitor{}
+	case models.RuleScopePage:
+		target = &models.MonitoredPage{}
+	default:
+		return fmt.Errorf("invalid scope_type %q", rule.ScopeType)
+	}
+	if err := s.db.First(target, rule.ScopeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%s not found", rule.ScopeType)
+		}
+		return err
+	}
+
+	if rule.Action == "" {
+		rule.Action = models.RuleActionNotify
+	}
+	if rule.Action != models.RuleActionNotify && rule.Action != models.RuleActionSuppress {
+		return fmt.Errorf("invalid action %q", rule.Action)
+	}
+	if rule.MinSeverity != "" {
+		if _, ok := severityRank[rule.MinSeverity]; !ok {
+			return fmt.Errorf("invalid min_severity %q", rule.MinSeverity)
+		}
+	}
+	if rule.Direction != "" && rule.Direction != models.DirectionUp && rule.Direction != models.DirectionDown {
+		return fmt.Errorf("invalid direction %q", rule.Direction)
+	}
+	if rule.MinPercent != nil && rule.MaxPercent != nil && *rule.MinPercent > *rule.MaxPercent {
+		return errors.New("min_percent is greater than max_percent")
+	}
+	for _, channel := range splitList(rule.Channels) {
+		if !validRuleChannels[channel] {
+			return fmt.Errorf("invalid channel %q", channel)
+		}
+		orgID, err := NewOrganizationService(s.db).OrgOfScope(rule.ScopeType, rule.ScopeID)
+		if err != nil {
+			return err
+		}
+		if err := checkPlanChannel(s.db, orgID, channel); err != nil {
+			return err
+		}
+	}
+	for _, recipient := range splitList(rule.Recipients) {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return fmt.Errorf("invalid recipient %q", recipient)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/rivalprice/api-go/models"
+)
+
+// Rule precedence: the most specific scope wins (page, then competitor, then
+// project). Within a scope, rules run by ascending priority, then by ID.
+// The first rule whose conditions all hold decides; when none matches, the
+// user's notification settings apply.
+var scopeRank = map[models.RuleScope]int{
+	models.RuleScopePage:       0,
+	models.RuleScopeCompetitor: 1,
+	models.RuleScopeProject:    2,
+}
+
+var severityRank = map[models.AlertSeverity]int{
+	models.SeverityLow:      0,
+	models.SeverityMedium:   1,
+	models.SeverityHigh:     2,
+	models.SeverityCritical: 3,
+}
+
+// sortRules orders rules by precedence, in place
+func sortRules(rules []models.NotificationRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if scopeRank[a.ScopeType] != scopeRank[b.ScopeType] {
+			return scopeRank[a.ScopeType] < scopeRank[b.ScopeType]
+		}
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return a.ID < b.ID
+	})
+}
+
+// matchRule returns the first enabled rule matching the change, or nil
+func matchRule(rules []models.NotificationRule, change *models.DetectedChange, severity models.AlertSeverity) *models.NotificationRule {
+	sorted := make([]models.NotificationRule, len(rules))
+	copy(sorted, rules)
+	sortRules(sorted)
+
+	for i := range sorted {
+		if sorted[i].Enabled && ruleMatches(&sorted[i], change, severity) {
+			return &sorted[i]
+		}
+	}
+	return nil
+}
+
+// ruleMatches reports whether every condition set on the rule holds
+func ruleMatches(rule *models.NotificationRule, change *models.DetectedChange, severity models.AlertSeverity) bool {
+	if types := splitList(rule.ChangeTypes); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if strings.Contains(change.ChangeType, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if rule.MinSeverity != "" && severityRank[severity] < severityRank[rule.MinSeverity] {
+		return false
+	}
+
+	abs := math.Abs(change.ChangePercent)
+	if rule.MinPercent != nil && abs < *rule.MinPercent {
+		return false
+	}
+	if rule.MaxPercent != nil && abs > *rule.MaxPercent {
+		return false
+	}
+
+	switch rule.Direction {
+	case models.DirectionUp:
+		if change.ChangePercent <= 0 {
+			return false
+		}
+	case models.DirectionDown:
+		if change.ChangePercent >= 0 {
+			return false
+		}
+	}
+
+	if rule.PlanName != "" && !containsFold(changePlanText(change), rule.PlanName) {
+		return false
+	}
+
+	if rule.Keyword != "" {
+		text := strings.Join([]string{change.OldText, change.NewText, change.FeaturesAdded, change.FeaturesRemoved}, "\n")
+		if !containsFold(text, rule.Keyword) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// changePlanText returns the pricing blocks touched by a change, as recorded
+// by the detector in raw_data.pricing_changes
+func changePlanText(change *models.DetectedChange) string {
+	if change.RawData == "" {
+		return ""
+	}
+	var data struct {
+		PricingChanges json.RawMessage `json:"pricing_changes"`
+	}
+	if err := json.Unmarshal([]byte(change.RawData), &data); err != nil {
+		return ""
+	}
+	return string(data.PricingChanges)
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// splitList splits a comma-separated list, dropping blanks
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rivalprice/api-go/models"
+)
+
+func ruleIDs(rules []models.NotificationRule) []uint {
+	ids := make([]uint, len(rules))
+	for i, r := range rules {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func percent(v float64) *float64 { return &v }
+
+func TestSortRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []models.NotificationRule
+		want  []uint
+	}{
+		{
+			name: "page before competitor before project",
+			rules: []models.NotificationRule{
+				{ID: 1, ScopeType: models.RuleScopeProject},
+				{ID: 2, ScopeType: models.RuleScopeCompetitor},
+				{ID: 3, ScopeType: models.RuleScopePage},
+			},
+			want: []uint{3, 2, 1},
+		},
+		{
+			name: "scope wins over priority",
+			rules: []models.NotificationRule{
+				{ID: 1, ScopeType: models.RuleScopeProject, Priority: -10},
+				{ID: 2, ScopeType: models.RuleScopePage, Priority: 100},
+			},
+			want: []uint{2, 1},
+		},
+		{
+			name: "ascending priority within a scope",
+			rules: []models.NotificationRule{
+				{ID: 1, ScopeType: models.RuleScopeCompetitor, Priority: 20},
+				{ID: 2, ScopeType: models.RuleScopeCompetitor, Priority: 5},
+				{ID: 3, ScopeType: models.RuleScopeCompetitor, Priority: 10},
+			},
+			want: []uint{2, 3, 1},
+		},
+		{
+			name: "ID breaks priority ties",
+			rules: []models.NotificationRule{
+				{ID: 9, ScopeType: models.RuleScopePage, Priority: 1},
+				{ID: 4, ScopeType: models.RuleScopePage, Priority: 1},
+				{ID: 7, ScopeType: models.RuleScopePage, Priority: 1},
+			},
+			want: []uint{4, 7, 9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sortRules(tt.rules)
+			if got := ruleIDs(tt.rules); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortRules() order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchRule(t *testing.T) {
+	change := &models.DetectedChange{ChangeType: "price_increase", ChangePercent: 12}
+
+	tests := []struct {
+		name  string
+		rules []models.NotificationRule
+		want  uint // 0 when no rule matches
+	}{
+		{name: "no rules"},
+		{
+			name: "most specific matching scope decides",
+			rules: []models.NotificationRule{
+				{ID: 1, ScopeType: models.RuleScopeProject, Enabled: true, Action: models.RuleActionSuppress},
+				{ID: 2, ScopeType: models.RuleScopePage, Enabled: true},
+				{ID: 3, ScopeType: models.RuleScopeCompetitor, Enabled: true},
+			},
+			want: 2,
+		},
+		{
+			name: "falls back to a broader scope when the specific rule does not match",
+			rules: []models.NotificationRule{
+				{ID: 1, ScopeType: models.RuleScopePage, Enabled: true, ChangeTypes: "feature_removed"},
+				{ID: 2, ScopeType: models.RuleScopeProject, Enabled: true},
+			},
+			want: 2,
+		},
+		{
+			name: "disabled rules are skipped",
+			rules: []models.NotificationRule{
+				{ID: 1, ScopeType: models.RuleScopePage, Enabled: false},
+				{ID: 2, ScopeType: models.RuleScopeCompetitor, Enabled: true},
+			},
+			want: 2,
+		},
+		{
+			name: "lowest priority first, then lowest ID",
+			rules: []models.NotificationRule{
+				{ID: 5, ScopeType: models.RuleScopePage, Enabled: true, Priority: 2},
+				{ID: 8, ScopeType: models.RuleScopePage, Enabled: true, Priority: 1},
+				{ID: 6, ScopeType: models.RuleScopePage, Enabled: true, Priority: 1},
+			},
+			want: 6,
+		},
+		{
+			name: "no rule matches",
+			rules: []models.NotificationRule{
+				{ID: 1, ScopeType: models.RuleScopePage, Enabled: true, Direction: models.DirectionDown},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchRule(tt.rules, change, models.SeverityMedium)
+			switch {
+			case tt.want == 0 && got != nil:
+				t.Errorf("matchRule() = rule %d, want none", got.ID)
+			case tt.want != 0 && (got == nil || got.ID != tt.want):
+				t.Errorf("matchRule() = %v, want rule %d", got, tt.want)
+			}
+		})
+	}
+
+	// The caller's slice keeps its order
+	rules := []models.NotificationRule{
+		{ID: 1, ScopeType: models.RuleScopeProject, Enabled: true},
+		{ID: 2, ScopeType: models.RuleScopePage, Enabled: true},
+	}
+	matchRule(rules, change, models.SeverityMedium)
+	if got := ruleIDs(rules); !reflect.DeepEqual(got, []uint{1, 2}) {
+		t.Errorf("matchRule() reordered its input to %v", got)
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	change := &models.DetectedChange{
+		ChangeType:    "price_increase_messaging_change",
+		ChangePercent: 12.5,
+		NewText:       "Now with Priority Support",
+		RawData:       `{"pricing_changes": {"pricing_blocks_changed": [{"name": "Business"}]}}`,
+	}
+
+	tests := []struct {
+		name     string
+		rule     models.NotificationRule
+		severity models.AlertSeverity
+		want     bool
+	}{
+		{name: "no conditions", want: true},
+		{name: "change type listed", rule: models.NotificationRule{ChangeTypes: "feature_added, price_increase"}, want: true},
+		{name: "change type part of a combined type", rule: models.NotificationRule{ChangeTypes: "messaging_change"}, want: true},
+		{name: "change type not listed", rule: models.NotificationRule{ChangeTypes: "price_decrease,feature_removed"}, want: false},
+		{name: "blank change types ignored", rule: models.NotificationRule{ChangeTypes: " , "}, want: true},
+
+		{name: "severity above minimum", rule: models.NotificationRule{MinSeverity: models.SeverityMedium}, severity: models.SeverityHigh, want: true},
+		{name: "severity at minimum", rule: models.NotificationRule{MinSeverity: models.SeverityHigh}, severity: models.SeverityHigh, want: true},
+		{name: "severity below minimum", rule: models.NotificationRule{MinSeverity: models.SeverityCritical}, severity: models.SeverityHigh, want: false},
+		{name: "low severity below medium", rule: models.NotificationRule{MinSeverity: models.SeverityMedium}, severity: models.SeverityLow, want: false},
+
+		{name: "percent within range", rule: models.NotificationRule{MinPercent: percent(10), MaxPercent: percent(20)}, want: true},
+		{name: "percent below minimum", rule: models.NotificationRule{MinPercent: percent(15)}, want: false},
+		{name: "percent above maximum", rule: models.NotificationRule{MaxPercent: percent(10)}, want: false},
+
+		{name: "direction up", rule: models.NotificationRule{Direction: models.DirectionUp}, want: true},
+		{name: "direction down", rule: models.NotificationRule{Direction: models.DirectionDown}, want: false},
+
+		{name: "plan name, any case", rule: models.NotificationRule{PlanName: "business"}, want: true},
+		{name: "other plan name", rule: models.NotificationRule{PlanName: "Enterprise"}, want: false},
+		{name: "keyword in text", rule: models.NotificationRule{Keyword: "priority support"}, want: true},
+		{name: "keyword absent", rule: models.NotificationRule{Keyword: "free trial"}, want: false},
+
+		{
+			name: "every condition must hold",
+			rule: models.NotificationRule{ChangeTypes: "price_increase", MinSeverity: models.SeverityCritical},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			severity := tt.severity
+			if severity == "" {
+				severity = models.SeverityMedium
+			}
+			if got := ruleMatches(&tt.rule, change, severity); got != tt.want {
+				t.Errorf("ruleMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Direction is about the sign of the change
+	down := &models.DetectedChange{ChangeType: "price_decrease", ChangePercent: -8}
+	if !ruleMatches(&models.NotificationRule{Direction: models.DirectionDown, MinPercent: percent(5)}, down, models.SeverityMedium) {
+		t.Error("ruleMatches() rejected a -8% change for direction=down, min_percent=5")
+	}
+}
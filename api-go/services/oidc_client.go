@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// oidcDiscoveryTTL is how long discovery documents and JWKS are cached
+	oidcDiscoveryTTL = time.Hour
+
+	// oidcJWKSRefetchInterval limits JWKS refetches on unknown key IDs
+	oidcJWKSRefetchInterval = time.Minute
+)
+
+// oidcDiscovery is the part of /.well-known/openid-configuration we use
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcIDClaims are the ID token claims used for login and provisioning
+type oidcIDClaims struct {
+	Email           string      `json:"email"`
+	EmailVerified   interface{} `json:"email_verified"` // bool, or "true" for some IdPs
+	Name            string      `json:"name"`
+	Nonce           string      `json:"nonce"`
+	AuthorizedParty string      `json:"azp"`
+	jwt.RegisteredClaims
+}
+
+// verifiedEmail returns the email only when the IdP vouches for it
+func (c *oidcIDClaims) verifiedEmail() string {
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		if v {
+			return strings.ToLower(c.Email)
+		}
+	case string:
+		if v == "true" {
+			return strings.ToLower(c.Email)
+		}
+	}
+	return ""
+}
+
+type jwksCache struct {
+	keys      map[string]interface{} // kid → *rsa.PublicKey / *ecdsa.PublicKey
+	fetchedAt time.Time
+}
+
+type discoveryCache struct {
+	doc       *oidcDiscovery
+	fetchedAt time.Time
+}
+
+// OIDCClient speaks the OpenID Connect authorization code flow: discovery,
+// PKCE, code exchange and ID token validation against the issuer's JWKS
+type OIDCClient struct {
+	http      *http.Client
+	mu        sync.Mutex
+	discovery map[string]discoveryCache // by issuer
+	jwks      map[string]jwksCache      // by jwks_uri
+}
+
+func NewOIDCClient() *OIDCClient {
+	return &OIDCClient{
+		http:      &http.Client{Timeout: 10 * time.Second},
+		discovery: map[string]discoveryCache{},
+		jwks:      map[string]jwksCache{},
+	}
+}
+
+// Discover fetches (or returns the cached) discovery document of issuer
+func (c *OIDCClient) Discover(ctx context.Context, issuer string) (*oidcDiscovery, error) {
+	issuer = strings.TrimRight(issuer, "/")
+
+	c.mu.Lock()
+	cached, ok := c.discovery[issuer]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < oidcDiscoveryTTL {
+		return cached.doc, nil
+	}
+
+	var doc oidcDiscovery
+	if err := c.getJSON(ctx, issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", doc.Issuer, issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("discovery document is incomplete")
+	}
+
+	c.mu.Lock()
+	c.discovery[issuer] = discoveryCache{doc: &doc, fetchedAt: time.Now()}
+	c.mu.Unlock()
+	return &doc, nil
+}
+
+// AuthCodeURL builds the authorization request, with the S256 PKCE
+// challenge of verifier
+func (c *OIDCClient) AuthCodeURL(doc *oidcDiscovery, clientID, redirectURI, scopes, state, nonce, verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {oidcScopes(scopes)},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return doc.AuthorizationEndpoint + sep + params.Encode()
+}
+
+// Exchange trades an authorization code for the ID token
+func (c *OIDCClient) Exchange(ctx context.Context, doc *oidcDiscovery, clientID, clientSecret, redirectURI, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {clientID},
+		"code_verifier": {verifier},
+	}
+	if clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token endpoint error: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// VerifyIDToken checks the ID token signature against the issuer's JWKS,
+// then its issuer, audience, expiry and nonce
+func (c *OIDCClient) VerifyIDToken(ctx context.Context, doc *oidcDiscovery, clientID, nonce, rawIDToken string) (*oidcIDClaims, error) {
+	claims := &oidcIDClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return c.publicKey(ctx, doc.JWKSURI, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != clientID {
+		return nil, errors.New("invalid id_token: azp mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid id_token: missing sub")
+	}
+	return claims, nil
+}
+
+// publicKey returns the JWKS key kid, refetching the set (at most once a
+// minute) when the IdP has rotated its keys
+func (c *OIDCClient) publicKey(ctx context.Context, jwksURI, kid string) (interface{}, error) {
+	c.mu.Lock()
+	cached, ok := c.jwks[jwksURI]
+	c.mu.Unlock()
+
+	fresh := ok && time.Since(cached.fetchedAt) < oidcDiscoveryTTL
+	if key := pickJWK(cached.keys, kid); fresh && key != nil {
+		return key, nil
+	}
+	if ok && time.Since(cached.fetchedAt) < oidcJWKSRefetchInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := c.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("JWKS fetch failed: %w", err)
+	}
+	keys := map[string]interface{}{}
+	for _, raw := range set.Keys {
+		id, key, err := parseJWK(raw)
+		if err == nil {
+			keys[id] = key
+		}
+	}
+
+	c.mu.Lock()
+	c.jwks[jwksURI] = jwksCache{keys: keys, fetchedAt: time.Now()}
+	c.mu.Unlock()
+
+	if key := pickJWK(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// pickJWK returns key kid, or the only key when the token names none
+func pickJWK(keys map[string]interface{}, kid string) interface{} {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return keys[kid]
+}
+
+// parseJWK decodes an RSA or EC signing key of a JWKS
+func parseJWK(raw json.RawMessage) (string, interface{}, error) {
+	var jwk struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return "", nil, err
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return "", nil, errors.New("not a signing key")
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return "", nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return "", nil, err
+		}
+		return jwk.Kid, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return "", nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return "", nil, err
+		}
+		return jwk.Kid, &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func (c *OIDCClient) getJSON(ctx context.Context, rawURL string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: HTTP %d", rawURL, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// oidcScopes always requests openid, email and profile on top of the
+// provider's extra scopes
+func oidcScopes(extra string) string {
+	scopes := []string{"openid", "email", "profile"}
+	for _, s := range strings.Fields(extra) {
+		if s != "openid" && s != "email" && s != "profile" {
+			scopes = append(scopes, s)
+		}
+	}
+	return strings.Join(scopes, " ")
+}
@@ -0,0 +1,615 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// invitationTTL is how long an invitation token can be accepted
+const invitationTTL = 7 * 24 * time.Hour
+
+// ErrNotOrgMember is returned when a user picked as assignee or recipient is
+// not a member of the organization owning the project
+var ErrNotOrgMember = errors.New("user is not a member of the project's organization")
+
+// OrganizationService manages organizations, their members and invitations,
+// and answers "which organization owns this resource" for access control
+type OrganizationService struct {
+	db       *gorm.DB
+	emailSvc *EmailService
+}
+
+func NewOrganizationService(db *gorm.DB) *OrganizationService {
+	return &OrganizationService{
+		db:       db,
+		emailSvc: NewEmailService(),
+	}
+}
+
+// OrganizationWithRole is an organization as seen by one of its members
+type OrganizationWithRole struct {
+	models.Organization
+	Role models.OrgRole `json:"role"`
+}
+
+// CreateOrganization creates an organization owned by the actor
+func (s *OrganizationService) CreateOrganization(actor Actor, name string) (*models.Organization, error) {
+	org := models.Organization{Name: strings.TrimSpace(name)}
+	if org.Name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.Membership{OrganizationID: org.ID, UserID: actor.UserID, Role: models.RoleOwner}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: org.ID,
+			Action:         models.AuditOrganizationCreated,
+			TargetID:       org.ID,
+			After:          org,
+		})
+	})
+	if err != nil {
+		return nil, errors.New("failed to create organization")
+	}
+	return &org, nil
+}
+
+func (s *OrganizationService) GetOrganizationByID(id uint) (*models.Organization, error) {
+	var org models.Organization
+	if err := s.db.First(&org, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("organization not found")
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+// ListForUser returns the organizations userID belongs to, with their role
+func (s *OrganizationService) ListForUser(userID uint) ([]OrganizationWithRole, error) {
+	var orgs []OrganizationWithRole
+	err := s.db.Table("organizations o").
+		Select("o.*, m.role").
+		Joins("JOIN memberships m ON m.organization_id = o.id").
+		Where("m.user_id = ?", userID).
+		Order("o.personal DESC, o.name").
+		Scan(&orgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// PersonalOrganization returns the personal organization of userID,
+// creating it on first use
+func (s *OrganizationService) PersonalOrganization(userID uint) (*models.Organization, error) {
+	var org models.Organization
+	err := s.db.Where("personal = ? AND owner_user_id = ?", true, userID).First(&org).Error
+	if err == nil {
+		return &org, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	org = models.Organization{Name: user.Email, Personal: true, OwnerUserID: &user.ID}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Membership{OrganizationID: org.ID, UserID: user.ID, Role: models.RoleOwner}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// EnsurePersonalOrganizations moves every project without an organization
+// into the personal organization of the user who created it. Safe to run at
+// every startup: it only touches projects still unassigned.
+func (s *OrganizationService) EnsurePersonalOrganizations() error {
+	var userIDs []uint
+	if err := s.db.Model(&models.Project{}).
+		Where("organization_id IS NULL").
+		Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		org, err := s.PersonalOrganization(userID)
+		if err != nil {
+			return fmt.Errorf("personal organization for user %d: %w", userID, err)
+		}
+		result := s.db.Model(&models.Project{}).
+			Where("organization_id IS NULL AND user_id = ?", userID).
+			Update("organization_id", org.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		log.Printf("ℹ️  %d project(s) of user %d moved to personal organization %d", result.RowsAffected, userID, org.ID)
+	}
+	return nil
+}
+
+// MemberRole returns the role of userID in orgID
+func (s *OrganizationService) MemberRole(orgID, userID uint) (models.OrgRole, error) {
+	var membership models.Membership
+	err := s.db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&membership).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errors.New("membership not found")
+		}
+		return "", err
+	}
+	return membership.Role, nil
+}
+
+// RequireMember returns ErrNotOrgMember unless userID is a member of orgID
+func (s *OrganizationService) RequireMember(orgID, userID uint) error {
+	var count int64
+	err := s.db.Model(&models.Membership{}).
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotOrgMember
+	}
+	return nil
+}
+
+// MFASatisfied reports whether userID meets the 2FA policy of orgID:
+// organizations requiring it only admit members who still have 2FA enabled,
+// with a credential that passed it (verified: amr claim of the session, or
+// API key created from such a session)
+func (s *OrganizationService) MFASatisfied(orgID, userID uint, verified bool) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.Organization{}).
+		Where("id = ?", orgID).
+		Where(mfaPolicyMet("organizations.require_mfa", Viewer{UserID: userID, MFA: verified})).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// UpdateOrganization renames an organization and/or changes its 2FA policy.
+// Requiring 2FA needs the acting user to have it enabled, so they keep access.
+func (s *OrganizationService) UpdateOrganization(actor Actor, orgID uint, name *string, requireMFA *bool) (*models.Organization, error) {
+	org, err := s.GetOrganizationByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if name != nil {
+		trimmed := strings.TrimSpace(*name)
+		if trimmed == "" {
+			return nil, errors.New("name cannot be empty")
+		}
+		updates["name"] = trimmed
+	}
+	if requireMFA != nil {
+		// The acting user must keep access under the new policy
+		if *requireMFA {
+			var enabled int64
+			if err := s.db.Model(&models.UserMFA{}).
+				Where("user_id = ? AND enabled_at IS NOT NULL", actor.UserID).
+				Count(&enabled).Error; err != nil {
+				return nil, err
+			}
+			if enabled == 0 || !actor.MFA {
+				return nil, errors.New("enable two-factor authentication on your account and sign in with it first")
+			}
+		}
+		updates["require_mfa"] = *requireMFA
+	}
+	if len(updates) == 0 {
+		return org, nil
+	}
+
+	before := *org
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(org).Updates(updates).Error; err != nil {
+			return errors.New("failed to update organization")
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: orgID,
+			Action:         models.AuditOrganizationUpdated,
+			TargetID:       orgID,
+			Before:         before,
+			After:          org,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetOrganizationByID(orgID)
+}
+
+func (s *OrganizationService) ListMembers(orgID uint) ([]models.Membership, error) {
+	var members []models.Membership
+	if err := s.db.Preload("User").Where("organization_id = ?", orgID).
+		Order("created_at").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// UpdateMemberRole changes the role of a member. Only owners may grant or
+// take away the owner role, and an organization always keeps one owner.
+func (s *OrganizationService) UpdateMemberRole(actor Actor, orgID, userID uint, role models.OrgRole, actorRole models.OrgRole) (*models.Membership, error) {
+	if !role.Valid() {
+		return nil, fmt.Errorf("invalid role %q", role)
+	}
+
+	var membership models.Membership
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&membership).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("member not found")
+			}
+			return err
+		}
+		if (role == models.RoleOwner || membership.Role == models.RoleOwner) && actorRole != models.RoleOwner {
+			return errors.New("only owners can change the owner role")
+		}
+		if membership.Role == models.RoleOwner && role != models.RoleOwner {
+			if err := ensureAnotherOwner(tx, orgID, userID); err != nil {
+				return err
+			}
+		}
+		before := membership
+		membership.Role = role
+		if err := tx.Model(&membership).Update("role", role).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: orgID,
+			Action:         models.AuditMemberRoleChanged,
+			TargetID:       membership.ID,
+			Before:         before,
+			After:          membership,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// RemoveMember removes a member from an organization, keeping at least one owner
+func (s *OrganizationService) RemoveMember(actor Actor, orgID, userID uint, actorRole models.OrgRole) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var membership models.Membership
+		if err := tx.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&membership).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("member not found")
+			}
+			return err
+		}
+		if membership.Role == models.RoleOwner {
+			if actorRole != models.RoleOwner {
+				return errors.New("only owners can remove an owner")
+			}
+			if err := ensureAnotherOwner(tx, orgID, userID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&membership).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: orgID,
+			Action:         models.AuditMemberRemoved,
+			TargetID:       membership.ID,
+			Before:         membership,
+		})
+	})
+}
+
+func ensureAnotherOwner(tx *gorm.DB, orgID, userID uint) error {
+	var owners int64
+	if err := tx.Model(&models.Membership{}).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", orgID, models.RoleOwner, userID).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners == 0 {
+		return errors.New("an organization must keep at least one owner")
+	}
+	return nil
+}
+
+// Invite emails a one-time token letting email join orgID with role
+func (s *OrganizationService) Invite(actor Actor, orgID uint, email string, role, actorRole models.OrgRole) (*models.Invitation, error) {
+	org, err := s.GetOrganizationByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !role.Valid() {
+		return nil, fmt.Errorf("invalid role %q", role)
+	}
+	if role == models.RoleOwner && actorRole != models.RoleOwner {
+		return nil, errors.New("only owners can invite owners")
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return nil, errors.New("invalid email address")
+	}
+	email = strings.ToLower(addr.Address)
+
+	var members int64
+	if err := s.db.Model(&models.Membership{}).
+		Joins("JOIN users u ON u.id = memberships.user_id").
+		Where("memberships.organization_id = ? AND LOWER(u.email) = ?", orgID, email).
+		Count(&members).Error; err != nil {
+		return nil, err
+	}
+	if members > 0 {
+		return nil, errors.New("user is already a member of this organization")
+	}
+
+	token, err := utils.RandomToken(24)
+	if err != nil {
+		return nil, err
+	}
+	invitation := models.Invitation{
+		OrganizationID: orgID,
+		Email:          email,
+		Role:           role,
+		Token:          token,
+		InvitedByID:    actor.UserID,
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&invitation).Error; err != nil {
+			return errors.New("failed to create invitation")
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: orgID,
+			Action:         models.AuditMemberInvited,
+			TargetID:       invitation.ID,
+			After:          invitation,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.emailSvc.SendInvitation(email, org.Name, string(role), InvitationAcceptPath(token)); err != nil {
+		log.Printf("⚠️  Invitation email to %s failed: %v", email, err)
+	}
+	return &invitation, nil
+}
+
+// InvitationAcceptPath is the API path that accepts an invitation
+func InvitationAcceptPath(token string) string {
+	return "/api/v1/invitations/" + token + "/accept"
+}
+
+// ListInvitations returns the pending invitations of an organization
+func (s *OrganizationService) ListInvitations(orgID uint) ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	if err := s.db.Where("organization_id = ? AND accepted_at IS NULL AND expires_at > ?", orgID, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// AcceptInvitation makes userID a member of the inviting organization. The
+// invitation must have been sent to the user's own email address.
+func (s *OrganizationService) AcceptInvitation(actor Actor, token string) (*models.Membership, error) {
+	userID := actor.UserID
+	var membership models.Membership
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var invitation models.Invitation
+		if err := tx.Where("token = ?", token).First(&invitation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("invitation not found")
+			}
+			return err
+		}
+		if invitation.AcceptedAt != nil {
+			return errors.New("invitation already accepted")
+		}
+		if time.Now().After(invitation.ExpiresAt) {
+			return errors.New("invitation expired")
+		}
+
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return errors.New("user not found")
+		}
+		if !strings.EqualFold(user.Email, invitation.Email) {
+			return errors.New("invitation was sent to another email address")
+		}
+
+		err := tx.Where("organization_id = ? AND user_id = ?", invitation.OrganizationID, userID).First(&membership).Error
+		switch {
+		case err == nil:
+			// Already a member: keep the higher of both roles
+			if invitation.Role.AtLeast(membership.Role) && invitation.Role != membership.Role {
+				membership.Role = invitation.Role
+				if err := tx.Model(&membership).Update("role", membership.Role).Error; err != nil {
+					return err
+				}
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			membership = models.Membership{OrganizationID: invitation.OrganizationID, UserID: userID, Role: invitation.Role}
+			if err := tx.Create(&membership).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		if err := tx.Model(&invitation).Update("accepted_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: invitation.OrganizationID,
+			Action:         models.AuditInvitationAccepted,
+			TargetID:       invitation.ID,
+			After:          membership,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// Resource → organization lookups, used by the role middleware
+
+func (s *OrganizationService) OrgExists(id uint) (uint, error) {
+	org, err := s.GetOrganizationByID(id)
+	if err != nil {
+		return 0, err
+	}
+	return org.ID, nil
+}
+
+func (s *OrganizationService) OrgOfProject(id uint) (uint, error) {
+	return s.orgOf("project", `SELECT organization_id FROM projects WHERE id = ?`, id)
+}
+
+func (s *OrganizationService) OrgOfCompetitor(id uint) (uint, error) {
+	return s.orgOf("competitor", `
+		SELECT p.organization_id FROM competitors c
+		JOIN projects p ON p.id = c.project_id
+		WHERE c.id = ?`, id)
+}
+
+func (s *OrganizationService) OrgOfPage(id uint) (uint, error) {
+	return s.orgOf("monitored page", `
+		SELECT p.organization_id FROM monitored_pages mp
+		JOIN competitors c ON c.id = mp.competitor_id
+		JOIN projects p ON p.id = c.project_id
+		WHERE mp.id = ?`, id)
+}
+
+func (s *OrganizationService) OrgOfSnapshot(id uint) (uint, error) {
+	return s.orgOf("snapshot", `
+		SELECT p.organization_id FROM snapshots sn
+		JOIN monitored_pages mp ON mp.id = sn.monitored_page_id
+		JOIN competitors c ON c.id = mp.competitor_id
+		JOIN projects p ON p.id = c.project_id
+		WHERE sn.id = ?`, id)
+}
+
+func (s *OrganizationService) OrgOfAlert(id uint) (uint, error) {
+	return s.orgOf("alert", `
+		SELECT p.organization_id FROM alert_logs al
+		JOIN monitored_pages mp ON mp.id = al.page_id
+		JOIN competitors c ON c.id = mp.competitor_id
+		JOIN projects p ON p.id = c.project_id
+		WHERE al.id = ?`, id)
+}
+
+func (s *OrganizationService) OrgOfMonitorAlert(id uint) (uint, error) {
+	return s.orgOf("monitor alert", `
+		SELECT p.organization_id FROM monitor_alerts ma
+		JOIN monitored_pages mp ON mp.id = ma.page_id
+		JOIN competitors c ON c.id = mp.competitor_id
+		JOIN projects p ON p.id = c.project_id
+		WHERE ma.id = ?`, id)
+}
+
+func (s *OrganizationService) OrgOfRule(id uint) (uint, error) {
+	var rule models.NotificationRule
+	if err := s.db.First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errors.New("notification rule not found")
+		}
+		return 0, err
+	}
+	return s.OrgOfScope(rule.ScopeType, rule.ScopeID)
+}
+
+// OrgOfScope resolves the organization behind a notification rule scope
+func (s *OrganizationService) OrgOfScope(scopeType models.RuleScope, scopeID uint) (uint, error) {
+	switch scopeType {
+	case models.RuleScopeProject:
+		return s.OrgOfProject(scopeID)
+	case models.RuleScopeCompetitor:
+		return s.OrgOfCompetitor(scopeID)
+	case models.RuleScopePage:
+		return s.OrgOfPage(scopeID)
+	default:
+		return 0, fmt.Errorf("invalid scope_type %q", scopeType)
+	}
+}
+
+func (s *OrganizationService) orgOf(resource, query string, id uint) (uint, error) {
+	var orgID *uint
+	result := s.db.Raw(query, id).Scan(&orgID)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 || orgID == nil {
+		return 0, fmt.Errorf("%s not found", resource)
+	}
+	return *orgID, nil
+}
+
+// Viewer is the user a list is filtered for, and whether their credential
+// passed 2FA (see MFASatisfied)
+type Viewer struct {
+	UserID uint
+	MFA    bool
+}
+
+// mfaPolicyMet is the condition of MFASatisfied on the require_mfa column
+// of an organization
+func mfaPolicyMet(requireMFA string, viewer Viewer) clause.Expr {
+	if !viewer.MFA {
+		return gorm.Expr(requireMFA + " = FALSE")
+	}
+	return gorm.Expr(requireMFA+" = FALSE OR EXISTS (SELECT 1 FROM user_mfa WHERE user_mfa.user_id = ? AND user_mfa.enabled_at IS NOT NULL)", viewer.UserID)
+}
+
+// visibleProjectIDs is a subquery of the projects viewer can see through
+// their memberships (minus organizations requiring a 2FA they did not pass)
+func visibleProjectIDs(db *gorm.DB, viewer Viewer) *gorm.DB {
+	return db.Table("projects").Select("projects.id").
+		Joins("JOIN memberships ON memberships.organization_id = projects.organization_id").
+		Joins("JOIN organizations ON organizations.id = projects.organization_id").
+		Where("memberships.user_id = ?", viewer.UserID).
+		Where(mfaPolicyMet("organizations.require_mfa", viewer))
+}
+
+// visibleCompetitorIDs is a subquery of the competitors viewer can see
+func visibleCompetitorIDs(db *gorm.DB, viewer Viewer) *gorm.DB {
+	return db.Table("competitors").Select("competitors.id").
+		Where("competitors.project_id IN (?)", visibleProjectIDs(db, viewer))
+}
+
+// visiblePageIDs is a subquery of the monitored pages viewer can see
+func visiblePageIDs(db *gorm.DB, viewer Viewer) *gorm.DB {
+	return db.Table("monitored_pages").Select("monitored_pages.id").
+		Where("monitored_pages.competitor_id IN (?)", visibleCompetitorIDs(db, viewer))
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"gorm.io/gorm"
+)
+
+// monitorFailureThreshold is the number of consecutive network failures
+// after which a page is reported as broken. HTTP errors are reported at once.
+const monitorFailureThreshold = 3
+
+// snapshotHealth is the subset of snapshots.raw_data used for health checks
+type snapshotHealth struct {
+	SelectorMatched *bool `json:"selector_matched"`
+}
+
+// pageDiagnosis is the outcome of a health check on one page
+type pageDiagnosis struct {
+	Broken     bool
+	Conclusive bool // false while failures are below the threshold
+	Reason     models.MonitorBrokenReason
+	Detail     string
+	Severity   models.AlertSeverity
+}
+
+// diagnosePage decides whether a page is broken from its fetch state and its
+// two most recent snapshots (latest first). It does not touch the database.
+func diagnosePage(page *models.MonitoredPage, snapshots []models.Snapshot) pageDiagnosis {
+	if page.LastStatusCode >= 400 {
+		return pageDiagnosis{
+			Broken:     true,
+			Conclusive: true,
+			Reason:     models.ReasonHTTPError,
+			Detail:     fmt.Sprintf("Page returned HTTP %d: %s", page.LastStatusCode, page.LastError),
+			Severity:   models.SeverityHigh,
+		}
+	}
+
+	if page.ConsecutiveFailures >= monitorFailureThreshold {
+		return pageDiagnosis{
+			Broken:     true,
+			Conclusive: true,
+			Reason:     models.ReasonConsecutiveFailures,
+			Detail:     fmt.Sprintf("%d consecutive fetch failures, last error: %s", page.ConsecutiveFailures, page.LastError),
+			Severity:   models.SeverityHigh,
+		}
+	}
+
+	// The latest snapshot is stale while the page keeps failing
+	if page.ConsecutiveFailures > 0 {
+		return pageDiagnosis{}
+	}
+
+	if len(snapshots) == 0 {
+		return pageDiagnosis{Conclusive: true}
+	}
+	latest := snapshots[0]
+
+	var health snapshotHealth
+	if len(latest.RawData) > 0 {
+		if err := json.Unmarshal(latest.RawData, &health); err != nil {
+			log.Printf("⚠️  PageHealth: unreadable raw_data on snapshot %d: %v", latest.ID, err)
+		}
+	}
+	if health.SelectorMatched != nil && !*health.SelectorMatched {
+		return pageDiagnosis{
+			Broken:     true,
+			Conclusive: true,
+			Reason:     models.ReasonSelectorNotMatched,
+			Detail:     fmt.Sprintf("CSS selector %q no longer matches the page", page.CSSSelector),
+			Severity:   models.SeverityMedium,
+		}
+	}
+
+	if len(snapshots) > 1 && latest.Price == "" && snapshots[1].Price != "" {
+		return pageDiagnosis{
+			Broken:     true,
+			Conclusive: true,
+			Reason:     models.ReasonPriceMissing,
+			Detail:     fmt.Sprintf("No price extracted, previous snapshot had %s", snapshots[1].Price),
+			Severity:   models.SeverityMedium,
+		}
+	}
+
+	return pageDiagnosis{Conclusive: true}
+}
+
+// monitorTransition is what a health check does to the page's open
+// monitor_broken alert
+type monitorTransition int
+
+const (
+	monitorUnchanged monitorTransition = iota
+	monitorRaise                       // a new alert is raised and notified
+	monitorResolve                     // the open alert is resolved
+)
+
+// monitorTransitionFor decides the transition from a diagnosis and whether
+// an alert is already open: a broken page raises at most one alert, and only
+// a conclusive healthy check resolves it.
+func monitorTransitionFor(diag pageDiagnosis, hasOpen bool) monitorTransition {
+	switch {
+	case diag.Broken && !hasOpen:
+		return monitorRaise
+	case !diag.Broken && diag.Conclusive && hasOpen:
+		return monitorResolve
+	}
+	return monitorUnchanged
+}
+
+// CheckPageHealth evaluates every page scraped since its last health check,
+// raises monitor_broken alerts and resolves them once the page recovers.
+func (s *AlertService) CheckPageHealth() error {
+	var pages []models.MonitoredPage
+	err := s.db.
+		Where("last_scraped_at IS NOT NULL").
+		Where("health_checked_at IS NULL OR health_checked_at < last_scraped_at").
+		Find(&pages).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range pages {
+		if err := s.checkPage(&pages[i]); err != nil {
+			log.Printf("❌ AlertService: health check failed for page %d: %v", pages[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// CheckPageHealthFor re-evaluates a single page, typically right after a
+// snapshot.created or scrape.failed event
+func (s *AlertService) CheckPageHealthFor(pageID uint) error {
+	var page models.MonitoredPage
+	if err := s.db.First(&page, pageID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("monitored page not found")
+		}
+		return err
+	}
+	return s.checkPage(&page)
+}
+
+func (s *AlertService) checkPage(page *models.MonitoredPage) error {
+	var snapshots []models.Snapshot
+	if err := s.db.Where("monitored_page_id = ?", page.ID).
+		Order("scraped_at DESC").Limit(2).Find(&snapshots).Error; err != nil {
+		return err
+	}
+
+	diag := diagnosePage(page, snapshots)
+
+	var open models.MonitorAlert
+	err := s.db.Where("page_id = ? AND resolved_at IS NULL", page.ID).
+		Order("created_at DESC").First(&open).Error
+	hasOpen := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	now := time.Now()
+	switch monitorTransitionFor(diag, hasOpen) {
+	case monitorRaise:
+		if err := s.raiseMonitorBroken(page, diag); err != nil {
+			return err
+		}
+	case monitorResolve:
+		if err := s.db.Model(&open).Update("resolved_at", now).Error; err != nil {
+			return err
+		}
+		log.Printf("✅ Monitor recovered: page=%d alert=%d", page.ID, open.ID)
+	}
+
+	return s.db.Model(page).Update("health_checked_at", now).Error
+}
+
+// raiseMonitorBroken persists a monitor_broken alert and notifies the page
+// owner and the project recipients, subject to their quiet hours
+func (s *AlertService) raiseMonitorBroken(page *models.MonitoredPage, diag pageDiagnosis) error {
+	alert := &models.MonitorAlert{
+		PageID:              page.ID,
+		AlertType:           models.AlertTypeMonitorBroken,
+		Reason:              diag.Reason,
+		Severity:            diag.Severity,
+		Detail:              diag.Detail,
+		StatusCode:          page.LastStatusCode,
+		ConsecutiveFailures: page.ConsecutiveFailures,
+	}
+	if err := s.db.Create(alert).Error; err != nil {
+		return err
+	}
+
+	log.Printf("🛠️  Monitor broken [%s] page=%d severity=%s | %s", diag.Reason, page.ID, diag.Severity, diag.Detail)
+
+	settings, userEmail, err := s.prefSvc.GetSettingsForPage(int(page.ID))
+	if err != nil {
+		settings = s.prefSvc.defaultSettings()
+	}
+
+	now := time.Now()
+	deliveries := routeDeliveries(routeNotification(nil, settings, userEmail), quietUntil(settings, diag.Severity, now))
+	deliveries = append(deliveries, s.recipientDeliveries(int(page.ID), nil, diag.Severity, now)...)
+	s.deliverySvc.DispatchMonitor(alert, page, dedupeDeliveries(deliveries), now)
+	return nil
+}
+
+// ListMonitorAlerts returns monitor_broken alerts, optionally filtered by page
+// and acknowledgement state, among the pages viewer can see
+func (s *AlertService) ListMonitorAlerts(viewer Viewer, pageID uint, acknowledged *bool, offset, limit int) ([]models.MonitorAlert, int64, error) {
+	query := s.db.Model(&models.MonitorAlert{}).Where("page_id IN (?)", visiblePageIDs(s.db, viewer))
+	if pageID != 0 {
+		query = query.Where("page_id = ?", pageID)
+	}
+	if acknowledged != nil {
+		query = query.Where("acknowledged = ?", *acknowledged)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var alerts []models.MonitorAlert
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&alerts).Error; err != nil {
+		return nil, 0, err
+	}
+	return alerts, total, nil
+}
+
+// AcknowledgeMonitorAlert marks a monitor_broken alert as seen by a user
+func (s *AlertService) AcknowledgeMonitorAlert(id, userID uint) (*models.MonitorAlert, error) {
+	var alert models.MonitorAlert
+	if err := s.db.First(&alert, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("monitor alert not found")
+		}
+		return nil, err
+	}
+
+	if alert.Acknowledged {
+		return &alert, nil
+	}
+
+	now := time.Now()
+	alert.Acknowledged = true
+	alert.AcknowledgedAt = &now
+	alert.AcknowledgedBy = &userID
+	if err := s.db.Save(&alert).Error; err != nil {
+		return nil, errors.New("failed to acknowledge monitor alert")
+	}
+	return &alert, nil
+}
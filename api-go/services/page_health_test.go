@@ -0,0 +1,177 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rivalprice/api-go/models"
+)
+
+func healthPage(statusCode, failures int, lastError string) *models.MonitoredPage {
+	page := &models.MonitoredPage{}
+	page.ID = 9
+	page.CSSSelector = ".plan-price"
+	page.LastStatusCode = statusCode
+	page.ConsecutiveFailures = failures
+	page.LastError = lastError
+	return page
+}
+
+func healthSnapshot(price, rawData string) models.Snapshot {
+	var snapshot models.Snapshot
+	snapshot.Price = price
+	snapshot.RawData = json.RawMessage(rawData)
+	return snapshot
+}
+
+func TestDiagnosePage(t *testing.T) {
+	matched := healthSnapshot("$29", `{"selector_matched": true}`)
+	notMatched := healthSnapshot("", `{"selector_matched": false}`)
+
+	tests := []struct {
+		name       string
+		page       *models.MonitoredPage
+		snapshots  []models.Snapshot
+		broken     bool
+		conclusive bool
+		reason     models.MonitorBrokenReason
+		severity   models.AlertSeverity
+		detail     string // substring of Detail
+	}{
+		{
+			name:       "healthy page",
+			page:       healthPage(200, 0, ""),
+			snapshots:  []models.Snapshot{matched, matched},
+			conclusive: true,
+		},
+		{
+			// Bot protection answering 403 is reported on the first fetch
+			name:       "blocked",
+			page:       healthPage(403, 1, "forbidden"),
+			snapshots:  []models.Snapshot{matched},
+			broken:     true,
+			conclusive: true,
+			reason:     models.ReasonHTTPError,
+			severity:   models.SeverityHigh,
+			detail:     "HTTP 403: forbidden",
+		},
+		{
+			name:       "rate limited",
+			page:       healthPage(429, 1, "too many requests"),
+			broken:     true,
+			conclusive: true,
+			reason:     models.ReasonHTTPError,
+			severity:   models.SeverityHigh,
+			detail:     "HTTP 429",
+		},
+		{
+			name:       "page removed",
+			page:       healthPage(404, 0, "not found"),
+			snapshots:  []models.Snapshot{matched},
+			broken:     true,
+			conclusive: true,
+			reason:     models.ReasonHTTPError,
+			severity:   models.SeverityHigh,
+			detail:     "HTTP 404",
+		},
+		{
+			name:       "redirect is not an error",
+			page:       healthPage(301, 0, ""),
+			snapshots:  []models.Snapshot{matched},
+			conclusive: true,
+		},
+		{
+			// The latest snapshot is stale, it proves nothing either way
+			name:      "timeouts below the threshold",
+			page:      healthPage(0, monitorFailureThreshold-1, "context deadline exceeded"),
+			snapshots: []models.Snapshot{notMatched},
+		},
+		{
+			name:       "timeouts at the threshold",
+			page:       healthPage(0, monitorFailureThreshold, "context deadline exceeded"),
+			broken:     true,
+			conclusive: true,
+			reason:     models.ReasonConsecutiveFailures,
+			severity:   models.SeverityHigh,
+			detail:     "3 consecutive fetch failures, last error: context deadline exceeded",
+		},
+		{
+			// A captcha page answers 200 without the priced content
+			name:       "captcha instead of the page",
+			page:       healthPage(200, 0, ""),
+			snapshots:  []models.Snapshot{notMatched, matched},
+			broken:     true,
+			conclusive: true,
+			reason:     models.ReasonSelectorNotMatched,
+			severity:   models.SeverityMedium,
+			detail:     `".plan-price"`,
+		},
+		{
+			name:       "price missing after a layout change",
+			page:       healthPage(200, 0, ""),
+			snapshots:  []models.Snapshot{healthSnapshot("", `{}`), matched},
+			broken:     true,
+			conclusive: true,
+			reason:     models.ReasonPriceMissing,
+			severity:   models.SeverityMedium,
+			detail:     "previous snapshot had $29",
+		},
+		{
+			name:       "page never had a price",
+			page:       healthPage(200, 0, ""),
+			snapshots:  []models.Snapshot{healthSnapshot("", `{}`), healthSnapshot("", `{}`)},
+			conclusive: true,
+		},
+		{
+			name:       "unreadable raw data",
+			page:       healthPage(200, 0, ""),
+			snapshots:  []models.Snapshot{healthSnapshot("$29", `not json`)},
+			conclusive: true,
+		},
+		{
+			name:       "not scraped yet",
+			page:       healthPage(0, 0, ""),
+			conclusive: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diagnosePage(tt.page, tt.snapshots)
+			if got.Broken != tt.broken || got.Conclusive != tt.conclusive || got.Reason != tt.reason || got.Severity != tt.severity {
+				t.Errorf("diagnosePage() = %+v, want broken %v conclusive %v reason %q severity %q",
+					got, tt.broken, tt.conclusive, tt.reason, tt.severity)
+			}
+			if !strings.Contains(got.Detail, tt.detail) {
+				t.Errorf("Detail = %q, want it to contain %q", got.Detail, tt.detail)
+			}
+		})
+	}
+}
+
+func TestMonitorTransitionFor(t *testing.T) {
+	broken := pageDiagnosis{Broken: true, Conclusive: true, Reason: models.ReasonHTTPError}
+	healthy := pageDiagnosis{Conclusive: true}
+	inconclusive := pageDiagnosis{}
+
+	tests := []struct {
+		name    string
+		diag    pageDiagnosis
+		hasOpen bool
+		want    monitorTransition
+	}{
+		{name: "broken page raises an alert", diag: broken, want: monitorRaise},
+		{name: "broken page with an open alert raises no other", diag: broken, hasOpen: true, want: monitorUnchanged},
+		{name: "healthy page resolves the open alert", diag: healthy, hasOpen: true, want: monitorResolve},
+		{name: "healthy page without alert", diag: healthy, want: monitorUnchanged},
+		{name: "failures below the threshold keep the alert open", diag: inconclusive, hasOpen: true, want: monitorUnchanged},
+		{name: "failures below the threshold raise nothing", diag: inconclusive, want: monitorUnchanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := monitorTransitionFor(tt.diag, tt.hasOpen); got != tt.want {
+				t.Errorf("monitorTransitionFor() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
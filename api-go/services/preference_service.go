@@ -1,7 +1,10 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/rivalprice/api-go/models"
 	"gorm.io/gorm"
@@ -40,27 +43,107 @@ func (s *PreferenceService) GetSettingsForPage(pageID int) (*models.UserNotifica
 		return s.defaultSettings(), "", nil
 	}
 
-	// Load notification settings for this user
+	settings, err := s.GetSettingsForUser(r.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return settings, r.Email, nil
+}
+
+// GetSettingsForUser returns the user's notification settings, creating the
+// default row on first access
+func (s *PreferenceService) GetSettingsForUser(userID uint) (*models.UserNotificationSettings, error) {
 	var settings models.UserNotificationSettings
-	err = s.db.Where("user_id = ?", r.UserID).First(&settings).Error
+	err := s.db.Where("user_id = ?", userID).First(&settings).Error
+	if err == nil {
+		return &settings, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// No settings row yet → create defaults
+	log.Printf("ℹ️  PreferenceService: no settings for user %d, creating defaults", userID)
+	settings = models.UserNotificationSettings{
+		UserID:                   userID,
+		NotifyEmail:              true,
+		NotifyWebhook:            false,
+		MinimumChangePercent:     5.0,
+		AlertOnPriceChange:       true,
+		AlertOnFeatureChange:     true,
+		AlertOnMessaging:         false,
+		AlertOnVisualChange:      true,
+		DigestFrequency:          models.DigestImmediate,
+		DigestHour:               8,
+		DigestWeekday:            int(time.Monday),
+		Timezone:                 "UTC",
+		QuietHoursStart:          "22:00",
+		QuietHoursEnd:            "07:00",
+		QuietHoursBypassCritical: true,
+	}
+	if createErr := s.db.Create(&settings).Error; createErr != nil {
+		log.Printf("⚠️  PreferenceService: failed to create default settings: %v", createErr)
+	}
+	return &settings, nil
+}
+
+// UpdateSettings applies a partial update to the user's notification settings
+func (s *PreferenceService) UpdateSettings(actor Actor, updates map[string]interface{}) (*models.UserNotificationSettings, error) {
+	settings, err := s.GetSettingsForUser(actor.UserID)
 	if err != nil {
-		// No settings row yet → create defaults
-		log.Printf("ℹ️  PreferenceService: no settings for user %d, creating defaults", r.UserID)
-		settings = models.UserNotificationSettings{
-			UserID:               r.UserID,
-			NotifyEmail:          true,
-			NotifyWebhook:        false,
-			MinimumChangePercent: 5.0,
-			AlertOnPriceChange:   true,
-			AlertOnFeatureChange: true,
-			AlertOnMessaging:     false,
+		return nil, err
+	}
+
+	if freq, ok := updates["digest_frequency"]; ok {
+		switch freq {
+		case models.DigestImmediate, models.DigestDaily, models.DigestWeekly:
+		default:
+			return nil, fmt.Errorf("invalid digest_frequency %v", freq)
+		}
+	}
+	if hour, ok := updates["digest_hour"].(int); ok && (hour < 0 || hour > 23) {
+		return nil, errors.New("digest_hour must be between 0 and 23")
+	}
+	if day, ok := updates["digest_weekday"].(int); ok && (day < 0 || day > 6) {
+		return nil, errors.New("digest_weekday must be between 0 (Sunday) and 6")
+	}
+	for _, column := range []string{"quiet_hours_start", "quiet_hours_end"} {
+		if value, ok := updates[column].(string); ok {
+			if _, err := parseClock(value); err != nil {
+				return nil, err
+			}
 		}
-		if createErr := s.db.Create(&settings).Error; createErr != nil {
-			log.Printf("⚠️  PreferenceService: failed to create default settings: %v", createErr)
+	}
+	if tz, ok := updates["timezone"].(string); ok {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", tz)
 		}
 	}
 
-	return &settings, r.Email, nil
+	if len(updates) == 0 {
+		return settings, nil
+	}
+	before := *settings
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(settings).Updates(updates).Error; err != nil {
+			return errors.New("failed to update notification settings")
+		}
+		var after models.UserNotificationSettings
+		if err := tx.First(&after, settings.ID).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditRecord{
+			Action:   models.AuditSettingsUpdated,
+			TargetID: settings.ID,
+			Before:   before,
+			After:    after,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetSettingsForUser(actor.UserID)
 }
 
 // defaultSettings returns safe defaults when user cannot be resolved
@@ -72,5 +155,8 @@ func (s *PreferenceService) defaultSettings() *models.UserNotificationSettings {
 		AlertOnPriceChange:   true,
 		AlertOnFeatureChange: true,
 		AlertOnMessaging:     false,
+		AlertOnVisualChange:  true,
+		DigestFrequency:      models.DigestImmediate,
+		Timezone:             "UTC",
 	}
 }
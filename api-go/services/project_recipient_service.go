@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
+	"gorm.io/gorm"
+)
+
+// UnsubscribePath is the public API path that unsubscribes a project recipient
+func UnsubscribePath(token string) string {
+	return "/api/v1/unsubscribe/" + token
+}
+
+// ProjectRecipientService manages who, besides the project owner, receives
+// a project's alerts
+type ProjectRecipientService struct {
+	db *gorm.DB
+}
+
+func NewProjectRecipientService(db *gorm.DB) *ProjectRecipientService {
+	return &ProjectRecipientService{db: db}
+}
+
+// AddRecipient adds a collaborator (userID set, a member of the project's
+// organization) or an external email address
+func (s *ProjectRecipientService) AddRecipient(actor Actor, recipient *models.ProjectRecipient) error {
+	var project models.Project
+	if err := s.db.First(&project, recipient.ProjectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("project not found")
+		}
+		return err
+	}
+
+	duplicate := s.db.Model(&models.ProjectRecipient{}).Where("project_id = ?", recipient.ProjectID)
+	switch {
+	case recipient.UserID != nil:
+		var user models.User
+		if err := s.db.First(&user, *recipient.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("user not found")
+			}
+			return err
+		}
+		if user.ID == project.UserID {
+			return errors.New("the project owner already receives its alerts")
+		}
+		if project.OrganizationID == nil {
+			return ErrNotOrgMember
+		}
+		if err := NewOrganizationService(s.db).RequireMember(*project.OrganizationID, user.ID); err != nil {
+			return err
+		}
+		recipient.Email = ""
+		recipient.MinSeverity = ""
+		duplicate = duplicate.Where("user_id = ?", user.ID)
+	case recipient.Email != "":
+		addr, err := mail.ParseAddress(recipient.Email)
+		if err != nil {
+			return errors.New("invalid email address")
+		}
+		recipient.Email = strings.ToLower(addr.Address)
+		if recipient.MinSeverity != "" {
+			if _, ok := severityRank[recipient.MinSeverity]; !ok {
+				return errors.New("invalid min_severity")
+			}
+		}
+		duplicate = duplicate.Where("email = ?", recipient.Email)
+	default:
+		return errors.New("user_id or email is required")
+	}
+	if recipient.WebhookURL != "" && project.OrganizationID != nil {
+		if err := checkPlanChannel(s.db, *project.OrganizationID, "webhook"); err != nil {
+			return err
+		}
+	}
+
+	var count int64
+	if err := duplicate.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("recipient already added to this project")
+	}
+
+	token, err := utils.RandomToken(24)
+	if err != nil {
+		return err
+	}
+	recipient.UnsubscribeToken = token
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(recipient).Error; err != nil {
+			return errors.New("failed to add recipient")
+		}
+		return s.audit(tx, actor, models.AuditRecipientAdded, recipient, nil, recipient)
+	})
+}
+
+func (s *ProjectRecipientService) ListRecipients(projectID uint) ([]models.ProjectRecipient, error) {
+	var recipients []models.ProjectRecipient
+	if err := s.db.Preload("User").Where("project_id = ?", projectID).Order("id").Find(&recipients).Error; err != nil {
+		return nil, err
+	}
+	return recipients, nil
+}
+
+func (s *ProjectRecipientService) RemoveRecipient(actor Actor, projectID, id uint) error {
+	var recipient models.ProjectRecipient
+	if err := s.db.Where("project_id = ?", projectID).First(&recipient, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("recipient not found")
+		}
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&recipient).Error; err != nil {
+			return err
+		}
+		return s.audit(tx, actor, models.AuditRecipientRemoved, &recipient, recipient, nil)
+	})
+}
+
+// RecipientByToken returns the recipient behind an unsubscribe link and its
+// project's name, without changing anything
+func (s *ProjectRecipientService) RecipientByToken(token string) (*models.ProjectRecipient, string, error) {
+	var recipient models.ProjectRecipient
+	if err := s.db.Where("unsubscribe_token = ?", token).First(&recipient).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", errors.New("unsubscribe link not found")
+		}
+		return nil, "", err
+	}
+
+	var project models.Project
+	if err := s.db.Select("id", "name").First(&project, recipient.ProjectID).Error; err != nil {
+		return nil, "", err
+	}
+	return &recipient, project.Name, nil
+}
+
+// Unsubscribe stops alerts to the recipient behind an unsubscribe link,
+// including the ones queued for the end of quiet hours
+func (s *ProjectRecipientService) Unsubscribe(actor Actor, token string) (*models.ProjectRecipient, error) {
+	var recipient models.ProjectRecipient
+	if err := s.db.Where("unsubscribe_token = ?", token).First(&recipient).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("unsubscribe link not found")
+		}
+		return nil, err
+	}
+
+	if recipient.UnsubscribedAt == nil {
+		before := recipient
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&recipient).Update("unsubscribed_at", time.Now()).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("unsubscribe_token = ? AND delivered_at IS NULL", token).
+				Delete(&models.PendingNotification{}).Error; err != nil {
+				return err
+			}
+			return s.audit(tx, actor, models.AuditRecipientUnsubscribed, &recipient, before, recipient)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &recipient, nil
+}
+
+// audit records an action on recipient, in its project's organization
+func (s *ProjectRecipientService) audit(tx *gorm.DB, actor Actor, action string, recipient *models.ProjectRecipient, before, after interface{}) error {
+	return recordAudit(tx, actor, auditRecord{
+		OrganizationID: auditOrg(NewOrganizationService(tx).OrgOfProject, recipient.ProjectID),
+		Action:         action,
+		TargetID:       recipient.ID,
+		Before:         before,
+		After:          after,
+	})
+}
+
+// RecipientsForPage returns the subscribed recipients of the page's project
+func (s *ProjectRecipientService) RecipientsForPage(pageID int) ([]models.ProjectRecipient, error) {
+	var recipients []models.ProjectRecipient
+	err := s.db.Preload("User").
+		Joins("JOIN competitors c ON c.project_id = project_recipients.project_id").
+		Joins("JOIN monitored_pages mp ON mp.competitor_id = c.id").
+		Where("mp.id = ? AND project_recipients.unsubscribed_at IS NULL", pageID).
+		Find(&recipients).Error
+	return recipients, err
+}
@@ -15,10 +15,12 @@ func NewProjectService(db *gorm.DB) *ProjectService {
 	return &ProjectService{db: db}
 }
 
-func (s *ProjectService) CreateProject(userID uint, name string) (*models.Project, error) {
+// CreateProject creates a project in organization orgID, the actor being its
+// creator
+func (s *ProjectService) CreateProject(actor Actor, orgID uint, name string) (*models.Project, error) {
 	// Verify user exists
 	var user models.User
-	if err := s.db.First(&user, userID).Error; err != nil {
+	if err := s.db.First(&user, actor.UserID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("user not found")
 		}
@@ -26,12 +28,27 @@ func (s *ProjectService) CreateProject(userID uint, name string) (*models.Projec
 	}
 
 	project := models.Project{
-		UserID: userID,
-		Name:   name,
+		UserID:         actor.UserID,
+		OrganizationID: &orgID,
+		Name:           name,
 	}
 
-	if err := s.db.Create(&project).Error; err != nil {
-		return nil, errors.New("failed to create project")
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkPlanCount(tx, orgID, resourceProjects); err != nil {
+			return err
+		}
+		if err := tx.Create(&project).Error; err != nil {
+			return errors.New("failed to create project")
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: orgID,
+			Action:         models.AuditProjectCreated,
+			TargetID:       project.ID,
+			After:          project,
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &project, nil
@@ -56,6 +73,21 @@ func (s *ProjectService) GetAllProjects() ([]models.Project, error) {
 	return projects, nil
 }
 
+// GetProjectsVisibleTo returns the projects of the organizations viewer
+// belongs to, optionally limited to one organization
+func (s *ProjectService) GetProjectsVisibleTo(viewer Viewer, orgID uint) ([]models.Project, error) {
+	query := s.db.Preload("User").Where("id IN (?)", visibleProjectIDs(s.db, viewer))
+	if orgID != 0 {
+		query = query.Where("organization_id = ?", orgID)
+	}
+
+	var projects []models.Project
+	if err := query.Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
 func (s *ProjectService) GetProjectsByUserID(userID uint) ([]models.Project, error) {
 	var projects []models.Project
 	if err := s.db.Where("user_id = ?", userID).Find(&projects).Error; err != nil {
@@ -64,7 +96,7 @@ func (s *ProjectService) GetProjectsByUserID(userID uint) ([]models.Project, err
 	return projects, nil
 }
 
-func (s *ProjectService) UpdateProject(id uint, name string) (*models.Project, error) {
+func (s *ProjectService) UpdateProject(actor Actor, id uint, name string) (*models.Project, error) {
 	var project models.Project
 	if err := s.db.First(&project, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -73,15 +105,28 @@ func (s *ProjectService) UpdateProject(id uint, name string) (*models.Project, e
 		return nil, err
 	}
 
+	before := project
 	project.Name = name
-	if err := s.db.Save(&project).Error; err != nil {
-		return nil, errors.New("failed to update project")
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&project).Error; err != nil {
+			return errors.New("failed to update project")
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: projectOrgID(&project),
+			Action:         models.AuditProjectUpdated,
+			TargetID:       project.ID,
+			Before:         before,
+			After:          project,
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &project, nil
 }
 
-func (s *ProjectService) DeleteProject(id uint) error {
+func (s *ProjectService) DeleteProject(actor Actor, id uint) error {
 	var project models.Project
 	if err := s.db.First(&project, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -90,12 +135,27 @@ func (s *ProjectService) DeleteProject(id uint) error {
 		return err
 	}
 
-	// Delete related competitors and monitored pages first
-	s.db.Where("project_id = ?", id).Delete(&models.Competitor{})
-	
-	if err := s.db.Delete(&project).Error; err != nil {
-		return errors.New("failed to delete project")
-	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Delete related competitors and monitored pages first
+		tx.Where("project_id = ?", id).Delete(&models.Competitor{})
 
-	return nil
+		if err := tx.Delete(&project).Error; err != nil {
+			return errors.New("failed to delete project")
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: projectOrgID(&project),
+			Action:         models.AuditProjectDeleted,
+			TargetID:       project.ID,
+			Before:         project,
+		})
+	})
+}
+
+// projectOrgID is the organization of project, 0 for projects predating
+// organizations
+func projectOrgID(project *models.Project) uint {
+	if project.OrganizationID == nil {
+		return 0
+	}
+	return *project.OrganizationID
 }
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+)
+
+// parseClock parses an "HH:MM" time of day into minutes since midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// quietHoursEnd reports whether now falls in the user's quiet hours and, if
+// so, when they end. Windows may span midnight (e.g. 22:00 → 07:00).
+func quietHoursEnd(settings *models.UserNotificationSettings, now time.Time) (time.Time, bool) {
+	if !settings.QuietHoursEnabled {
+		return time.Time{}, false
+	}
+	start, err := parseClock(settings.QuietHoursStart)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := parseClock(settings.QuietHoursEnd)
+	if err != nil || start == end {
+		return time.Time{}, false
+	}
+
+	loc := settingsLocation(settings)
+	local := now.In(loc)
+	current := local.Hour()*60 + local.Minute()
+	endToday := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+
+	if start < end {
+		// Same-day window
+		if current >= start && current < end {
+			return endToday, true
+		}
+		return time.Time{}, false
+	}
+
+	// Overnight window
+	switch {
+	case current >= start:
+		return endToday.AddDate(0, 0, 1), true
+	case current < end:
+		return endToday, true
+	default:
+		return time.Time{}, false
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+)
+
+func quietSettings(tz, start, end string) *models.UserNotificationSettings {
+	return &models.UserNotificationSettings{
+		QuietHoursEnabled:        true,
+		QuietHoursStart:          start,
+		QuietHoursEnd:            end,
+		QuietHoursBypassCritical: true,
+		Timezone:                 tz,
+	}
+}
+
+func TestQuietHoursEnd(t *testing.T) {
+	paris := mustLoadLocation(t, "Europe/Paris")
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+
+	disabled := quietSettings("Europe/Paris", "22:00", "07:00")
+	disabled.QuietHoursEnabled = false
+
+	tests := []struct {
+		name     string
+		settings *models.UserNotificationSettings
+		now      time.Time
+		want     time.Time // zero when not in quiet hours
+	}{
+		{
+			name:     "disabled",
+			settings: disabled,
+			now:      time.Date(2026, 3, 10, 23, 0, 0, 0, paris),
+		},
+		{
+			name:     "overnight, before midnight",
+			settings: quietSettings("Europe/Paris", "22:00", "07:00"),
+			now:      time.Date(2026, 3, 10, 23, 30, 0, 0, paris),
+			want:     time.Date(2026, 3, 11, 7, 0, 0, 0, paris),
+		},
+		{
+			name:     "overnight, after midnight",
+			settings: quietSettings("Europe/Paris", "22:00", "07:00"),
+			now:      time.Date(2026, 3, 11, 2, 0, 0, 0, paris),
+			want:     time.Date(2026, 3, 11, 7, 0, 0, 0, paris),
+		},
+		{
+			name:     "overnight, at the start",
+			settings: quietSettings("Europe/Paris", "22:00", "07:00"),
+			now:      time.Date(2026, 3, 10, 22, 0, 0, 0, paris),
+			want:     time.Date(2026, 3, 11, 7, 0, 0, 0, paris),
+		},
+		{
+			name:     "overnight, at the end",
+			settings: quietSettings("Europe/Paris", "22:00", "07:00"),
+			now:      time.Date(2026, 3, 11, 7, 0, 0, 0, paris),
+		},
+		{
+			name:     "overnight, during the day",
+			settings: quietSettings("Europe/Paris", "22:00", "07:00"),
+			now:      time.Date(2026, 3, 11, 12, 0, 0, 0, paris),
+		},
+		{
+			name:     "same-day window",
+			settings: quietSettings("Europe/Paris", "12:00", "14:00"),
+			now:      time.Date(2026, 3, 11, 13, 59, 0, 0, paris),
+			want:     time.Date(2026, 3, 11, 14, 0, 0, 0, paris),
+		},
+		{
+			name:     "same-day window, after it",
+			settings: quietSettings("Europe/Paris", "12:00", "14:00"),
+			now:      time.Date(2026, 3, 11, 14, 0, 0, 0, paris),
+		},
+		{
+			// 14:00 UTC is 23:00 in Tokyo, already in the user's night
+			name:     "evaluated in the user's time zone",
+			settings: quietSettings("Asia/Tokyo", "22:00", "07:00"),
+			now:      time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 3, 11, 7, 0, 0, 0, tokyo),
+		},
+		{
+			name:     "unknown time zone falls back to UTC",
+			settings: quietSettings("Mars/Olympus", "22:00", "07:00"),
+			now:      time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 3, 11, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			// Clocks go forward at 02:00 on March 29th: the night is an
+			// hour shorter but still ends at 07:00 local
+			name:     "spring DST change during the night",
+			settings: quietSettings("Europe/Paris", "22:00", "07:00"),
+			now:      time.Date(2026, 3, 28, 23, 0, 0, 0, paris),
+			want:     time.Date(2026, 3, 29, 7, 0, 0, 0, paris),
+		},
+		{
+			name:     "autumn DST change during the night",
+			settings: quietSettings("Europe/Paris", "22:00", "07:00"),
+			now:      time.Date(2026, 10, 24, 23, 0, 0, 0, paris),
+			want:     time.Date(2026, 10, 25, 7, 0, 0, 0, paris),
+		},
+		{
+			// 02:30 is skipped that night: the window ends when clocks
+			// show 03:30
+			name:     "end inside the skipped hour",
+			settings: quietSettings("Europe/Paris", "22:00", "02:30"),
+			now:      time.Date(2026, 3, 28, 23, 0, 0, 0, paris),
+			want:     time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "empty window",
+			settings: quietSettings("Europe/Paris", "07:00", "07:00"),
+			now:      time.Date(2026, 3, 11, 7, 0, 0, 0, paris),
+		},
+		{
+			name:     "invalid time of day",
+			settings: quietSettings("Europe/Paris", "25:00", "07:00"),
+			now:      time.Date(2026, 3, 11, 2, 0, 0, 0, paris),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, quiet := quietHoursEnd(tt.settings, tt.now)
+			if quiet != !tt.want.IsZero() || !got.Equal(tt.want) {
+				t.Errorf("quietHoursEnd() = %v, %v, want %v", got, quiet, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	paris := mustLoadLocation(t, "Europe/Paris")
+	night := time.Date(2026, 3, 10, 23, 0, 0, 0, paris)
+	morning := time.Date(2026, 3, 11, 7, 0, 0, 0, paris)
+
+	noBypass := quietSettings("Europe/Paris", "22:00", "07:00")
+	noBypass.QuietHoursBypassCritical = false
+
+	tests := []struct {
+		name     string
+		settings *models.UserNotificationSettings
+		severity models.AlertSeverity
+		want     time.Time
+	}{
+		{name: "non-critical alert waits", settings: quietSettings("Europe/Paris", "22:00", "07:00"), severity: models.SeverityHigh, want: morning},
+		{name: "critical alert bypasses", settings: quietSettings("Europe/Paris", "22:00", "07:00"), severity: models.SeverityCritical},
+		{name: "critical alert waits without bypass", settings: noBypass, severity: models.SeverityCritical, want: morning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quietUntil(tt.settings, tt.severity, night); !got.Equal(tt.want) {
+				t.Errorf("quietUntil() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuotaExceeded is wrapped by every error refusing an action because of
+// the organization's plan
+var ErrQuotaExceeded = errors.New("plan limit reached")
+
+// Resources counted against plan limits
+const (
+	resourceProjects    = "projects"
+	resourceCompetitors = "competitors"
+	resourcePages       = "monitored pages"
+)
+
+// UsageItem is the consumption of one limit; Limit is -1 when unlimited
+type UsageItem struct {
+	Used  int64 `json:"used"`
+	Limit int   `json:"limit"`
+}
+
+// UsageReport shows an organization's consumption against its plan
+type UsageReport struct {
+	Plan          models.Plan `json:"plan"`
+	Period        string      `json:"period"` // month of the monthly allowances, YYYY-MM
+	Projects      UsageItem   `json:"projects"`
+	Competitors   UsageItem   `json:"competitors"`
+	Pages         UsageItem   `json:"pages"`
+	AIAnalyses    UsageItem   `json:"ai_analyses"`
+	ManualScrapes UsageItem   `json:"manual_scrapes"`
+}
+
+// QuotaService meters monthly allowances (AI analyses, manual scrapes) and
+// reports usage. Creation limits are checked by the services creating the
+// resources, with checkPlanCount.
+type QuotaService struct {
+	db *gorm.DB
+}
+
+func NewQuotaService(db *gorm.DB) *QuotaService {
+	return &QuotaService{db: db}
+}
+
+// Usage returns the consumption of orgID for the current month
+func (s *QuotaService) Usage(orgID uint) (*UsageReport, error) {
+	plan, err := organizationPlan(s.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	report := &UsageReport{
+		Plan:          plan,
+		Period:        usagePeriod(time.Now()),
+		Projects:      UsageItem{Limit: plan.MaxProjects},
+		Competitors:   UsageItem{Limit: plan.MaxCompetitors},
+		Pages:         UsageItem{Limit: plan.MaxPages},
+		AIAnalyses:    UsageItem{Limit: plan.AIAnalysesPerMonth},
+		ManualScrapes: UsageItem{Limit: plan.ManualScrapesPerMonth},
+	}
+
+	for resource, item := range map[string]*UsageItem{
+		resourceProjects:    &report.Projects,
+		resourceCompetitors: &report.Competitors,
+		resourcePages:       &report.Pages,
+	} {
+		if item.Used, err = countOrgResource(s.db, orgID, resource); err != nil {
+			return nil, err
+		}
+	}
+
+	var counters []models.UsageCounter
+	if err := s.db.Where("organization_id = ? AND period = ?", orgID, report.Period).Find(&counters).Error; err != nil {
+		return nil, err
+	}
+	for _, counter := range counters {
+		switch counter.Metric {
+		case models.UsageAIAnalyses:
+			report.AIAnalyses.Used = int64(counter.Count)
+		case models.UsageManualScrapes:
+			report.ManualScrapes.Used = int64(counter.Count)
+		}
+	}
+	return report, nil
+}
+
+// Consume counts n uses of a monthly allowance by orgID, or returns an
+// ErrQuotaExceeded error and counts nothing if the plan has not enough left
+func (s *QuotaService) Consume(orgID uint, metric string, n int) error {
+	plan, err := organizationPlan(s.db, orgID)
+	if err != nil {
+		return err
+	}
+	var limit int
+	switch metric {
+	case models.UsageAIAnalyses:
+		limit = plan.AIAnalysesPerMonth
+	case models.UsageManualScrapes:
+		limit = plan.ManualScrapesPerMonth
+	default:
+		return fmt.Errorf("unknown usage metric %q", metric)
+	}
+
+	exceeded := fmt.Errorf("%w: the %s plan allows %d %s per month", ErrQuotaExceeded, plan.Name, limit, metric)
+	if limit != models.Unlimited && n > limit {
+		return exceeded
+	}
+
+	// The limit is checked by the upsert itself, so concurrent requests
+	// cannot overshoot it
+	result := s.db.Exec(`
+		INSERT INTO usage_counters (organization_id, metric, period, count, updated_at)
+		VALUES (?, ?, ?, ?, NOW())
+		ON CONFLICT (organization_id, metric, period) DO UPDATE
+		SET count = usage_counters.count + EXCLUDED.count, updated_at = NOW()
+		WHERE ? < 0 OR usage_counters.count + EXCLUDED.count <= ?`,
+		orgID, metric, usagePeriod(time.Now()), n, limit, limit)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return exceeded
+	}
+	return nil
+}
+
+// ConsumeForPage consumes n uses of metric by the organization of pageID
+func (s *QuotaService) ConsumeForPage(pageID uint, metric string, n int) error {
+	orgID, err := NewOrganizationService(s.db).OrgOfPage(pageID)
+	if err != nil {
+		return err
+	}
+	return s.Consume(orgID, metric, n)
+}
+
+// Release gives back n uses of a monthly allowance consumed by orgID for an
+// action that did not happen
+func (s *QuotaService) Release(orgID uint, metric string, n int) error {
+	return s.db.Exec(`
+		UPDATE usage_counters SET count = GREATEST(count - ?, 0), updated_at = NOW()
+		WHERE organization_id = ? AND metric = ? AND period = ?`,
+		n, orgID, metric, usagePeriod(time.Now())).Error
+}
+
+// ReleaseForPage releases n uses of metric by the organization of pageID
+func (s *QuotaService) ReleaseForPage(pageID uint, metric string, n int) error {
+	orgID, err := NewOrganizationService(s.db).OrgOfPage(pageID)
+	if err != nil {
+		return err
+	}
+	return s.Release(orgID, metric, n)
+}
+
+// checkPlanCount checks orgID may create one more resource. The
+// organization row is locked until tx ends, so call it in the transaction
+// creating the resource to keep concurrent creations from overshooting.
+func checkPlanCount(tx *gorm.DB, orgID uint, resource string) error {
+	var org models.Organization
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&org, orgID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("organization not found")
+		}
+		return err
+	}
+	plan := models.PlanByName(org.Plan)
+
+	var limit int
+	switch resource {
+	case resourceProjects:
+		limit = plan.MaxProjects
+	case resourceCompetitors:
+		limit = plan.MaxCompetitors
+	case resourcePages:
+		limit = plan.MaxPages
+	}
+	if limit == models.Unlimited {
+		return nil
+	}
+
+	count, err := countOrgResource(tx, orgID, resource)
+	if err != nil {
+		return err
+	}
+	if count >= int64(limit) {
+		return fmt.Errorf("%w: the %s plan allows %d %s", ErrQuotaExceeded, plan.Name, limit, resource)
+	}
+	return nil
+}
+
+// checkPlanFrequency checks pages of orgID may be scraped as often as f
+func checkPlanFrequency(db *gorm.DB, orgID uint, f models.Frequency) error {
+	plan, err := organizationPlan(db, orgID)
+	if err != nil {
+		return err
+	}
+	if !plan.AllowsFrequency(f) {
+		return fmt.Errorf("%w: the %s plan scrapes pages at most %s", ErrQuotaExceeded, plan.Name, plan.MinFrequency)
+	}
+	return nil
+}
+
+// checkPlanChannel checks orgID may send alerts through channel
+func checkPlanChannel(db *gorm.DB, orgID uint, channel string) error {
+	plan, err := organizationPlan(db, orgID)
+	if err != nil {
+		return err
+	}
+	if !plan.AllowsChannel(channel) {
+		return fmt.Errorf("%w: the %s plan does not include %s notifications", ErrQuotaExceeded, plan.Name, channel)
+	}
+	return nil
+}
+
+// countOrgResource counts the projects, competitors or pages of orgID
+func countOrgResource(db *gorm.DB, orgID uint, resource string) (int64, error) {
+	var count int64
+	query := db.Table("projects").Where("projects.organization_id = ?", orgID)
+	switch resource {
+	case resourceCompetitors:
+		query = query.Joins("JOIN competitors ON competitors.project_id = projects.id")
+	case resourcePages:
+		query = query.Joins("JOIN competitors ON competitors.project_id = projects.id").
+			Joins("JOIN monitored_pages ON monitored_pages.competitor_id = competitors.id")
+	}
+	err := query.Count(&count).Error
+	return count, err
+}
+
+func organizationPlan(db *gorm.DB, orgID uint) (models.Plan, error) {
+	var org models.Organization
+	if err := db.Select("id", "plan").First(&org, orgID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Plan{}, errors.New("organization not found")
+		}
+		return models.Plan{}, err
+	}
+	return models.PlanByName(org.Plan), nil
+}
+
+// usagePeriod is the month usage is counted in
+func usagePeriod(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rivalprice/api-go/models"
+)
+
+func expectOrganizationPlan(mock sqlmock.Sqlmock, orgID uint, plan string) {
+	mock.ExpectQuery(`SELECT "id","plan" FROM "organizations" WHERE "organizations"."id" = \$1`).WithArgs(orgID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "plan"}).AddRow(orgID, plan))
+}
+
+// consumeUpsert is the usage_counters upsert, with the limit checked in the
+// WHERE of its DO UPDATE
+const consumeUpsert = `INSERT INTO usage_counters \(organization_id, metric, period, count, updated_at\)\s+` +
+	`VALUES \(\$1, \$2, \$3, \$4, NOW\(\)\)\s+` +
+	`ON CONFLICT \(organization_id, metric, period\) DO UPDATE\s+` +
+	`SET count = usage_counters.count \+ EXCLUDED.count, updated_at = NOW\(\)\s+` +
+	`WHERE \$5 < 0 OR usage_counters.count \+ EXCLUDED.count <= \$6`
+
+func TestConsume(t *testing.T) {
+	period := usagePeriod(time.Now())
+
+	tests := []struct {
+		name     string
+		plan     string
+		metric   string
+		n        int
+		limit    int   // expected in the upsert, if it runs
+		affected int64 // rows the upsert inserts or updates
+		upsert   bool
+		wantErr  error
+	}{
+		{name: "within the allowance", plan: models.PlanFree, metric: models.UsageAIAnalyses, n: 1, limit: 20, affected: 1, upsert: true},
+		{name: "allowance used up", plan: models.PlanFree, metric: models.UsageAIAnalyses, n: 1, limit: 20, affected: 0, upsert: true, wantErr: ErrQuotaExceeded},
+		{name: "manual scrapes of the pro plan", plan: models.PlanPro, metric: models.UsageManualScrapes, n: 5, limit: 300, affected: 1, upsert: true},
+		{name: "more than the whole allowance", plan: models.PlanFree, metric: models.UsageManualScrapes, n: 11, wantErr: ErrQuotaExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			expectOrganizationPlan(mock, 4, tt.plan)
+			if tt.upsert {
+				mock.ExpectExec(consumeUpsert).
+					WithArgs(4, tt.metric, period, tt.n, tt.limit, tt.limit).
+					WillReturnResult(sqlmock.NewResult(0, tt.affected))
+			}
+
+			err := NewQuotaService(db).Consume(4, tt.metric, tt.n)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Consume() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("unknown metric", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectOrganizationPlan(mock, 4, models.PlanFree)
+		if err := NewQuotaService(db).Consume(4, "exports", 1); err == nil || errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Consume() of an unknown metric = %v", err)
+		}
+	})
+}
+
+func TestRelease(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectExec(`UPDATE usage_counters SET count = GREATEST\(count - \$1, 0\).*WHERE organization_id = \$2 AND metric = \$3 AND period = \$4`).
+		WithArgs(1, 4, models.UsageAIAnalyses, usagePeriod(time.Now())).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := NewQuotaService(db).Release(4, models.UsageAIAnalyses, 1); err != nil {
+		t.Errorf("Release() = %v", err)
+	}
+}
+
+func TestCheckPlanCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		plan     string
+		resource string
+		count    int64
+		counted  bool
+		wantErr  bool
+	}{
+		{name: "below the limit", plan: models.PlanFree, resource: resourceCompetitors, count: 2, counted: true},
+		{name: "at the limit", plan: models.PlanFree, resource: resourceCompetitors, count: 3, counted: true, wantErr: true},
+		{name: "over the limit after a downgrade", plan: models.PlanFree, resource: resourcePages, count: 40, counted: true, wantErr: true},
+		{name: "unlimited is not counted", plan: models.PlanBusiness, resource: resourceProjects},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			// The organization is locked first, so concurrent creations
+			// count one after the other
+			mock.ExpectQuery(`SELECT \* FROM "organizations" WHERE "organizations"."id" = \$1 .*FOR UPDATE`).WithArgs(4, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "plan"}).AddRow(4, tt.plan))
+			if tt.counted {
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "projects" .*WHERE projects.organization_id = \$1`).WithArgs(4).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.count))
+			}
+
+			err := checkPlanCount(db, 4, tt.resource)
+			if tt.wantErr != errors.Is(err, ErrQuotaExceeded) || !tt.wantErr && err != nil {
+				t.Errorf("checkPlanCount() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("unknown organization", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(`FROM "organizations"`).WillReturnRows(sqlmock.NewRows([]string{"id", "plan"}))
+		if err := checkPlanCount(db, 4, resourceProjects); err == nil || errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("checkPlanCount() = %v, want organization not found", err)
+		}
+	})
+}
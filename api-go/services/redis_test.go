@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// fakeRedis is an in-memory Redis server speaking enough of RESP for the
+// services' commands (strings, hashes, expiry, MULTI/EXEC). Keys expire
+// against now, which tests move forward.
+type fakeRedis struct {
+	mu      sync.Mutex
+	now     time.Time
+	values  map[string]string
+	hashes  map[string]map[string]string
+	expires map[string]time.Time
+}
+
+// newFakeRedis starts a fakeRedis and returns a client connected to it
+func newFakeRedis(t *testing.T) (*redis.Client, *fakeRedis) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{
+		now:     time.Now(),
+		values:  map[string]string{},
+		hashes:  map[string]map[string]string{},
+		expires: map[string]time.Time{},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), MaxRetries: -1})
+	t.Cleanup(func() {
+		client.Close()
+		listener.Close()
+	})
+	return client, f
+}
+
+// advance moves the clock keys expire against
+func (f *fakeRedis) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// exists reports whether key is set and alive
+func (f *fakeRedis) exists(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.alive(key)
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		switch name := strings.ToUpper(args[0]); {
+		case name == "MULTI":
+			inMulti, queued = true, nil
+			w.WriteString("+OK\r\n")
+		case name == "EXEC":
+			fmt.Fprintf(w, "*%d\r\n", len(queued))
+			for _, cmd := range queued {
+				w.WriteString(f.exec(cmd))
+			}
+			inMulti, queued = false, nil
+		case inMulti:
+			queued = append(queued, args)
+			w.WriteString("+QUEUED\r\n")
+		default:
+			w.WriteString(f.exec(args))
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// alive drops key if it expired and reports whether it is still set
+func (f *fakeRedis) alive(key string) bool {
+	if at, ok := f.expires[key]; ok && !f.now.Before(at) {
+		f.del(key)
+		return false
+	}
+	_, isValue := f.values[key]
+	_, isHash := f.hashes[key]
+	return isValue || isHash
+}
+
+func (f *fakeRedis) del(key string) {
+	delete(f.values, key)
+	delete(f.hashes, key)
+	delete(f.expires, key)
+}
+
+func bulk(s string) string   { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+func integer(n int64) string { return fmt.Sprintf(":%d\r\n", n) }
+
+const nilReply = "$-1\r\n"
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+		f.alive(key)
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "SET":
+		f.del(key)
+		f.values[key] = args[2]
+		for i := 3; i+1 < len(args); i += 2 {
+			n, _ := strconv.ParseInt(args[i+1], 10, 64)
+			switch strings.ToUpper(args[i]) {
+			case "EX":
+				f.expires[key] = f.now.Add(time.Duration(n) * time.Second)
+			case "PX":
+				f.expires[key] = f.now.Add(time.Duration(n) * time.Millisecond)
+			}
+		}
+		return "+OK\r\n"
+	case "GET":
+		if v, ok := f.values[key]; ok {
+			return bulk(v)
+		}
+		return nilReply
+	case "EXISTS", "DEL":
+		var n int64
+		for _, k := range args[1:] {
+			if f.alive(k) {
+				n++
+				if strings.ToUpper(args[0]) == "DEL" {
+					f.del(k)
+				}
+			}
+		}
+		return integer(n)
+	case "EXPIRE":
+		if _, ok := f.values[key]; !ok && f.hashes[key] == nil {
+			return integer(0)
+		}
+		seconds, _ := strconv.ParseInt(args[2], 10, 64)
+		f.expires[key] = f.now.Add(time.Duration(seconds) * time.Second)
+		return integer(1)
+	case "HSET":
+		if f.hashes[key] == nil {
+			f.hashes[key] = map[string]string{}
+		}
+		var added int64
+		for i := 2; i+1 < len(args); i += 2 {
+			if _, ok := f.hashes[key][args[i]]; !ok {
+				added++
+			}
+			f.hashes[key][args[i]] = args[i+1]
+		}
+		return integer(added)
+	case "HGET":
+		if v, ok := f.hashes[key][args[2]]; ok {
+			return bulk(v)
+		}
+		return nilReply
+	case "HINCRBY":
+		if f.hashes[key] == nil {
+			f.hashes[key] = map[string]string{}
+		}
+		current, _ := strconv.ParseInt(f.hashes[key][args[2]], 10, 64)
+		by, _ := strconv.ParseInt(args[3], 10, 64)
+		f.hashes[key][args[2]] = strconv.FormatInt(current+by, 10)
+		return integer(current + by)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
@@ -2,7 +2,6 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"time"
 
@@ -10,6 +9,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/domain/queue"
 )
 
 type SchedulerService struct {
@@ -26,7 +26,7 @@ func NewSchedulerService(db *gorm.DB, redisClient *redis.Client) *SchedulerServi
 
 func (s *SchedulerService) Start() {
 	log.Println("⏰ Scheduler started, ticking every 60 seconds")
-	
+
 	ticker := time.NewTicker(60 * time.Second)
 	defer ticker.Stop()
 
@@ -53,8 +53,14 @@ func (s *SchedulerService) tick() {
 
 	log.Printf("📅 Scheduler: found %d pages to scrape", len(pages))
 
+	frequencies, err := s.planFrequencies(pages)
+	if err != nil {
+		log.Printf("❌ Scheduler: failed to load plans: %v", err)
+		return
+	}
+
 	for _, page := range pages {
-		if err := s.queuePage(page); err != nil {
+		if err := s.queuePage(page, frequencies[page.ID]); err != nil {
 			log.Printf("❌ Scheduler: failed to queue page %d: %v", page.ID, err)
 			continue
 		}
@@ -62,14 +68,41 @@ func (s *SchedulerService) tick() {
 	}
 }
 
-func (s *SchedulerService) queuePage(page models.MonitoredPage) error {
-	job := ScrapeJob{
-		PageID: page.ID,
-		URL:    page.URL,
-		Type:   page.PageType,
+// planFrequencies returns the frequency each page is scraped at: its own,
+// slowed down to what the plan of its organization allows. Pages keep their
+// frequency after a downgrade, so it applies again after an upgrade.
+func (s *SchedulerService) planFrequencies(pages []models.MonitoredPage) (map[uint]models.Frequency, error) {
+	ids := make([]uint, len(pages))
+	for i, page := range pages {
+		ids[i] = page.ID
+	}
+	var plans []struct {
+		ID   uint
+		Plan string
+	}
+	if err := s.db.Table("monitored_pages").
+		Select("monitored_pages.id, organizations.plan").
+		Joins("JOIN competitors ON competitors.id = monitored_pages.competitor_id").
+		Joins("JOIN projects ON projects.id = competitors.project_id").
+		Joins("JOIN organizations ON organizations.id = projects.organization_id").
+		Where("monitored_pages.id IN ?", ids).
+		Scan(&plans).Error; err != nil {
+		return nil, err
 	}
+	planOf := make(map[uint]string, len(plans))
+	for _, p := range plans {
+		planOf[p.ID] = p.Plan
+	}
+
+	frequencies := make(map[uint]models.Frequency, len(pages))
+	for _, page := range pages {
+		frequencies[page.ID] = models.PlanByName(planOf[page.ID]).ClampFrequency(page.Frequency)
+	}
+	return frequencies, nil
+}
 
-	jobJSON, err := json.Marshal(job)
+func (s *SchedulerService) queuePage(page models.MonitoredPage, frequency models.Frequency) error {
+	jobJSON, err := scrapeJobPayload(&page)
 	if err != nil {
 		return err
 	}
@@ -78,7 +111,7 @@ func (s *SchedulerService) queuePage(page models.MonitoredPage) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := s.redis.RPush(ctx, "scrape_job", jobJSON).Err(); err != nil {
+	if err := s.redis.RPush(ctx, queue.ScrapeJobQueue, jobJSON).Err(); err != nil {
 		return err
 	}
 
@@ -86,7 +119,7 @@ func (s *SchedulerService) queuePage(page models.MonitoredPage) error {
 	now := time.Now()
 	if err := s.db.Model(&page).Updates(map[string]interface{}{
 		"last_checked_at": now,
-		"next_run_at":     s.calculateNextRun(page.Frequency),
+		"next_run_at":     s.calculateNextRun(frequency),
 	}).Error; err != nil {
 		return err
 	}
@@ -112,7 +145,7 @@ func (s *SchedulerService) calculateNextRun(frequency models.Frequency) time.Tim
 // InitializeScheduledPages sets next_run_at for pages that don't have it
 func (s *SchedulerService) InitializeScheduledPages() error {
 	now := time.Now()
-	
+
 	return s.db.Model(&models.MonitoredPage{}).
 		Where("next_run_at IS NULL").
 		Updates(map[string]interface{}{
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rivalprice/api-go/models"
+)
+
+func TestPlanFrequencies(t *testing.T) {
+	db, mock := newMockDB(t)
+	s := NewSchedulerService(db, nil)
+	page := func(id uint, f models.Frequency) models.MonitoredPage {
+		var p models.MonitoredPage
+		p.ID, p.Frequency = id, f
+		return p
+	}
+	pages := []models.MonitoredPage{
+		page(1, models.FrequencyDaily),   // downgraded to free
+		page(2, models.FrequencyMonthly), // free, slower than required
+		page(3, models.FrequencyDaily),   // pro
+		page(4, models.FrequencyDaily),   // no organization found
+	}
+	mock.ExpectQuery(`SELECT monitored_pages.id, organizations.plan FROM "monitored_pages" JOIN competitors .* JOIN projects .* JOIN organizations .*WHERE monitored_pages.id IN \(\$1,\$2,\$3,\$4\)`).
+		WithArgs(1, 2, 3, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "plan"}).
+			AddRow(1, models.PlanFree).
+			AddRow(2, models.PlanFree).
+			AddRow(3, models.PlanPro))
+
+	got, err := s.planFrequencies(pages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint]models.Frequency{
+		1: models.FrequencyWeekly,
+		2: models.FrequencyMonthly,
+		3: models.FrequencyDaily,
+		4: models.FrequencyWeekly,
+	}
+	for id, f := range want {
+		if got[id] != f {
+			t.Errorf("page %d scraped %s, want %s", id, got[id], f)
+		}
+	}
+}
@@ -2,47 +2,57 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/domain/queue"
 	"gorm.io/gorm"
 )
 
 type ScrapingService struct {
-	db    *gorm.DB
-	redis *redis.Client
+	db       *gorm.DB
+	redis    *redis.Client
+	quotaSvc *QuotaService
 }
 
 func NewScrapingService(db *gorm.DB, redisClient *redis.Client) *ScrapingService {
 	return &ScrapingService{
-		db:    db,
-		redis: redisClient,
+		db:       db,
+		redis:    redisClient,
+		quotaSvc: NewQuotaService(db),
 	}
 }
 
-type ScrapeJob struct {
-	PageID uint   `json:"page_id"`
-	URL    string `json:"url"`
-	Type   string `json:"type"`
+// scrapeJobPayload is the queue message asking a scraper to fetch page
+func scrapeJobPayload(page *models.MonitoredPage) ([]byte, error) {
+	return queue.NewScrapeJob(page.ID, page.URL, page.PageType).Encode()
 }
 
-// QueueScrapeJob adds a scraping job to the Redis queue
-func (s *ScrapingService) QueueScrapeJob(pageID uint) error {
+// QueueScrapeJob adds a scraping job to the Redis queue, counted against the
+// manual scrapes of the organization's plan
+func (s *ScrapingService) QueueScrapeJob(actor Actor, pageID uint) error {
 	// Get the monitored page
 	var page models.MonitoredPage
 	if err := s.db.First(&page, pageID).Error; err != nil {
 		return err
 	}
 
-	job := ScrapeJob{
-		PageID: page.ID,
-		URL:    page.URL,
-		Type:   page.PageType,
+	if err := s.quotaSvc.ConsumeForPage(page.ID, models.UsageManualScrapes, 1); err != nil {
+		return err
+	}
+	if err := recordAudit(s.db, actor, auditRecord{
+		OrganizationID: auditOrg(NewOrganizationService(s.db).OrgOfPage, page.ID),
+		Action:         models.AuditScrapeTriggered,
+		TargetID:       page.ID,
+	}); err != nil {
+		return err
 	}
+	return s.queue(&page)
+}
 
-	jobData, err := json.Marshal(job)
+func (s *ScrapingService) queue(page *models.MonitoredPage) error {
+	jobData, err := scrapeJobPayload(page)
 	if err != nil {
 		return err
 	}
@@ -51,13 +61,15 @@ func (s *ScrapingService) QueueScrapeJob(pageID uint) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	return s.redis.RPush(ctx, "scrape_job", jobData).Err()
+	return s.redis.RPush(ctx, queue.ScrapeJobQueue, jobData).Err()
 }
 
-// QueueScrapeJobForProject queues scraping jobs for all pages in a project
-func (s *ScrapingService) QueueScrapeJobForProject(projectID uint) error {
+// QueueScrapeJobForProject queues scraping jobs for all pages in a project.
+// Each page counts as a manual scrape; if the plan has not enough left,
+// nothing is queued.
+func (s *ScrapingService) QueueScrapeJobForProject(actor Actor, projectID uint) error {
 	var pages []models.MonitoredPage
-	
+
 	// Get all competitors for the project
 	var competitors []models.Competitor
 	if err := s.db.Where("project_id = ?", projectID).Find(&competitors).Error; err != nil {
@@ -74,9 +86,27 @@ func (s *ScrapingService) QueueScrapeJobForProject(projectID uint) error {
 		return err
 	}
 
+	if len(pages) == 0 {
+		return nil
+	}
+	orgID, err := NewOrganizationService(s.db).OrgOfProject(projectID)
+	if err != nil {
+		return err
+	}
+	if err := s.quotaSvc.Consume(orgID, models.UsageManualScrapes, len(pages)); err != nil {
+		return err
+	}
+	if err := recordAudit(s.db, actor, auditRecord{
+		OrganizationID: orgID,
+		Action:         models.AuditProjectScrapeTriggered,
+		TargetID:       projectID,
+	}); err != nil {
+		return err
+	}
+
 	// Queue each page
-	for _, page := range pages {
-		if err := s.QueueScrapeJob(page.ID); err != nil {
+	for i := range pages {
+		if err := s.queue(&pages[i]); err != nil {
 			return err
 		}
 	}
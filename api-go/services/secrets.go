@@ -0,0 +1,34 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+
+	"github.com/rivalprice/api-go/utils"
+)
+
+// newSecretBox returns the box encrypting one kind of secret at rest. Its key
+// comes from envKey (64 hex characters), or is derived from jwtSecret and
+// label when envKey is not set.
+func newSecretBox(envKey, label, jwtSecret string) *utils.SecretBox {
+	var key []byte
+	if raw := os.Getenv(envKey); raw != "" {
+		decoded, err := hex.DecodeString(raw)
+		if err != nil || len(decoded) != 32 {
+			log.Fatalf("❌ %s must be 64 hex characters (32 bytes)", envKey)
+		}
+		key = decoded
+	} else {
+		log.Printf("⚠️  %s not set, deriving the %s encryption key from JWT_SECRET", envKey, label)
+		sum := sha256.Sum256([]byte("rivalprice-" + label + ":" + jwtSecret))
+		key = sum[:]
+	}
+
+	box, err := utils.NewSecretBox(key)
+	if err != nil {
+		log.Fatalf("❌ %s: %v", envKey, err)
+	}
+	return box
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	denylistKeyPrefix      = "auth:denylist:"       // + jti
+	revokedBeforeKeyPrefix = "auth:revoked_before:" // + user ID
+)
+
+// ErrRefreshTokenReused is returned when a consumed refresh token is
+// presented again: the session is revoked since the token may have leaked
+var ErrRefreshTokenReused = errors.New("refresh token reused, session revoked")
+
+// AccessTokenSigner signs an access token for a session and returns it with
+// its jti (see middleware.NewAccessTokenSigner)
+type AccessTokenSigner func(userID uint, email, sessionID string, mfa bool, ttl time.Duration) (token, jti string, err error)
+
+// SessionTokens is what a client receives at login and on every refresh
+type SessionTokens struct {
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresIn        int       `json:"expires_in"` // access token lifetime in seconds
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        string    `json:"session_id"`
+}
+
+// SessionService issues short-lived access tokens with rotating refresh
+// tokens stored server-side, and revokes them: refresh token rows in
+// Postgres, revoked access token IDs (jti) in a Redis denylist.
+type SessionService struct {
+	db         *gorm.DB
+	redis      *redis.Client
+	sign       AccessTokenSigner
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewSessionService(db *gorm.DB, redisClient *redis.Client, sign AccessTokenSigner) *SessionService {
+	return &SessionService{
+		db:         db,
+		redis:      redisClient,
+		sign:       sign,
+		accessTTL:  envMinutes("ACCESS_TOKEN_TTL_MINUTES", defaultAccessTokenTTL),
+		refreshTTL: envMinutes("REFRESH_TOKEN_TTL_MINUTES", defaultRefreshTokenTTL),
+	}
+}
+
+// StartSession opens a new session (refresh token family) for user. mfa
+// records that the login passed two-factor authentication; every token of
+// the session carries it.
+func (s *SessionService) StartSession(user *models.User, mfa bool, userAgent, ip string) (*SessionTokens, error) {
+	familyID, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(s.db, user, familyID, mfa, userAgent, ip)
+}
+
+// Refresh consumes a refresh token and returns the next pair of the same
+// session. A token that was already consumed revokes the session.
+func (s *SessionService) Refresh(refreshToken, userAgent, ip string) (*SessionTokens, error) {
+	var tokens *SessionTokens
+	var reused *models.RefreshToken
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashRefreshToken(refreshToken)).
+			First(&current).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("invalid refresh token")
+			}
+			return err
+		}
+
+		now := time.Now()
+		if current.RevokedAt != nil {
+			return errors.New("session revoked")
+		}
+		if current.UsedAt != nil {
+			reused = &current
+			return ErrRefreshTokenReused
+		}
+		if now.After(current.ExpiresAt) {
+			return errors.New("refresh token expired")
+		}
+
+		var user models.User
+		if err := tx.First(&user, current.UserID).Error; err != nil {
+			return errors.New("user not found")
+		}
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		tokens, err = s.issue(tx, &user, current.FamilyID, current.MFA, userAgent, ip)
+		return err
+	})
+
+	if reused != nil {
+		log.Printf("⚠️  Refresh token reuse detected for user %d, session %s revoked", reused.UserID, reused.FamilyID)
+		if err := s.RevokeSession(reused.UserID, reused.FamilyID); err != nil {
+			log.Printf("❌ SessionService: failed to revoke session %s: %v", reused.FamilyID, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *SessionService) issue(tx *gorm.DB, user *models.User, familyID string, mfa bool, userAgent, ip string) (*SessionTokens, error) {
+	accessToken, jti, err := s.sign(user.ID, user.Email, familyID, mfa, s.accessTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+	plaintext, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	row := models.RefreshToken{
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		TokenHash:       hashRefreshToken(plaintext),
+		AccessJTI:       jti,
+		AccessExpiresAt: now.Add(s.accessTTL),
+		ExpiresAt:       now.Add(s.refreshTTL),
+		UserAgent:       truncate(userAgent, 255),
+		IP:              ip,
+		MFA:             mfa,
+	}
+	if err := tx.Create(&row).Error; err != nil {
+		return nil, errors.New("failed to store refresh token")
+	}
+
+	return &SessionTokens{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(s.accessTTL.Seconds()),
+		RefreshToken:     plaintext,
+		RefreshExpiresAt: row.ExpiresAt,
+		SessionID:        familyID,
+	}, nil
+}
+
+// Logout ends the session an access token belongs to and denylists it
+func (s *SessionService) Logout(userID uint, sessionID, jti string, accessExpiresAt time.Time) error {
+	if err := s.deny(jti, accessExpiresAt); err != nil {
+		return err
+	}
+	if sessionID == "" {
+		return nil
+	}
+	return s.RevokeSession(userID, sessionID)
+}
+
+// RevokeSession revokes every refresh token of a session and denylists the
+// access tokens still alive in it
+func (s *SessionService) RevokeSession(userID uint, sessionID string) error {
+	return s.revoke("user_id = ? AND family_id = ?", userID, sessionID)
+}
+
+// LogoutAll revokes every session of userID, including access tokens that
+// are not tracked by a refresh token
+func (s *SessionService) LogoutAll(userID uint) error {
+	if err := s.revoke("user_id = ?", userID); err != nil {
+		return err
+	}
+	if s.redis == nil {
+		return errors.New("token denylist unavailable")
+	}
+	key := revokedBeforeKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+	return s.redis.Set(context.Background(), key, time.Now().Unix(), s.accessTTL+time.Minute).Err()
+}
+
+// revoke revokes the refresh tokens matching a condition and denylists their
+// access tokens
+func (s *SessionService) revoke(cond string, args ...interface{}) error {
+	now := time.Now()
+	var alive []models.RefreshToken
+	if err := s.db.Where(cond, args...).Where("access_expires_at > ?", now).Find(&alive).Error; err != nil {
+		return err
+	}
+	for _, t := range alive {
+		if err := s.deny(t.AccessJTI, t.AccessExpiresAt); err != nil {
+			return err
+		}
+	}
+	return s.db.Model(&models.RefreshToken{}).
+		Where(cond, args...).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", now).Error
+}
+
+// deny adds jti to the denylist until the token would have expired anyway
+func (s *SessionService) deny(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	if s.redis == nil {
+		return errors.New("token denylist unavailable")
+	}
+	return s.redis.Set(context.Background(), denylistKeyPrefix+jti, 1, ttl+time.Minute).Err()
+}
+
+// IsRevoked reports whether an access token was logged out, either by its
+// jti or by a "log out all sessions" after it was issued
+func (s *SessionService) IsRevoked(jti string, userID uint, issuedAt time.Time) (bool, error) {
+	if s.redis == nil {
+		return false, errors.New("token denylist unavailable")
+	}
+	ctx := context.Background()
+
+	n, err := s.redis.Exists(ctx, denylistKeyPrefix+jti).Result()
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return true, nil
+	}
+
+	before, err := s.redis.Get(ctx, revokedBeforeKeyPrefix+strconv.FormatUint(uint64(userID), 10)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return issuedAt.Unix() < before, nil
+}
+
+func hashRefreshToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
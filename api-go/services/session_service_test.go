@@ -0,0 +1,166 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// testSigner signs fake access tokens, numbered by call, and records whether
+// they were issued for a session that passed 2FA
+type testSigner struct {
+	calls int
+	mfa   []bool
+}
+
+func (s *testSigner) sign(userID uint, email, sessionID string, mfa bool, ttl time.Duration) (string, string, error) {
+	s.calls++
+	s.mfa = append(s.mfa, mfa)
+	return fmt.Sprintf("access-%d", s.calls), fmt.Sprintf("jti-%d", s.calls), nil
+}
+
+var refreshTokenColumns = []string{"id", "user_id", "family_id", "token_hash", "access_jti", "access_expires_at", "expires_at", "used_at", "revoked_at", "mfa"}
+
+// expectRefreshLock expects Refresh to lock the row of token, returned as
+// row (nil when unknown)
+func expectRefreshLock(mock sqlmock.Sqlmock, token string, row []interface{}) {
+	rows := sqlmock.NewRows(refreshTokenColumns)
+	if row != nil {
+		values := make([]driver.Value, len(row))
+		for i, v := range row {
+			values[i] = v
+		}
+		rows.AddRow(values...)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "refresh_tokens" WHERE token_hash = \$1 .*FOR UPDATE`).
+		WithArgs(hashRefreshToken(token), 1).WillReturnRows(rows)
+}
+
+func TestRefreshRotatesTheToken(t *testing.T) {
+	db, mock := newMockDB(t)
+	redisClient, _ := newFakeRedis(t)
+	signer := &testSigner{}
+	s := NewSessionService(db, redisClient, signer.sign)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	expectRefreshLock(mock, "old-token", []interface{}{1, 7, "family", hashRefreshToken("old-token"), "jti-0", now.Add(time.Minute), now.Add(time.Hour), nil, nil, true})
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "ann@example.com"))
+	// The presented token is consumed...
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET "used_at"=\$1 WHERE "id" = \$2`).WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// ...and replaced by a new one of the same family
+	mock.ExpectQuery(`INSERT INTO "refresh_tokens"`).
+		WithArgs(7, "family", sqlmock.AnyArg(), "jti-1", sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil, "curl/8", "203.0.113.9", true, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+
+	tokens, err := s.Refresh("old-token", "curl/8", "203.0.113.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.RefreshToken == "old-token" || tokens.RefreshToken == "" {
+		t.Errorf("RefreshToken = %q, want a new token", tokens.RefreshToken)
+	}
+	if tokens.SessionID != "family" || tokens.AccessToken != "access-1" {
+		t.Errorf("tokens = %+v, want access-1 in session family", tokens)
+	}
+	if len(signer.mfa) != 1 || !signer.mfa[0] {
+		t.Errorf("access token signed with mfa %v, want the session's 2FA kept", signer.mfa)
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		row     []interface{}
+		wantErr string
+	}{
+		{name: "unknown token", wantErr: "invalid refresh token"},
+		{
+			name:    "revoked session",
+			row:     []interface{}{1, 7, "family", "h", "jti-0", now, now.Add(time.Hour), nil, now.Add(-time.Minute), false},
+			wantErr: "session revoked",
+		},
+		{
+			name:    "expired token",
+			row:     []interface{}{1, 7, "family", "h", "jti-0", now.Add(-time.Hour), now.Add(-time.Minute), nil, nil, false},
+			wantErr: "refresh token expired",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			redisClient, _ := newFakeRedis(t)
+			signer := &testSigner{}
+			mock.ExpectBegin()
+			expectRefreshLock(mock, "token", tt.row)
+			mock.ExpectRollback()
+
+			_, err := NewSessionService(db, redisClient, signer.sign).Refresh("token", "", "")
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Refresh() = %v, want %q", err, tt.wantErr)
+			}
+			if signer.calls != 0 {
+				t.Error("access token issued for a rejected refresh")
+			}
+		})
+	}
+}
+
+func TestRefreshReuseRevokesTheFamily(t *testing.T) {
+	db, mock := newMockDB(t)
+	redisClient, fake := newFakeRedis(t)
+	signer := &testSigner{}
+	s := NewSessionService(db, redisClient, signer.sign)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	expectRefreshLock(mock, "stolen", []interface{}{1, 7, "family", hashRefreshToken("stolen"), "jti-a", now.Add(-time.Hour), now.Add(time.Hour), now.Add(-time.Hour), nil, false})
+	mock.ExpectRollback()
+
+	// Every token of the family is revoked and the access tokens still
+	// alive in it are denylisted, the thief's and the user's alike
+	mock.ExpectQuery(`SELECT \* FROM "refresh_tokens" WHERE \(user_id = \$1 AND family_id = \$2\) AND access_expires_at > \$3`).
+		WithArgs(7, "family", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).
+			AddRow(2, 7, "family", "h2", "jti-b", now.Add(5*time.Minute), now.Add(time.Hour), now.Add(-time.Minute), nil, false).
+			AddRow(3, 7, "family", "h3", "jti-c", now.Add(10*time.Minute), now.Add(time.Hour), nil, nil, false))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"=\$1 WHERE \(user_id = \$2 AND family_id = \$3\) AND revoked_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 7, "family").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	_, err := s.Refresh("stolen", "", "")
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Refresh() = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if signer.calls != 0 {
+		t.Error("access token issued for a reused refresh token")
+	}
+	for _, jti := range []string{"jti-b", "jti-c"} {
+		if !fake.exists(denylistKeyPrefix + jti) {
+			t.Errorf("access token %s of the family not denylisted", jti)
+		}
+		if revoked, err := s.IsRevoked(jti, 7, now); err != nil || !revoked {
+			t.Errorf("IsRevoked(%s) = %v, %v, want true", jti, revoked, err)
+		}
+	}
+	// The expired access token is not worth denylisting
+	if fake.exists(denylistKeyPrefix + "jti-a") {
+		t.Error("expired access token denylisted")
+	}
+
+	// The denylist entries expire with the access tokens they cover
+	fake.advance(10*time.Minute + 2*time.Minute)
+	if fake.exists(denylistKeyPrefix + "jti-c") {
+		t.Error("denylist entry outlived its access token")
+	}
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultKeyRotation = 30 * 24 * time.Hour
+
+	// keyPrepublish is how long a new key is in the JWKS before it signs, so
+	// verifiers caching the JWKS know it in time
+	keyPrepublish = 15 * time.Minute
+
+	// Keys are reloaded from the database at most every keyCacheTTL, or after
+	// keyReloadMinInterval when a token names an unknown kid
+	keyCacheTTL          = time.Minute
+	keyReloadMinInterval = 5 * time.Second
+
+	// signingKeyLockID is the Postgres advisory lock serializing rotations
+	// across API replicas
+	signingKeyLockID = 7324001
+)
+
+type loadedKey struct {
+	kid       string
+	alg       string
+	private   crypto.Signer
+	public    crypto.PublicKey
+	signFrom  time.Time
+	expiresAt *time.Time
+}
+
+// SigningKeyService owns the asymmetric keys signing access tokens: it signs
+// with the newest active key, resolves verification keys by kid, rotates
+// keys on a schedule and publishes the public keys as a JWKS.
+type SigningKeyService struct {
+	db          *gorm.DB
+	box         *utils.SecretBox
+	alg         string
+	rotateEvery time.Duration
+	retainFor   time.Duration // how long a replaced key still verifies
+
+	// HS256 tokens signed with JWT_SECRET before the switch to asymmetric
+	// keys (the cutover, stored in signing_key_cutover) are accepted until
+	// they expire
+	legacySecret []byte
+	legacyTTL    time.Duration
+
+	mu       sync.RWMutex
+	keys     []*loadedKey // newest SignFrom first
+	cutover  time.Time    // zero until the first key exists
+	loadedAt time.Time
+}
+
+func NewSigningKeyService(db *gorm.DB, jwtSecret string) *SigningKeyService {
+	alg := "RS256"
+	switch strings.ToUpper(os.Getenv("JWT_SIGNING_ALG")) {
+	case "", "RS256":
+	case "EDDSA":
+		alg = "EdDSA"
+	default:
+		log.Fatalf("❌ JWT_SIGNING_ALG must be RS256 or EdDSA")
+	}
+
+	accessTTL := envMinutes("ACCESS_TOKEN_TTL_MINUTES", defaultAccessTokenTTL)
+	s := &SigningKeyService{
+		db:          db,
+		box:         newSecretBox("JWT_KEYS_ENCRYPTION_KEY", "jwt-keys", jwtSecret),
+		alg:         alg,
+		rotateEvery: envMinutes("JWT_KEY_ROTATION_MINUTES", defaultKeyRotation),
+		retainFor:   accessTTL + 5*time.Minute,
+	}
+	if os.Getenv("JWT_ACCEPT_LEGACY_HS256") != "false" {
+		s.legacySecret = []byte(jwtSecret)
+		s.legacyTTL = accessTTL
+	}
+	return s
+}
+
+// Init makes sure a signing key exists and loads the keys (call at startup)
+func (s *SigningKeyService) Init() error {
+	_, err := s.RotateIfDue(time.Now())
+	return err
+}
+
+// RotateIfDue creates the next key when the signing key reaches its rotation
+// age, and deletes keys nothing can be verified with anymore
+func (s *SigningKeyService) RotateIfDue(now time.Time) (bool, error) {
+	rotated := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyLockID).Error; err != nil {
+			return err
+		}
+
+		var latest *models.SigningKey
+		var row models.SigningKey
+		err := tx.Where("expires_at IS NULL").Order("sign_from DESC").First(&row).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+		case err != nil:
+			return err
+		default:
+			latest = &row
+		}
+		signFrom, due := s.nextKeyStart(latest, now)
+		if !due {
+			return nil
+		}
+
+		key, err := s.generate(signFrom)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+		// The first key ends HS256 signing, for good: later keys, restarts
+		// and deploys keep the original cutover
+		if err := tx.Exec("INSERT INTO signing_key_cutover (id, cutover_at) VALUES (1, ?) ON CONFLICT (id) DO NOTHING", signFrom).Error; err != nil {
+			return err
+		}
+		// Previous keys stop signing at signFrom and verify their last tokens
+		if err := tx.Model(&models.SigningKey{}).
+			Where("expires_at IS NULL AND id <> ?", key.ID).
+			Update("expires_at", signFrom.Add(s.retainFor)).Error; err != nil {
+			return err
+		}
+		log.Printf("🔑 Signing key %s (%s) created, signs from %s", key.KID, key.Algorithm, signFrom.Format(time.RFC3339))
+		rotated = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.db.Where("expires_at < ?", now).Delete(&models.SigningKey{}).Error; err != nil {
+		log.Printf("⚠️  Failed to delete expired signing keys: %v", err)
+	}
+	if err := s.reload(); err != nil {
+		return rotated, err
+	}
+	return rotated, nil
+}
+
+// nextKeyStart returns when a new key should start signing, or false while
+// latest (nil when there is no key yet) needs no successor
+func (s *SigningKeyService) nextKeyStart(latest *models.SigningKey, now time.Time) (time.Time, bool) {
+	switch {
+	case latest == nil:
+		// First key: signs right away
+		return now, true
+	case !s.decryptable(latest):
+		log.Printf("⚠️  Signing key %s cannot be decrypted (encryption key changed?), replacing it", latest.KID)
+		return now, true
+	case now.Add(keyPrepublish).Before(latest.SignFrom.Add(s.rotateEvery)):
+		return time.Time{}, false
+	}
+	if next := latest.SignFrom.Add(s.rotateEvery); next.After(now) {
+		return next, true
+	}
+	return now, true
+}
+
+// Sign signs claims with the current key, naming it in the kid header
+func (s *SigningKeyService) Sign(claims jwt.Claims) (string, error) {
+	now := time.Now()
+	for _, k := range s.loaded() {
+		if k.signFrom.After(now) {
+			continue // published, not signing yet
+		}
+		token := jwt.NewWithClaims(jwt.GetSigningMethod(k.alg), claims)
+		token.Header["kid"] = k.kid
+		return token.SignedString(k.private)
+	}
+	return "", errors.New("no signing key available")
+}
+
+// Keyfunc returns the key verifying token (a jwt.Keyfunc)
+func (s *SigningKeyService) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return s.legacyKey(token)
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+	k := s.find(kid)
+	if k == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != k.alg {
+		return nil, errors.New("token algorithm does not match its key")
+	}
+	if k.expiresAt != nil && time.Now().After(*k.expiresAt) {
+		return nil, errors.New("signing key retired")
+	}
+	return k.public, nil
+}
+
+// legacyKey returns JWT_SECRET for HS256 tokens issued before the cutover,
+// during one access token lifetime after it
+func (s *SigningKeyService) legacyKey(token *jwt.Token) (interface{}, error) {
+	s.loaded()
+	s.mu.RLock()
+	cutover := s.cutover
+	s.mu.RUnlock()
+
+	rejected := errors.New("HS256 tokens are no longer accepted")
+	if len(s.legacySecret) == 0 || cutover.IsZero() || token.Method.Alg() != "HS256" {
+		return nil, rejected
+	}
+	if time.Now().After(cutover.Add(s.legacyTTL)) {
+		return nil, rejected
+	}
+	issuedAt, err := token.Claims.GetIssuedAt()
+	if err != nil || issuedAt == nil || issuedAt.After(cutover) {
+		return nil, rejected
+	}
+	return s.legacySecret, nil
+}
+
+// JWKS returns the public keys verifying access tokens, in JWK format
+func (s *SigningKeyService) JWKS() []map[string]string {
+	keys := []map[string]string{}
+	for _, k := range s.loaded() {
+		jwk := map[string]string{"kid": k.kid, "alg": k.alg, "use": "sig"}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return keys
+}
+
+func (s *SigningKeyService) generate(signFrom time.Time) (*models.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch s.alg {
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.box.Seal(base64.StdEncoding.EncodeToString(der))
+	if err != nil {
+		return nil, err
+	}
+	kid, err := utils.RandomToken(8)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SigningKey{
+		KID:           kid,
+		Algorithm:     s.alg,
+		PrivateKeyEnc: sealed,
+		SignFrom:      signFrom,
+	}, nil
+}
+
+func (s *SigningKeyService) decode(row *models.SigningKey) (*loadedKey, error) {
+	encoded, err := s.box.Open(row.PrivateKeyEnc)
+	if err != nil {
+		return nil, err
+	}
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported key type")
+	}
+	return &loadedKey{
+		kid:       row.KID,
+		alg:       row.Algorithm,
+		private:   private,
+		public:    private.Public(),
+		signFrom:  row.SignFrom,
+		expiresAt: row.ExpiresAt,
+	}, nil
+}
+
+func (s *SigningKeyService) decryptable(row *models.SigningKey) bool {
+	_, err := s.decode(row)
+	return err == nil
+}
+
+// reload reads the keys still valid for verification from the database
+func (s *SigningKeyService) reload() error {
+	var rows []models.SigningKey
+	if err := s.db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("sign_from DESC").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	var cutover time.Time
+	if err := s.db.Raw("SELECT cutover_at FROM signing_key_cutover WHERE id = 1").Scan(&cutover).Error; err != nil {
+		return err
+	}
+
+	keys := make([]*loadedKey, 0, len(rows))
+	for i := range rows {
+		k, err := s.decode(&rows[i])
+		if err != nil {
+			log.Printf("⚠️  Skipping signing key %s: %v", rows[i].KID, err)
+			continue
+		}
+		keys = append(keys, k)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.cutover = cutover
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// loaded returns the cached keys, refreshed every keyCacheTTL so keys
+// rotated by another replica are picked up
+func (s *SigningKeyService) loaded() []*loadedKey {
+	s.mu.RLock()
+	keys, loadedAt := s.keys, s.loadedAt
+	s.mu.RUnlock()
+	if time.Since(loadedAt) < keyCacheTTL {
+		return keys
+	}
+
+	if err := s.reload(); err != nil {
+		log.Printf("⚠️  Failed to reload signing keys: %v", err)
+		return keys
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys
+}
+
+// find returns key kid, reloading once if it is unknown (rotated elsewhere)
+func (s *SigningKeyService) find(kid string) *loadedKey {
+	for _, k := range s.loaded() {
+		if k.kid == kid {
+			return k
+		}
+	}
+
+	s.mu.RLock()
+	recent := time.Since(s.loadedAt) < keyReloadMinInterval
+	s.mu.RUnlock()
+	if recent {
+		return nil
+	}
+	if err := s.reload(); err != nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.kid == kid {
+			return k
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rivalprice/api-go/models"
+)
+
+// newTestSigningKeys returns a service holding keys created by generate,
+// loaded as if just read from the database
+func newTestSigningKeys(t *testing.T, alg string, rows ...*models.SigningKey) *SigningKeyService {
+	t.Helper()
+	s := NewSigningKeyService(nil, "test-secret")
+	s.alg = alg
+	for _, row := range rows {
+		k, err := s.decode(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.keys = append(s.keys, k)
+	}
+	s.loadedAt = time.Now()
+	return s
+}
+
+func generateKey(t *testing.T, s *SigningKeyService, signFrom time.Time, expiresAt *time.Time) *models.SigningKey {
+	t.Helper()
+	row, err := s.generate(signFrom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row.ExpiresAt = expiresAt
+	return row
+}
+
+func TestNextKeyStart(t *testing.T) {
+	s := NewSigningKeyService(nil, "test-secret")
+	s.rotateEvery = 30 * 24 * time.Hour
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	unreadable := generateKey(t, s, now.Add(-time.Hour), nil)
+	unreadable.PrivateKeyEnc = "not-encrypted-by-this-key"
+
+	tests := []struct {
+		name   string
+		latest *models.SigningKey
+		want   time.Time
+		due    bool
+	}{
+		{name: "first key signs right away", want: now, due: true},
+		{name: "young key", latest: generateKey(t, s, now.Add(-24*time.Hour), nil)},
+		{
+			name:   "published ahead of its rotation",
+			latest: generateKey(t, s, now.Add(-30*24*time.Hour+10*time.Minute), nil),
+			want:   now.Add(10 * time.Minute),
+			due:    true,
+		},
+		{name: "rotation overdue", latest: generateKey(t, s, now.Add(-40*24*time.Hour), nil), want: now, due: true},
+		{name: "undecryptable key replaced", latest: unreadable, want: now, due: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, due := s.nextKeyStart(tt.latest, now)
+			if due != tt.due || !got.Equal(tt.want) {
+				t.Errorf("nextKeyStart() = %s, %v, want %s, %v", got, due, tt.want, tt.due)
+			}
+		})
+	}
+}
+
+func TestSignAndKeyfunc(t *testing.T) {
+	now := time.Now()
+	base := NewSigningKeyService(nil, "test-secret")
+	retiredAt := now.Add(-time.Minute)
+	current := generateKey(t, base, now.Add(-time.Hour), nil)
+	next := generateKey(t, base, now.Add(10*time.Minute), nil)
+	retired := generateKey(t, base, now.Add(-48*time.Hour), &retiredAt)
+	s := newTestSigningKeys(t, "RS256", next, current, retired)
+
+	claims := jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute))}
+	signed, err := s.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Parse(signed, s.Keyfunc)
+	if err != nil {
+		t.Fatalf("Keyfunc() rejected a token it signed: %v", err)
+	}
+	if kid := token.Header["kid"]; kid != current.KID {
+		t.Errorf("signed with %v, want the current key %s (the next one is not signing yet)", kid, current.KID)
+	}
+
+	sign := func(kid string, key *models.SigningKey) string {
+		k, err := s.decode(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(k.private)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	rejected := map[string]string{
+		"unknown kid": sign("unknown", current),
+		"no kid":      sign("", current),
+		"retired key": sign(retired.KID, retired),
+		"wrong key":   sign(current.KID, next),
+	}
+	for name, signed := range rejected {
+		if _, err := jwt.Parse(signed, s.Keyfunc); err == nil {
+			t.Errorf("Keyfunc() accepted a token with %s", name)
+		}
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	now := time.Now()
+	rsaKey := generateKey(t, newTestSigningKeys(t, "RS256"), now, nil)
+	edKey := generateKey(t, newTestSigningKeys(t, "EdDSA"), now.Add(-time.Hour), nil)
+	s := newTestSigningKeys(t, "RS256", rsaKey, edKey)
+
+	jwks := s.JWKS()
+	if len(jwks) != 2 {
+		t.Fatalf("JWKS() has %d keys, want 2", len(jwks))
+	}
+	for _, jwk := range jwks {
+		if _, ok := jwk["d"]; ok {
+			t.Errorf("JWKS() publishes a private key: %v", jwk)
+		}
+		if jwk["use"] != "sig" {
+			t.Errorf("use = %q, want sig", jwk["use"])
+		}
+	}
+
+	rsaJWK, edJWK := jwks[0], jwks[1]
+	rsaPublic := s.keys[0].public.(*rsa.PublicKey)
+	if rsaJWK["kid"] != rsaKey.KID || rsaJWK["alg"] != "RS256" || rsaJWK["kty"] != "RSA" ||
+		rsaJWK["n"] != base64.RawURLEncoding.EncodeToString(rsaPublic.N.Bytes()) ||
+		rsaJWK["e"] != base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaPublic.E)).Bytes()) {
+		t.Errorf("RSA JWK = %v", rsaJWK)
+	}
+	edPublic := s.keys[1].public.(ed25519.PublicKey)
+	if edJWK["kid"] != edKey.KID || edJWK["alg"] != "EdDSA" || edJWK["kty"] != "OKP" || edJWK["crv"] != "Ed25519" ||
+		edJWK["x"] != base64.RawURLEncoding.EncodeToString(edPublic) {
+		t.Errorf("Ed25519 JWK = %v", edJWK)
+	}
+}
+
+func TestLegacyHS256Tokens(t *testing.T) {
+	now := time.Now()
+	legacy := func(issuedAt time.Time) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		}).SignedString([]byte("test-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name     string
+		cutover  time.Time
+		issuedAt time.Time
+		want     bool
+	}{
+		{name: "issued before a recent cutover", cutover: now.Add(-5 * time.Minute), issuedAt: now.Add(-10 * time.Minute), want: true},
+		{name: "issued after the cutover", cutover: now.Add(-5 * time.Minute), issuedAt: now.Add(-time.Minute), want: false},
+		// A restart does not reopen the window: it is anchored to the stored cutover
+		{name: "window elapsed", cutover: now.Add(-24 * time.Hour), issuedAt: now.Add(-25 * time.Hour), want: false},
+		{name: "forged after the window", cutover: now.Add(-24 * time.Hour), issuedAt: now.Add(-24*time.Hour - time.Second), want: false},
+		{name: "no signing key yet", issuedAt: now.Add(-time.Minute), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSigningKeys(t, "RS256")
+			s.legacyTTL = 15 * time.Minute
+			s.cutover = tt.cutover
+			_, err := jwt.Parse(legacy(tt.issuedAt), s.Keyfunc)
+			if got := err == nil; got != tt.want {
+				t.Errorf("HS256 token accepted = %v (%v), want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	// Disabled with JWT_ACCEPT_LEGACY_HS256=false
+	t.Setenv("JWT_ACCEPT_LEGACY_HS256", "false")
+	s := newTestSigningKeys(t, "RS256")
+	s.cutover = now.Add(-5 * time.Minute)
+	if _, err := jwt.Parse(legacy(now.Add(-10*time.Minute)), s.Keyfunc); err == nil {
+		t.Error("HS256 token accepted with JWT_ACCEPT_LEGACY_HS256=false")
+	}
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
+	"gorm.io/gorm"
+)
+
+// ErrSnapshotPageMismatch is returned when diffing snapshots of two different pages
+var ErrSnapshotPageMismatch = errors.New("snapshots belong to different monitored pages")
+
+// ErrSnapshotDiffTooLarge is returned when a snapshot has too many lines to diff
+var ErrSnapshotDiffTooLarge = fmt.Errorf("snapshot text exceeds %d lines, too large to diff", maxDiffLines)
+
+const (
+	// diffContextLines is the number of unchanged lines kept around each hunk
+	diffContextLines = 3
+	// maxDiffLines caps the normalized text of each side, which bounds the
+	// O((n+m)D) diff time
+	maxDiffLines = 10000
+)
+
+type SnapshotService struct {
+	db *gorm.DB
+}
+
+func NewSnapshotService(db *gorm.DB) *SnapshotService {
+	return &SnapshotService{db: db}
+}
+
+// SnapshotRef identifies one side of a diff
+type SnapshotRef struct {
+	ID              uint      `json:"id"`
+	MonitoredPageID uint      `json:"monitored_page_id"`
+	ScrapedAt       time.Time `json:"scraped_at"`
+	ScreenshotKey   string    `json:"screenshot_key,omitempty"`
+}
+
+// FieldDiff compares one structured field of two snapshots
+type FieldDiff struct {
+	Field   string `json:"field"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+	Changed bool   `json:"changed"`
+}
+
+// PlanDiff describes a pricing plan that was added, removed or repriced
+type PlanDiff struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"` // added, removed, changed
+	OldPrice string `json:"old_price,omitempty"`
+	NewPrice string `json:"new_price,omitempty"`
+}
+
+// TextDiff holds the normalized text diff in both presentations
+type TextDiff struct {
+	Unified    string                `json:"unified"`
+	SideBySide []utils.SideBySideRow `json:"side_by_side"`
+	Added      int                   `json:"added"`
+	Removed    int                   `json:"removed"`
+}
+
+// SnapshotDiff is the full comparison of two snapshots of the same page
+type SnapshotDiff struct {
+	Old    SnapshotRef `json:"old"`
+	New    SnapshotRef `json:"new"`
+	Fields []FieldDiff `json:"fields"`
+	Plans  []PlanDiff  `json:"plans"`
+	Text   TextDiff    `json:"text"`
+}
+
+// snapshotContent is the subset of snapshots.raw_data used for diffs
+type snapshotContent struct {
+	HTML          string                   `json:"html"`
+	TextContent   string                   `json:"text_content"`
+	PricingBlocks []map[string]interface{} `json:"pricing_blocks"`
+	Plans         []map[string]interface{} `json:"plans"`
+}
+
+func (s *SnapshotService) GetSnapshotByID(id uint) (*models.Snapshot, error) {
+	var snapshot models.Snapshot
+	if err := s.db.First(&snapshot, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("snapshot %d not found", id)
+		}
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// getVisibleSnapshot loads snapshot id if it belongs to a page viewer can
+// see. Snapshots of other organizations are reported as not found, so their
+// IDs cannot be probed.
+func (s *SnapshotService) getVisibleSnapshot(id uint, viewer Viewer) (*models.Snapshot, error) {
+	var snapshot models.Snapshot
+	err := s.db.Where("monitored_page_id IN (?)", visiblePageIDs(s.db, viewer)).First(&snapshot, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("snapshot %d not found", id)
+		}
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// DiffSnapshots compares snapshot oldID against snapshot newID, both of
+// which must be visible to viewer
+func (s *SnapshotService) DiffSnapshots(oldID, newID uint, viewer Viewer) (*SnapshotDiff, error) {
+	oldSnap, err := s.getVisibleSnapshot(oldID, viewer)
+	if err != nil {
+		return nil, err
+	}
+	newSnap, err := s.getVisibleSnapshot(newID, viewer)
+	if err != nil {
+		return nil, err
+	}
+	if oldSnap.MonitoredPageID != newSnap.MonitoredPageID {
+		return nil, ErrSnapshotPageMismatch
+	}
+
+	oldContent := parseSnapshotContent(oldSnap)
+	newContent := parseSnapshotContent(newSnap)
+
+	oldLines, newLines := snapshotText(oldContent), snapshotText(newContent)
+	if len(oldLines) > maxDiffLines || len(newLines) > maxDiffLines {
+		return nil, ErrSnapshotDiffTooLarge
+	}
+
+	ops := utils.DiffLines(oldLines, newLines)
+	text := TextDiff{
+		Unified: utils.UnifiedDiff(ops,
+			fmt.Sprintf("snapshot/%d", oldSnap.ID),
+			fmt.Sprintf("snapshot/%d", newSnap.ID),
+			diffContextLines),
+		SideBySide: utils.SideBySide(ops),
+	}
+	for _, op := range ops {
+		switch op.Kind {
+		case utils.DiffInsert:
+			text.Added++
+		case utils.DiffDelete:
+			text.Removed++
+		}
+	}
+
+	return &SnapshotDiff{
+		Old: snapshotRef(oldSnap),
+		New: snapshotRef(newSnap),
+		Fields: []FieldDiff{
+			fieldDiff("price", oldSnap.Price, newSnap.Price),
+			fieldDiff("availability", oldSnap.Availability, newSnap.Availability),
+		},
+		Plans: diffPlans(snapshotPlans(oldContent), snapshotPlans(newContent)),
+		Text:  text,
+	}, nil
+}
+
+// DiffPath returns the API path of the diff between two snapshots
+func DiffPath(oldID, newID uint) string {
+	return fmt.Sprintf("/api/v1/snapshots/%d/diff/%d", oldID, newID)
+}
+
+func snapshotRef(s *models.Snapshot) SnapshotRef {
+	return SnapshotRef{
+		ID:              s.ID,
+		MonitoredPageID: s.MonitoredPageID,
+		ScrapedAt:       s.ScrapedAt,
+		ScreenshotKey:   s.ScreenshotKey,
+	}
+}
+
+func fieldDiff(field, oldValue, newValue string) FieldDiff {
+	return FieldDiff{Field: field, Old: oldValue, New: newValue, Changed: oldValue != newValue}
+}
+
+func parseSnapshotContent(s *models.Snapshot) snapshotContent {
+	var content snapshotContent
+	if len(s.RawData) > 0 {
+		_ = json.Unmarshal(s.RawData, &content)
+	}
+	return content
+}
+
+// snapshotText prefers the canonical text computed by the scraper and falls
+// back to normalizing the stored HTML
+func snapshotText(c snapshotContent) []string {
+	if c.TextContent != "" {
+		var lines []string
+		for _, line := range strings.Split(c.TextContent, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		return lines
+	}
+	return utils.HTMLToText(c.HTML)
+}
+
+// snapshotPlans maps plan name → price from "plans" or "pricing_blocks"
+func snapshotPlans(c snapshotContent) map[string]string {
+	blocks := c.Plans
+	if len(blocks) == 0 {
+		blocks = c.PricingBlocks
+	}
+
+	plans := make(map[string]string)
+	for _, block := range blocks {
+		name := firstString(block, "name", "plan", "title")
+		if name == "" {
+			// Unnamed blocks are compared as a whole
+			raw, _ := json.Marshal(block)
+			name = string(raw)
+		}
+		plans[name] = firstString(block, "price", "amount")
+	}
+	return plans
+}
+
+func firstString(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch v := m[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return fmt.Sprintf("%g", v)
+		}
+	}
+	return ""
+}
+
+func diffPlans(oldPlans, newPlans map[string]string) []PlanDiff {
+	diffs := []PlanDiff{}
+	for name, oldPrice := range oldPlans {
+		newPrice, ok := newPlans[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, PlanDiff{Name: name, Status: "removed", OldPrice: oldPrice})
+		case newPrice != oldPrice:
+			diffs = append(diffs, PlanDiff{Name: name, Status: "changed", OldPrice: oldPrice, NewPrice: newPrice})
+		}
+	}
+	for name, newPrice := range newPlans {
+		if _, ok := oldPlans[name]; !ok {
+			diffs = append(diffs, PlanDiff{Name: name, Status: "added", NewPrice: newPrice})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// visibleSnapshotQuery loads a snapshot restricted to the pages of the
+// viewer's memberships
+const visibleSnapshotQuery = `SELECT \* FROM "snapshots" WHERE monitored_page_id IN \(SELECT monitored_pages.id FROM "monitored_pages" ` +
+	`WHERE monitored_pages.competitor_id IN \(SELECT competitors.id FROM "competitors" WHERE competitors.project_id IN \(` +
+	`SELECT projects.id FROM "projects" JOIN memberships .* WHERE memberships.user_id = \$1 .*\)\)\) ` +
+	`AND "snapshots"."id" = \$2`
+
+func expectVisibleSnapshot(mock sqlmock.Sqlmock, id, pageID uint) {
+	rows := sqlmock.NewRows([]string{"id", "monitored_page_id", "price"})
+	if pageID != 0 {
+		rows.AddRow(id, pageID, "$29")
+	}
+	mock.ExpectQuery(visibleSnapshotQuery).WithArgs(7, id, 1).WillReturnRows(rows)
+}
+
+func TestDiffSnapshotsVisibility(t *testing.T) {
+	viewer := Viewer{UserID: 7}
+
+	tests := []struct {
+		name     string
+		oldPage  uint // 0 when the viewer cannot see the snapshot
+		newPage  uint
+		wantErr  error
+		notFound uint
+	}{
+		{name: "both snapshots visible", oldPage: 3, newPage: 3},
+		{name: "other organization's snapshot", oldPage: 3, newPage: 0, notFound: 12},
+		{name: "other organization's snapshot first", oldPage: 0, notFound: 11},
+		{name: "two visible pages", oldPage: 3, newPage: 5, wantErr: ErrSnapshotPageMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			expectVisibleSnapshot(mock, 11, tt.oldPage)
+			if tt.oldPage != 0 {
+				expectVisibleSnapshot(mock, 12, tt.newPage)
+			}
+
+			diff, err := NewSnapshotService(db).DiffSnapshots(11, 12, viewer)
+			switch {
+			case tt.notFound != 0:
+				// Reported like a missing snapshot, not as a page mismatch
+				if err == nil || err.Error() != fmt.Sprintf("snapshot %d not found", tt.notFound) {
+					t.Errorf("DiffSnapshots() = %v, want snapshot %d not found", err, tt.notFound)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("DiffSnapshots() = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil || diff.Old.ID != 11 || diff.New.ID != 12:
+				t.Errorf("DiffSnapshots() = %+v, %v, want the diff of 11 and 12", diff, err)
+			}
+		})
+	}
+}
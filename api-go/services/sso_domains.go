@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// domainVerificationPrefix is the DNS name, under the domain, of the TXT
+	// record proving an organization owns it
+	domainVerificationPrefix = "_rivalprice-verification."
+
+	// domainVerificationValue prefixes the token in the TXT record
+	domainVerificationValue = "rivalprice-verification="
+)
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// DomainVerificationRecord returns the TXT record name and value that verify
+// domain for an organization
+func DomainVerificationRecord(domain *models.OrganizationDomain) (string, string) {
+	return domainVerificationPrefix + domain.Domain, domainVerificationValue + domain.VerificationToken
+}
+
+// AddDomain registers an email domain to verify for an organization
+func (s *SSOService) AddDomain(actor Actor, orgID uint, name string) (*models.OrganizationDomain, error) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if len(name) > 253 || !domainPattern.MatchString(name) {
+		return nil, errors.New("invalid domain")
+	}
+
+	var count int64
+	if err := s.db.Model(&models.OrganizationDomain{}).Where("organization_id = ? AND domain = ?", orgID, name).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("domain already added")
+	}
+
+	token, err := utils.RandomToken(24)
+	if err != nil {
+		return nil, err
+	}
+	domain := &models.OrganizationDomain{OrganizationID: orgID, Domain: name, VerificationToken: token}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(domain).Error; err != nil {
+			return errors.New("failed to add domain")
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: orgID,
+			Action:         models.AuditDomainAdded,
+			TargetID:       domain.ID,
+			After:          domain,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
+
+func (s *SSOService) ListDomains(orgID uint) ([]models.OrganizationDomain, error) {
+	var domains []models.OrganizationDomain
+	if err := s.db.Where("organization_id = ?", orgID).Order("domain").Find(&domains).Error; err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+func (s *SSOService) getDomain(orgID, id uint) (*models.OrganizationDomain, error) {
+	var domain models.OrganizationDomain
+	if err := s.db.Where("id = ? AND organization_id = ?", id, orgID).First(&domain).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("domain not found")
+		}
+		return nil, err
+	}
+	return &domain, nil
+}
+
+// VerifyDomain looks up the domain's TXT record and marks it verified when
+// it holds the organization's token. A domain already verified by another
+// organization is refused.
+func (s *SSOService) VerifyDomain(ctx context.Context, actor Actor, orgID, id uint) (*models.OrganizationDomain, error) {
+	domain, err := s.getDomain(orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if domain.VerifiedAt != nil {
+		return domain, nil
+	}
+
+	name, value := DomainVerificationRecord(domain)
+	records, err := s.lookupTXT(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("TXT record %s not found", name)
+	}
+	found := false
+	for _, r := range records {
+		if strings.TrimSpace(r) == value {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("TXT record %s does not contain %s", name, value)
+	}
+
+	var taken int64
+	if err := s.db.Model(&models.OrganizationDomain{}).
+		Where("domain = ? AND verified_at IS NOT NULL AND organization_id <> ?", domain.Domain, orgID).
+		Count(&taken).Error; err != nil {
+		return nil, err
+	}
+	if taken > 0 {
+		return nil, errors.New("domain already verified by another organization")
+	}
+
+	before := *domain
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(domain).Update("verified_at", now).Error; err != nil {
+			return errors.New("failed to verify domain")
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: orgID,
+			Action:         models.AuditDomainVerified,
+			TargetID:       domain.ID,
+			Before:         before,
+			After:          domain,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
+
+func (s *SSOService) DeleteDomain(actor Actor, orgID, id uint) error {
+	domain, err := s.getDomain(orgID, id)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(domain).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: orgID,
+			Action:         models.AuditDomainDeleted,
+			TargetID:       domain.ID,
+			Before:         domain,
+		})
+	})
+}
+
+// verifiedDomains returns the domains orgID has verified
+func verifiedDomains(db *gorm.DB, orgID uint) ([]string, error) {
+	var domains []string
+	err := db.Model(&models.OrganizationDomain{}).
+		Where("organization_id = ? AND verified_at IS NOT NULL", orgID).
+		Pluck("domain", &domains).Error
+	return domains, err
+}
+
+// linkByEmailAllowed reports whether an SSO login may be linked to the
+// existing account with the same email without its owner confirming: the
+// provider must restrict allowed domains, and the email's domain must be one
+// of them and verified as owned by the provider's organization
+func linkByEmailAllowed(email, allowedDomains string, verified []string) bool {
+	if len(splitList(allowedDomains)) == 0 || !emailDomainAllowed(email, allowedDomains) {
+		return false
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for _, d := range verified {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultLookupTXT(ctx context.Context, name string) ([]string, error) {
+	return net.DefaultResolver.LookupTXT(ctx, name)
+}
@@ -0,0 +1,497 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rivalprice/api-go/models"
+	"github.com/rivalprice/api-go/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// oidcStateTTL is how long a user has to complete the IdP login
+	oidcStateTTL = 10 * time.Minute
+
+	oidcStateKeyPrefix = "oidc:state:"
+
+	// oidcLinkTTL is how long the owner of an existing account has to confirm
+	// the link of an SSO login that could not be linked automatically
+	oidcLinkTTL = time.Hour
+
+	oidcLinkKeyPrefix = "oidc:link:"
+)
+
+var providerSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+// oidcLoginState is kept in Redis between the redirect to the IdP and the
+// callback; the PKCE verifier and nonce never leave the server
+type oidcLoginState struct {
+	ProviderID uint   `json:"provider_id"`
+	Verifier   string `json:"verifier"`
+	Nonce      string `json:"nonce"`
+}
+
+// oidcPendingLink is an SSO login waiting for the owner of the account with
+// the same email to confirm the link from their session
+type oidcPendingLink struct {
+	ProviderID uint   `json:"provider_id"`
+	Subject    string `json:"subject"`
+	Email      string `json:"email"`
+	UserID     uint   `json:"user_id"`
+}
+
+// LinkRequiredError is returned by CompleteLogin when the IdP account has
+// the email of an existing account that may not be linked automatically.
+// The account's owner confirms the link with Token from a session.
+type LinkRequiredError struct {
+	Token string
+}
+
+func (e *LinkRequiredError) Error() string {
+	return "an account already uses this email: log in to it and confirm the link"
+}
+
+// errLinkPending rolls back provisioning when the link needs a confirmation
+var errLinkPending = errors.New("link pending confirmation")
+
+// SSOService manages the OIDC providers of organizations and logs users in
+// through them, provisioning accounts just in time
+type SSOService struct {
+	db        *gorm.DB
+	redis     *redis.Client
+	oidc      *OIDCClient
+	baseURL   string // public API URL, for redirect URIs
+	lookupTXT func(ctx context.Context, name string) ([]string, error)
+}
+
+func NewSSOService(db *gorm.DB, redisClient *redis.Client) *SSOService {
+	return &SSOService{
+		db:        db,
+		redis:     redisClient,
+		oidc:      NewOIDCClient(),
+		baseURL:   strings.TrimRight(os.Getenv("PUBLIC_API_URL"), "/"),
+		lookupTXT: defaultLookupTXT,
+	}
+}
+
+// RedirectURI is the callback URL to register at the identity provider
+func (s *SSOService) RedirectURI(slug string) string {
+	return s.baseURL + "/api/v1/auth/oidc/" + slug + "/callback"
+}
+
+// CreateProvider adds an identity provider to an organization
+func (s *SSOService) CreateProvider(actor Actor, provider *models.OIDCProvider) error {
+	if err := s.validateProvider(provider); err != nil {
+		return err
+	}
+	var count int64
+	if err := s.db.Model(&models.OIDCProvider{}).Where("slug = ?", provider.Slug).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("slug already taken")
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(provider).Error; err != nil {
+			return errors.New("failed to create SSO provider")
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: provider.OrganizationID,
+			Action:         models.AuditSSOProviderCreated,
+			TargetID:       provider.ID,
+			After:          provider,
+		})
+	})
+}
+
+func (s *SSOService) ListProviders(orgID uint) ([]models.OIDCProvider, error) {
+	var providers []models.OIDCProvider
+	if err := s.db.Where("organization_id = ?", orgID).Order("name").Find(&providers).Error; err != nil {
+		return nil, err
+	}
+	return providers, nil
+}
+
+func (s *SSOService) GetProvider(orgID, id uint) (*models.OIDCProvider, error) {
+	var provider models.OIDCProvider
+	if err := s.db.Where("id = ? AND organization_id = ?", id, orgID).First(&provider).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("SSO provider not found")
+		}
+		return nil, err
+	}
+	return &provider, nil
+}
+
+// UpdateProvider replaces a provider's settings; an empty client secret
+// keeps the stored one
+func (s *SSOService) UpdateProvider(actor Actor, orgID, id uint, input *models.OIDCProvider) (*models.OIDCProvider, error) {
+	existing, err := s.GetProvider(orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	input.ID = existing.ID
+	input.OrganizationID = existing.OrganizationID
+	input.Slug = existing.Slug
+	input.CreatedAt = existing.CreatedAt
+	if input.ClientSecret == "" {
+		input.ClientSecret = existing.ClientSecret
+	}
+	if err := s.validateProvider(input); err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(input).Error; err != nil {
+			return errors.New("failed to update SSO provider")
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: orgID,
+			Action:         models.AuditSSOProviderUpdated,
+			TargetID:       input.ID,
+			Before:         existing,
+			After:          input,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return input, nil
+}
+
+func (s *SSOService) DeleteProvider(actor Actor, orgID, id uint) error {
+	existing, err := s.GetProvider(orgID, id)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(existing).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: orgID,
+			Action:         models.AuditSSOProviderDeleted,
+			TargetID:       existing.ID,
+			Before:         existing,
+		})
+	})
+}
+
+func (s *SSOService) validateProvider(p *models.OIDCProvider) error {
+	p.Slug = strings.ToLower(strings.TrimSpace(p.Slug))
+	if !providerSlugPattern.MatchString(p.Slug) {
+		return errors.New("slug must be 2-63 lowercase letters, digits or dashes")
+	}
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("name is required")
+	}
+	if p.ClientID == "" {
+		return errors.New("client_id is required")
+	}
+
+	p.Issuer = strings.TrimRight(strings.TrimSpace(p.Issuer), "/")
+	issuer, err := url.Parse(p.Issuer)
+	if err != nil || issuer.Host == "" {
+		return errors.New("invalid issuer URL")
+	}
+	// Plain HTTP is only accepted outside production, for local mock providers
+	if issuer.Scheme != "https" && (issuer.Scheme != "http" || os.Getenv("ENV") == "production") {
+		return errors.New("issuer must use https")
+	}
+
+	if p.DefaultRole == "" {
+		p.DefaultRole = models.RoleViewer
+	}
+	if !p.DefaultRole.Valid() || p.DefaultRole == models.RoleOwner {
+		return fmt.Errorf("invalid default_role %q", p.DefaultRole)
+	}
+	return nil
+}
+
+// BeginLogin returns the IdP authorization URL for provider slug
+func (s *SSOService) BeginLogin(ctx context.Context, slug string) (string, error) {
+	provider, err := s.enabledProvider(slug)
+	if err != nil {
+		return "", err
+	}
+	if s.baseURL == "" {
+		return "", errors.New("PUBLIC_API_URL must be set to use SSO")
+	}
+	doc, err := s.oidc.Discover(ctx, provider.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := utils.RandomToken(24)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := utils.RandomToken(24)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	payload, _ := json.Marshal(oidcLoginState{ProviderID: provider.ID, Verifier: verifier, Nonce: nonce})
+	if err := s.redis.Set(ctx, oidcStateKeyPrefix+state, payload, oidcStateTTL).Err(); err != nil {
+		return "", err
+	}
+
+	return s.oidc.AuthCodeURL(doc, provider.ClientID, s.RedirectURI(provider.Slug), provider.Scopes, state, nonce, verifier), nil
+}
+
+// CompleteLogin handles the IdP callback: it consumes the state, exchanges
+// the code, validates the ID token and returns the (possibly new) user
+func (s *SSOService) CompleteLogin(ctx context.Context, slug, code, state string) (*models.User, error) {
+	if code == "" || state == "" {
+		return nil, errors.New("missing code or state")
+	}
+	raw, err := s.redis.GetDel(ctx, oidcStateKeyPrefix+state).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errors.New("login expired or already completed, please retry")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved oidcLoginState
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		return nil, err
+	}
+
+	provider, err := s.enabledProvider(slug)
+	if err != nil {
+		return nil, err
+	}
+	if provider.ID != saved.ProviderID {
+		return nil, errors.New("state does not belong to this provider")
+	}
+
+	doc, err := s.oidc.Discover(ctx, provider.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := s.oidc.Exchange(ctx, doc, provider.ClientID, provider.ClientSecret, s.RedirectURI(provider.Slug), code, saved.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.oidc.VerifyIDToken(ctx, doc, provider.ClientID, saved.Nonce, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.provision(ctx, provider, claims)
+}
+
+// provision finds the user behind an ID token: by provider subject first,
+// then by verified email, else creates one. An existing account is linked by
+// email only on a domain the organization restricts to and has verified;
+// otherwise its owner must confirm the link (LinkRequiredError). The user
+// joins the provider's organization if not a member yet.
+func (s *SSOService) provision(ctx context.Context, provider *models.OIDCProvider, claims *oidcIDClaims) (*models.User, error) {
+	var user models.User
+	var pending *oidcPendingLink
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var identity models.UserIdentity
+		err := tx.Where("provider_id = ? AND subject = ?", provider.ID, claims.Subject).First(&identity).Error
+		switch {
+		case err == nil:
+			if err := tx.First(&user, identity.UserID).Error; err != nil {
+				return errors.New("user not found")
+			}
+			tx.Model(&identity).Updates(map[string]interface{}{"last_login_at": now, "email": strings.ToLower(claims.Email)})
+
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			email := claims.verifiedEmail()
+			if email == "" {
+				return errors.New("identity provider did not return a verified email")
+			}
+			if !emailDomainAllowed(email, provider.AllowedDomains) {
+				return errors.New("email domain not allowed for this organization")
+			}
+
+			err := tx.Where("LOWER(email) = ?", email).First(&user).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Just-in-time provisioning; password login stays disabled
+				user = models.User{Email: email, HashedPassword: unusablePasswordPrefix + "sso", EmailVerifiedAt: &now}
+				if err := tx.Create(&user).Error; err != nil {
+					return errors.New("failed to create user")
+				}
+				log.Printf("✅ SSO: user %d provisioned from provider %s", user.ID, provider.Slug)
+			} else if err != nil {
+				return err
+			} else {
+				verified, err := verifiedDomains(tx, provider.OrganizationID)
+				if err != nil {
+					return err
+				}
+				if !linkByEmailAllowed(email, provider.AllowedDomains, verified) {
+					pending = &oidcPendingLink{ProviderID: provider.ID, Subject: claims.Subject, Email: email, UserID: user.ID}
+					return errLinkPending
+				}
+				if user.EmailVerifiedAt == nil {
+					tx.Model(&user).Update("email_verified_at", now)
+				}
+				log.Printf("ℹ️  SSO: user %d linked to provider %s by verified domain", user.ID, provider.Slug)
+			}
+
+			identity = models.UserIdentity{
+				UserID:      user.ID,
+				ProviderID:  provider.ID,
+				Subject:     claims.Subject,
+				Email:       email,
+				LastLoginAt: &now,
+			}
+			if err := tx.Create(&identity).Error; err != nil {
+				return err
+			}
+
+		default:
+			return err
+		}
+
+		return joinProviderOrg(tx, provider, user.ID)
+	})
+	if errors.Is(err, errLinkPending) {
+		return nil, s.requestLink(ctx, pending)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// requestLink saves a pending link and returns the LinkRequiredError that
+// carries its token
+func (s *SSOService) requestLink(ctx context.Context, pending *oidcPendingLink) error {
+	token, err := utils.RandomToken(24)
+	if err != nil {
+		return err
+	}
+	payload, _ := json.Marshal(pending)
+	if err := s.redis.Set(ctx, oidcLinkKeyPrefix+token, payload, oidcLinkTTL).Err(); err != nil {
+		return err
+	}
+	log.Printf("ℹ️  SSO: login to user %d through provider %d waits for the user to confirm the link", pending.UserID, pending.ProviderID)
+	return &LinkRequiredError{Token: token}
+}
+
+// ConfirmLink links the pending SSO login of token to the actor's account,
+// which must be the account the link was requested for
+func (s *SSOService) ConfirmLink(ctx context.Context, actor Actor, token string) (*models.UserIdentity, error) {
+	key := oidcLinkKeyPrefix + token
+	raw, err := s.redis.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errors.New("link request not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pending oidcPendingLink
+	if err := json.Unmarshal(raw, &pending); err != nil {
+		return nil, err
+	}
+	if pending.UserID != actor.UserID {
+		return nil, errors.New("link request not found")
+	}
+	if err := s.redis.Del(ctx, key).Err(); err != nil {
+		return nil, err
+	}
+
+	var provider models.OIDCProvider
+	if err := s.db.Where("id = ? AND enabled = ?", pending.ProviderID, true).First(&provider).Error; err != nil {
+		return nil, errors.New("SSO provider not found")
+	}
+
+	now := time.Now()
+	identity := models.UserIdentity{
+		UserID:      actor.UserID,
+		ProviderID:  provider.ID,
+		Subject:     pending.Subject,
+		Email:       pending.Email,
+		LastLoginAt: &now,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&identity).Error; err != nil {
+			return errors.New("identity already linked to an account")
+		}
+		if err := joinProviderOrg(tx, &provider, actor.UserID); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditRecord{
+			OrganizationID: provider.OrganizationID,
+			Action:         models.AuditSSOIdentityLinked,
+			TargetID:       identity.ID,
+			After:          identity,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("ℹ️  SSO: user %d confirmed the link to provider %s", actor.UserID, provider.Slug)
+	return &identity, nil
+}
+
+// joinProviderOrg makes userID a member of the provider's organization with
+// its default role, unless already a member
+func joinProviderOrg(tx *gorm.DB, provider *models.OIDCProvider, userID uint) error {
+	var members int64
+	if err := tx.Model(&models.Membership{}).
+		Where("organization_id = ? AND user_id = ?", provider.OrganizationID, userID).
+		Count(&members).Error; err != nil {
+		return err
+	}
+	if members > 0 {
+		return nil
+	}
+	return tx.Create(&models.Membership{
+		OrganizationID: provider.OrganizationID,
+		UserID:         userID,
+		Role:           provider.DefaultRole,
+	}).Error
+}
+
+func (s *SSOService) enabledProvider(slug string) (*models.OIDCProvider, error) {
+	var provider models.OIDCProvider
+	if err := s.db.Where("slug = ? AND enabled = ?", slug, true).First(&provider).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("SSO provider not found")
+		}
+		return nil, err
+	}
+	return &provider, nil
+}
+
+// emailDomainAllowed checks email against a comma-separated domain list
+// (empty list = any domain)
+func emailDomainAllowed(email, allowed string) bool {
+	domains := splitList(allowed)
+	if len(domains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, d := range domains {
+		if strings.EqualFold(strings.TrimPrefix(d, "@"), domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIdP is an OpenID Connect provider issuing RS256 ID tokens for one
+// account. Whoever runs an organization's IdP chooses the emails it asserts.
+type mockIdP struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	clientID  string
+	email     string
+	verified  bool
+	nonce     string // from the authorization request
+	challenge string // PKCE code_challenge of the authorization request
+}
+
+func newMockIdP(t *testing.T, clientID string) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key, clientID: clientID}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "test-key",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "valid-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.idToken(t, idp.key)})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) idToken(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            idp.clientID,
+		"sub":            "idp-user-1",
+		"email":          idp.email,
+		"email_verified": idp.verified,
+		"nonce":          idp.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	})
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// login runs the authorization code flow against the mock IdP and returns
+// the validated ID token claims
+func (idp *mockIdP) login(t *testing.T, client *OIDCClient) (*oidcIDClaims, error) {
+	t.Helper()
+	ctx := context.Background()
+	doc, err := client.Discover(ctx, idp.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := url.Parse(client.AuthCodeURL(doc, idp.clientID, "https://api.example.com/callback", "", "state-1", "nonce-1", "verifier-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.nonce = authURL.Query().Get("nonce")
+	idp.challenge = authURL.Query().Get("code_challenge")
+
+	idToken, err := client.Exchange(ctx, doc, idp.clientID, "secret", "https://api.example.com/callback", "valid-code", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.VerifyIDToken(ctx, doc, idp.clientID, "nonce-1", idToken)
+}
+
+func TestSSOLinksExistingAccountsOnlyOnVerifiedDomains(t *testing.T) {
+	idp := newMockIdP(t, "rivalprice")
+	client := NewOIDCClient()
+
+	tests := []struct {
+		name           string
+		email          string
+		allowedDomains string
+		verified       []string
+		want           bool
+	}{
+		// An org admin's IdP asserting someone else's address must not take
+		// over their account
+		{name: "any domain allowed", email: "victim@gmail.com", want: false},
+		{name: "any domain allowed, even a verified one", email: "ceo@acme.com", verified: []string{"acme.com"}, want: false},
+		{name: "allowed but not verified", email: "ceo@acme.com", allowedDomains: "acme.com", want: false},
+		{name: "verified by the organization but not allowed", email: "ceo@acme.com", allowedDomains: "acme.io", verified: []string{"acme.com"}, want: false},
+		{name: "other domain than the verified one", email: "victim@gmail.com", allowedDomains: "acme.com,gmail.com", verified: []string{"acme.com"}, want: false},
+		{name: "allowed and verified", email: "jane@acme.com", allowedDomains: "acme.io, @acme.com", verified: []string{"acme.com"}, want: true},
+		{name: "domain case ignored", email: "Jane@ACME.com", allowedDomains: "acme.com", verified: []string{"Acme.com"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp.email, idp.verified = tt.email, true
+			claims, err := idp.login(t, client)
+			if err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+			email := claims.verifiedEmail()
+			if got := linkByEmailAllowed(email, tt.allowedDomains, tt.verified); got != tt.want {
+				t.Errorf("linkByEmailAllowed(%q, %q, %v) = %v, want %v", email, tt.allowedDomains, tt.verified, got, tt.want)
+			}
+		})
+	}
+
+	// Without email_verified the IdP does not vouch for the address at all
+	idp.email, idp.verified = "jane@acme.com", false
+	claims, err := idp.login(t, client)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if email := claims.verifiedEmail(); email != "" {
+		t.Errorf("verifiedEmail() = %q for an unverified email", email)
+	}
+}
+
+func TestVerifyIDTokenRejectsForgedTokens(t *testing.T) {
+	idp := newMockIdP(t, "rivalprice")
+	client := NewOIDCClient()
+	ctx := context.Background()
+	doc, err := client.Discover(ctx, idp.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.email, idp.verified, idp.nonce = "jane@acme.com", true, "nonce-1"
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.VerifyIDToken(ctx, doc, "rivalprice", "nonce-1", idp.idToken(t, other)); err == nil {
+		t.Error("VerifyIDToken() accepted a token signed by another key")
+	}
+	if _, err := client.VerifyIDToken(ctx, doc, "rivalprice", "nonce-2", idp.idToken(t, idp.key)); err == nil {
+		t.Error("VerifyIDToken() accepted a token for another nonce")
+	}
+	if _, err := client.VerifyIDToken(ctx, doc, "other-client", "nonce-1", idp.idToken(t, idp.key)); err == nil {
+		t.Error("VerifyIDToken() accepted a token for another client")
+	}
+}
@@ -1,12 +1,20 @@
 package services
 
 import (
+	"crypto/subtle"
 	"errors"
+	"log"
+	"strings"
 
 	"github.com/rivalprice/api-go/models"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// unusablePasswordPrefix marks accounts without password login (e.g. created
+// through SSO): no password can match such a value
+const unusablePasswordPrefix = "!"
+
 type UserService struct {
 	db *gorm.DB
 }
@@ -22,9 +30,13 @@ func (s *UserService) CreateUser(email, password string) (*models.User, error) {
 		return nil, errors.New("user with this email already exists")
 	}
 
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
 	user := models.User{
 		Email:          email,
-		HashedPassword: password, // In production: bcrypt hash
+		HashedPassword: hashed,
 	}
 
 	if err := s.db.Create(&user).Error; err != nil {
@@ -45,14 +57,6 @@ func (s *UserService) GetUserByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
-func (s *UserService) GetAllUsers() ([]models.User, error) {
-	var users []models.User
-	if err := s.db.Find(&users).Error; err != nil {
-		return nil, err
-	}
-	return users, nil
-}
-
 func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
 	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
@@ -64,7 +68,38 @@ func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+// SetPassword replaces the password of userID
+func (s *UserService) SetPassword(userID uint, password string) error {
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&models.User{}).Where("id = ?", userID).Update("hashed_password", hashed).Error
+}
+
 func (s *UserService) ValidatePassword(user *models.User, password string) bool {
-	// In production: bcrypt.CompareHashAndPassword
-	return user.HashedPassword == password
+	stored := user.HashedPassword
+	if stored == "" || strings.HasPrefix(stored, unusablePasswordPrefix) {
+		return false
+	}
+	if strings.HasPrefix(stored, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+
+	// Accounts created before passwords were hashed: compare, then upgrade
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(password)) != 1 {
+		return false
+	}
+	if err := s.SetPassword(user.ID, password); err != nil {
+		log.Printf("⚠️  Failed to rehash password of user %d: %v", user.ID, err)
+	}
+	return true
+}
+
+func hashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.New("failed to hash password")
+	}
+	return string(hashed), nil
 }
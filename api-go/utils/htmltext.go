@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// boilerplateElements never carry competitor content
+var boilerplateElements = []string{"script", "style", "noscript", "svg", "template", "iframe", "nav", "footer"}
+
+var (
+	boilerplateBlocks = compileElementStrippers(boilerplateElements)
+	htmlComments      = regexp.MustCompile(`(?s)<!--.*?-->`)
+	blockBoundaries   = regexp.MustCompile(`(?i)<(br|/?p|/?div|/?li|/?ul|/?ol|/?tr|/?table|/?section|/?article|/?h[1-6])\b[^>]*>`)
+	anyTag            = regexp.MustCompile(`<[^>]+>`)
+	spaceRuns         = regexp.MustCompile(`[ \t\f\r\v\x{00a0}]+`)
+)
+
+// HTMLToText reduces an HTML page to readable text: boilerplate elements are
+// dropped, block elements become line breaks and whitespace is collapsed.
+// Each returned line is non-empty.
+func HTMLToText(page string) []string {
+	page = htmlComments.ReplaceAllString(page, "")
+	for _, re := range boilerplateBlocks {
+		page = re.ReplaceAllString(page, "")
+	}
+	page = blockBoundaries.ReplaceAllString(page, "\n")
+	page = anyTag.ReplaceAllString(page, " ")
+	page = html.UnescapeString(page)
+
+	var lines []string
+	for _, line := range strings.Split(page, "\n") {
+		line = strings.TrimSpace(spaceRuns.ReplaceAllString(line, " "))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// compileElementStrippers builds one regex per element since RE2 has no
+// back-references to match the closing tag of the opening one
+func compileElementStrippers(elements []string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, 0, len(elements))
+	for _, el := range elements {
+		res = append(res, regexp.MustCompile(`(?is)<`+el+`\b[^>]*>.*?</`+el+`\s*>`))
+	}
+	return res
+}
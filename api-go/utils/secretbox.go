@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+const secretBoxVersion = "v1:"
+
+// SecretBox encrypts small secrets for storage with AES-256-GCM. Sealed
+// values are versioned ("v1:<base64 nonce+ciphertext>") to allow a future
+// key or algorithm change.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox returns a box using a 32-byte key
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != 32 {
+		return nil, errors.New("secret box key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal encrypts plaintext
+func (b *SecretBox) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretBoxVersion + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value returned by Seal
+func (b *SecretBox) Open(sealed string) (string, error) {
+	if !strings.HasPrefix(sealed, secretBoxVersion) {
+		return "", errors.New("unknown secret format")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, secretBoxVersion))
+	if err != nil {
+		return "", err
+	}
+	size := b.aead.NonceSize()
+	if len(raw) < size {
+		return "", errors.New("sealed secret too short")
+	}
+	plaintext, err := b.aead.Open(nil, raw[:size], raw[size:], nil)
+	if err != nil {
+		return "", errors.New("cannot decrypt secret")
+	}
+	return string(plaintext), nil
+}
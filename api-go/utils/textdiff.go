@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffKind identifies a line operation in a diff
+type DiffKind string
+
+const (
+	DiffEqual  DiffKind = "equal"
+	DiffDelete DiffKind = "delete"
+	DiffInsert DiffKind = "insert"
+)
+
+// DiffLine is one line of a line-based diff. Line numbers are 1-based and
+// zero when the line does not exist on that side.
+type DiffLine struct {
+	Kind    DiffKind `json:"kind"`
+	Text    string   `json:"text"`
+	OldLine int      `json:"old_line,omitempty"`
+	NewLine int      `json:"new_line,omitempty"`
+}
+
+// SideBySideRow pairs a line of the old text with a line of the new text
+type SideBySideRow struct {
+	Kind    string `json:"kind"` // equal, delete, insert, replace
+	OldLine int    `json:"old_line,omitempty"`
+	OldText string `json:"old_text"`
+	NewLine int    `json:"new_line,omitempty"`
+	NewText string `json:"new_text"`
+}
+
+// DiffLines computes the shortest edit script between two line slices using
+// the linear-space variant of Myers' O(ND) algorithm: memory stays O(n+m)
+// whatever the distance between the two texts.
+func DiffLines(a, b []string) []DiffLine {
+	if len(a)+len(b) == 0 {
+		return nil
+	}
+
+	d := &differ{a: a, b: b}
+	path := d.findPath(0, 0, len(a), len(b))
+
+	// Walk the path: every segment is a diagonal run plus at most one edit
+	var ops []DiffLine
+	for i := 1; i < len(path); i++ {
+		x, y := path[i-1].x, path[i-1].y
+		x2, y2 := path[i].x, path[i].y
+
+		x, y = d.walkDiagonal(x, y, x2, y2, &ops)
+		switch {
+		case x2-x < y2-y:
+			ops = append(ops, DiffLine{Kind: DiffInsert, Text: b[y], NewLine: y + 1})
+			y++
+		case x2-x > y2-y:
+			ops = append(ops, DiffLine{Kind: DiffDelete, Text: a[x], OldLine: x + 1})
+			x++
+		}
+		d.walkDiagonal(x, y, x2, y2, &ops)
+	}
+	return ops
+}
+
+// diffPoint is a position in the edit graph: x lines of a and y lines of b consumed
+type diffPoint struct{ x, y int }
+
+type differ struct {
+	a, b []string
+}
+
+func (d *differ) walkDiagonal(x, y, x2, y2 int, ops *[]DiffLine) (int, int) {
+	for x < x2 && y < y2 && d.a[x] == d.b[y] {
+		*ops = append(*ops, DiffLine{Kind: DiffEqual, Text: d.a[x], OldLine: x + 1, NewLine: y + 1})
+		x++
+		y++
+	}
+	return x, y
+}
+
+// findPath returns the points of a shortest path from (left, top) to
+// (right, bottom), splitting the box on its middle snake and recursing on
+// both halves. It returns nil for an empty box.
+func (d *differ) findPath(left, top, right, bottom int) []diffPoint {
+	start, finish, ok := d.midpoint(left, top, right, bottom)
+	if !ok {
+		return nil
+	}
+
+	head := d.findPath(left, top, start.x, start.y)
+	if head == nil {
+		head = []diffPoint{start}
+	}
+	tail := d.findPath(finish.x, finish.y, right, bottom)
+	if tail == nil {
+		tail = []diffPoint{finish}
+	}
+	return append(head, tail...)
+}
+
+// midpoint searches forwards from the top-left corner and backwards from the
+// bottom-right corner at the same time, and returns the snake where the two
+// searches meet. vf holds the furthest x reached on each forward diagonal k,
+// vb the furthest y reached on each backward diagonal c.
+func (d *differ) midpoint(left, top, right, bottom int) (diffPoint, diffPoint, bool) {
+	width, height := right-left, bottom-top
+	size := width + height
+	if size == 0 {
+		return diffPoint{}, diffPoint{}, false
+	}
+	delta := width - height
+	maxD := (size + 1) / 2
+
+	offset := maxD + 1
+	vf := make([]int, 2*maxD+3)
+	vb := make([]int, 2*maxD+3)
+	vf[offset+1] = left
+	vb[offset+1] = bottom
+
+	for depth := 0; depth <= maxD; depth++ {
+		// Forward search
+		for k := depth; k >= -depth; k -= 2 {
+			c := k - delta
+			var x, px int
+			if k == -depth || (k != depth && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+				px = x
+			} else {
+				px = vf[offset+k-1]
+				x = px + 1
+			}
+			y := top + (x - left) - k
+			py := y
+			if depth != 0 && x == px {
+				py = y - 1
+			}
+			for x < right && y < bottom && d.a[x] == d.b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			if delta%2 != 0 && c >= -(depth-1) && c <= depth-1 && y >= vb[offset+c] {
+				return diffPoint{px, py}, diffPoint{x, y}, true
+			}
+		}
+
+		// Backward search
+		for c := depth; c >= -depth; c -= 2 {
+			k := c + delta
+			var y, py int
+			if c == -depth || (c != depth && vb[offset+c-1] > vb[offset+c+1]) {
+				y = vb[offset+c+1]
+				py = y
+			} else {
+				py = vb[offset+c-1]
+				y = py - 1
+			}
+			x := left + (y - top) + k
+			px := x
+			if depth != 0 && y == py {
+				px = x + 1
+			}
+			for x > left && y > top && d.a[x-1] == d.b[y-1] {
+				x--
+				y--
+			}
+			vb[offset+c] = y
+
+			if delta%2 == 0 && k >= -depth && k <= depth && x <= vf[offset+k] {
+				return diffPoint{x, y}, diffPoint{px, py}, true
+			}
+		}
+	}
+	return diffPoint{}, diffPoint{}, false
+}
+
+// UnifiedDiff renders a diff in unified format with the given context lines
+func UnifiedDiff(ops []DiffLine, oldName, newName string, context int) string {
+	if len(ops) == 0 {
+		return ""
+	}
+
+	// Find the ranges of ops to print: every change plus its context
+	var hunks [][2]int
+	for i, op := range ops {
+		if op.Kind == DiffEqual {
+			continue
+		}
+		start, end := max(0, i-context), min(len(ops), i+context+1)
+		if len(hunks) > 0 && start <= hunks[len(hunks)-1][1] {
+			hunks[len(hunks)-1][1] = end
+		} else {
+			hunks = append(hunks, [2]int{start, end})
+		}
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName))
+
+	for _, h := range hunks {
+		oldStart, newStart, oldCount, newCount := 0, 0, 0, 0
+		for _, op := range ops[h[0]:h[1]] {
+			if op.OldLine != 0 {
+				if oldStart == 0 {
+					oldStart = op.OldLine
+				}
+				oldCount++
+			}
+			if op.NewLine != 0 {
+				if newStart == 0 {
+					newStart = op.NewLine
+				}
+				newCount++
+			}
+		}
+		out.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount))
+
+		for _, op := range ops[h[0]:h[1]] {
+			switch op.Kind {
+			case DiffEqual:
+				out.WriteString(" ")
+			case DiffDelete:
+				out.WriteString("-")
+			case DiffInsert:
+				out.WriteString("+")
+			}
+			out.WriteString(op.Text)
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
+
+// SideBySide pairs deletions with the insertions that follow them so that
+// modified lines appear on the same row
+func SideBySide(ops []DiffLine) []SideBySideRow {
+	var rows []SideBySideRow
+	var deletes, inserts []DiffLine
+
+	flush := func() {
+		for i := 0; i < max(len(deletes), len(inserts)); i++ {
+			row := SideBySideRow{}
+			switch {
+			case i < len(deletes) && i < len(inserts):
+				row.Kind = "replace"
+			case i < len(deletes):
+				row.Kind = string(DiffDelete)
+			default:
+				row.Kind = string(DiffInsert)
+			}
+			if i < len(deletes) {
+				row.OldLine, row.OldText = deletes[i].OldLine, deletes[i].Text
+			}
+			if i < len(inserts) {
+				row.NewLine, row.NewText = inserts[i].NewLine, inserts[i].Text
+			}
+			rows = append(rows, row)
+		}
+		deletes, inserts = nil, nil
+	}
+
+	for _, op := range ops {
+		switch op.Kind {
+		case DiffDelete:
+			deletes = append(deletes, op)
+		case DiffInsert:
+			inserts = append(inserts, op)
+		default:
+			flush()
+			rows = append(rows, SideBySideRow{
+				Kind:    string(DiffEqual),
+				OldLine: op.OldLine,
+				OldText: op.Text,
+				NewLine: op.NewLine,
+				NewText: op.Text,
+			})
+		}
+	}
+	flush()
+	return rows
+}
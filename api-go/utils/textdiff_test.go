@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lcsLength is the reference the edit script length is checked against:
+// a shortest script has len(a)+len(b)-2*LCS edits
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func checkDiff(t *testing.T, a, b []string) {
+	t.Helper()
+	ops := DiffLines(a, b)
+
+	var gotA, gotB []string
+	edits := 0
+	for _, op := range ops {
+		switch op.Kind {
+		case DiffEqual:
+			if a[op.OldLine-1] != op.Text || b[op.NewLine-1] != op.Text {
+				t.Fatalf("equal op %+v does not match its lines", op)
+			}
+			gotA = append(gotA, op.Text)
+			gotB = append(gotB, op.Text)
+		case DiffDelete:
+			if a[op.OldLine-1] != op.Text {
+				t.Fatalf("delete op %+v does not match its line", op)
+			}
+			gotA = append(gotA, op.Text)
+			edits++
+		case DiffInsert:
+			if b[op.NewLine-1] != op.Text {
+				t.Fatalf("insert op %+v does not match its line", op)
+			}
+			gotB = append(gotB, op.Text)
+			edits++
+		}
+	}
+	if strings.Join(gotA, "\n") != strings.Join(a, "\n") || strings.Join(gotB, "\n") != strings.Join(b, "\n") {
+		t.Fatalf("script does not rebuild the inputs\na=%q\nb=%q\nops=%+v", a, b, ops)
+	}
+	if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+		t.Fatalf("script has %d edits, shortest has %d\na=%q\nb=%q", edits, want, a, b)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want []DiffLine
+	}{
+		{name: "both empty"},
+		{
+			name: "all inserted",
+			b:    []string{"x", "y"},
+			want: []DiffLine{
+				{Kind: DiffInsert, Text: "x", NewLine: 1},
+				{Kind: DiffInsert, Text: "y", NewLine: 2},
+			},
+		},
+		{
+			name: "all deleted",
+			a:    []string{"x"},
+			want: []DiffLine{{Kind: DiffDelete, Text: "x", OldLine: 1}},
+		},
+		{
+			name: "one line changed",
+			a:    []string{"Pro", "$29", "Support"},
+			b:    []string{"Pro", "$35", "Support"},
+			want: []DiffLine{
+				{Kind: DiffEqual, Text: "Pro", OldLine: 1, NewLine: 1},
+				{Kind: DiffDelete, Text: "$29", OldLine: 2},
+				{Kind: DiffInsert, Text: "$35", NewLine: 2},
+				{Kind: DiffEqual, Text: "Support", OldLine: 3, NewLine: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffLines() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesShortestScript(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	lines := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return out
+	}
+	for i := 0; i < 2000; i++ {
+		checkDiff(t, lines(rng.Intn(20)), lines(rng.Intn(20)))
+	}
+}
+
+func TestDiffLinesLargeInput(t *testing.T) {
+	// Completely different texts are the worst case for the edit distance
+	a := make([]string, 5000)
+	b := make([]string, 5000)
+	for i := range a {
+		a[i] = "old " + string(rune('a'+i%26))
+		b[i] = "new " + string(rune('a'+i%26))
+	}
+	if ops := DiffLines(a, b); len(ops) != len(a)+len(b) {
+		t.Fatalf("got %d ops, want %d", len(ops), len(a)+len(b))
+	}
+}
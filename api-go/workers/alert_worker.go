@@ -1,44 +1,76 @@
 package workers
 
 import (
+	"context"
 	"log"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/rivalprice/api-go/services"
+	"github.com/rivalprice/domain/queue"
 	"gorm.io/gorm"
 )
 
-// AlertWorker polls detected_changes every 30s and processes new ones
+// alertConsumerGroup is the Redis consumer group shared by all API instances
+const alertConsumerGroup = "alerting"
+
+// sweepBatchSize is the number of changes claimed at once by a sweep
+const sweepBatchSize = 50
+
+// AlertWorker consumes change.detected and snapshot events from Redis Streams
+// and turns them into alerts. A periodic sweep of the database reprocesses
+// anything that was missed (events lost, or published while Redis was down).
 type AlertWorker struct {
-	alertSvc *services.AlertService
-	interval time.Duration
-	stopCh   chan struct{}
+	alertSvc      *services.AlertService
+	alertLogSvc   *services.AlertLogService
+	redis         *redis.Client
+	consumer      string
+	sweepInterval time.Duration
+	blockTimeout  time.Duration
+	stopCh        chan struct{}
 }
 
-func NewAlertWorker(db *gorm.DB) *AlertWorker {
+func NewAlertWorker(db *gorm.DB, redisClient *redis.Client) *AlertWorker {
+	consumer, err := os.Hostname()
+	if err != nil || consumer == "" {
+		consumer = "api-go"
+	}
 	return &AlertWorker{
-		alertSvc: services.NewAlertService(db),
-		interval: 30 * time.Second,
-		stopCh:   make(chan struct{}),
+		alertSvc:      services.NewAlertService(db),
+		alertLogSvc:   services.NewAlertLogService(db),
+		redis:         redisClient,
+		consumer:      consumer,
+		sweepInterval: 5 * time.Minute,
+		blockTimeout:  5 * time.Second,
+		stopCh:        make(chan struct{}),
 	}
 }
 
 // Start runs the alert worker loop in the background
 func (w *AlertWorker) Start() {
-	log.Println("🔔 AlertWorker started (interval: 30s)")
-	ticker := time.NewTicker(w.interval)
-	defer ticker.Stop()
+	log.Printf("🔔 AlertWorker started (consumer %s, sweep every %s)", w.consumer, w.sweepInterval)
+	w.ensureGroups()
 
-	// Run once immediately on start
-	w.run()
+	// Catch-up: events delivered to us before a crash, then a full sweep
+	w.consume("0")
+	w.sweep()
 
+	lastSweep := time.Now()
 	for {
 		select {
-		case <-ticker.C:
-			w.run()
 		case <-w.stopCh:
 			log.Println("🔔 AlertWorker stopped")
 			return
+		default:
+		}
+
+		w.consume(">")
+
+		if time.Since(lastSweep) >= w.sweepInterval {
+			w.sweep()
+			lastSweep = time.Now()
 		}
 	}
 }
@@ -48,24 +80,125 @@ func (w *AlertWorker) Stop() {
 	close(w.stopCh)
 }
 
-// run processes all unprocessed detected_changes
-func (w *AlertWorker) run() {
-	changes, err := w.alertSvc.GetUnprocessedChanges()
+// ensureGroups creates the consumer groups (and streams) if they do not exist.
+// New groups start at "0" so events published before the first start count.
+func (w *AlertWorker) ensureGroups() {
+	ctx := context.Background()
+	for _, stream := range []string{queue.ChangeEventsStream, queue.SnapshotEventsStream} {
+		err := w.redis.XGroupCreateMkStream(ctx, stream, alertConsumerGroup, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			log.Printf("❌ AlertWorker: failed to create group on %s: %v", stream, err)
+		}
+	}
+}
+
+// consume reads one batch from both streams. start is ">" for new events or
+// "0" to re-read events delivered to this consumer but never acknowledged.
+func (w *AlertWorker) consume(start string) {
+	block := w.blockTimeout
+	if start != ">" {
+		block = -1 // pending entries are returned immediately, never block
+	}
+
+	streams, err := w.redis.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+		Group:    alertConsumerGroup,
+		Consumer: w.consumer,
+		Streams:  []string{queue.ChangeEventsStream, queue.SnapshotEventsStream, start, start},
+		Count:    50,
+		Block:    block,
+	}).Result()
 	if err != nil {
-		log.Printf("❌ AlertWorker: failed to fetch changes: %v", err)
+		if err != redis.Nil {
+			log.Printf("❌ AlertWorker: failed to read events: %v", err)
+			time.Sleep(w.blockTimeout)
+		}
 		return
 	}
 
-	if len(changes) == 0 {
-		return
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			event, err := services.ParseStreamEvent(msg.ID, msg.Values)
+			if err != nil {
+				log.Printf("⚠️  AlertWorker: dropping malformed event: %v", err)
+				w.ack(stream.Stream, msg.ID)
+				continue
+			}
+			if err := w.handle(event); err != nil {
+				// Left pending: retried by the next catch-up
+				log.Printf("❌ AlertWorker: error handling %s %s: %v", event.Type, event.StreamID, err)
+				continue
+			}
+			w.ack(stream.Stream, msg.ID)
+		}
+	}
+}
+
+func (w *AlertWorker) handle(event services.StreamEvent) error {
+	switch event.Type {
+	case queue.EventChangeDetected:
+		claimed, err := w.alertSvc.ClaimChange(event.ChangeID, w.consumer)
+		if err != nil || !claimed {
+			return err
+		}
+		change, err := w.alertSvc.GetChangeByID(event.ChangeID)
+		if err != nil {
+			return err
+		}
+		return w.alertSvc.ProcessChange(change)
+	case queue.EventSnapshotCreated, queue.EventScrapeFailed:
+		return w.alertSvc.CheckPageHealthFor(event.PageID)
+	default:
+		return nil
 	}
+}
 
-	log.Printf("🔔 AlertWorker: processing %d new change(s)", len(changes))
+func (w *AlertWorker) ack(stream, id string) {
+	if err := w.redis.XAck(context.Background(), stream, alertConsumerGroup, id).Err(); err != nil {
+		log.Printf("⚠️  AlertWorker: failed to ack %s on %s: %v", id, stream, err)
+	}
+}
+
+// sweep processes every pending detected change and every page scraped
+// since its last health check, whether or not an event was received, and
+// wakes alerts whose snooze has expired
+func (w *AlertWorker) sweep() {
+	// Claim batches until none is left: processed changes leave the
+	// claimable set, and failed ones wait for their backoff
+	total := 0
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		default:
+		}
 
-	for i := range changes {
-		change := &changes[i]
-		if err := w.alertSvc.ProcessChange(change); err != nil {
-			log.Printf("❌ AlertWorker: error processing change %d: %v", change.ID, err)
+		changes, err := w.alertSvc.ClaimChanges(w.consumer, sweepBatchSize)
+		if err != nil {
+			log.Printf("❌ AlertWorker: failed to fetch changes: %v", err)
+			break
+		}
+		if len(changes) == 0 {
+			break
 		}
+		total += len(changes)
+		for i := range changes {
+			change := &changes[i]
+			if err := w.alertSvc.ProcessChange(change); err != nil {
+				log.Printf("❌ AlertWorker: error processing change %d: %v", change.ID, err)
+			}
+		}
+	}
+	if total > 0 {
+		log.Printf("🔔 AlertWorker: catch-up on %d change(s)", total)
+	}
+
+	if err := w.alertSvc.CheckPageHealth(); err != nil {
+		log.Printf("❌ AlertWorker: page health check failed: %v", err)
+	}
+
+	if woken, err := w.alertLogSvc.WakeSnoozedAlerts(); err != nil {
+		log.Printf("❌ AlertWorker: failed to wake snoozed alerts: %v", err)
+	} else if woken > 0 {
+		log.Printf("🔔 AlertWorker: %d snoozed alert(s) back to new", woken)
 	}
 }
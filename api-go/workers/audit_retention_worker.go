@@ -0,0 +1,52 @@
+package workers
+
+import (
+	"log"
+	"time"
+
+	"github.com/rivalprice/api-go/services"
+)
+
+// AuditRetentionWorker deletes the audit events older than the retention
+// period
+type AuditRetentionWorker struct {
+	audit    *services.AuditService
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+func NewAuditRetentionWorker(audit *services.AuditService) *AuditRetentionWorker {
+	return &AuditRetentionWorker{
+		audit:    audit,
+		interval: time.Hour,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start runs the purge loop in the background
+func (w *AuditRetentionWorker) Start() {
+	log.Printf("🗄️  AuditRetentionWorker started (purging every %s)", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			log.Println("🗄️  AuditRetentionWorker stopped")
+			return
+		case now := <-ticker.C:
+			deleted, err := w.audit.PurgeExpired(now)
+			if err != nil {
+				log.Printf("❌ AuditRetentionWorker: purge failed: %v", err)
+			} else if deleted > 0 {
+				log.Printf("🗄️  AuditRetentionWorker: purged %d expired audit events", deleted)
+			}
+		}
+	}
+}
+
+// Stop gracefully stops the worker
+func (w *AuditRetentionWorker) Stop() {
+	close(w.stopCh)
+}
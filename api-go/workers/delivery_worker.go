@@ -0,0 +1,53 @@
+package workers
+
+import (
+	"log"
+	"time"
+
+	"github.com/rivalprice/api-go/services"
+	"gorm.io/gorm"
+)
+
+// DeliveryWorker flushes notifications held back by quiet hours once the
+// recipient's window has ended
+type DeliveryWorker struct {
+	deliverySvc *services.DeliveryService
+	interval    time.Duration
+	stopCh      chan struct{}
+}
+
+func NewDeliveryWorker(db *gorm.DB) *DeliveryWorker {
+	return &DeliveryWorker{
+		deliverySvc: services.NewDeliveryService(db),
+		interval:    time.Minute,
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// Start runs the delivery loop in the background
+func (w *DeliveryWorker) Start() {
+	log.Printf("🌙 DeliveryWorker started (checking every %s)", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			log.Println("🌙 DeliveryWorker stopped")
+			return
+		case now := <-ticker.C:
+			sent, err := w.deliverySvc.FlushDue(now)
+			if err != nil {
+				log.Printf("❌ DeliveryWorker: failed to flush queued notifications: %v", err)
+			} else if sent > 0 {
+				log.Printf("🌙 DeliveryWorker: %d queued notification(s) sent", sent)
+			}
+		}
+	}
+}
+
+// Stop gracefully stops the worker
+func (w *DeliveryWorker) Stop() {
+	close(w.stopCh)
+}
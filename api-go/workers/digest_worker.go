@@ -0,0 +1,50 @@
+package workers
+
+import (
+	"log"
+	"time"
+
+	"github.com/rivalprice/api-go/services"
+	"gorm.io/gorm"
+)
+
+// DigestWorker sends daily and weekly alert digests. It checks every minute
+// which users have passed their local digest hour.
+type DigestWorker struct {
+	digestSvc *services.DigestService
+	interval  time.Duration
+	stopCh    chan struct{}
+}
+
+func NewDigestWorker(db *gorm.DB) *DigestWorker {
+	return &DigestWorker{
+		digestSvc: services.NewDigestService(db),
+		interval:  time.Minute,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start runs the digest loop in the background
+func (w *DigestWorker) Start() {
+	log.Printf("📧 DigestWorker started (checking every %s)", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			log.Println("📧 DigestWorker stopped")
+			return
+		case now := <-ticker.C:
+			if err := w.digestSvc.SendDueDigests(now); err != nil {
+				log.Printf("❌ DigestWorker: failed to send digests: %v", err)
+			}
+		}
+	}
+}
+
+// Stop gracefully stops the worker
+func (w *DigestWorker) Stop() {
+	close(w.stopCh)
+}
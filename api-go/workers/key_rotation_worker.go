@@ -0,0 +1,49 @@
+package workers
+
+import (
+	"log"
+	"time"
+
+	"github.com/rivalprice/api-go/services"
+)
+
+// KeyRotationWorker rotates the access token signing keys on schedule and
+// drops the keys no token can be verified with anymore
+type KeyRotationWorker struct {
+	signingKeys *services.SigningKeyService
+	interval    time.Duration
+	stopCh      chan struct{}
+}
+
+func NewKeyRotationWorker(signingKeys *services.SigningKeyService) *KeyRotationWorker {
+	return &KeyRotationWorker{
+		signingKeys: signingKeys,
+		interval:    5 * time.Minute,
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// Start runs the rotation loop in the background
+func (w *KeyRotationWorker) Start() {
+	log.Printf("🔑 KeyRotationWorker started (checking every %s)", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			log.Println("🔑 KeyRotationWorker stopped")
+			return
+		case now := <-ticker.C:
+			if _, err := w.signingKeys.RotateIfDue(now); err != nil {
+				log.Printf("❌ KeyRotationWorker: rotation failed: %v", err)
+			}
+		}
+	}
+}
+
+// Stop gracefully stops the worker
+func (w *KeyRotationWorker) Stop() {
+	close(w.stopCh)
+}
@@ -0,0 +1,5 @@
+// Package domain is the contract between the RivalPrice services: the
+// canonical database models (models) and the messages exchanged through
+// Redis (queue). api-go and scraper-go both build against this module,
+// through a replace directive, so a field cannot drift on one side only.
+package domain
@@ -0,0 +1,3 @@
+module github.com/rivalprice/domain
+
+go 1.22
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+type PageType string
+
+const (
+	PageTypePricing  PageType = "pricing"
+	PageTypeFeatures PageType = "features"
+)
+
+type Frequency string
+
+const (
+	FrequencyDaily   Frequency = "daily"
+	FrequencyWeekly  Frequency = "weekly"
+	FrequencyMonthly Frequency = "monthly"
+)
+
+// MonitoredPage is a competitor page scraped on schedule. The API manages
+// it; scraper-go reads its extraction settings and writes its fetch health.
+type MonitoredPage struct {
+	ID                uint   `gorm:"primaryKey" json:"id"`
+	CompetitorID      uint   `gorm:"not null;index" json:"competitor_id"`
+	PageType          string `gorm:"type:varchar(50);not null" json:"page_type"` // pricing / features
+	URL               string `gorm:"type:varchar(512);not null" json:"url"`
+	CSSSelector       string `gorm:"type:text" json:"css_selector"`           // optional for specific targeting
+	CaptureScreenshot bool   `gorm:"default:false" json:"capture_screenshot"` // opt-in visual diff per scrape
+	IgnoreSelectors   string `gorm:"type:text" json:"ignore_selectors"`       // newline-separated, removed before hashing
+	IgnorePatterns    string `gorm:"type:text" json:"ignore_patterns"`        // newline-separated regexes, removed from text
+	// Alert noise control, nil uses ALERT_COOLDOWN_MINUTES / ALERT_MERGE_WINDOW_MINUTES
+	AlertCooldownMinutes    *int       `json:"alert_cooldown_minutes"`
+	AlertMergeWindowMinutes *int       `json:"alert_merge_window_minutes"`
+	Frequency               Frequency  `gorm:"type:varchar(20);not null;default:daily" json:"frequency"`
+	NextRunAt               time.Time  `gorm:"not null;index" json:"next_run_at"`
+	LastCheckedAt           *time.Time `json:"last_checked_at"`
+	// Fetch health, written by scraper-go after every job
+	ConsecutiveFailures int        `gorm:"default:0" json:"consecutive_failures"`
+	LastStatusCode      int        `gorm:"default:0" json:"last_status_code"`
+	LastError           string     `gorm:"type:text" json:"last_error"`
+	LastScrapedAt       *time.Time `json:"last_scraped_at"`
+	HealthCheckedAt     *time.Time `json:"health_checked_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+func (MonitoredPage) TableName() string {
+	return "monitored_pages"
+}
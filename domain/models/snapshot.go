@@ -0,0 +1,24 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Snapshot is the result of one scrape of a page, written by scraper-go
+type Snapshot struct {
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	MonitoredPageID uint            `gorm:"not null;index" json:"monitored_page_id"`
+	Price           string          `gorm:"type:varchar(100)" json:"price"`
+	Availability    string          `gorm:"type:varchar(50)" json:"availability"`
+	RawData         json.RawMessage `gorm:"type:jsonb" json:"raw_data"`
+	ScrapedAt       time.Time       `json:"scraped_at"`
+	// Visual capture (blob storage keys), set when the page captures screenshots
+	ScreenshotKey     string   `gorm:"type:varchar(255);default:''" json:"screenshot_key,omitempty"`
+	VisualDiffKey     string   `gorm:"type:varchar(255);default:''" json:"visual_diff_key,omitempty"`
+	VisualChangeRatio *float64 `json:"visual_change_ratio,omitempty"`
+}
+
+func (Snapshot) TableName() string {
+	return "snapshots"
+}
@@ -0,0 +1,200 @@
+// Package contracttest holds the wire-format fixtures of the queue
+// messages and the checks every service runs against them in its own
+// tests, so a producer and a consumer cannot disagree on a message.
+package contracttest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rivalprice/domain/queue"
+)
+
+// scrapeJobFixtures are scrape job payloads as they travel on the queue
+var scrapeJobFixtures = []struct {
+	name    string
+	payload string
+	want    queue.ScrapeJob
+	err     error
+}{
+	{
+		name:    "current version",
+		payload: `{"schema_version":1,"page_id":42,"url":"https://example.com/pricing","type":"pricing"}`,
+		want:    queue.ScrapeJob{SchemaVersion: 1, PageID: 42, URL: "https://example.com/pricing", Type: "pricing"},
+	},
+	{
+		name:    "queued before schema versions",
+		payload: `{"page_id":42,"url":"https://example.com/pricing","type":"pricing"}`,
+		want:    queue.ScrapeJob{SchemaVersion: 1, PageID: 42, URL: "https://example.com/pricing", Type: "pricing"},
+	},
+	{
+		name:    "newer version",
+		payload: `{"schema_version":99,"page_id":42,"url":"https://example.com/pricing","type":"pricing"}`,
+		err:     queue.ErrUnsupportedVersion,
+	},
+	{
+		name:    "missing page",
+		payload: `{"schema_version":1,"url":"https://example.com/pricing","type":"pricing"}`,
+		err:     queue.ErrInvalidMessage,
+	},
+	{
+		name:    "relative url",
+		payload: `{"schema_version":1,"page_id":42,"url":"/pricing","type":"pricing"}`,
+		err:     queue.ErrInvalidMessage,
+	},
+	{
+		name:    "not json",
+		payload: `page 42`,
+		err:     queue.ErrInvalidMessage,
+	},
+}
+
+var occurredAt = time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+
+// eventFixtures are stream entries as Redis returns them (string fields)
+var eventFixtures = []struct {
+	name   string
+	values map[string]interface{}
+	want   queue.Event
+	err    error
+}{
+	{
+		name: "snapshot created",
+		values: map[string]interface{}{
+			"schema_version": "1", "type": "snapshot.created", "occurred_at": "2025-03-01T12:30:00Z",
+			"page_id": "7", "snapshot_id": "1200", "content_hash": "ab12",
+		},
+		want: queue.Event{SchemaVersion: 1, Type: queue.EventSnapshotCreated, OccurredAt: occurredAt, PageID: 7, SnapshotID: 1200, ContentHash: "ab12"},
+	},
+	{
+		name: "scrape failed, unreachable page",
+		values: map[string]interface{}{
+			"schema_version": "1", "type": "scrape.failed", "occurred_at": "2025-03-01T12:30:00Z",
+			"page_id": "7", "status_code": "0",
+		},
+		want: queue.Event{SchemaVersion: 1, Type: queue.EventScrapeFailed, OccurredAt: occurredAt, PageID: 7},
+	},
+	{
+		name: "change detected by the Python detector before schema versions",
+		values: map[string]interface{}{
+			"type": "change.detected", "change_id": "55", "page_id": "7",
+		},
+		want: queue.Event{SchemaVersion: 1, Type: queue.EventChangeDetected, PageID: 7, ChangeID: 55},
+	},
+	{
+		name:   "unknown type",
+		values: map[string]interface{}{"schema_version": "1", "type": "page.archived", "page_id": "7"},
+		want:   queue.Event{SchemaVersion: 1, Type: "page.archived", PageID: 7},
+	},
+	{
+		name:   "newer version",
+		values: map[string]interface{}{"schema_version": "2", "type": "snapshot.created", "page_id": "7", "snapshot_id": "1"},
+		err:    queue.ErrUnsupportedVersion,
+	},
+	{
+		name:   "change without change_id",
+		values: map[string]interface{}{"schema_version": "1", "type": "change.detected", "page_id": "7"},
+		err:    queue.ErrInvalidMessage,
+	},
+	{
+		name:   "invalid page_id",
+		values: map[string]interface{}{"schema_version": "1", "type": "scrape.failed", "page_id": "seven"},
+		err:    queue.ErrInvalidMessage,
+	},
+	{
+		name:   "no type",
+		values: map[string]interface{}{"schema_version": "1", "page_id": "7"},
+		err:    queue.ErrInvalidMessage,
+	},
+}
+
+// ScrapeJobConsumer checks that decode, the consumer's way of reading the
+// scrape job queue, accepts and rejects the fixtures as the contract says
+func ScrapeJobConsumer(t *testing.T, decode func(payload []byte) (queue.ScrapeJob, error)) {
+	t.Helper()
+	for _, f := range scrapeJobFixtures {
+		t.Run(f.name, func(t *testing.T) {
+			got, err := decode([]byte(f.payload))
+			if f.err != nil {
+				if !errors.Is(err, f.err) {
+					t.Fatalf("decode(%s) error = %v, want %v", f.payload, err, f.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode(%s) error = %v", f.payload, err)
+			}
+			if got != f.want {
+				t.Fatalf("decode(%s) = %+v, want %+v", f.payload, got, f.want)
+			}
+		})
+	}
+}
+
+// ScrapeJobProducer checks a payload a producer pushes on the scrape job
+// queue: current version, valid, and carrying want
+func ScrapeJobProducer(t *testing.T, payload []byte, want queue.ScrapeJob) {
+	t.Helper()
+	got, err := queue.DecodeScrapeJob(payload)
+	if err != nil {
+		t.Fatalf("produced scrape job %s: %v", payload, err)
+	}
+	want.SchemaVersion = queue.SchemaVersion
+	if got != want {
+		t.Fatalf("produced scrape job %s = %+v, want %+v", payload, got, want)
+	}
+}
+
+// EventConsumer checks that parse, the consumer's way of reading stream
+// entries, accepts and rejects the fixtures as the contract says
+func EventConsumer(t *testing.T, parse func(values map[string]interface{}) (queue.Event, error)) {
+	t.Helper()
+	for _, f := range eventFixtures {
+		t.Run(f.name, func(t *testing.T) {
+			got, err := parse(f.values)
+			if f.err != nil {
+				if !errors.Is(err, f.err) {
+					t.Fatalf("parse(%v) error = %v, want %v", f.values, err, f.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse(%v) error = %v", f.values, err)
+			}
+			if got != f.want {
+				t.Fatalf("parse(%v) = %+v, want %+v", f.values, got, f.want)
+			}
+		})
+	}
+}
+
+// EventProducer checks the entry a producer appends to stream: on the
+// stream of its type, current version, valid, every field a string (what
+// consumers read back from Redis) and carrying want
+func EventProducer(t *testing.T, stream string, values map[string]interface{}, want queue.Event) {
+	t.Helper()
+	fields := map[string]interface{}{}
+	for key, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			t.Fatalf("event field %s = %v (%T), want a string", key, value, value)
+		}
+		fields[key] = s
+	}
+
+	got, err := queue.ParseEvent(fields)
+	if err != nil {
+		t.Fatalf("produced event %v: %v", values, err)
+	}
+	if got.SchemaVersion != queue.SchemaVersion || got.OccurredAt.IsZero() {
+		t.Fatalf("produced event %v: want schema_version %d and occurred_at", values, queue.SchemaVersion)
+	}
+	if stream != got.Stream() {
+		t.Fatalf("produced %s on %s, want %s", got.Type, stream, got.Stream())
+	}
+	want.SchemaVersion, want.OccurredAt = got.SchemaVersion, got.OccurredAt
+	if got != want {
+		t.Fatalf("produced event %+v, want %+v", got, want)
+	}
+}
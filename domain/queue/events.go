@@ -0,0 +1,183 @@
+package queue
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Redis Streams shared by the services. Consumers use consumer groups, so
+// events published while they are down are delivered when they come back.
+const (
+	// SnapshotEventsStream carries snapshot.created and scrape.failed,
+	// published by scraper-go
+	SnapshotEventsStream = "snapshot_events"
+	// ChangeEventsStream carries change.detected, published by the Python
+	// detector (and scraper-go for visual changes)
+	ChangeEventsStream = "change_events"
+)
+
+const (
+	EventSnapshotCreated = "snapshot.created"
+	EventScrapeFailed    = "scrape.failed"
+	EventChangeDetected  = "change.detected"
+)
+
+// Event is a stream entry. Stream entries are flat string maps: Values and
+// ParseEvent convert from and to them. Fields unused by a type are zero.
+type Event struct {
+	SchemaVersion int
+	Type          string
+	OccurredAt    time.Time // zero when the producer did not set it
+	PageID        uint
+	SnapshotID    uint   // snapshot.created
+	ContentHash   string // snapshot.created, canonical text hash
+	StatusCode    int    // scrape.failed, 0 when the page could not be reached
+	ChangeID      uint   // change.detected
+}
+
+func SnapshotCreated(pageID, snapshotID uint, contentHash string) Event {
+	return newEvent(EventSnapshotCreated, Event{PageID: pageID, SnapshotID: snapshotID, ContentHash: contentHash})
+}
+
+func ScrapeFailed(pageID uint, statusCode int) Event {
+	return newEvent(EventScrapeFailed, Event{PageID: pageID, StatusCode: statusCode})
+}
+
+func ChangeDetected(changeID, pageID uint) Event {
+	return newEvent(EventChangeDetected, Event{PageID: pageID, ChangeID: changeID})
+}
+
+func newEvent(eventType string, e Event) Event {
+	e.SchemaVersion = SchemaVersion
+	e.Type = eventType
+	e.OccurredAt = time.Now().UTC()
+	return e
+}
+
+// Stream returns the stream the event is published on
+func (e Event) Stream() string {
+	if e.Type == EventChangeDetected {
+		return ChangeEventsStream
+	}
+	return SnapshotEventsStream
+}
+
+// Validate checks the fields required by the event type. Unknown types are
+// accepted, consumers skip them.
+func (e Event) Validate() error {
+	if e.Type == "" {
+		return invalid("event without type")
+	}
+	switch e.Type {
+	case EventSnapshotCreated:
+		if e.PageID == 0 || e.SnapshotID == 0 {
+			return invalid("%s needs page_id and snapshot_id", e.Type)
+		}
+	case EventScrapeFailed:
+		if e.PageID == 0 {
+			return invalid("%s needs page_id", e.Type)
+		}
+	case EventChangeDetected:
+		if e.PageID == 0 || e.ChangeID == 0 {
+			return invalid("%s needs page_id and change_id", e.Type)
+		}
+	}
+	return nil
+}
+
+// Values returns the stream entry fields of the event
+func (e Event) Values() map[string]interface{} {
+	values := map[string]interface{}{
+		"schema_version": strconv.Itoa(e.SchemaVersion),
+		"type":           e.Type,
+		"page_id":        formatID(e.PageID),
+	}
+	if !e.OccurredAt.IsZero() {
+		values["occurred_at"] = e.OccurredAt.UTC().Format(time.RFC3339Nano)
+	}
+	switch e.Type {
+	case EventSnapshotCreated:
+		values["snapshot_id"] = formatID(e.SnapshotID)
+		values["content_hash"] = e.ContentHash
+	case EventScrapeFailed:
+		values["status_code"] = strconv.Itoa(e.StatusCode)
+	case EventChangeDetected:
+		values["change_id"] = formatID(e.ChangeID)
+	}
+	return values
+}
+
+// ParseEvent decodes and validates the fields of a stream entry
+func ParseEvent(values map[string]interface{}) (Event, error) {
+	var e Event
+	e.Type = stringField(values, "type")
+
+	version, err := intField(values, "schema_version")
+	if err != nil {
+		return e, err
+	}
+	if e.SchemaVersion, err = checkVersion(version); err != nil {
+		return e, err
+	}
+	if raw := stringField(values, "occurred_at"); raw != "" {
+		if e.OccurredAt, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			return e, invalid("invalid occurred_at %q", raw)
+		}
+	}
+	if e.PageID, err = idField(values, "page_id"); err != nil {
+		return e, err
+	}
+	if e.SnapshotID, err = idField(values, "snapshot_id"); err != nil {
+		return e, err
+	}
+	if e.ChangeID, err = idField(values, "change_id"); err != nil {
+		return e, err
+	}
+	if e.StatusCode, err = intField(values, "status_code"); err != nil {
+		return e, err
+	}
+	e.ContentHash = stringField(values, "content_hash")
+	return e, e.Validate()
+}
+
+// stringField reads a field. Redis returns strings, values built in
+// process may hold numbers.
+func stringField(values map[string]interface{}, key string) string {
+	switch v := values[key].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func intField(values map[string]interface{}, key string) (int, error) {
+	raw := stringField(values, key)
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, invalid("invalid %s %q", key, raw)
+	}
+	return v, nil
+}
+
+func idField(values map[string]interface{}, key string) (uint, error) {
+	raw := stringField(values, key)
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return 0, invalid("invalid %s %q", key, raw)
+	}
+	return uint(v), nil
+}
+
+func formatID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
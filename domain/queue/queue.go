@@ -0,0 +1,39 @@
+// Package queue defines the messages the services exchange through Redis:
+// scrape jobs (a list) and events (streams). Every message carries the
+// schema version it was written with.
+package queue
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the version of the message formats of this package.
+// Bump it on an incompatible change: consumers reject messages newer than
+// the version they were built with.
+const SchemaVersion = 1
+
+var (
+	// ErrInvalidMessage is returned for a message missing required fields
+	ErrInvalidMessage = errors.New("invalid queue message")
+	// ErrUnsupportedVersion is returned for a message written with a newer
+	// schema version than the consumer's
+	ErrUnsupportedVersion = errors.New("unsupported queue message schema version")
+)
+
+// checkVersion returns the schema version of a message. Messages written
+// before versioning have none and are version 1.
+func checkVersion(version int) (int, error) {
+	switch {
+	case version == 0:
+		return 1, nil
+	case version < 0 || version > SchemaVersion:
+		return version, fmt.Errorf("%w: %d (supported: %d)", ErrUnsupportedVersion, version, SchemaVersion)
+	default:
+		return version, nil
+	}
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidMessage, fmt.Sprintf(format, args...))
+}
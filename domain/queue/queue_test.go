@@ -0,0 +1,31 @@
+package queue_test
+
+import (
+	"testing"
+
+	"github.com/rivalprice/domain/queue"
+	"github.com/rivalprice/domain/queue/contracttest"
+)
+
+func TestScrapeJobContract(t *testing.T) {
+	contracttest.ScrapeJobConsumer(t, queue.DecodeScrapeJob)
+
+	job := queue.NewScrapeJob(42, "https://example.com/pricing", "pricing")
+	payload, err := job.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contracttest.ScrapeJobProducer(t, payload, job)
+}
+
+func TestEventContract(t *testing.T) {
+	contracttest.EventConsumer(t, queue.ParseEvent)
+
+	for _, e := range []queue.Event{
+		queue.SnapshotCreated(7, 1200, "ab12"),
+		queue.ScrapeFailed(7, 503),
+		queue.ChangeDetected(55, 7),
+	} {
+		contracttest.EventProducer(t, e.Stream(), e.Values(), e)
+	}
+}
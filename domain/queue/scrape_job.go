@@ -0,0 +1,56 @@
+package queue
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// ScrapeJobQueue is the Redis list of scrape jobs: the API and its
+// scheduler push, scraper-go workers pop
+const ScrapeJobQueue = "scrape_job"
+
+// ScrapeJob asks a scraper to fetch a page. Extraction settings (CSS
+// selector, ignore rules) are read from the page itself.
+type ScrapeJob struct {
+	SchemaVersion int    `json:"schema_version"`
+	PageID        uint   `json:"page_id"`
+	URL           string `json:"url"`
+	Type          string `json:"type"` // page type
+}
+
+func NewScrapeJob(pageID uint, pageURL, pageType string) ScrapeJob {
+	return ScrapeJob{SchemaVersion: SchemaVersion, PageID: pageID, URL: pageURL, Type: pageType}
+}
+
+// Validate checks the fields every scraper relies on
+func (j ScrapeJob) Validate() error {
+	if j.PageID == 0 {
+		return invalid("scrape job without page_id")
+	}
+	u, err := url.Parse(j.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalid("scrape job for page %d has no absolute http(s) url: %q", j.PageID, j.URL)
+	}
+	return nil
+}
+
+// Encode validates the job and returns its queue payload
+func (j ScrapeJob) Encode() ([]byte, error) {
+	if err := j.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// DecodeScrapeJob reads and validates a queue payload
+func DecodeScrapeJob(payload []byte) (ScrapeJob, error) {
+	var job ScrapeJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return job, invalid("scrape job is not JSON: %v", err)
+	}
+	var err error
+	if job.SchemaVersion, err = checkVersion(job.SchemaVersion); err != nil {
+		return job, err
+	}
+	return job, job.Validate()
+}
@@ -0,0 +1,206 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakePostgres emulates the statements the Migrator sends to Postgres:
+// the advisory lock, schema_migrations and migration scripts. A script
+// containing "FAIL" returns an error.
+type fakePostgres struct {
+	mu         sync.Mutex
+	unlocked   *sync.Cond
+	lockHolder *fakeConn
+
+	tableExists bool
+	rows        map[int64]applied
+	scripts     []string // migration scripts committed, in order
+}
+
+type fakeDriver struct {
+	mu      sync.Mutex
+	servers map[string]*fakePostgres
+}
+
+var testDriver = &fakeDriver{servers: map[string]*fakePostgres{}}
+
+func init() {
+	sql.Register("fakepostgres", testDriver)
+}
+
+// openFake returns a database backed by a fresh fake server
+func openFake(name string) (*sql.DB, *fakePostgres) {
+	server := &fakePostgres{rows: map[int64]applied{}}
+	server.unlocked = sync.NewCond(&server.mu)
+
+	testDriver.mu.Lock()
+	testDriver.servers[name] = server
+	testDriver.mu.Unlock()
+
+	db, _ := sql.Open("fakepostgres", name)
+	return db, server
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	server, ok := d.servers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown fake database %q", name)
+	}
+	return &fakeConn{server: server}, nil
+}
+
+// fakeConn is one session. Inside a transaction the writes are buffered
+// and only reach the server on commit.
+type fakeConn struct {
+	server  *fakePostgres
+	inTx    bool
+	pending []func()
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	// Postgres releases session locks when the connection ends
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	if c.server.lockHolder == c {
+		c.server.lockHolder = nil
+		c.server.unlocked.Broadcast()
+	}
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.inTx = true
+	c.pending = nil
+	return fakeTx{c}, nil
+}
+
+type fakeTx struct{ c *fakeConn }
+
+func (tx fakeTx) Commit() error {
+	tx.c.server.mu.Lock()
+	for _, write := range tx.c.pending {
+		write()
+	}
+	tx.c.server.mu.Unlock()
+	tx.c.inTx, tx.c.pending = false, nil
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.c.inTx, tx.c.pending = false, nil
+	return nil
+}
+
+// write applies fn now, or on commit inside a transaction
+func (c *fakeConn) write(fn func()) {
+	if c.inTx {
+		c.pending = append(c.pending, fn)
+		return
+	}
+	c.server.mu.Lock()
+	fn()
+	c.server.mu.Unlock()
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	s := c.server
+	switch {
+	case strings.Contains(query, "pg_advisory_lock"):
+		s.mu.Lock()
+		for s.lockHolder != nil && s.lockHolder != c {
+			s.unlocked.Wait()
+		}
+		s.lockHolder = c
+		s.mu.Unlock()
+	case strings.Contains(query, "pg_advisory_unlock"):
+		s.mu.Lock()
+		if s.lockHolder == c {
+			s.lockHolder = nil
+			s.unlocked.Broadcast()
+		}
+		s.mu.Unlock()
+	case strings.Contains(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		c.write(func() { s.tableExists = true })
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		version := args[0].Value.(int64)
+		row := applied{name: args[1].Value.(string), checksum: args[2].Value.(string), appliedAt: time.Now()}
+		c.write(func() { s.rows[version] = row })
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		version := args[0].Value.(int64)
+		c.write(func() { delete(s.rows, version) })
+	case strings.Contains(query, "FAIL"):
+		return nil, errors.New("syntax error at or near \"FAIL\"")
+	default:
+		// Scripts take time, which lets concurrent migrators interleave
+		time.Sleep(time.Millisecond)
+		c.write(func() { s.scripts = append(s.scripts, query) })
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	s := c.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case strings.Contains(query, "to_regclass('schema_migrations')"):
+		return &fakeRows{columns: []string{"exists"}, values: [][]driver.Value{{s.tableExists}}}, nil
+	case strings.Contains(query, "FROM schema_migrations"):
+		if !s.tableExists {
+			return nil, errors.New(`relation "schema_migrations" does not exist`)
+		}
+		rows := &fakeRows{columns: []string{"version", "name", "checksum", "applied_at"}}
+		for version, row := range s.rows {
+			rows.values = append(rows.values, []driver.Value{version, row.name, row.checksum, row.appliedAt})
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", query)
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// state returns the applied versions and the committed scripts
+func (s *fakePostgres) state() (map[int64]applied, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows := make(map[int64]applied, len(s.rows))
+	for v, row := range s.rows {
+		rows[v] = row
+	}
+	return rows, append([]string(nil), s.scripts...)
+}
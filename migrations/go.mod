@@ -0,0 +1,3 @@
+module github.com/rivalprice/migrations
+
+go 1.22
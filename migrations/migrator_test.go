@@ -0,0 +1,239 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func testMigrations() []Migration {
+	return []Migration{
+		{Version: 1, Name: "baseline", Up: "create a", Down: "drop a"},
+		{Version: 2, Name: "columns", Up: "alter a add b", Down: "alter a drop b"},
+		{Version: 3, Name: "table", Up: "create c", Down: "drop c"},
+	}
+}
+
+func newTestMigrator(t *testing.T, migrations []Migration) (*Migrator, *fakePostgres) {
+	t.Helper()
+	db, server := openFake(t.Name())
+	t.Cleanup(func() { db.Close() })
+	return &Migrator{db: db, migrations: migrations}, server
+}
+
+func versions(migrations []Migration) []int64 {
+	var out []int64
+	for _, m := range migrations {
+		out = append(out, m.Version)
+	}
+	return out
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	m, server := newTestMigrator(t, testMigrations())
+
+	if err := m.Check(ctx); !errors.Is(err, ErrPending) {
+		t.Fatalf("Check() on an empty database = %v, want ErrPending", err)
+	}
+	if v, err := m.Version(ctx); err != nil || v != 0 {
+		t.Fatalf("Version() on an empty database = %d, %v, want 0", v, err)
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Fatalf("Up() applied %v, want [1 2 3]", got)
+	}
+	if _, scripts := server.state(); !reflect.DeepEqual(scripts, []string{"create a", "alter a add b", "create c"}) {
+		t.Fatalf("scripts run = %q", scripts)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("Check() after Up = %v", err)
+	}
+	if v, _ := m.Version(ctx); v != 3 || m.Latest() != 3 {
+		t.Fatalf("Version() = %d, Latest() = %d, want 3 and 3", v, m.Latest())
+	}
+
+	// Nothing left to apply
+	if done, err := m.Up(ctx); err != nil || len(done) != 0 {
+		t.Fatalf("second Up() = %v, %v, want nothing applied", versions(done), err)
+	}
+
+	done, err = m.Down(ctx, 2)
+	if err != nil {
+		t.Fatalf("Down(2) error = %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{3, 2}) {
+		t.Fatalf("Down(2) reverted %v, want [3 2]", got)
+	}
+	rows, scripts := server.state()
+	if len(rows) != 1 || scripts[len(scripts)-1] != "alter a drop b" {
+		t.Fatalf("after Down(2): applied %v, last script %q", rows, scripts[len(scripts)-1])
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrPending) {
+		t.Fatalf("Check() after Down = %v, want ErrPending", err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.Pending() != (s.Version != 1) {
+			t.Errorf("migration %d: Pending() = %v", s.Version, s.Pending())
+		}
+	}
+}
+
+func TestUpStopsAtFailingMigration(t *testing.T) {
+	ctx := context.Background()
+	migrations := testMigrations()
+	migrations[1].Up = "alter a FAIL"
+	m, server := newTestMigrator(t, migrations)
+
+	done, err := m.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "migration 2_columns (up)") {
+		t.Fatalf("Up() error = %v, want the failing migration named", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1}) {
+		t.Fatalf("Up() applied %v, want [1]", got)
+	}
+
+	// The failed migration is neither recorded nor partially applied, and
+	// the next one did not run
+	rows, scripts := server.state()
+	if _, ok := rows[2]; ok || len(rows) != 1 {
+		t.Fatalf("applied versions = %v, want only 1", rows)
+	}
+	if !reflect.DeepEqual(scripts, []string{"create a"}) {
+		t.Fatalf("scripts run = %q", scripts)
+	}
+}
+
+func TestStatusReportsModifiedMigrations(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMigrator(t, testMigrations())
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A release edits migration 2 after it was applied
+	m.migrations[1].Up = "alter a add b not null"
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.Modified != (s.Version == 2) {
+			t.Errorf("migration %d: Modified = %v", s.Version, s.Modified)
+		}
+	}
+}
+
+func TestConcurrentUpAppliesEachMigrationOnce(t *testing.T) {
+	ctx := context.Background()
+	first, server := newTestMigrator(t, testMigrations())
+
+	const replicas = 4
+	var wg sync.WaitGroup
+	applied := make([][]Migration, replicas)
+	errs := make([]error, replicas)
+	for i := 0; i < replicas; i++ {
+		m := &Migrator{db: first.db, migrations: testMigrations()}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			applied[i], errs[i] = m.Up(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range applied {
+		if errs[i] != nil {
+			t.Fatalf("replica %d: Up() error = %v", i, errs[i])
+		}
+		total += len(applied[i])
+	}
+	if total != 3 {
+		t.Fatalf("replicas applied %d migrations in total, want 3", total)
+	}
+	if _, scripts := server.state(); len(scripts) != 3 {
+		t.Fatalf("scripts run = %q, want each migration once", scripts)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.lockHolder != nil {
+		t.Fatal("migration lock still held after Up")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0002_second.up.sql":   {Data: []byte("up 2")},
+		"sql/0002_second.down.sql": {Data: []byte("down 2")},
+		"sql/0010_tenth.up.sql":    {Data: []byte("up 10")},
+		"sql/0010_tenth.down.sql":  {Data: []byte("down 10")},
+		"sql/0001_first.up.sql":    {Data: []byte("up 1")},
+		"sql/0001_first.down.sql":  {Data: []byte("down 1")},
+	}
+	migrations, err := load(fsys, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "first", Up: "up 1", Down: "down 1"},
+		{Version: 2, Name: "second", Up: "up 2", Down: "down 2"},
+		{Version: 10, Name: "tenth", Up: "up 10", Down: "down 10"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Fatalf("load() = %+v, want %+v", migrations, want)
+	}
+	if migrations[0].Checksum() == (Migration{Up: "up 1 "}).Checksum() {
+		t.Fatal("Checksum() ignores the content of the up script")
+	}
+
+	invalid := map[string]fstest.MapFS{
+		"missing down": {"sql/0001_a.up.sql": {Data: []byte("x")}},
+		"bad version":  {"sql/v1_a.up.sql": {Data: []byte("x")}, "sql/v1_a.down.sql": {Data: []byte("x")}},
+		"bad suffix":   {"sql/0001_a.sql": {Data: []byte("x")}},
+		"two names":    {"sql/0001_a.up.sql": {Data: []byte("x")}, "sql/0001_b.down.sql": {Data: []byte("x")}},
+	}
+	for name, fsys := range invalid {
+		if _, err := load(fsys, "sql"); err == nil {
+			t.Errorf("%s: load() accepted the files", name)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %s has version %d, want %d (no gaps)", m.Name, m.Version, i+1)
+		}
+	}
+
+	// Only the baseline may create the tables that existed before versioned
+	// migrations; later changes to them must be explicit so that adopted
+	// databases get them too
+	for _, m := range migrations[1:] {
+		for _, table := range []string{"users", "projects", "competitors", "monitored_pages", "snapshots", "alert_logs", "user_notification_settings"} {
+			if strings.Contains(m.Up, "CREATE TABLE IF NOT EXISTS "+table+" ") {
+				t.Errorf("migration %d_%s recreates baseline table %s", m.Version, m.Name, table)
+			}
+		}
+	}
+}
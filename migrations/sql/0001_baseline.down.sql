@@ -0,0 +1,11 @@
+-- Drops the baseline tables
+
+DROP TABLE IF EXISTS ai_analysis CASCADE;
+DROP TABLE IF EXISTS detected_changes CASCADE;
+DROP TABLE IF EXISTS user_notification_settings CASCADE;
+DROP TABLE IF EXISTS alert_logs CASCADE;
+DROP TABLE IF EXISTS snapshots CASCADE;
+DROP TABLE IF EXISTS monitored_pages CASCADE;
+DROP TABLE IF EXISTS competitors CASCADE;
+DROP TABLE IF EXISTS projects CASCADE;
+DROP TABLE IF EXISTS users CASCADE;
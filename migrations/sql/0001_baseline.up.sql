@@ -0,0 +1,145 @@
+-- Baseline: the schema api-go and scraper-go created with gorm AutoMigrate
+-- before versioned migrations, plus the tables of the Python engine
+-- (detected_changes, ai_analysis). Statements are IF NOT EXISTS so that such
+-- a database is adopted; every later change to these tables is a migration
+-- of its own, which brings adopted and new databases to the same schema.
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial,
+    email text NOT NULL,
+    hashed_password text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS projects (
+    id bigserial,
+    user_id bigint NOT NULL,
+    name text NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_projects_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_projects_user_id ON projects (user_id);
+
+CREATE TABLE IF NOT EXISTS competitors (
+    id bigserial,
+    project_id bigint NOT NULL,
+    name text NOT NULL,
+    url varchar(512),
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_competitors_project FOREIGN KEY (project_id) REFERENCES projects(id)
+);
+CREATE INDEX IF NOT EXISTS idx_competitors_project_id ON competitors (project_id);
+
+CREATE TABLE IF NOT EXISTS monitored_pages (
+    id bigserial,
+    competitor_id bigint NOT NULL,
+    page_type varchar(50) NOT NULL,
+    url varchar(512) NOT NULL,
+    css_selector text,
+    frequency varchar(20) NOT NULL DEFAULT 'daily',
+    next_run_at timestamptz NOT NULL,
+    last_checked_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_monitored_pages_competitor FOREIGN KEY (competitor_id) REFERENCES competitors(id)
+);
+CREATE INDEX IF NOT EXISTS idx_monitored_pages_next_run_at ON monitored_pages (next_run_at);
+CREATE INDEX IF NOT EXISTS idx_monitored_pages_competitor_id ON monitored_pages (competitor_id);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+    id bigserial,
+    monitored_page_id bigint NOT NULL,
+    price varchar(100),
+    availability varchar(50),
+    raw_data jsonb,
+    scraped_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_snapshots_monitored_page FOREIGN KEY (monitored_page_id) REFERENCES monitored_pages(id)
+);
+CREATE INDEX IF NOT EXISTS idx_snapshots_monitored_page_id ON snapshots (monitored_page_id);
+
+CREATE TABLE IF NOT EXISTS alert_logs (
+    id bigserial,
+    change_id bigint NOT NULL,
+    page_id bigint NOT NULL,
+    alert_type varchar(50) NOT NULL,
+    severity varchar(20) NOT NULL,
+    old_price varchar(50),
+    new_price varchar(50),
+    change_percent decimal,
+    ai_summary text,
+    ai_recommendation varchar(500),
+    impact_level bigint DEFAULT 0,
+    ai_model varchar(50),
+    message text,
+    notified boolean DEFAULT false,
+    notified_at timestamptz,
+    notify_channel varchar(50),
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_alert_logs_change_id ON alert_logs (change_id);
+CREATE INDEX IF NOT EXISTS idx_alert_logs_page_id ON alert_logs (page_id);
+
+CREATE TABLE IF NOT EXISTS user_notification_settings (
+    id bigserial,
+    user_id bigint NOT NULL,
+    notify_email boolean DEFAULT true,
+    notify_webhook boolean DEFAULT false,
+    webhook_url varchar(512),
+    minimum_change_percent decimal DEFAULT 5,
+    alert_on_price_change boolean DEFAULT true,
+    alert_on_feature_change boolean DEFAULT true,
+    alert_on_messaging boolean DEFAULT false,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_user_notification_settings_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_notification_settings_user_id ON user_notification_settings (user_id);
+
+-- Written by the Python engine (SQLAlchemy models in ai-python/models)
+
+CREATE TABLE IF NOT EXISTS detected_changes (
+    id serial PRIMARY KEY,
+    page_id integer NOT NULL,
+    page_type varchar(20),
+    old_price varchar(50),
+    new_price varchar(50),
+    change_percent double precision,
+    old_availability varchar(50),
+    new_availability varchar(50),
+    old_features text,
+    new_features text,
+    features_added text,
+    features_removed text,
+    old_text text,
+    new_text text,
+    change_type varchar(50) NOT NULL,
+    old_hash varchar(64),
+    new_hash varchar(64),
+    detected_at timestamp NOT NULL,
+    raw_data text
+);
+CREATE INDEX IF NOT EXISTS ix_detected_changes_page_id ON detected_changes (page_id);
+
+CREATE TABLE IF NOT EXISTS ai_analysis (
+    id serial PRIMARY KEY,
+    change_id integer NOT NULL,
+    summary text NOT NULL,
+    recommendation varchar(500),
+    change_type varchar(50),
+    page_type varchar(20),
+    old_price varchar(50),
+    new_price varchar(50),
+    change_percent varchar(20),
+    model varchar(50),
+    created_at timestamp NOT NULL
+);
+CREATE INDEX IF NOT EXISTS ix_ai_analysis_change_id ON ai_analysis (change_id);
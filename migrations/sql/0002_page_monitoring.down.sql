@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS monitor_alerts CASCADE;
+
+ALTER TABLE snapshots
+    DROP COLUMN IF EXISTS visual_change_ratio,
+    DROP COLUMN IF EXISTS visual_diff_key,
+    DROP COLUMN IF EXISTS screenshot_key;
+
+ALTER TABLE monitored_pages
+    DROP COLUMN IF EXISTS health_checked_at,
+    DROP COLUMN IF EXISTS last_scraped_at,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS last_status_code,
+    DROP COLUMN IF EXISTS consecutive_failures,
+    DROP COLUMN IF EXISTS alert_merge_window_minutes,
+    DROP COLUMN IF EXISTS alert_cooldown_minutes,
+    DROP COLUMN IF EXISTS ignore_patterns,
+    DROP COLUMN IF EXISTS ignore_selectors,
+    DROP COLUMN IF EXISTS capture_screenshot;
@@ -0,0 +1,38 @@
+-- Page health, visual capture, ignore rules and per-page alert windows
+
+ALTER TABLE monitored_pages
+    ADD COLUMN IF NOT EXISTS capture_screenshot boolean DEFAULT false,
+    ADD COLUMN IF NOT EXISTS ignore_selectors text,
+    ADD COLUMN IF NOT EXISTS ignore_patterns text,
+    ADD COLUMN IF NOT EXISTS alert_cooldown_minutes bigint,
+    ADD COLUMN IF NOT EXISTS alert_merge_window_minutes bigint,
+    ADD COLUMN IF NOT EXISTS consecutive_failures bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_status_code bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error text,
+    ADD COLUMN IF NOT EXISTS last_scraped_at timestamptz,
+    ADD COLUMN IF NOT EXISTS health_checked_at timestamptz;
+
+ALTER TABLE snapshots
+    ADD COLUMN IF NOT EXISTS screenshot_key varchar(255) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS visual_diff_key varchar(255) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS visual_change_ratio decimal;
+
+CREATE TABLE IF NOT EXISTS monitor_alerts (
+    id bigserial,
+    page_id bigint NOT NULL,
+    alert_type varchar(50) NOT NULL DEFAULT 'monitor_broken',
+    reason varchar(50) NOT NULL,
+    severity varchar(20) NOT NULL,
+    detail text,
+    status_code bigint,
+    consecutive_failures bigint,
+    notified boolean DEFAULT false,
+    acknowledged boolean DEFAULT false,
+    acknowledged_at timestamptz,
+    acknowledged_by bigint,
+    resolved_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_monitor_alerts_acknowledged ON monitor_alerts (acknowledged);
+CREATE INDEX IF NOT EXISTS idx_monitor_alerts_page_id ON monitor_alerts (page_id);
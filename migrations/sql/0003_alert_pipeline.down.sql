@@ -0,0 +1,33 @@
+DROP TABLE IF EXISTS project_recipients CASCADE;
+DROP TABLE IF EXISTS pending_notifications CASCADE;
+DROP TABLE IF EXISTS notification_rules CASCADE;
+DROP TABLE IF EXISTS alert_activities CASCADE;
+DROP TABLE IF EXISTS alert_comments CASCADE;
+DROP TABLE IF EXISTS change_processing CASCADE;
+
+ALTER TABLE user_notification_settings
+    DROP COLUMN IF EXISTS quiet_hours_bypass_critical,
+    DROP COLUMN IF EXISTS quiet_hours_end,
+    DROP COLUMN IF EXISTS quiet_hours_start,
+    DROP COLUMN IF EXISTS quiet_hours_enabled,
+    DROP COLUMN IF EXISTS last_digest_at,
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS digest_weekday,
+    DROP COLUMN IF EXISTS digest_hour,
+    DROP COLUMN IF EXISTS digest_frequency,
+    DROP COLUMN IF EXISTS alert_on_visual_change;
+
+CREATE INDEX IF NOT EXISTS idx_alert_logs_change_id ON alert_logs (change_id);
+DROP INDEX IF EXISTS uq_alert_logs_change_id;
+
+ALTER TABLE alert_logs
+    DROP COLUMN IF EXISTS state_changed_at,
+    DROP COLUMN IF EXISTS assignee_id,
+    DROP COLUMN IF EXISTS snoozed_until,
+    DROP COLUMN IF EXISTS state,
+    DROP COLUMN IF EXISTS flapping,
+    DROP COLUMN IF EXISTS last_occurrence_at,
+    DROP COLUMN IF EXISTS occurrences,
+    DROP COLUMN IF EXISTS rule_id,
+    DROP COLUMN IF EXISTS digested_at,
+    DROP COLUMN IF EXISTS diff_url;
@@ -0,0 +1,149 @@
+-- Alert pipeline: processing states, triage, rules, folding, digests,
+-- quiet hours and project recipients
+
+ALTER TABLE alert_logs
+    ADD COLUMN IF NOT EXISTS diff_url varchar(255),
+    ADD COLUMN IF NOT EXISTS digested_at timestamptz,
+    ADD COLUMN IF NOT EXISTS rule_id bigint,
+    ADD COLUMN IF NOT EXISTS occurrences bigint NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS last_occurrence_at timestamptz,
+    ADD COLUMN IF NOT EXISTS flapping boolean DEFAULT false,
+    ADD COLUMN IF NOT EXISTS state varchar(20) NOT NULL DEFAULT 'new',
+    ADD COLUMN IF NOT EXISTS snoozed_until timestamptz,
+    ADD COLUMN IF NOT EXISTS assignee_id bigint,
+    ADD COLUMN IF NOT EXISTS state_changed_at timestamptz;
+CREATE INDEX IF NOT EXISTS idx_alert_logs_assignee_id ON alert_logs (assignee_id);
+CREATE INDEX IF NOT EXISTS idx_alert_logs_state ON alert_logs (state);
+
+ALTER TABLE user_notification_settings
+    ADD COLUMN IF NOT EXISTS alert_on_visual_change boolean DEFAULT true,
+    ADD COLUMN IF NOT EXISTS digest_frequency varchar(20) DEFAULT 'immediate',
+    ADD COLUMN IF NOT EXISTS digest_hour bigint DEFAULT 8,
+    ADD COLUMN IF NOT EXISTS digest_weekday bigint DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS timezone varchar(64) DEFAULT 'UTC',
+    ADD COLUMN IF NOT EXISTS last_digest_at timestamptz,
+    ADD COLUMN IF NOT EXISTS quiet_hours_enabled boolean DEFAULT false,
+    ADD COLUMN IF NOT EXISTS quiet_hours_start varchar(5) DEFAULT '22:00',
+    ADD COLUMN IF NOT EXISTS quiet_hours_end varchar(5) DEFAULT '07:00',
+    ADD COLUMN IF NOT EXISTS quiet_hours_bypass_critical boolean DEFAULT true;
+
+CREATE TABLE IF NOT EXISTS change_processing (
+    change_id bigint,
+    state varchar(20) NOT NULL DEFAULT 'pending',
+    reason text,
+    folded_into_alert_id bigint,
+    attempts bigint NOT NULL DEFAULT 0,
+    claimed_by varchar(100),
+    claimed_at timestamptz,
+    next_attempt_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (change_id)
+);
+CREATE INDEX IF NOT EXISTS idx_change_processing_folded_into_alert_id ON change_processing (folded_into_alert_id);
+CREATE INDEX IF NOT EXISTS idx_change_processing_state ON change_processing (state);
+
+CREATE TABLE IF NOT EXISTS alert_comments (
+    id bigserial,
+    alert_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    body text NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_alert_comments_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_alert_comments_alert_id ON alert_comments (alert_id);
+
+CREATE TABLE IF NOT EXISTS alert_activities (
+    id bigserial,
+    alert_id bigint NOT NULL,
+    actor_id bigint,
+    action varchar(50) NOT NULL,
+    from_value varchar(255),
+    to_value varchar(255),
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_alert_activities_alert_id ON alert_activities (alert_id);
+
+CREATE TABLE IF NOT EXISTS notification_rules (
+    id bigserial,
+    name varchar(255),
+    scope_type varchar(20) NOT NULL,
+    scope_id bigint NOT NULL,
+    priority bigint NOT NULL,
+    enabled boolean NOT NULL,
+    change_types varchar(255),
+    min_severity varchar(20),
+    min_percent decimal,
+    max_percent decimal,
+    direction varchar(10),
+    plan_name varchar(255),
+    keyword varchar(255),
+    action varchar(20) NOT NULL DEFAULT 'notify',
+    channels varchar(100),
+    recipients text,
+    webhook_url varchar(512),
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_notification_rules_scope ON notification_rules (scope_type,scope_id);
+
+CREATE TABLE IF NOT EXISTS pending_notifications (
+    id bigserial,
+    alert_id bigint NOT NULL,
+    channel varchar(20) NOT NULL,
+    recipient varchar(512) NOT NULL,
+    unsubscribe_token varchar(64),
+    deliver_after timestamptz NOT NULL,
+    delivered_at timestamptz,
+    attempts bigint NOT NULL DEFAULT 0,
+    last_error text,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_pending_notifications_deliver_after ON pending_notifications (deliver_after);
+CREATE INDEX IF NOT EXISTS idx_pending_notifications_alert_id ON pending_notifications (alert_id);
+
+CREATE TABLE IF NOT EXISTS project_recipients (
+    id bigserial,
+    project_id bigint NOT NULL,
+    user_id bigint,
+    email varchar(255),
+    label varchar(255),
+    notify_email boolean NOT NULL,
+    webhook_url varchar(512),
+    min_severity varchar(20),
+    unsubscribe_token varchar(64) NOT NULL,
+    unsubscribed_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_project_recipients_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_project_recipients_unsubscribe_token ON project_recipients (unsubscribe_token);
+CREATE INDEX IF NOT EXISTS idx_project_recipients_user_id ON project_recipients (user_id);
+CREATE INDEX IF NOT EXISTS idx_project_recipients_project_id ON project_recipients (project_id);
+
+-- Concurrent instances used to alert the same change more than once: keep
+-- the oldest alert of each change and move what references the others to it
+CREATE TEMPORARY TABLE alert_log_duplicates ON COMMIT DROP AS
+SELECT a.id, keep.id AS keep_id
+FROM alert_logs a
+JOIN (SELECT change_id, MIN(id) AS id FROM alert_logs GROUP BY change_id HAVING COUNT(*) > 1) keep
+    ON keep.change_id = a.change_id AND a.id <> keep.id;
+
+UPDATE alert_comments t SET alert_id = d.keep_id FROM alert_log_duplicates d WHERE t.alert_id = d.id;
+UPDATE alert_activities t SET alert_id = d.keep_id FROM alert_log_duplicates d WHERE t.alert_id = d.id;
+UPDATE pending_notifications t SET alert_id = d.keep_id FROM alert_log_duplicates d WHERE t.alert_id = d.id;
+UPDATE change_processing t SET folded_into_alert_id = d.keep_id FROM alert_log_duplicates d WHERE t.folded_into_alert_id = d.id;
+DELETE FROM alert_logs a USING alert_log_duplicates d WHERE a.id = d.id;
+
+-- One alert per change, replacing the plain index
+CREATE UNIQUE INDEX IF NOT EXISTS uq_alert_logs_change_id ON alert_logs (change_id);
+DROP INDEX IF EXISTS idx_alert_logs_change_id;
+
+-- Changes alerted before processing states existed are done
+INSERT INTO change_processing (change_id, state, attempts, created_at, updated_at)
+SELECT DISTINCT change_id, 'alerted', 0, NOW(), NOW() FROM alert_logs
+ON CONFLICT (change_id) DO NOTHING;
@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS signing_keys CASCADE;
+DROP TABLE IF EXISTS mfa_recovery_codes CASCADE;
+DROP TABLE IF EXISTS user_mfa CASCADE;
+DROP TABLE IF EXISTS account_tokens CASCADE;
+DROP TABLE IF EXISTS user_identities CASCADE;
+DROP TABLE IF EXISTS oidc_providers CASCADE;
+DROP TABLE IF EXISTS refresh_tokens CASCADE;
+DROP TABLE IF EXISTS api_keys CASCADE;
+
+DROP INDEX IF EXISTS idx_projects_organization_id;
+ALTER TABLE projects DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS invitations CASCADE;
+DROP TABLE IF EXISTS memberships CASCADE;
+DROP TABLE IF EXISTS organizations CASCADE;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,178 @@
+-- Accounts: email verification, organizations and memberships, API keys,
+-- sessions, single sign-on, two-factor authentication and signing keys
+
+-- Accounts created before email verification keep working: they are
+-- considered verified when the column is introduced
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'email_verified_at'
+    ) THEN
+        ALTER TABLE users ADD COLUMN email_verified_at timestamptz;
+        UPDATE users SET email_verified_at = COALESCE(created_at, NOW());
+    END IF;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id bigserial,
+    name varchar(255) NOT NULL,
+    personal boolean DEFAULT false,
+    owner_user_id bigint,
+    require_mfa boolean NOT NULL DEFAULT false,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_organizations_owner_user_id ON organizations (owner_user_id);
+
+CREATE TABLE IF NOT EXISTS memberships (
+    id bigserial,
+    organization_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    role varchar(20) NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_memberships_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_memberships_user_id ON memberships (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_memberships_org_user ON memberships (organization_id,user_id);
+
+CREATE TABLE IF NOT EXISTS invitations (
+    id bigserial,
+    organization_id bigint NOT NULL,
+    email varchar(255) NOT NULL,
+    role varchar(20) NOT NULL,
+    token varchar(64) NOT NULL,
+    invited_by_id bigint NOT NULL,
+    expires_at timestamptz NOT NULL,
+    accepted_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invitations_token ON invitations (token);
+CREATE INDEX IF NOT EXISTS idx_invitations_organization_id ON invitations (organization_id);
+
+-- Existing projects are moved to personal organizations by api-go at startup
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS organization_id bigint;
+CREATE INDEX IF NOT EXISTS idx_projects_organization_id ON projects (organization_id);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id bigserial,
+    user_id bigint NOT NULL,
+    name varchar(255) NOT NULL,
+    kind varchar(20) NOT NULL,
+    prefix varchar(16) NOT NULL,
+    key_hash varchar(64) NOT NULL,
+    scopes text NOT NULL,
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    last_used_ip varchar(64),
+    revoked_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_api_keys_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_prefix ON api_keys (prefix);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id bigserial,
+    user_id bigint NOT NULL,
+    family_id varchar(64) NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    access_jti varchar(64),
+    access_expires_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    revoked_at timestamptz,
+    user_agent varchar(255),
+    ip varchar(64),
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS oidc_providers (
+    id bigserial,
+    organization_id bigint NOT NULL,
+    slug varchar(64) NOT NULL,
+    name varchar(255) NOT NULL,
+    issuer text NOT NULL,
+    client_id varchar(255) NOT NULL,
+    client_secret text,
+    scopes text,
+    allowed_domains text,
+    default_role varchar(20) NOT NULL,
+    enabled boolean,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oidc_providers_slug ON oidc_providers (slug);
+CREATE INDEX IF NOT EXISTS idx_oidc_providers_organization_id ON oidc_providers (organization_id);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id bigserial,
+    user_id bigint NOT NULL,
+    provider_id bigint NOT NULL,
+    subject varchar(255) NOT NULL,
+    email varchar(255),
+    last_login_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_user_identities_provider_subject ON user_identities (provider_id,subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+
+CREATE TABLE IF NOT EXISTS account_tokens (
+    id bigserial,
+    user_id bigint NOT NULL,
+    purpose varchar(20) NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_tokens_token_hash ON account_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_account_tokens_user_id ON account_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS user_mfa (
+    id bigserial,
+    user_id bigint NOT NULL,
+    secret_enc text NOT NULL,
+    enabled_at timestamptz,
+    last_used_step bigint,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_mfa_user_id ON user_mfa (user_id);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id bigserial,
+    user_id bigint NOT NULL,
+    code_hash varchar(64) NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id bigserial,
+    kid varchar(64) NOT NULL,
+    algorithm varchar(10) NOT NULL,
+    private_key_enc text NOT NULL,
+    sign_from timestamptz NOT NULL,
+    expires_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_signing_keys_expires_at ON signing_keys (expires_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_keys_k_id ON signing_keys (kid);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS billing_events CASCADE;
+DROP TABLE IF EXISTS usage_counters CASCADE;
+
+DROP INDEX IF EXISTS idx_organizations_billing_customer_id;
+ALTER TABLE organizations
+    DROP COLUMN IF EXISTS billing_event_at,
+    DROP COLUMN IF EXISTS subscription_status,
+    DROP COLUMN IF EXISTS subscription_id,
+    DROP COLUMN IF EXISTS billing_customer_id,
+    DROP COLUMN IF EXISTS plan;
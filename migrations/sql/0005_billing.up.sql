@@ -0,0 +1,32 @@
+-- Subscription plans, usage metering and billing webhooks
+
+ALTER TABLE organizations
+    ADD COLUMN IF NOT EXISTS plan varchar(20) NOT NULL DEFAULT 'free',
+    ADD COLUMN IF NOT EXISTS billing_customer_id varchar(255),
+    ADD COLUMN IF NOT EXISTS subscription_id varchar(255),
+    ADD COLUMN IF NOT EXISTS subscription_status varchar(30),
+    ADD COLUMN IF NOT EXISTS billing_event_at timestamptz;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organizations_billing_customer_id ON organizations (billing_customer_id);
+
+CREATE TABLE IF NOT EXISTS usage_counters (
+    id bigserial,
+    organization_id bigint NOT NULL,
+    metric varchar(30) NOT NULL,
+    period varchar(7) NOT NULL,
+    count bigint NOT NULL DEFAULT 0,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_usage_org_metric_period ON usage_counters (organization_id,metric,period);
+
+CREATE TABLE IF NOT EXISTS billing_events (
+    id bigserial,
+    provider varchar(20) NOT NULL,
+    event_id varchar(255) NOT NULL,
+    type varchar(100) NOT NULL,
+    organization_id bigint,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_billing_events_organization_id ON billing_events (organization_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_billing_event_provider_id ON billing_events (provider,event_id);
@@ -0,0 +1,33 @@
+-- Append-only audit log
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id bigserial,
+    organization_id bigint,
+    actor_type varchar(20) NOT NULL,
+    actor_user_id bigint,
+    actor_api_key_id bigint,
+    action varchar(100) NOT NULL,
+    target_type varchar(50) NOT NULL,
+    target_id bigint,
+    changes jsonb,
+    ip varchar(64),
+    user_agent varchar(512),
+    created_at timestamptz NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target_type,target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_user_id ON audit_events (actor_user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_org_created ON audit_events (organization_id,created_at);
+
+-- Audit events are never updated; the retention policy only deletes them
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_no_update ON audit_events;
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS digested_alerts CASCADE;
@@ -0,0 +1,9 @@
+-- Alerts sent in each user's digest: a digest takes the alerts its user has
+-- not received yet, so a failed send or a late commit loses nothing
+CREATE TABLE IF NOT EXISTS digested_alerts (
+    user_id bigint,
+    alert_id bigint,
+    digested_at timestamptz NOT NULL,
+    PRIMARY KEY (user_id, alert_id)
+);
+CREATE INDEX IF NOT EXISTS idx_digested_alerts_alert_id ON digested_alerts (alert_id);
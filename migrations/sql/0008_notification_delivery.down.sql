@@ -0,0 +1,3 @@
+DELETE FROM pending_notifications WHERE alert_kind = 'monitor';
+ALTER TABLE pending_notifications DROP COLUMN IF EXISTS claimed_at;
+ALTER TABLE pending_notifications DROP COLUMN IF EXISTS alert_kind;
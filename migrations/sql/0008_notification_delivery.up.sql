@@ -0,0 +1,5 @@
+-- Monitor alerts are delivered through the queue too: alert_kind says which
+-- table alert_id refers to. Queued notifications are claimed (claimed_at)
+-- before they are sent, outside of any transaction.
+ALTER TABLE pending_notifications ADD COLUMN IF NOT EXISTS alert_kind varchar(20) NOT NULL DEFAULT 'alert';
+ALTER TABLE pending_notifications ADD COLUMN IF NOT EXISTS claimed_at timestamptz;
@@ -0,0 +1,14 @@
+-- Email domains an organization proves it owns with a DNS TXT record. SSO
+-- links an existing account by email only on such a domain; a domain is
+-- verified by one organization at most.
+CREATE TABLE IF NOT EXISTS organization_domains (
+    id bigserial,
+    organization_id bigint NOT NULL,
+    domain varchar(253) NOT NULL,
+    verification_token varchar(64) NOT NULL,
+    verified_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_organization_domains_org_domain ON organization_domains (organization_id, domain);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_organization_domains_verified ON organization_domains (domain) WHERE verified_at IS NOT NULL;
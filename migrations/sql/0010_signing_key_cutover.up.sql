@@ -0,0 +1,11 @@
+-- When access tokens started being signed with asymmetric keys. HS256 tokens
+-- signed with JWT_SECRET are only accepted if issued before, and for one
+-- access token lifetime after; the row survives key rotations and restarts.
+CREATE TABLE IF NOT EXISTS signing_key_cutover (
+    id smallint NOT NULL DEFAULT 1 CHECK (id = 1),
+    cutover_at timestamptz NOT NULL,
+    PRIMARY KEY (id)
+);
+INSERT INTO signing_key_cutover (id, cutover_at)
+SELECT 1, min(COALESCE(created_at, sign_from)) FROM signing_keys HAVING count(*) > 0
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,5 @@
+-- Whether a credential passed two-factor authentication: sessions opened
+-- with a second factor (every refresh token of the family), API keys created
+-- from such a session. Organizations requiring 2FA only admit those.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS mfa boolean NOT NULL DEFAULT false;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS mfa boolean NOT NULL DEFAULT false;
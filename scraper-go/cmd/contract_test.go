@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/rivalprice/domain/queue"
+	"github.com/rivalprice/domain/queue/contracttest"
+)
+
+// The scraper consumes scrape jobs
+func TestScrapeJobContract(t *testing.T) {
+	contracttest.ScrapeJobConsumer(t, queue.DecodeScrapeJob)
+}
+
+// The scraper produces snapshot events and visual change events
+func TestStreamEventContract(t *testing.T) {
+	for _, event := range []queue.Event{
+		queue.SnapshotCreated(7, 1200, "ab12"),
+		queue.ScrapeFailed(7, 0),
+		queue.ChangeDetected(55, 7),
+	} {
+		contracttest.EventProducer(t, event.Stream(), event.Values(), event)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rivalprice/domain/queue"
+)
+
+// Approximate cap on stream length, older events are trimmed
+const eventStreamMaxLen = 100000
+
+// publishEvent appends an event to its stream (queue contracts). Publishing
+// is best effort: the consumers' catch-up sweep picks up anything that was
+// not announced.
+func publishEvent(event queue.Event) {
+	if err := event.Validate(); err != nil {
+		log.Printf("❌ Not publishing %s: %v", event.Type, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: event.Stream(),
+		MaxLen: eventStreamMaxLen,
+		Approx: true,
+		Values: event.Values(),
+	}).Err()
+	if err != nil {
+		log.Printf("⚠️  Failed to publish %s on %s: %v", event.Type, event.Stream(), err)
+	}
+}
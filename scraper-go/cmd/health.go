@@ -0,0 +1,45 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/rivalprice/domain/models"
+	"github.com/rivalprice/domain/queue"
+)
+
+// recordFetchFailure bumps the consecutive failure counter of a page.
+// statusCode is 0 when the request never got a response.
+func recordFetchFailure(page *models.MonitoredPage, statusCode int, reason string) {
+	now := time.Now()
+	err := db.Model(page).Updates(map[string]interface{}{
+		"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+		"last_status_code":     statusCode,
+		"last_error":           reason,
+		"last_scraped_at":      now,
+	}).Error
+	if err != nil {
+		log.Printf("⚠️  Failed to record fetch failure for page %d: %v", page.ID, err)
+	}
+}
+
+// recordFetchSuccess resets the failure counter after a successful fetch
+func recordFetchSuccess(page *models.MonitoredPage, statusCode int) {
+	now := time.Now()
+	err := db.Model(page).Updates(map[string]interface{}{
+		"consecutive_failures": 0,
+		"last_status_code":     statusCode,
+		"last_error":           "",
+		"last_scraped_at":      now,
+	}).Error
+	if err != nil {
+		log.Printf("⚠️  Failed to record fetch success for page %d: %v", page.ID, err)
+	}
+}
+
+// publishScrapeFailed lets the API re-check the page health right away
+func publishScrapeFailed(page *models.MonitoredPage, statusCode int) {
+	publishEvent(queue.ScrapeFailed(page.ID, statusCode))
+}
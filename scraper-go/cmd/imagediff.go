@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+const (
+	// perceptualTolerance is the luminance delta (0-255) below which two
+	// blocks are considered identical. It absorbs anti-aliasing and
+	// JPEG-like noise.
+	perceptualTolerance = 24
+
+	// blockWidth is the width of the blocks a row is averaged into: rows are
+	// compared block by block, not pixel by pixel
+	blockWidth = 8
+
+	// maxLayoutShift is how far (pixels) content may move up or down, e.g.
+	// below an inserted banner, and still be recognised
+	maxLayoutShift = 600
+
+	// resyncRows is how many consecutive rows must match at a new offset
+	// before content is considered moved rather than changed
+	resyncRows = 8
+
+	// diffScale shrinks the diff image (1/diffScale of each side)
+	diffScale = 2
+)
+
+// VisualDiff is the result of comparing two screenshots
+type VisualDiff struct {
+	ChangedRatio float64 // changed area / page area, 0..1
+	DiffPNG      []byte  // current screenshot dimmed at 1/diffScale, changed blocks in red
+}
+
+// diffScreenshots compares two PNG screenshots row by row, on the average
+// luminance of blockWidth-wide blocks. Rows of the current screenshot are
+// aligned with the previous one, so content pushed down (or up) by an
+// insertion (or a removal) only counts the inserted (removed) rows as
+// changed. Area gained or lost when the page was resized counts as changed.
+func diffScreenshots(previousPNG, currentPNG []byte) (*VisualDiff, error) {
+	prev, err := png.Decode(bytes.NewReader(previousPNG))
+	if err != nil {
+		return nil, err
+	}
+	curr, err := png.Decode(bytes.NewReader(currentPNG))
+	if err != nil {
+		return nil, err
+	}
+
+	pb, cb := prev.Bounds(), curr.Bounds()
+	width := max(pb.Dx(), cb.Dx())
+	height := max(pb.Dy(), cb.Dy())
+	if width == 0 || height == 0 {
+		return &VisualDiff{}, nil
+	}
+
+	prevRows, currRows := rowSignatures(prev), rowSignatures(curr)
+	matches, references := alignRows(prevRows, currRows)
+
+	// Changed blocks of each current row (nil when unchanged), and their area
+	changes := make([][]bool, len(currRows))
+	changedArea := 0
+	for y, row := range currRows {
+		var reference []uint8
+		if r := references[y]; r >= 0 && r < len(prevRows) {
+			reference = prevRows[r]
+		}
+		changes[y] = changedBlocks(reference, row, matches[y] >= 0)
+		for col, changed := range changes[y] {
+			if changed {
+				changedArea += min(blockWidth, cb.Dx()-col*blockWidth)
+			}
+		}
+	}
+
+	// Rows of the previous screenshot no current row shows nor was compared
+	// with were removed
+	seen := make([]bool, len(prevRows))
+	for y, p := range matches {
+		if p >= 0 {
+			seen[p] = true
+		}
+		if r := references[y]; r >= 0 && r < len(prevRows) {
+			seen[r] = true
+		}
+	}
+	removedArea := 0
+	for _, ok := range seen {
+		if !ok {
+			removedArea += pb.Dx()
+		}
+	}
+	if pb.Dx() > cb.Dx() {
+		removedArea += (pb.Dx() - cb.Dx()) * len(currRows)
+	}
+
+	diffPNG, err := renderDiff(curr, changes)
+	if err != nil {
+		return nil, err
+	}
+	return &VisualDiff{
+		ChangedRatio: min(1, float64(changedArea+removedArea)/float64(width*height)),
+		DiffPNG:      diffPNG,
+	}, nil
+}
+
+// rowSignatures averages the luminance of every row of img over
+// blockWidth-wide blocks
+func rowSignatures(img image.Image) [][]uint8 {
+	b := img.Bounds()
+	cols := (b.Dx() + blockWidth - 1) / blockWidth
+	rows := make([][]uint8, b.Dy())
+	for y := range rows {
+		row := make([]uint8, cols)
+		for col := range row {
+			x0 := col * blockWidth
+			x1 := min(x0+blockWidth, b.Dx())
+			sum := 0
+			for x := x0; x < x1; x++ {
+				sum += luminance(img.At(b.Min.X+x, b.Min.Y+y))
+			}
+			row[col] = uint8(sum / (x1 - x0))
+		}
+		rows[y] = row
+	}
+	return rows
+}
+
+// rowsMatch reports whether two rows look the same over the blocks they
+// both have
+func rowsMatch(a, b []uint8) bool {
+	for i := 0; i < min(len(a), len(b)); i++ {
+		if absInt(int(a[i])-int(b[i])) > perceptualTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// alignRows maps each current row to the previous row it shows (matches, -1
+// when new or changed) and to the previous row it is compared with
+// (references). Rows follow the offset between the two pages until they
+// stop matching; the nearest offset within maxLayoutShift where resyncRows
+// rows match again is then taken.
+func alignRows(prev, curr [][]uint8) (matches, references []int) {
+	matches = make([]int, len(curr))
+	references = make([]int, len(curr))
+	offset := 0
+	for y := range curr {
+		matches[y] = -1
+		if p := y + offset; p >= 0 && p < len(prev) && rowsMatch(prev[p], curr[y]) {
+			matches[y] = p
+		} else if d, ok := resync(prev, curr, y, offset); ok {
+			offset = d
+			matches[y] = y + offset
+		}
+		references[y] = y + offset
+	}
+	return matches, references
+}
+
+// resync returns the offset nearest to offset at which the rows from y on
+// match the previous page again
+func resync(prev, curr [][]uint8, y, offset int) (int, bool) {
+	for delta := 1; delta <= maxLayoutShift; delta++ {
+		for _, d := range [2]int{offset - delta, offset + delta} {
+			if runMatches(prev, curr, y, d) {
+				return d, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// runMatches reports whether resyncRows rows from y match the previous page
+// shifted by offset (fewer at the bottom of the page)
+func runMatches(prev, curr [][]uint8, y, offset int) bool {
+	for i := 0; i < resyncRows && y+i < len(curr); i++ {
+		p := y + i + offset
+		if p < 0 || p >= len(prev) || !rowsMatch(prev[p], curr[y+i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// changedBlocks returns the blocks of row that differ from reference (all
+// of them when there is none), or nil when none does. A matched row only
+// has the blocks beyond the reference's width changed.
+func changedBlocks(reference, row []uint8, matched bool) []bool {
+	var changes []bool
+	for col := range row {
+		changed := col >= len(reference) ||
+			!matched && absInt(int(reference[col])-int(row[col])) > perceptualTolerance
+		if changed {
+			if changes == nil {
+				changes = make([]bool, len(row))
+			}
+			changes[col] = true
+		}
+	}
+	return changes
+}
+
+// renderDiff draws curr dimmed at 1/diffScale with its changed blocks in red
+func renderDiff(curr image.Image, changes [][]bool) ([]byte, error) {
+	b := curr.Bounds()
+	palette := make(color.Palette, 0, 256)
+	for level := 0; level < 255; level++ {
+		palette = append(palette, color.Gray{Y: uint8(level)})
+	}
+	highlight := uint8(len(palette))
+	palette = append(palette, color.RGBA{R: 255, A: 255})
+
+	out := image.NewPaletted(image.Rect(0, 0, (b.Dx()+diffScale-1)/diffScale, (b.Dy()+diffScale-1)/diffScale), palette)
+	for y := 0; y < out.Rect.Dy(); y++ {
+		changed := changes[y*diffScale]
+		for x := 0; x < out.Rect.Dx(); x++ {
+			if changed != nil && changed[x*diffScale/blockWidth] {
+				out.SetColorIndex(x, y, highlight)
+				continue
+			}
+			// Dim unchanged pixels so the highlights stand out
+			l := luminance(curr.At(b.Min.X+x*diffScale, b.Min.Y+y*diffScale))
+			out.SetColorIndex(x, y, uint8(min(254, 255-(255-l)/3)))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// luminance returns the perceived brightness of a color on a 0-255 scale
+func luminance(c color.Color) int {
+	r, g, b, _ := c.RGBA()
+	// ITU-R BT.601 weights, inputs are 16-bit
+	return int((299*r + 587*g + 114*b) / 1000 >> 8)
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// testPage draws a synthetic screenshot: bands of "text" blocks of random
+// shades on white, the same for the same seed
+func testPage(width, height int, seed int64) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	r := rand.New(rand.NewSource(seed))
+	for y := 0; y < height; {
+		band := 6 + r.Intn(10)
+		for x := 0; x < width; x += 12 {
+			shade := uint8(255)
+			if r.Intn(3) > 0 {
+				shade = uint8(r.Intn(160))
+			}
+			for dy := 0; dy < band && y+dy < height; dy++ {
+				for dx := 0; dx < 12 && x+dx < width; dx++ {
+					img.SetGray(x+dx, y+dy, color.Gray{Y: shade})
+				}
+			}
+		}
+		y += band + 2 + r.Intn(4) // white gap between lines
+	}
+	return img
+}
+
+// stack returns the images one above the other, left-aligned
+func stack(parts ...*image.Gray) *image.Gray {
+	width, height := 0, 0
+	for _, p := range parts {
+		width = max(width, p.Rect.Dx())
+		height += p.Rect.Dy()
+	}
+	out := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range out.Pix {
+		out.Pix[i] = 255
+	}
+	y := 0
+	for _, p := range parts {
+		for py := 0; py < p.Rect.Dy(); py++ {
+			copy(out.Pix[(y+py)*out.Stride:], p.Pix[py*p.Stride:py*p.Stride+p.Rect.Dx()])
+		}
+		y += p.Rect.Dy()
+	}
+	return out
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiffScreenshots(t *testing.T) {
+	page := testPage(240, 600, 1)
+	banner := testPage(240, 40, 2)
+	edited := stack(page) // copy
+	for y := 300; y < 330; y++ {
+		for x := 100; x < 130; x++ {
+			edited.SetGray(x, y, color.Gray{Y: 255 - edited.GrayAt(x, y).Y})
+		}
+	}
+
+	tests := []struct {
+		name     string
+		previous *image.Gray
+		current  *image.Gray
+		min, max float64
+	}{
+		{name: "identical", previous: page, current: page, min: 0, max: 0},
+		{name: "noise below the tolerance", previous: page, current: lighten(page, 10), min: 0, max: 0},
+		// Everything below the banner moved down 40px: only the banner changed
+		{name: "banner inserted at the top", previous: page, current: stack(banner, page), min: 0.05, max: 0.12},
+		{name: "banner removed", previous: stack(banner, page), current: page, min: 0.05, max: 0.12},
+		{name: "banner inserted mid-page", previous: page, current: stack(crop(page, 0, 250), banner, crop(page, 250, 600)), min: 0.03, max: 0.12},
+		{name: "one block edited", previous: page, current: edited, min: 0.003, max: 0.02},
+		{name: "page grew", previous: page, current: stack(page, testPage(240, 150, 3)), min: 0.15, max: 0.25},
+		{name: "page got wider", previous: page, current: stack(page, testPage(300, 1, 3)), min: 0.15, max: 0.25},
+		{name: "different page", previous: page, current: testPage(240, 600, 4), min: 0.5, max: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := diffScreenshots(encodePNG(t, tt.previous), encodePNG(t, tt.current))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff.ChangedRatio < tt.min || diff.ChangedRatio > tt.max {
+				t.Errorf("ChangedRatio = %.4f, want between %.4f and %.4f", diff.ChangedRatio, tt.min, tt.max)
+			}
+
+			img, err := png.Decode(bytes.NewReader(diff.DiffPNG))
+			if err != nil {
+				t.Fatalf("DiffPNG is not a PNG: %v", err)
+			}
+			cb := tt.current.Bounds()
+			if got, want := img.Bounds().Size(), image.Pt((cb.Dx()+1)/2, (cb.Dy()+1)/2); got != want {
+				t.Errorf("DiffPNG is %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDiffScreenshotsHighlightsChangedBlocks(t *testing.T) {
+	page := testPage(240, 600, 1)
+	edited := stack(page)
+	for y := 300; y < 330; y++ {
+		for x := 100; x < 130; x++ {
+			edited.SetGray(x, y, color.Gray{Y: 255 - edited.GrayAt(x, y).Y})
+		}
+	}
+
+	diff, err := diffScreenshots(encodePNG(t, page), encodePNG(t, edited))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(diff.DiffPNG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := func(x, y int) bool {
+		r, g, b, _ := img.At(x/diffScale, y/diffScale).RGBA()
+		return r == 0xffff && g == 0 && b == 0
+	}
+	if !red(115, 315) {
+		t.Error("edited block not highlighted")
+	}
+	if red(20, 315) || red(115, 100) || red(115, 500) {
+		t.Error("unchanged blocks highlighted")
+	}
+}
+
+func lighten(img *image.Gray, by uint8) *image.Gray {
+	out := stack(img)
+	for i, v := range out.Pix {
+		out.Pix[i] = uint8(min(255, int(v)+int(by)))
+	}
+	return out
+}
+
+func crop(img *image.Gray, from, to int) *image.Gray {
+	return img.SubImage(image.Rect(0, from, img.Rect.Dx(), to)).(*image.Gray)
+}
@@ -17,14 +17,21 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
-	"github.com/rivalprice/scraper-go/models"
+	"github.com/rivalprice/domain/models"
+	"github.com/rivalprice/domain/queue"
+	"github.com/rivalprice/migrations"
+	"github.com/rivalprice/scraper-go/normalize"
 )
 
 var (
-	db           *gorm.DB
-	redisClient  *redis.Client
-	httpClient   *http.Client
-	
+	db          *gorm.DB
+	redisClient *redis.Client
+	httpClient  *http.Client
+	renderer    Renderer // nil when RENDERER_URL is not set
+	blobStore   BlobStore
+
+	visualChangeThreshold float64
+
 	// Pre-compiled regex patterns for price extraction
 	pricePatterns = []*regexp.Regexp{
 		regexp.MustCompile(`\$[\d,]+\.?\d*`),
@@ -37,7 +44,7 @@ var (
 		regexp.MustCompile(`class="[^"]*price[^"]*"[^>]*>[\s]*([^<]+)`),
 		regexp.MustCompile(`"price"\s*:\s*"([^"]+)"`),
 	}
-	
+
 	// Pre-compiled regex for title extraction
 	titleRegex = regexp.MustCompile(`<title>([^<]+)</title>`)
 )
@@ -48,6 +55,10 @@ type Config struct {
 	RedisAddr   string
 	RedisPass   string
 	RedisDB     int
+
+	RendererURL           string
+	BlobDir               string
+	VisualChangeThreshold float64
 }
 
 // LoadConfig loads configuration from environment variables
@@ -57,6 +68,10 @@ func LoadConfig() *Config {
 		RedisAddr:   getEnv("REDIS_ADDR", "localhost:6379"),
 		RedisPass:   getEnv("REDIS_PASSWORD", ""),
 		RedisDB:     getEnvAsInt("REDIS_DB", 0),
+
+		RendererURL:           getEnv("RENDERER_URL", ""),
+		BlobDir:               getEnv("BLOB_DIR", "/data/blobs"),
+		VisualChangeThreshold: getEnvAsFloat("VISUAL_CHANGE_THRESHOLD", 0.02),
 	}
 }
 
@@ -79,6 +94,18 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return value
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 func initDB(cfg *Config) {
 	var err error
 	db, err = gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{})
@@ -87,11 +114,36 @@ func initDB(cfg *Config) {
 	}
 	log.Println("✅ PostgreSQL connected")
 
-	err = db.AutoMigrate(&models.MonitoredPage{}, &models.Snapshot{})
+	// The schema is owned by the shared migrations (`scraper-go migrate up`)
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	migrator, err := migrations.New(sqlDB)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	log.Println("✅ Database schema up to date")
+}
+
+// runMigrate runs the migrate subcommand
+func runMigrate(cfg *Config, args []string) {
+	gormDB, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	sqlDB, err := gormDB.DB()
 	if err != nil {
-		log.Fatalf("Failed to migrate: %v", err)
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer sqlDB.Close()
+
+	if err := migrations.Run(context.Background(), sqlDB, args, os.Stdout); err != nil {
+		log.Fatalf("❌ migrate: %v", err)
 	}
-	log.Println("✅ Database migrated")
 }
 
 func initRedis(cfg *Config) {
@@ -100,13 +152,13 @@ func initRedis(cfg *Config) {
 		Password: cfg.RedisPass,
 		DB:       cfg.RedisDB,
 	})
-	
+
 	// Test connection
 	_, err := redisClient.Ping(context.Background()).Result()
 	if err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
-	
+
 	log.Println("✅ Redis connected")
 }
 
@@ -117,10 +169,15 @@ func initHTTP() {
 	log.Println("✅ HTTP client ready")
 }
 
-type ScrapeJob struct {
-	PageID uint   `json:"page_id"`
-	URL    string `json:"url"`
-	Type   string `json:"type"`
+func initVisual(cfg *Config) {
+	blobStore = &fsBlobStore{root: cfg.BlobDir}
+	visualChangeThreshold = cfg.VisualChangeThreshold
+	if cfg.RendererURL == "" {
+		log.Println("ℹ️  RENDERER_URL not set, screenshots disabled")
+		return
+	}
+	renderer = newHTTPRenderer(cfg.RendererURL)
+	log.Printf("✅ Renderer ready (%s), blobs in %s", cfg.RendererURL, cfg.BlobDir)
 }
 
 func extractPrice(html string) string {
@@ -138,7 +195,7 @@ func extractPrice(html string) string {
 
 func extractAvailability(html string) string {
 	htmlLower := strings.ToLower(html)
-	
+
 	if strings.Contains(htmlLower, "out of stock") || strings.Contains(htmlLower, "outofstock") {
 		return "out_of_stock"
 	}
@@ -148,7 +205,7 @@ func extractAvailability(html string) string {
 	if strings.Contains(htmlLower, "pre-order") || strings.Contains(htmlLower, "preorder") {
 		return "pre_order"
 	}
-	
+
 	return "available"
 }
 
@@ -162,9 +219,15 @@ func mustJson(v interface{}) []byte {
 	return data
 }
 
-func processScrapeJob(ctx context.Context, job ScrapeJob) error {
+func processScrapeJob(ctx context.Context, job queue.ScrapeJob) error {
 	log.Printf("🔄 Processing scrape job for page %d: %s", job.PageID, job.URL)
 
+	// CSSSelector is not part of the job payload, read it from the page itself
+	var page models.MonitoredPage
+	if err := db.First(&page, job.PageID).Error; err != nil {
+		return fmt.Errorf("failed to load page %d: %w", job.PageID, err)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", job.URL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
@@ -174,12 +237,24 @@ func processScrapeJob(ctx context.Context, job ScrapeJob) error {
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
+		recordFetchFailure(&page, 0, err.Error())
+		publishScrapeFailed(&page, 0)
 		return fmt.Errorf("failed to fetch page: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// Error pages are not stored as snapshots: their (missing) price would
+	// be picked up as a competitor change by the detector.
+	if resp.StatusCode >= 400 {
+		recordFetchFailure(&page, resp.StatusCode, resp.Status)
+		publishScrapeFailed(&page, resp.StatusCode)
+		return fmt.Errorf("page returned HTTP %d", resp.StatusCode)
+	}
+
 	htmlBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
+		recordFetchFailure(&page, resp.StatusCode, err.Error())
+		publishScrapeFailed(&page, resp.StatusCode)
 		return fmt.Errorf("failed to read response: %w", err)
 	}
 
@@ -203,34 +278,61 @@ func processScrapeJob(ctx context.Context, job ScrapeJob) error {
 		"availability": availability,
 		"status_code":  resp.StatusCode,
 	}
+	if page.CSSSelector != "" {
+		rawData["selector_matched"] = normalize.SelectorMatches(html, page.CSSSelector)
+	}
+
+	// Canonical text: what the detector hashes and the API diffs
+	rules, err := normalize.ParseRules(page.IgnoreSelectors, page.IgnorePatterns)
+	if err != nil {
+		log.Printf("⚠️  Page %d: %v", page.ID, err)
+	}
+	canonical := normalize.Normalize(html, rules)
+	rawData["text_content"] = canonical.Text
+	rawData["content_hash"] = canonical.Hash
 
 	snapshot := models.Snapshot{
 		MonitoredPageID: job.PageID,
-		Price:          price,
-		Availability:   availability,
-		RawData:        mustJson(rawData),
-		ScrapedAt:      time.Now(),
+		Price:           price,
+		Availability:    availability,
+		RawData:         mustJson(rawData),
+		ScrapedAt:       time.Now(),
 	}
 
 	if err := db.Create(&snapshot).Error; err != nil {
 		return fmt.Errorf("failed to store snapshot: %w", err)
 	}
 
+	recordFetchSuccess(&page, resp.StatusCode)
+
+	if page.CaptureScreenshot && renderer != nil {
+		captureVisual(ctx, &page, &snapshot)
+	}
+
+	publishEvent(queue.SnapshotCreated(page.ID, snapshot.ID, canonical.Hash))
+
 	log.Printf("✅ Snapshot stored: Page %d, Price: %s, Availability: %s", job.PageID, price, availability)
 	return nil
 }
 
 func main() {
 	cfg := LoadConfig()
-	
+
+	// `scraper-go migrate <command>` manages the database schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
+
 	initDB(cfg)
 	initRedis(cfg)
 	initHTTP()
+	initVisual(cfg)
 
 	log.Println("🚀 Worker started, waiting for jobs...")
 
 	for {
-		result, err := redisClient.BRPop(context.Background(), 5*time.Second, "scrape_job").Result()
+		result, err := redisClient.BRPop(context.Background(), 5*time.Second, queue.ScrapeJobQueue).Result()
 		if err != nil {
 			continue
 		}
@@ -239,9 +341,9 @@ func main() {
 			continue
 		}
 
-		var job ScrapeJob
-		if err := json.Unmarshal([]byte(result[1]), &job); err != nil {
-			log.Printf("❌ Failed to parse job: %v", err)
+		job, err := queue.DecodeScrapeJob([]byte(result[1]))
+		if err != nil {
+			log.Printf("❌ Dropping scrape job: %v", err)
 			continue
 		}
 
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rivalprice/domain/models"
+	"github.com/rivalprice/domain/queue"
+)
+
+// Renderer captures a full-page PNG screenshot of a URL
+type Renderer interface {
+	Screenshot(ctx context.Context, url string) ([]byte, error)
+}
+
+// BlobStore persists binary objects (screenshots, diff images) by key
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+}
+
+// httpRenderer talks to a headless browser service exposing a
+// browserless-compatible POST /screenshot endpoint.
+type httpRenderer struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newHTTPRenderer(baseURL string) *httpRenderer {
+	return &httpRenderer{
+		endpoint: baseURL + "/screenshot",
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (r *httpRenderer) Screenshot(ctx context.Context, url string) ([]byte, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"url": url,
+		"options": map[string]interface{}{
+			"fullPage": true,
+			"type":     "png",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("renderer returned HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// fsBlobStore stores blobs as files under a root directory (a mounted
+// volume in docker-compose)
+type fsBlobStore struct {
+	root string
+}
+
+func (s *fsBlobStore) Put(key string, data []byte) error {
+	path := filepath.Join(s.root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *fsBlobStore) Get(key string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.root, filepath.FromSlash(key)))
+}
+
+// captureVisual screenshots the page, diffs it against the previous
+// screenshot and records a visual_change when enough pixels moved.
+// Failures are logged only: a missing screenshot must not fail the scrape.
+func captureVisual(ctx context.Context, page *models.MonitoredPage, snapshot *models.Snapshot) {
+	shot, err := renderer.Screenshot(ctx, page.URL)
+	if err != nil {
+		log.Printf("⚠️  Screenshot failed for page %d: %v", page.ID, err)
+		return
+	}
+
+	key := fmt.Sprintf("screenshots/page-%d/%d.png", page.ID, snapshot.ID)
+	if err := blobStore.Put(key, shot); err != nil {
+		log.Printf("⚠️  Failed to store screenshot for page %d: %v", page.ID, err)
+		return
+	}
+	updates := map[string]interface{}{"screenshot_key": key}
+
+	var previous models.Snapshot
+	err = db.Where("monitored_page_id = ? AND id <> ? AND screenshot_key <> ''", page.ID, snapshot.ID).
+		Order("scraped_at DESC").First(&previous).Error
+	if err != nil {
+		// First screenshot for this page, nothing to compare against
+		db.Model(snapshot).Updates(updates)
+		return
+	}
+
+	previousShot, err := blobStore.Get(previous.ScreenshotKey)
+	if err != nil {
+		log.Printf("⚠️  Previous screenshot %s unavailable: %v", previous.ScreenshotKey, err)
+		db.Model(snapshot).Updates(updates)
+		return
+	}
+
+	diff, err := diffScreenshots(previousShot, shot)
+	if err != nil {
+		log.Printf("⚠️  Visual diff failed for page %d: %v", page.ID, err)
+		db.Model(snapshot).Updates(updates)
+		return
+	}
+
+	diffKey := fmt.Sprintf("diffs/page-%d/%d-%d.png", page.ID, previous.ID, snapshot.ID)
+	if err := blobStore.Put(diffKey, diff.DiffPNG); err != nil {
+		log.Printf("⚠️  Failed to store diff image for page %d: %v", page.ID, err)
+		diffKey = ""
+	}
+	updates["visual_diff_key"] = diffKey
+	updates["visual_change_ratio"] = diff.ChangedRatio
+
+	if err := db.Model(snapshot).Updates(updates).Error; err != nil {
+		log.Printf("⚠️  Failed to update snapshot %d: %v", snapshot.ID, err)
+	}
+
+	if diff.ChangedRatio < visualChangeThreshold {
+		return
+	}
+
+	rawData := map[string]interface{}{
+		"previous_snapshot_id": previous.ID,
+		"latest_snapshot_id":   snapshot.ID,
+		"screenshot_key":       key,
+		"previous_key":         previous.ScreenshotKey,
+		"diff_key":             diffKey,
+		"changed_ratio":        diff.ChangedRatio,
+	}
+	change := models.DetectedChange{
+		PageID:        int(page.ID),
+		PageType:      page.PageType,
+		ChangeType:    models.ChangeTypeVisual,
+		ChangePercent: diff.ChangedRatio * 100,
+		DetectedAt:    time.Now(),
+		RawData:       string(mustJson(rawData)),
+	}
+	// Only the visual columns: the price and text columns stay NULL
+	if err := db.Select("page_id", "page_type", "change_type", "change_percent", "detected_at", "raw_data").Create(&change).Error; err != nil {
+		log.Printf("❌ Failed to record visual change for page %d: %v", page.ID, err)
+		return
+	}
+
+	publishEvent(queue.ChangeDetected(change.ID, page.ID))
+
+	log.Printf("🖼️  Visual change on page %d: %.2f%% of pixels changed", page.ID, diff.ChangedRatio*100)
+}
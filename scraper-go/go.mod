@@ -6,6 +6,8 @@ toolchain go1.22.2
 
 require (
 	github.com/go-redis/redis/v8 v8.11.5
+	github.com/rivalprice/domain v0.0.0
+	github.com/rivalprice/migrations v0.0.0
 	gorm.io/driver/postgres v1.5.4
 	gorm.io/gorm v1.25.5
 )
@@ -22,3 +24,7 @@ require (
 	golang.org/x/crypto v0.19.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 )
+
+replace github.com/rivalprice/migrations => ../migrations
+
+replace github.com/rivalprice/domain => ../domain
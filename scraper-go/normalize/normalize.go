@@ -0,0 +1,153 @@
+// Package normalize turns a scraped HTML page into the canonical text that
+// content hashes and diffs are computed on. It removes boilerplate and noise
+// (scripts, navigation, cookie banners, rotating testimonials, timestamps,
+// CSRF tokens) so that only real competitor changes alter the hash.
+package normalize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// boilerplateTags are removed with their content on every page
+var boilerplateTags = []string{
+	"script", "style", "noscript", "svg", "template", "iframe", "canvas",
+	"nav", "footer",
+}
+
+// noiseKeywords remove any element whose id or class has one of them as a
+// word (words are split on spaces, dashes and underscores, plurals count):
+// cookie banners, consent popups and rotating testimonials. Generic widget
+// names such as "carousel" or "slider" are left out, pricing tables use them
+// too.
+var noiseKeywords = map[string]bool{"cookie": true, "consent": true, "gdpr": true, "testimonial": true}
+
+var idClassWords = regexp.MustCompile(`[\s_-]+`)
+
+// structuralTags are never removed by keyword, whatever their class says
+var structuralTags = map[string]bool{"html": true, "head": true, "body": true, "main": true}
+
+// noisePatterns are removed from the extracted text of every page
+var noisePatterns = []*regexp.Regexp{
+	// ISO-8601 timestamps and dates
+	regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2})?(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?)?\b`),
+	// Clock times
+	regexp.MustCompile(`(?i)\b\d{1,2}:\d{2}(?::\d{2})?\s?(?:am|pm)?\b`),
+	// Relative times ("updated 5 minutes ago")
+	regexp.MustCompile(`(?i)\b\d+\s+(?:seconds?|minutes?|mins?|hours?|days?)\s+ago\b`),
+	// UUIDs, hex digests and long opaque tokens (CSRF, nonces, session IDs)
+	regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`),
+	regexp.MustCompile(`\b[A-Za-z0-9_\-]{32,}\b`),
+	// Copyright years
+	regexp.MustCompile(`(?i)(?:©|\(c\)|copyright)\s*\d{4}(?:\s*[-–]\s*\d{4})?`),
+}
+
+var (
+	commentRegex    = regexp.MustCompile(`(?s)<!--.*?-->`)
+	blockBoundaries = regexp.MustCompile(`(?i)<(br|/?p|/?div|/?li|/?ul|/?ol|/?tr|/?table|/?section|/?article|/?h[1-6])\b[^>]*>`)
+	tagRegex        = regexp.MustCompile(`<[^>]+>`)
+	spaceRuns       = regexp.MustCompile(`[ \t\f\r\v\x{00a0}]+`)
+)
+
+// Rules are per-page ignore rules on top of the built-in ones
+type Rules struct {
+	Selectors []string         // elements to drop before text extraction
+	Patterns  []*regexp.Regexp // text to drop from every line
+}
+
+// ParseRules builds Rules from the newline-separated columns stored on
+// monitored_pages. Invalid regexes are reported, the others still apply.
+func ParseRules(selectors, patterns string) (Rules, error) {
+	var rules Rules
+	for _, line := range splitLines(selectors) {
+		rules.Selectors = append(rules.Selectors, line)
+	}
+
+	var invalid []string
+	for _, line := range splitLines(patterns) {
+		re, err := regexp.Compile(line)
+		if err != nil {
+			invalid = append(invalid, line)
+			continue
+		}
+		rules.Patterns = append(rules.Patterns, re)
+	}
+	if len(invalid) > 0 {
+		return rules, fmt.Errorf("invalid ignore patterns: %s", strings.Join(invalid, ", "))
+	}
+	return rules, nil
+}
+
+// Result is the canonical form of a page
+type Result struct {
+	Text string // one content line per line, no empty lines
+	Hash string // sha256 of Text, hex encoded
+}
+
+// Normalize runs the full pipeline on a page
+func Normalize(page string, rules Rules) Result {
+	page = commentRegex.ReplaceAllString(page, "")
+
+	for _, tag := range boilerplateTags {
+		page = removeMatching(page, tagNamed(tag))
+	}
+	page = removeMatching(page, mentionsNoise)
+	for _, selector := range rules.Selectors {
+		page = RemoveElements(page, selector)
+	}
+
+	page = blockBoundaries.ReplaceAllString(page, "\n")
+	page = tagRegex.ReplaceAllString(page, " ")
+	page = html.UnescapeString(page)
+
+	var lines []string
+	for _, line := range strings.Split(page, "\n") {
+		for _, re := range noisePatterns {
+			line = re.ReplaceAllString(line, "")
+		}
+		for _, re := range rules.Patterns {
+			line = re.ReplaceAllString(line, "")
+		}
+		line = strings.TrimSpace(spaceRuns.ReplaceAllString(line, " "))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	text := strings.Join(lines, "\n")
+	sum := sha256.Sum256([]byte(text))
+	return Result{Text: text, Hash: hex.EncodeToString(sum[:])}
+}
+
+func tagNamed(tag string) func(name, attrs string) bool {
+	return func(name, _ string) bool {
+		return strings.EqualFold(name, tag)
+	}
+}
+
+func mentionsNoise(name, attrs string) bool {
+	if structuralTags[strings.ToLower(name)] {
+		return false
+	}
+	idClass := strings.ToLower(attrValue(attrs, idAttrRegex) + " " + attrValue(attrs, classAttrRegex))
+	for _, word := range idClassWords.Split(idClass, -1) {
+		if noiseKeywords[word] || noiseKeywords[strings.TrimSuffix(word, "s")] {
+			return true
+		}
+	}
+	return false
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
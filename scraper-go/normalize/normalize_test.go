@@ -0,0 +1,212 @@
+package normalize
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  string
+		rules Rules
+		want  string
+	}{
+		{
+			name: "collapses whitespace and drops empty lines",
+			page: "<p>  Pro \t plan&nbsp;&nbsp;$29 </p>\n\n<p>\r\n</p><div>Billed   yearly</div>",
+			want: "Pro plan $29\nBilled yearly",
+		},
+		{
+			name: "block tags start new lines, inline tags do not",
+			page: "<h1>Pricing</h1><ul><li>Starter <b>$9</b></li><li>Pro<br>$29</li></ul>",
+			want: "Pricing\nStarter $9\nPro\n$29",
+		},
+		{
+			name: "strips scripts, styles and comments with their content",
+			page: `<head><style>.price { color: red }</style><script type="text/javascript">var t = "<p>x</p>";</script></head>` +
+				`<body><!-- build 42 --><p>Pro $29</p><noscript>Enable JS</noscript></body>`,
+			want: "Pro $29",
+		},
+		{
+			name: "strips navigation and footer",
+			page: `<nav><a href="/">Home</a><nav><a>Nested</a></nav></nav><main><p>Pro $29</p></main><footer>About us</footer>`,
+			want: "Pro $29",
+		},
+		{
+			name: "strips cookie banners and testimonials by id or class",
+			page: `<div id="cookie-banner"><p>We use cookies</p></div>` +
+				`<section class="reviews testimonial-slider"><p>"Great tool" - Ann</p></section>` +
+				`<body class="consent-given"><p>Pro $29</p></body>`,
+			want: "Pro $29",
+		},
+		{
+			name: "keeps pricing carousels and words that only contain a keyword",
+			page: `<div class="pricing-carousel"><p>Pro $29</p></div>` +
+				`<div id="cookiecutter-plans"><p>Team $99</p></div>` +
+				`<div class="cookies_notice">We use cookies</div>`,
+			want: "Pro $29\nTeam $99",
+		},
+		{
+			name: "an unclosed cookie note does not swallow the page",
+			page: `<body><div><p class="cookie-note">We use cookies<p>Pro $29</div><ul>` +
+				`<li class="gdpr">Your data<li>Team $99</ul><p>Enterprise</body>`,
+			want: "Pro $29\nTeam $99\nEnterprise",
+		},
+		{
+			name: "drops timestamps and opaque tokens",
+			page: `<p>Updated 2024-05-01T10:22:31Z, 5 minutes ago</p><p>token a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8</p><p>© 2019-2024 Acme</p>`,
+			want: "Updated ,\ntoken\nAcme",
+		},
+		{
+			name:  "applies per-page selectors and patterns",
+			page:  `<div class="promo banner">Spring sale!</div><p>Pro $29 (visitor #1234)</p>`,
+			rules: Rules{Selectors: []string{".promo"}, Patterns: []*regexp.Regexp{regexp.MustCompile(`\(visitor #\d+\)`)}},
+			want:  "Pro $29",
+		},
+		{
+			name: "unescapes entities",
+			page: `<p>Team &amp; Enterprise &lt;3</p>`,
+			want: "Team & Enterprise <3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Normalize(tt.page, tt.rules)
+			if got.Text != tt.want {
+				t.Errorf("Normalize().Text = %q, want %q", got.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeHashIgnoresNoise(t *testing.T) {
+	a := Normalize(`<p>Pro $29</p><input type="hidden" value="x"><p>csrf Zx9Qw8Er7Ty6Ui5Op4As3Df2Gh1Jk0LzXcVb</p><script>track(1)</script>`, Rules{})
+	b := Normalize(`<p>Pro   $29</p><p>csrf Mn1Bv2Cx3Zl4Kj5Hg6Fd7Sa8Qw9Er0TyUiOp</p><script>track(2)</script>`, Rules{})
+	if a.Hash != b.Hash {
+		t.Errorf("hashes differ for pages that only differ by noise: %q vs %q", a.Text, b.Text)
+	}
+	if c := Normalize(`<p>Pro $35</p>`, Rules{}); c.Hash == a.Hash {
+		t.Error("hash did not change with the price")
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(" .promo \n\n#chat-widget\n", "visitor #\\d+\n([unclosed\n")
+	if err == nil {
+		t.Error("ParseRules() accepted an invalid pattern")
+	}
+	if want := []string{".promo", "#chat-widget"}; !reflect.DeepEqual(rules.Selectors, want) {
+		t.Errorf("Selectors = %q, want %q", rules.Selectors, want)
+	}
+	if len(rules.Patterns) != 1 || rules.Patterns[0].String() != `visitor #\d+` {
+		t.Errorf("Patterns = %v, want the valid pattern only", rules.Patterns)
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     Selector
+	}{
+		{"", Selector{}},
+		{"DIV", Selector{Tag: "div"}},
+		{"#main", Selector{ID: "main"}},
+		{"div#main.price.large", Selector{Tag: "div", ID: "main", Classes: []string{"price", "large"}}},
+		{"nav .price", Selector{Classes: []string{"price"}}},
+	}
+	for _, tt := range tests {
+		if got := ParseSelector(tt.selector); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSelector(%q) = %+v, want %+v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	page := `<div id="plans" class="grid pricing"><span class='price big'>$29</span><span data-id="plans">x</span></div>`
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"span", true},
+		{"p", false},
+		{"#plans", true},
+		{"div#plans.pricing", true},
+		{"span#plans", false}, // data-id is not id
+		{".price.big", true},
+		{".price.small", false},
+		{"section .pricing", true},
+	}
+	for _, tt := range tests {
+		if got := SelectorMatches(page, tt.selector); got != tt.want {
+			t.Errorf("SelectorMatches(%q) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestRemoveElements(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		selector string
+		want     string
+	}{
+		{
+			name:     "removes the element and its content",
+			html:     `<p>a</p><div class="ad">b<span>c</span></div><p>d</p>`,
+			selector: ".ad",
+			want:     `<p>a</p><p>d</p>`,
+		},
+		{
+			name:     "counts nested elements of the same name",
+			html:     `<div class="ad"><div>x</div><div>y</div></div><div>keep</div>`,
+			selector: "div.ad",
+			want:     `<div>keep</div>`,
+		},
+		{
+			name:     "removes void and self-closing elements alone",
+			html:     `<img class="ad" src="a.png"><p>keep</p><widget class="ad"/><p>too</p>`,
+			selector: ".ad",
+			want:     `<p>keep</p><p>too</p>`,
+		},
+		{
+			name:     "drops an unclosed element to the end when nothing encloses it",
+			html:     `<p>keep</p><div class="ad">never closed`,
+			selector: ".ad",
+			want:     `<p>keep</p>`,
+		},
+		{
+			name:     "stops an unclosed element at its parent's closing tag",
+			html:     `<section><div class="ad">never closed<span>x</span></section><p>keep</p>`,
+			selector: ".ad",
+			want:     `<section></section><p>keep</p>`,
+		},
+		{
+			name:     "stops an unclosed element at the next sibling it implies closed",
+			html:     `<ul><li class="ad">a<li>keep<li class="ad">b<ul><li>c</ul></ul><p class="ad">d<div>keep too</div>`,
+			selector: ".ad",
+			want:     `<ul><li>keep</ul><div>keep too</div>`,
+		},
+		{
+			name:     "unclosed elements inside end with the removed one",
+			html:     `<div class="ad"><p>a<div><p>b</div></div><p>keep</p>`,
+			selector: ".ad",
+			want:     `<p>keep</p>`,
+		},
+		{
+			name:     "empty selector keeps everything",
+			html:     `<p>keep</p>`,
+			selector: " ",
+			want:     `<p>keep</p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RemoveElements(tt.html, tt.selector); got != tt.want {
+				t.Errorf("RemoveElements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
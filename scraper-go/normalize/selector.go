@@ -0,0 +1,243 @@
+package normalize
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	openTagRegex = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9-]*)([^>]*)>`)
+	anyTagRegex  = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9-]*)[^>]*?(/?)>`)
+
+	idAttrRegex    = regexp.MustCompile(`(?i)(?:^|\s)id\s*=\s*["']([^"']*)["']`)
+	classAttrRegex = regexp.MustCompile(`(?i)(?:^|\s)class\s*=\s*["']([^"']*)["']`)
+)
+
+// voidElements never have a closing tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// Selector is a simple CSS compound selector: tag, #id and .class parts.
+// Combinators and attribute selectors are not supported; for descendant
+// selectors only the right-most compound is kept.
+type Selector struct {
+	Tag     string
+	ID      string
+	Classes []string
+}
+
+// ParseSelector parses "div#main.price.large" (or "nav .price", keeping ".price")
+func ParseSelector(selector string) Selector {
+	parts := strings.Fields(strings.TrimSpace(selector))
+	if len(parts) == 0 {
+		return Selector{}
+	}
+	compound := parts[len(parts)-1]
+
+	var sel Selector
+	token := ""
+	kind := byte(0)
+	flush := func() {
+		switch kind {
+		case 0:
+			sel.Tag = strings.ToLower(token)
+		case '#':
+			sel.ID = token
+		case '.':
+			if token != "" {
+				sel.Classes = append(sel.Classes, token)
+			}
+		}
+		token = ""
+	}
+
+	for i := 0; i < len(compound); i++ {
+		c := compound[i]
+		if c == '#' || c == '.' {
+			flush()
+			kind = c
+			continue
+		}
+		token += string(c)
+	}
+	flush()
+	return sel
+}
+
+// IsEmpty reports whether the selector matches nothing specific
+func (s Selector) IsEmpty() bool {
+	return s.Tag == "" && s.ID == "" && len(s.Classes) == 0
+}
+
+// matchesTag reports whether an opening tag (name + raw attributes) matches
+func (s Selector) matchesTag(name, attrs string) bool {
+	if s.Tag != "" && !strings.EqualFold(name, s.Tag) {
+		return false
+	}
+	if s.ID != "" && attrValue(attrs, idAttrRegex) != s.ID {
+		return false
+	}
+	if len(s.Classes) > 0 && !hasClasses(attrValue(attrs, classAttrRegex), s.Classes) {
+		return false
+	}
+	return true
+}
+
+// SelectorMatches reports whether the HTML contains an element matching selector
+func SelectorMatches(html, selector string) bool {
+	sel := ParseSelector(selector)
+	if sel.IsEmpty() {
+		return true
+	}
+	for _, m := range openTagRegex.FindAllStringSubmatch(html, -1) {
+		if sel.matchesTag(m[1], m[2]) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveElements drops every element matching selector, including its content
+func RemoveElements(html, selector string) string {
+	sel := ParseSelector(selector)
+	if sel.IsEmpty() {
+		return html
+	}
+
+	return removeMatching(html, sel.matchesTag)
+}
+
+// removeMatching drops every element whose opening tag satisfies match
+func removeMatching(html string, match func(name, attrs string) bool) string {
+	var out strings.Builder
+	pos := 0
+	for {
+		loc := findMatchingOpenTag(html, pos, match)
+		if loc == nil {
+			break
+		}
+		out.WriteString(html[pos:loc[0]])
+		pos = elementEnd(html, loc)
+	}
+	out.WriteString(html[pos:])
+	return out.String()
+}
+
+// findMatchingOpenTag returns the submatch indexes of the next opening tag at
+// or after pos that satisfies match
+func findMatchingOpenTag(html string, pos int, match func(name, attrs string) bool) []int {
+	for pos < len(html) {
+		loc := openTagRegex.FindStringSubmatchIndex(html[pos:])
+		if loc == nil {
+			return nil
+		}
+		for i := range loc {
+			loc[i] += pos
+		}
+		if match(html[loc[2]:loc[3]], html[loc[4]:loc[5]]) {
+			return loc
+		}
+		pos = loc[1]
+	}
+	return nil
+}
+
+// implicitlyClosed lists, for elements whose closing tag HTML lets authors
+// omit, the opening tags that end them as a next sibling
+var implicitlyClosed = map[string]map[string]bool{
+	"p":      setOf("p", "div", "ul", "ol", "dl", "table", "section", "article", "aside", "header", "footer", "nav", "form", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "pre", "hr"),
+	"li":     setOf("li"),
+	"dt":     setOf("dt", "dd"),
+	"dd":     setOf("dt", "dd"),
+	"option": setOf("option", "optgroup"),
+	"tr":     setOf("tr", "tbody", "tfoot"),
+	"td":     setOf("td", "th", "tr"),
+	"th":     setOf("td", "th", "tr"),
+}
+
+// elementEnd returns the offset just after the closing tag of the element
+// opened at loc, keeping track of the elements opened inside it. An unclosed
+// element ends before its parent's closing tag, or before the next sibling
+// that implicitly closes it (<li> after <li>, a block after <p>); only an
+// element without either runs to the end of the page.
+func elementEnd(html string, loc []int) int {
+	name := strings.ToLower(html[loc[2]:loc[3]])
+	if voidElements[name] || strings.HasSuffix(html[loc[4]:loc[5]], "/") {
+		return loc[1]
+	}
+
+	var open []string // elements opened inside, innermost last
+	pos := loc[1]
+	for {
+		m := anyTagRegex.FindStringSubmatchIndex(html[pos:])
+		if m == nil {
+			return len(html)
+		}
+		start := pos + m[0]
+		tagName := strings.ToLower(html[pos+m[4] : pos+m[5]])
+		closing := m[3] > m[2]
+		selfClosing := m[7] > m[6]
+		pos += m[1]
+
+		switch {
+		case selfClosing || voidElements[tagName]:
+		case !closing:
+			if len(open) == 0 && implicitlyClosed[name][tagName] {
+				return start
+			}
+			open = append(open, tagName)
+		default:
+			if i := lastIndex(open, tagName); i >= 0 {
+				// Closes an inner element, and the unclosed ones inside it
+				open = open[:i]
+			} else if tagName == name {
+				return pos
+			} else {
+				// The parent's closing tag
+				return start
+			}
+		}
+	}
+}
+
+func lastIndex(names []string, name string) int {
+	for i := len(names) - 1; i >= 0; i-- {
+		if names[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func setOf(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[n] = true
+	}
+	return set
+}
+
+// attrValue returns the value of the attribute re (idAttrRegex or
+// classAttrRegex) captures in the raw attributes of a tag
+func attrValue(attrs string, re *regexp.Regexp) string {
+	m := re.FindStringSubmatch(attrs)
+	if len(m) < 2 {
+		return ""
+	}
+	return m[1]
+}
+
+func hasClasses(classAttr string, wanted []string) bool {
+	present := make(map[string]bool)
+	for _, c := range strings.Fields(classAttr) {
+		present[c] = true
+	}
+	for _, c := range wanted {
+		if !present[c] {
+			return false
+		}
+	}
+	return true
+}